@@ -2,25 +2,40 @@ package main
 
 //go run main.go --mode=memory
 //go run main.go --mode=postgres
+//go run main.go migrate up|down [N]|status
+//go run main.go set-role <username> user|moderator|admin
 
 import (
 	"context"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
+	"time"
 
 	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/extension"
+	"github.com/99designs/gqlgen/graphql/handler/lru"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
 	"github.com/99designs/gqlgen/graphql/playground"
 	"github.com/go-chi/chi/v5"
+	"github.com/vektah/gqlparser/v2/ast"
 
 	"github.com/MAPiryazev/OzonTest/graph"
+	"github.com/MAPiryazev/OzonTest/internal/auth"
 	"github.com/MAPiryazev/OzonTest/internal/config"
 	hndl "github.com/MAPiryazev/OzonTest/internal/handler"
 	"github.com/MAPiryazev/OzonTest/internal/infra/db"
+	"github.com/MAPiryazev/OzonTest/internal/loaders"
+	"github.com/MAPiryazev/OzonTest/internal/models"
+	"github.com/MAPiryazev/OzonTest/internal/outbox"
+	"github.com/MAPiryazev/OzonTest/internal/policy"
 	"github.com/MAPiryazev/OzonTest/internal/service"
 	"github.com/MAPiryazev/OzonTest/internal/shutdown"
+	"github.com/MAPiryazev/OzonTest/internal/webhooks"
 )
 
 func main() {
@@ -31,6 +46,20 @@ func main() {
 		log.Fatalf("Ошибка при получении режима запуска: %v", err)
 	}
 
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(mode, os.Args[2:]); err != nil {
+			log.Fatalf("Ошибка миграции: %v", err)
+		}
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "set-role" {
+		if err := runSetRole(mode, os.Args[2:]); err != nil {
+			log.Fatalf("Ошибка при смене роли: %v", err)
+		}
+		return
+	}
+
 	// инициализация хранилища
 	strg, err := db.InitStorage(mode)
 	if err != nil {
@@ -42,17 +71,62 @@ func main() {
 		log.Println("ошибка при загрузке конфига API, значения параметров могут быть выставлены по умолчанию")
 	}
 
+	authConfig, err := config.LoadAuthConfig()
+	if err != nil {
+		log.Fatalf("Ошибка при загрузке конфига авторизации: %v", err)
+	}
+	tokens, err := auth.NewTokenManager(authConfig)
+	if err != nil {
+		log.Fatalf("Ошибка при инициализации токенов: %v", err)
+	}
+
+	webhookConfig, err := config.LoadWebhookConfig()
+	if err != nil {
+		log.Fatalf("Ошибка при загрузке конфига вебхуков: %v", err)
+	}
+	outboxConfig, err := config.LoadOutboxConfig()
+	if err != nil {
+		log.Fatalf("Ошибка при загрузке конфига outbox: %v", err)
+	}
+
 	// сервисный слой
-	svc := service.NewService(strg, apiConfig)
+	svc := service.NewService(strg, apiConfig, tokens)
+
+	// доставка вебхуков в фоне, останавливается до закрытия хранилища
+	webhookWorker := webhooks.NewWorker(strg, webhookConfig)
+	webhookWorker.Start()
+
+	// ретранслятор доменных событий из outbox: подписки, уведомления и вебхуки получают события как его потребители
+	relay := outbox.NewRelay(strg, outboxConfig)
+	relay.Register("subscriptions", svc.PublishCommentEvent)
+	relay.Register("notifications", svc.NotifyEvent)
+	relay.Register("webhooks", webhookWorker.Enqueue)
+	relay.Start()
 
 	// хендлер
 	myHandler := hndl.NewHandler(svc)
 
 	// graphql resolver
 	resolver := &graph.Resolver{Handler: myHandler}
-	srv := handler.NewDefaultServer(graph.NewExecutableSchema(graph.Config{Resolvers: resolver}))
+	srv := handler.New(graph.NewExecutableSchema(graph.Config{
+		Resolvers:  resolver,
+		Directives: graph.DirectiveRoot{HasRole: graph.HasRole},
+	}))
+
+	// websocket нужен для subscription
+	srv.AddTransport(transport.Websocket{
+		KeepAlivePingInterval: 10 * time.Second,
+		InitFunc:              auth.WebsocketInit(tokens),
+	})
+	srv.AddTransport(transport.Options{})
+	srv.AddTransport(transport.GET{})
+	srv.AddTransport(transport.POST{})
+	srv.SetQueryCache(lru.New[*ast.QueryDocument](1000))
+	srv.Use(extension.Introspection{})
+	srv.Use(loaders.NewExtension(myHandler))
 
 	r := chi.NewRouter()
+	r.Use(auth.Middleware(tokens))
 	r.Handle("/query", srv)
 	r.Handle("/", playground.Handler("GraphQL Playground", "/query"))
 
@@ -74,6 +148,81 @@ func main() {
 	}()
 
 	<-ctx.Done()
-	shutdown.Shutdown(httpServer, strg)
+	shutdown.Shutdown(httpServer, strg, svc, relay, webhookWorker)
+
+}
 
+// подкоманда migrate: up применяет все миграции, down [N] откатывает N последних (по умолчанию 1), status печатает состояние
+func runMigrate(mode string, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("укажите действие: up, down [N] или status")
+	}
+
+	migrator, conn, err := db.InitMigrator(mode)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	ctx := context.Background()
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		log.Printf("применено миграций: %d", applied)
+		return err
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil {
+				return fmt.Errorf("некорректное количество шагов: %s", args[1])
+			}
+		}
+		reverted, err := migrator.Down(ctx, steps)
+		log.Printf("откачено миграций: %d", reverted)
+		return err
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, st := range statuses {
+			state := "не применена"
+			if st.Applied {
+				state = "применена " + st.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%04d_%s\t%s\n", st.Version, st.Name, state)
+		}
+		return nil
+	default:
+		return fmt.Errorf("неизвестное действие migrate: %s", args[0])
+	}
+}
+
+// подкоманда set-role: назначает роль напрямую в хранилище, нужна чтобы завести первого администратора
+func runSetRole(mode string, args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("использование: set-role <username> user|moderator|admin")
+	}
+	role := models.Role(args[1])
+	if !policy.ValidRole(role) {
+		return fmt.Errorf("неизвестная роль %s", args[1])
+	}
+
+	strg, err := db.InitStorage(mode)
+	if err != nil {
+		return err
+	}
+	defer strg.Close()
+
+	ctx := context.Background()
+	user, err := strg.GetUserByUsername(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	if err := strg.SetUserRole(ctx, user.ID, role); err != nil {
+		return err
+	}
+	log.Printf("пользователю %s назначена роль %s, она вступит в силу со следующего входа", user.Username, role)
+	return nil
 }
@@ -0,0 +1,25 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/99designs/gqlgen/graphql"
+
+	"github.com/MAPiryazev/OzonTest/graph/model"
+	"github.com/MAPiryazev/OzonTest/internal/auth"
+	"github.com/MAPiryazev/OzonTest/internal/customerrors"
+	"github.com/MAPiryazev/OzonTest/internal/policy"
+)
+
+// директива @hasRole, сервисный слой все равно проверяет действие через policy
+func HasRole(ctx context.Context, obj any, next graphql.Resolver, role model.Role) (any, error) {
+	actor, err := auth.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !policy.HasRole(actor, parseRole(role)) {
+		return nil, fmt.Errorf("%w: нужна роль %s", customerrors.ErrForbidden, role)
+	}
+	return next(ctx)
+}
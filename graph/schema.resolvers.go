@@ -2,18 +2,41 @@ package graph
 
 import (
 	"context"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/MAPiryazev/OzonTest/graph/model"
+	"github.com/MAPiryazev/OzonTest/internal/customerrors"
+	"github.com/MAPiryazev/OzonTest/internal/loaders"
 	internal "github.com/MAPiryazev/OzonTest/internal/models"
 )
 
 // Следующие несколько функций - слой преобразования internal моделей в graphql модели
+func convertRole(role internal.Role) model.Role {
+	if role == "" {
+		return model.RoleUser
+	}
+	return model.Role(strings.ToUpper(string(role)))
+}
+
+func parseRole(role model.Role) internal.Role {
+	return internal.Role(strings.ToLower(string(role)))
+}
+
 func convertUser(user *internal.User) *model.User {
 	if user == nil {
 		return nil
 	}
-	return &model.User{ID: user.ID, Username: user.Username}
+	return &model.User{ID: user.ID, Username: user.Username, Role: convertRole(user.Role)}
+}
+
+func convertAuthPayload(payload *internal.AuthPayload) *model.AuthPayload {
+	return &model.AuthPayload{
+		Token:     payload.Token,
+		ExpiresAt: payload.ExpiresAt.Format(time.RFC3339),
+		User:      convertUser(payload.User),
+	}
 }
 
 func convertPost(post *internal.Post) *model.Post {
@@ -27,6 +50,7 @@ func convertPost(post *internal.Post) *model.Post {
 		AuthorID:        post.AuthorID,
 		CommentsEnabled: post.CommentsEnabled,
 		CreatedAt:       post.CreatedAt.Format(time.RFC3339),
+		EditedAt:        formatOptionalTime(post.EditedAt),
 	}
 }
 
@@ -40,7 +64,104 @@ func convertComment(comment *internal.Comment) *model.Comment {
 		ParentID:  comment.ParentID,
 		AuthorID:  comment.AuthorID,
 		Text:      comment.Text,
-		CreatedAt: comment.CreatedAt.Format(time.RFC3339)}
+		CreatedAt: comment.CreatedAt.Format(time.RFC3339),
+		EditedAt:  formatOptionalTime(comment.EditedAt),
+		DeletedAt: formatOptionalTime(comment.DeletedAt)}
+}
+
+func convertPostRevisions(revisions []*internal.PostRevision) []*model.PostRevision {
+	res := make([]*model.PostRevision, len(revisions))
+	for i, rev := range revisions {
+		res[i] = &model.PostRevision{Title: rev.Title, Content: rev.Content, EditorID: rev.EditorID, CreatedAt: rev.CreatedAt.Format(time.RFC3339)}
+	}
+	return res
+}
+
+func convertCommentRevisions(revisions []*internal.CommentRevision) []*model.CommentRevision {
+	res := make([]*model.CommentRevision, len(revisions))
+	for i, rev := range revisions {
+		res[i] = &model.CommentRevision{Text: rev.Text, EditorID: rev.EditorID, CreatedAt: rev.CreatedAt.Format(time.RFC3339)}
+	}
+	return res
+}
+
+func formatOptionalTime(t *time.Time) *string {
+	if t == nil {
+		return nil
+	}
+	s := t.Format(time.RFC3339)
+	return &s
+}
+
+func convertReactionSummary(summary *internal.ReactionSummary) *model.ReactionSummary {
+	res := &model.ReactionSummary{Score: int32(summary.Score), Counts: make([]*model.ReactionCount, 0, len(summary.Counts))}
+	for _, c := range summary.Counts {
+		res.Counts = append(res.Counts, &model.ReactionCount{Kind: model.ReactionKind(strings.ToUpper(string(c.Kind))), Count: int32(c.Count)})
+	}
+	return res
+}
+
+func convertReactionKind(kind internal.ReactionKind) *model.ReactionKind {
+	if kind == "" {
+		return nil
+	}
+	res := model.ReactionKind(strings.ToUpper(string(kind)))
+	return &res
+}
+
+func parseReactionKind(kind model.ReactionKind) internal.ReactionKind {
+	return internal.ReactionKind(strings.ToLower(string(kind)))
+}
+
+func parsePostFilter(filter *model.PostFilter) (internal.PostFilter, error) {
+	if filter == nil {
+		return internal.PostFilter{}, nil
+	}
+	createdAfter, err := parseOptionalTime("createdAfter", filter.CreatedAfter)
+	if err != nil {
+		return internal.PostFilter{}, err
+	}
+	createdBefore, err := parseOptionalTime("createdBefore", filter.CreatedBefore)
+	if err != nil {
+		return internal.PostFilter{}, err
+	}
+	return internal.PostFilter{
+		AuthorID:        filter.AuthorID,
+		CreatedAfter:    createdAfter,
+		CreatedBefore:   createdBefore,
+		CommentsEnabled: filter.CommentsEnabled,
+		HasComments:     filter.HasComments,
+	}, nil
+}
+
+func parseOptionalTime(field string, value *string) (*time.Time, error) {
+	if value == nil {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, *value)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s должно быть временем в формате RFC3339", customerrors.ErrValidation, field)
+	}
+	return &t, nil
+}
+
+// без аргумента sort комментарии идут по времени создания
+func parsePostSort(sort *model.PostSort) internal.PostSort {
+	if sort == nil {
+		return internal.PostSortNewest
+	}
+	return internal.PostSort(strings.ToLower(string(*sort)))
+}
+
+func parseCommentSort(sort *model.CommentSort) internal.CommentSort {
+	if sort == nil {
+		return internal.CommentSortOld
+	}
+	return internal.CommentSort(strings.ToLower(string(*sort)))
+}
+
+func parseReactionTarget(target model.ReactionTarget) internal.ReactionTarget {
+	return internal.ReactionTarget(strings.ToLower(string(target)))
 }
 
 func convertMultPosts(posts []*internal.Post) []*model.Post {
@@ -59,42 +180,279 @@ func convertMultComments(commentArr []*internal.Comment) []*model.Comment {
 	return res
 }
 
+func convertPageInfo(info internal.PageInfo) *model.PageInfo {
+	return &model.PageInfo{
+		HasNextPage:     info.HasNextPage,
+		HasPreviousPage: info.HasPreviousPage,
+		StartCursor:     info.StartCursor,
+		EndCursor:       info.EndCursor,
+	}
+}
+
+func convertPostConnection(conn *internal.PostConnection) *model.PostConnection {
+	edges := make([]*model.PostEdge, len(conn.Edges))
+	for i, edge := range conn.Edges {
+		edges[i] = &model.PostEdge{Cursor: edge.Cursor, Node: convertPost(edge.Node)}
+	}
+	return &model.PostConnection{Edges: edges, PageInfo: convertPageInfo(conn.PageInfo)}
+}
+
+func convertCommentConnection(conn *internal.CommentConnection) *model.CommentConnection {
+	edges := make([]*model.CommentEdge, len(conn.Edges))
+	for i, edge := range conn.Edges {
+		edges[i] = &model.CommentEdge{Cursor: edge.Cursor, Node: convertComment(edge.Node)}
+	}
+	return &model.CommentConnection{Edges: edges, PageInfo: convertPageInfo(conn.PageInfo)}
+}
+
+func convertCommentThread(thread *internal.CommentThread) *model.CommentThread {
+	items := make([]*model.ThreadComment, len(thread.Items))
+	for i, item := range thread.Items {
+		items[i] = &model.ThreadComment{Depth: int32(item.Depth), Comment: convertComment(item.Comment)}
+	}
+	return &model.CommentThread{Items: items, Truncated: thread.Truncated}
+}
+
+func convertSearchConnection(conn *internal.SearchConnection) *model.SearchConnection {
+	edges := make([]*model.SearchEdge, len(conn.Edges))
+	for i, edge := range conn.Edges {
+		hit := edge.Node
+		edges[i] = &model.SearchEdge{Cursor: edge.Cursor, Node: &model.SearchHit{
+			Type:    model.SearchType(strings.ToUpper(string(hit.Type))),
+			Post:    convertPost(hit.Post),
+			Comment: convertComment(hit.Comment),
+			Rank:    hit.Rank,
+			Snippet: hit.Snippet,
+		}}
+	}
+	return &model.SearchConnection{Edges: edges, PageInfo: convertPageInfo(conn.PageInfo)}
+}
+
+func convertNotification(n *internal.Notification) *model.Notification {
+	return &model.Notification{
+		ID:        n.ID,
+		Kind:      model.NotificationKind(strings.ToUpper(string(n.Kind))),
+		ActorID:   n.ActorID,
+		PostID:    n.PostID,
+		CommentID: n.CommentID,
+		CreatedAt: n.CreatedAt.Format(time.RFC3339),
+		ReadAt:    formatOptionalTime(n.ReadAt),
+	}
+}
+
+func convertNotificationConnection(conn *internal.NotificationConnection) *model.NotificationConnection {
+	edges := make([]*model.NotificationEdge, len(conn.Edges))
+	for i, edge := range conn.Edges {
+		edges[i] = &model.NotificationEdge{Cursor: edge.Cursor, Node: convertNotification(edge.Node)}
+	}
+	return &model.NotificationConnection{Edges: edges, PageInfo: convertPageInfo(conn.PageInfo), UnreadCount: int32(conn.UnreadCount)}
+}
+
+func convertWebhook(webhook *internal.Webhook) *model.Webhook {
+	events := make([]model.WebhookEvent, len(webhook.Events))
+	for i, event := range webhook.Events {
+		events[i] = model.WebhookEvent(strings.ToUpper(string(event)))
+	}
+	return &model.Webhook{
+		ID:        webhook.ID,
+		URL:       webhook.URL,
+		Events:    events,
+		Active:    webhook.Active,
+		CreatedAt: webhook.CreatedAt.Format(time.RFC3339),
+	}
+}
+
+func convertWebhookDelivery(d *internal.WebhookDelivery) *model.WebhookDelivery {
+	res := &model.WebhookDelivery{
+		ID:            d.ID,
+		WebhookID:     d.WebhookID,
+		Event:         model.WebhookEvent(strings.ToUpper(string(d.Event))),
+		Status:        model.WebhookDeliveryStatus(strings.ToUpper(string(d.Status))),
+		Attempts:      int32(d.Attempts),
+		NextAttemptAt: d.NextAttemptAt.Format(time.RFC3339),
+		CreatedAt:     d.CreatedAt.Format(time.RFC3339),
+		DeliveredAt:   formatOptionalTime(d.DeliveredAt),
+	}
+	if d.LastError != "" {
+		res.LastError = &d.LastError
+	}
+	return res
+}
+
+func parseWebhookEvents(events []model.WebhookEvent) []internal.WebhookEvent {
+	res := make([]internal.WebhookEvent, len(events))
+	for i, event := range events {
+		res[i] = internal.WebhookEvent(strings.ToLower(string(event)))
+	}
+	return res
+}
+
 // дальше идут резолверы (в данном случае обертки над хендлерами)
-func (r *mutationResolver) CreateUser(ctx context.Context, username string) (*model.User, error) {
-	user, err := r.Handler.CreateUser(ctx, username)
+func (r *mutationResolver) Register(ctx context.Context, username, password string) (*model.AuthPayload, error) {
+	payload, err := r.Handler.Register(ctx, username, password)
 	if err != nil {
 		return nil, err
 	}
+	return convertAuthPayload(payload), nil
+}
 
+func (r *mutationResolver) Login(ctx context.Context, username, password string) (*model.AuthPayload, error) {
+	payload, err := r.Handler.Login(ctx, username, password)
+	if err != nil {
+		return nil, err
+	}
+	return convertAuthPayload(payload), nil
+}
+
+func (r *mutationResolver) SetUserRole(ctx context.Context, userID string, role model.Role) (*model.User, error) {
+	user, err := r.Handler.SetUserRole(ctx, userID, parseRole(role))
+	if err != nil {
+		return nil, err
+	}
 	return convertUser(user), nil
 }
 
-func (r *mutationResolver) CreatePost(ctx context.Context, title, content, authorID string, commentsEnabled bool) (*model.Post, error) {
-	post, err := r.Handler.CreatePost(ctx, title, content, authorID, commentsEnabled)
+func (r *mutationResolver) CreatePost(ctx context.Context, title, content string, commentsEnabled bool) (*model.Post, error) {
+	post, err := r.Handler.CreatePost(ctx, title, content, commentsEnabled)
+	if err != nil {
+		return nil, err
+	}
+	return convertPost(post), nil
+}
+
+func (r *mutationResolver) UpdatePost(ctx context.Context, id string, input model.UpdatePostInput) (*model.Post, error) {
+	patch := internal.PostPatch{Title: input.Title, Content: input.Content, CommentsEnabled: input.CommentsEnabled}
+	post, err := r.Handler.UpdatePost(ctx, id, patch)
 	if err != nil {
 		return nil, err
 	}
 	return convertPost(post), nil
 }
 
-func (r *mutationResolver) UpdatePost(ctx context.Context, id, title, content, userID string) (*model.Post, error) {
-	post, err := r.Handler.UpdatePost(ctx, id, title, content, userID)
+func (r *mutationResolver) SetCommentsEnabled(ctx context.Context, postID string, enabled bool) (*model.Post, error) {
+	post, err := r.Handler.SetCommentsEnabled(ctx, postID, enabled)
 	if err != nil {
 		return nil, err
 	}
 	return convertPost(post), nil
 }
 
-func (r *mutationResolver) CreateComment(ctx context.Context, postID, text, authorID string, parentID *string) (*model.Comment, error) {
-	comment, err := r.Handler.CreateComment(ctx, postID, text, authorID, parentID)
+func (r *mutationResolver) CreateComment(ctx context.Context, postID, text string, parentID *string) (*model.Comment, error) {
+	comment, err := r.Handler.CreateComment(ctx, postID, text, parentID)
+	if err != nil {
+		return nil, err
+	}
+	return convertComment(comment), nil
+}
+
+func (r *mutationResolver) UpdateComment(ctx context.Context, id string, text string) (*model.Comment, error) {
+	comment, err := r.Handler.UpdateComment(ctx, id, text)
 	if err != nil {
 		return nil, err
 	}
 	return convertComment(comment), nil
 }
 
-func (r *queryResolver) ListPosts(ctx context.Context, offset, limit int32) ([]*model.Post, error) {
-	posts, err := r.Handler.ListPosts(ctx, int(offset), int(limit))
+// hard по умолчанию false, как в схеме
+func (r *mutationResolver) DeletePost(ctx context.Context, id string, hard *bool) (bool, error) {
+	return r.Handler.DeletePost(ctx, id, hard != nil && *hard)
+}
+
+func (r *mutationResolver) DeleteComment(ctx context.Context, id string, hard *bool) (bool, error) {
+	return r.Handler.DeleteComment(ctx, id, hard != nil && *hard)
+}
+
+func (r *mutationResolver) MarkNotificationsRead(ctx context.Context, ids []string) (int32, error) {
+	marked, err := r.Handler.MarkNotificationsRead(ctx, ids)
+	return int32(marked), err
+}
+
+func (r *mutationResolver) MarkAllNotificationsRead(ctx context.Context) (int32, error) {
+	marked, err := r.Handler.MarkAllNotificationsRead(ctx)
+	return int32(marked), err
+}
+
+func (r *mutationResolver) CreateWebhook(ctx context.Context, input model.NewWebhook) (*model.CreatedWebhook, error) {
+	secret := ""
+	if input.Secret != nil {
+		secret = *input.Secret
+	}
+	webhook, err := r.Handler.CreateWebhook(ctx, input.URL, parseWebhookEvents(input.Events), secret)
+	if err != nil {
+		return nil, err
+	}
+	return &model.CreatedWebhook{Webhook: convertWebhook(webhook), Secret: webhook.Secret}, nil
+}
+
+func (r *mutationResolver) SetWebhookActive(ctx context.Context, id string, active bool) (*model.Webhook, error) {
+	webhook, err := r.Handler.SetWebhookActive(ctx, id, active)
+	if err != nil {
+		return nil, err
+	}
+	return convertWebhook(webhook), nil
+}
+
+func (r *mutationResolver) DeleteWebhook(ctx context.Context, id string) (bool, error) {
+	if err := r.Handler.DeleteWebhook(ctx, id); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (r *mutationResolver) RetryWebhookDelivery(ctx context.Context, id string) (*model.WebhookDelivery, error) {
+	delivery, err := r.Handler.RetryWebhookDelivery(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return convertWebhookDelivery(delivery), nil
+}
+
+func (r *mutationResolver) SetReaction(ctx context.Context, target model.ReactionTarget, targetID string, kind model.ReactionKind) (*model.ReactionSummary, error) {
+	summary, err := r.Handler.SetReaction(ctx, parseReactionTarget(target), targetID, parseReactionKind(kind))
+	if err != nil {
+		return nil, err
+	}
+	return convertReactionSummary(summary), nil
+}
+
+func (r *mutationResolver) RemoveReaction(ctx context.Context, target model.ReactionTarget, targetID string) (*model.ReactionSummary, error) {
+	summary, err := r.Handler.RemoveReaction(ctx, parseReactionTarget(target), targetID)
+	if err != nil {
+		return nil, err
+	}
+	return convertReactionSummary(summary), nil
+}
+
+func (r *queryResolver) Me(ctx context.Context) (*model.User, error) {
+	user, err := r.Handler.Me(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return convertUser(user), nil
+}
+
+func (r *queryResolver) User(ctx context.Context, id string) (*model.User, error) {
+	user, err := r.Handler.GetUser(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return convertUser(user), nil
+}
+
+func (r *queryResolver) UserByUsername(ctx context.Context, name string) (*model.User, error) {
+	user, err := r.Handler.GetUserByUsername(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return convertUser(user), nil
+}
+
+func (r *queryResolver) ListPosts(ctx context.Context, offset, limit int32, filter *model.PostFilter, sort *model.PostSort) ([]*model.Post, error) {
+	postFilter, err := parsePostFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+	posts, err := r.Handler.ListPosts(ctx, postFilter, parsePostSort(sort), int(offset), int(limit))
 	if err != nil {
 		return nil, err
 	}
@@ -109,17 +467,345 @@ func (r *queryResolver) GetPost(ctx context.Context, id string) (*model.Post, er
 	return convertPost(post), nil
 }
 
-func (r *queryResolver) ListComments(ctx context.Context, postID string, parentID *string, offset, limit int32) ([]*model.Comment, error) {
-	comments, err := r.Handler.ListComments(ctx, postID, parentID, int(offset), int(limit))
+func (r *queryResolver) ListComments(ctx context.Context, postID string, parentID *string, offset, limit int32, sort *model.CommentSort) ([]*model.Comment, error) {
+	comments, err := r.Handler.ListComments(ctx, postID, parentID, parseCommentSort(sort), int(offset), int(limit))
+	if err != nil {
+		return nil, err
+	}
+	return convertMultComments(comments), nil
+}
+
+func (r *queryResolver) Posts(ctx context.Context, first int32, after *string) (*model.PostConnection, error) {
+	conn, err := r.Handler.PostsConnection(ctx, int(first), after)
+	if err != nil {
+		return nil, err
+	}
+	return convertPostConnection(conn), nil
+}
+
+func (r *queryResolver) Comments(ctx context.Context, postID string, parentID *string, first int32, after *string) (*model.CommentConnection, error) {
+	conn, err := r.Handler.CommentsConnection(ctx, postID, parentID, int(first), after)
+	if err != nil {
+		return nil, err
+	}
+	return convertCommentConnection(conn), nil
+}
+
+func (r *queryResolver) CommentThread(ctx context.Context, postID string, rootID *string, maxDepth, maxNodes int32) (*model.CommentThread, error) {
+	thread, err := r.Handler.CommentThread(ctx, postID, rootID, int(maxDepth), int(maxNodes))
+	if err != nil {
+		return nil, err
+	}
+	return convertCommentThread(thread), nil
+}
+
+func (r *queryResolver) Search(ctx context.Context, query string, typeArg *model.SearchType, first int32, after *string) (*model.SearchConnection, error) {
+	searchType := internal.SearchAll
+	if typeArg != nil {
+		searchType = internal.SearchType(strings.ToLower(string(*typeArg)))
+	}
+	conn, err := r.Handler.Search(ctx, query, searchType, int(first), after)
+	if err != nil {
+		return nil, err
+	}
+	return convertSearchConnection(conn), nil
+}
+
+func (r *queryResolver) Notifications(ctx context.Context, first int32, after *string, unreadOnly *bool) (*model.NotificationConnection, error) {
+	conn, err := r.Handler.Notifications(ctx, int(first), after, unreadOnly != nil && *unreadOnly)
+	if err != nil {
+		return nil, err
+	}
+	return convertNotificationConnection(conn), nil
+}
+
+func (r *queryResolver) Webhooks(ctx context.Context) ([]*model.Webhook, error) {
+	webhooks, err := r.Handler.ListWebhooks(ctx)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]*model.Webhook, len(webhooks))
+	for i, webhook := range webhooks {
+		res[i] = convertWebhook(webhook)
+	}
+	return res, nil
+}
+
+func (r *queryResolver) WebhookDeliveries(ctx context.Context, webhookID string, status *model.WebhookDeliveryStatus, offset, limit int32) ([]*model.WebhookDelivery, error) {
+	var st internal.WebhookDeliveryStatus
+	if status != nil {
+		st = internal.WebhookDeliveryStatus(strings.ToLower(string(*status)))
+	}
+	deliveries, err := r.Handler.ListWebhookDeliveries(ctx, webhookID, st, int(offset), int(limit))
+	if err != nil {
+		return nil, err
+	}
+	res := make([]*model.WebhookDelivery, len(deliveries))
+	for i, d := range deliveries {
+		res[i] = convertWebhookDelivery(d)
+	}
+	return res, nil
+}
+
+func (r *notificationResolver) Actor(ctx context.Context, obj *model.Notification) (*model.User, error) {
+	return r.author(ctx, obj.ActorID)
+}
+
+// пост уведомления, удаленный пост отдается как null
+func (r *notificationResolver) Post(ctx context.Context, obj *model.Notification) (*model.Post, error) {
+	if l := loaders.For(ctx); l != nil {
+		post, err := l.Posts.Load(ctx, obj.PostID)
+		if err != nil {
+			return nil, err
+		}
+		return convertPost(post), nil
+	}
+
+	posts, err := r.Handler.GetPostsByIDs(ctx, []string{obj.PostID})
+	if err != nil || len(posts) == 0 {
+		return nil, err
+	}
+	return convertPost(posts[0]), nil
+}
+
+// комментарии поста как вложенное поле, пагинация валидируется в service
+func (r *postResolver) Comments(ctx context.Context, obj *model.Post, parentID *string, offset, limit int32, sort *model.CommentSort) ([]*model.Comment, error) {
+	comments, err := r.Handler.ListComments(ctx, obj.ID, parentID, parseCommentSort(sort), int(offset), int(limit))
 	if err != nil {
 		return nil, err
 	}
 	return convertMultComments(comments), nil
 }
 
+// посты пользователя - тот же listPosts с фильтром по автору
+func (r *userResolver) Posts(ctx context.Context, obj *model.User, offset, limit int32, sort *model.PostSort) ([]*model.Post, error) {
+	authorID := obj.ID
+	posts, err := r.Handler.ListPosts(ctx, internal.PostFilter{AuthorID: &authorID}, parsePostSort(sort), int(offset), int(limit))
+	if err != nil {
+		return nil, err
+	}
+	return convertMultPosts(posts), nil
+}
+
+func (r *userResolver) Comments(ctx context.Context, obj *model.User, offset, limit int32) ([]*model.Comment, error) {
+	comments, err := r.Handler.ListCommentsByAuthor(ctx, obj.ID, int(offset), int(limit))
+	if err != nil {
+		return nil, err
+	}
+	return convertMultComments(comments), nil
+}
+
+func (r *postResolver) Author(ctx context.Context, obj *model.Post) (*model.User, error) {
+	return r.author(ctx, obj.AuthorID)
+}
+
+func (r *commentResolver) Author(ctx context.Context, obj *model.Comment) (*model.User, error) {
+	return r.author(ctx, obj.AuthorID)
+}
+
+// авторы всех постов и комментариев на странице загружаются одной пачкой
+func (r *Resolver) author(ctx context.Context, id string) (*model.User, error) {
+	var user *internal.User
+	if l := loaders.For(ctx); l != nil {
+		loaded, err := l.Users.Load(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		user = loaded
+	} else {
+		loaded, err := r.Handler.GetUser(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		user = loaded
+	}
+
+	if user == nil {
+		return nil, fmt.Errorf("%w: автор %s", customerrors.ErrNotFound, id)
+	}
+	return convertUser(user), nil
+}
+
+// история правок запрашивается для отдельных объектов, поэтому без загрузчика
+func (r *postResolver) Revisions(ctx context.Context, obj *model.Post) ([]*model.PostRevision, error) {
+	revisions, err := r.Handler.ListPostRevisions(ctx, obj.ID)
+	if err != nil {
+		return nil, err
+	}
+	return convertPostRevisions(revisions), nil
+}
+
+func (r *commentResolver) Revisions(ctx context.Context, obj *model.Comment) ([]*model.CommentRevision, error) {
+	revisions, err := r.Handler.ListCommentRevisions(ctx, obj.ID)
+	if err != nil {
+		return nil, err
+	}
+	return convertCommentRevisions(revisions), nil
+}
+
+func (r *postResolver) Reactions(ctx context.Context, obj *model.Post) (*model.ReactionSummary, error) {
+	return r.reactionSummary(ctx, internal.ReactionTargetPost, obj.ID)
+}
+
+func (r *postResolver) MyReaction(ctx context.Context, obj *model.Post) (*model.ReactionKind, error) {
+	return r.myReaction(ctx, internal.ReactionTargetPost, obj.ID)
+}
+
+func (r *commentResolver) Reactions(ctx context.Context, obj *model.Comment) (*model.ReactionSummary, error) {
+	return r.reactionSummary(ctx, internal.ReactionTargetComment, obj.ID)
+}
+
+func (r *commentResolver) MyReaction(ctx context.Context, obj *model.Comment) (*model.ReactionKind, error) {
+	return r.myReaction(ctx, internal.ReactionTargetComment, obj.ID)
+}
+
+func (r *postResolver) Mentions(ctx context.Context, obj *model.Post) ([]*model.Mention, error) {
+	return r.mentions(ctx, internal.ReactionTargetPost, obj.ID)
+}
+
+// у удаленного комментария текст скрыт, поэтому упоминания тоже не отдаются
+func (r *commentResolver) Mentions(ctx context.Context, obj *model.Comment) ([]*model.Mention, error) {
+	if obj.DeletedAt != nil {
+		return []*model.Mention{}, nil
+	}
+	return r.mentions(ctx, internal.ReactionTargetComment, obj.ID)
+}
+
+func (r *Resolver) mentions(ctx context.Context, target internal.ReactionTarget, id string) ([]*model.Mention, error) {
+	var mentions []internal.Mention
+	if l := loaders.For(ctx); l != nil {
+		loaded, err := l.Mentions.Load(ctx, loaders.ReactionKey{Target: target, ID: id})
+		if err != nil {
+			return nil, err
+		}
+		mentions = loaded
+	} else {
+		loaded, err := r.Handler.GetMentions(ctx, target, []string{id})
+		if err != nil {
+			return nil, err
+		}
+		mentions = loaded[id]
+	}
+
+	result := make([]*model.Mention, len(mentions))
+	for i, m := range mentions {
+		result[i] = &model.Mention{UserID: m.UserID, Username: m.Username}
+	}
+	return result, nil
+}
+
+// счетчики реакций всех объектов на странице выдачи загружаются одной пачкой
+func (r *Resolver) reactionSummary(ctx context.Context, target internal.ReactionTarget, id string) (*model.ReactionSummary, error) {
+	if l := loaders.For(ctx); l != nil {
+		summary, err := l.Reactions.Load(ctx, loaders.ReactionKey{Target: target, ID: id})
+		if err != nil {
+			return nil, err
+		}
+		if summary == nil {
+			summary = &internal.ReactionSummary{}
+		}
+		return convertReactionSummary(summary), nil
+	}
+
+	summaries, err := r.Handler.GetReactionSummaries(ctx, target, []string{id})
+	if err != nil {
+		return nil, err
+	}
+	summary := summaries[id]
+	if summary == nil {
+		summary = &internal.ReactionSummary{}
+	}
+	return convertReactionSummary(summary), nil
+}
+
+func (r *Resolver) myReaction(ctx context.Context, target internal.ReactionTarget, id string) (*model.ReactionKind, error) {
+	if l := loaders.For(ctx); l != nil {
+		kind, err := l.MyReactions.Load(ctx, loaders.ReactionKey{Target: target, ID: id})
+		if err != nil {
+			return nil, err
+		}
+		return convertReactionKind(kind), nil
+	}
+
+	reactions, err := r.Handler.GetMyReactions(ctx, target, []string{id})
+	if err != nil {
+		return nil, err
+	}
+	return convertReactionKind(reactions[id]), nil
+}
+
+// ответы на комментарий, соседние комментарии грузятся одной пачкой через загрузчик
+func (r *commentResolver) Replies(ctx context.Context, obj *model.Comment, offset, limit int32, sort *model.CommentSort) ([]*model.Comment, error) {
+	if l := loaders.For(ctx); l != nil {
+		comments, err := l.Replies.Load(ctx, loaders.RepliesKey{ParentID: obj.ID, Sort: parseCommentSort(sort), Offset: int(offset), Limit: int(limit)})
+		if err != nil {
+			return nil, err
+		}
+		return convertMultComments(comments), nil
+	}
+
+	parentID := obj.ID
+	comments, err := r.Handler.ListComments(ctx, obj.PostID, &parentID, parseCommentSort(sort), int(offset), int(limit))
+	if err != nil {
+		return nil, err
+	}
+	return convertMultComments(comments), nil
+}
+
+// подписка: переводит канал internal комментариев в канал graphql моделей
+func (r *subscriptionResolver) CommentAdded(ctx context.Context, postID string) (<-chan *model.Comment, error) {
+	comments, err := r.Handler.CommentAdded(ctx, postID)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(chan *model.Comment)
+	go func() {
+		defer close(out)
+		for comment := range comments {
+			select {
+			case out <- convertComment(comment):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+func (r *subscriptionResolver) NotificationAdded(ctx context.Context) (<-chan *model.Notification, error) {
+	notifications, err := r.Handler.NotificationAdded(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(chan *model.Notification)
+	go func() {
+		defer close(out)
+		for n := range notifications {
+			select {
+			case out <- convertNotification(n):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
 // служебные, сгенерированные gqlgen
-func (r *Resolver) Mutation() MutationResolver { return &mutationResolver{r} }
-func (r *Resolver) Query() QueryResolver       { return &queryResolver{r} }
+func (r *Resolver) Mutation() MutationResolver         { return &mutationResolver{r} }
+func (r *Resolver) Query() QueryResolver               { return &queryResolver{r} }
+func (r *Resolver) Subscription() SubscriptionResolver { return &subscriptionResolver{r} }
+func (r *Resolver) Post() PostResolver                 { return &postResolver{r} }
+func (r *Resolver) Comment() CommentResolver           { return &commentResolver{r} }
+func (r *Resolver) User() UserResolver                 { return &userResolver{r} }
+func (r *Resolver) Notification() NotificationResolver { return &notificationResolver{r} }
 
 type mutationResolver struct{ *Resolver }
 type queryResolver struct{ *Resolver }
+type subscriptionResolver struct{ *Resolver }
+type postResolver struct{ *Resolver }
+type commentResolver struct{ *Resolver }
+type userResolver struct{ *Resolver }
+type notificationResolver struct{ *Resolver }
@@ -0,0 +1,37 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/MAPiryazev/OzonTest/internal/customerrors"
+	"github.com/MAPiryazev/OzonTest/internal/models"
+)
+
+// пользователь, от имени которого выполняется запрос, роль берется из токена
+type Identity struct {
+	UserID   string
+	Username string
+	Role     models.Role
+}
+
+type ctxKey struct{}
+
+func WithIdentity(ctx context.Context, id *Identity) context.Context {
+	return context.WithValue(ctx, ctxKey{}, id)
+}
+
+// возвращает пользователя запроса, ok == false для анонимного запроса
+func FromContext(ctx context.Context) (*Identity, bool) {
+	id, ok := ctx.Value(ctxKey{}).(*Identity)
+	return id, ok && id != nil
+}
+
+// то же, что FromContext, но для действий, где нужен вход
+func Require(ctx context.Context) (*Identity, error) {
+	id, ok := FromContext(ctx)
+	if !ok {
+		return nil, fmt.Errorf("%w: нужен токен в заголовке Authorization", customerrors.ErrUnauthorized)
+	}
+	return id, nil
+}
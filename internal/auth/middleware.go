@@ -0,0 +1,73 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+
+	"github.com/MAPiryazev/OzonTest/internal/customerrors"
+)
+
+// разбирает заголовок вида "Bearer <token>", пустая строка - токена нет
+func bearerToken(header string) (string, error) {
+	if header == "" {
+		return "", nil
+	}
+	scheme, token, ok := strings.Cut(header, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || strings.TrimSpace(token) == "" {
+		return "", fmt.Errorf("%w: ожидается заголовок Authorization: Bearer <token>", customerrors.ErrInvalidToken)
+	}
+	return strings.TrimSpace(token), nil
+}
+
+// запросы без токена проходят анонимно, с неверным токеном отклоняются с 401
+func Middleware(tokens *TokenManager) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token, err := bearerToken(r.Header.Get("Authorization"))
+			if err == nil && token != "" {
+				var id *Identity
+				id, err = tokens.Verify(token)
+				if err == nil {
+					r = r.WithContext(WithIdentity(r.Context(), id))
+				}
+			}
+			if err != nil {
+				writeUnauthorized(w, err)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func writeUnauthorized(w http.ResponseWriter, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnauthorized)
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"errors": []map[string]string{{"message": err.Error()}},
+	})
+}
+
+// проверка токена из connection_init для websocket подписок, заголовки там браузером не передаются
+func WebsocketInit(tokens *TokenManager) transport.WebsocketInitFunc {
+	return func(ctx context.Context, payload transport.InitPayload) (context.Context, *transport.InitPayload, error) {
+		token, err := bearerToken(payload.Authorization())
+		if err != nil {
+			return ctx, nil, err
+		}
+		// payload не возвращаем в connection_ack, чтобы не отправлять токен обратно
+		if token == "" {
+			return ctx, nil, nil
+		}
+		id, err := tokens.Verify(token)
+		if err != nil {
+			return ctx, nil, err
+		}
+		return WithIdentity(ctx, id), nil, nil
+	}
+}
@@ -0,0 +1,141 @@
+package auth
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/MAPiryazev/OzonTest/internal/config"
+	"github.com/MAPiryazev/OzonTest/internal/customerrors"
+	"github.com/MAPiryazev/OzonTest/internal/models"
+)
+
+// способ входа (amr, RFC 8176), под которым выпускаются токены: только после проверки пароля
+const amrPassword = "pwd"
+
+type claims struct {
+	Username string      `json:"name"`
+	Role     models.Role `json:"role,omitempty"`
+	// токены без amr выпускал вход по одному имени, они не принимаются
+	AMR []string `json:"amr,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// выпускает и проверяет jwt, алгоритм фиксирован конфигом, чтобы нельзя было подсунуть токен с другим alg
+type TokenManager struct {
+	method    jwt.SigningMethod
+	signKey   any
+	verifyKey any
+	issuer    string
+	ttl       time.Duration
+}
+
+func NewTokenManager(cfg *config.AuthConfig) (*TokenManager, error) {
+	m := &TokenManager{issuer: cfg.Issuer, ttl: time.Duration(cfg.TokenTTLMin) * time.Minute}
+
+	switch cfg.Algorithm {
+	case config.JWTAlgRS256:
+		m.method = jwt.SigningMethodRS256
+		if cfg.PrivateKeyPath != "" {
+			key, err := readPEM(cfg.PrivateKeyPath, jwt.ParseRSAPrivateKeyFromPEM)
+			if err != nil {
+				return nil, err
+			}
+			m.signKey = key
+			m.verifyKey = &key.PublicKey
+		}
+		if cfg.PublicKeyPath != "" {
+			key, err := readPEM(cfg.PublicKeyPath, jwt.ParseRSAPublicKeyFromPEM)
+			if err != nil {
+				return nil, err
+			}
+			m.verifyKey = key
+		}
+	default:
+		m.method = jwt.SigningMethodHS256
+		secret := []byte(cfg.Secret)
+		// как и у курсоров: без секрета токены не переживают рестарт
+		if len(secret) == 0 {
+			secret = make([]byte, 32)
+			if _, err := rand.Read(secret); err != nil {
+				return nil, fmt.Errorf("не удалось сгенерировать ключ для токенов: %w", err)
+			}
+			log.Println("JWT_SECRET не задан, используется случайный ключ, токены станут недействительны после рестарта")
+		}
+		m.signKey = secret
+		m.verifyKey = secret
+	}
+	return m, nil
+}
+
+func readPEM[K any](path string, parse func([]byte) (K, error)) (K, error) {
+	var zero K
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return zero, fmt.Errorf("%w: не удалось прочитать ключ %s: %v", customerrors.ErrInvalidEnvValue, path, err)
+	}
+	key, err := parse(data)
+	if err != nil {
+		return zero, fmt.Errorf("%w: некорректный ключ %s: %v", customerrors.ErrInvalidEnvValue, path, err)
+	}
+	return key, nil
+}
+
+// выпускает токен для пользователя, чей пароль уже проверен; возвращает его и время истечения
+func (m *TokenManager) Issue(user *models.User) (string, time.Time, error) {
+	if m.signKey == nil {
+		return "", time.Time{}, errors.New("не задан приватный ключ, выпуск токенов невозможен")
+	}
+
+	now := time.Now().UTC()
+	expiresAt := now.Add(m.ttl)
+	token := jwt.NewWithClaims(m.method, claims{
+		Username: user.Username,
+		Role:     user.Role,
+		AMR:      []string{amrPassword},
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   user.ID,
+			Issuer:    m.issuer,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+	})
+
+	signed, err := token.SignedString(m.signKey)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("не удалось подписать токен: %w", err)
+	}
+	return signed, expiresAt, nil
+}
+
+// проверяет подпись, алгоритм, издателя, срок действия и то, что токен выпущен после проверки пароля
+func (m *TokenManager) Verify(token string) (*Identity, error) {
+	var c claims
+	_, err := jwt.ParseWithClaims(token, &c, func(*jwt.Token) (any, error) {
+		return m.verifyKey, nil
+	},
+		jwt.WithValidMethods([]string{m.method.Alg()}),
+		jwt.WithIssuer(m.issuer),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", customerrors.ErrInvalidToken, err)
+	}
+	if c.Subject == "" {
+		return nil, fmt.Errorf("%w: в токене нет пользователя", customerrors.ErrInvalidToken)
+	}
+	if !slices.Contains(c.AMR, amrPassword) {
+		return nil, fmt.Errorf("%w: токен выпущен без проверки пароля", customerrors.ErrInvalidToken)
+	}
+	role := c.Role
+	if role == "" {
+		role = models.RoleUser
+	}
+	return &Identity{UserID: c.Subject, Username: c.Username, Role: role}, nil
+}
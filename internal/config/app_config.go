@@ -15,6 +15,14 @@ type AppConfig struct {
 	DefaultListLimit int
 	MaxListLimit     int
 	MinUsernameLen   int
+	CursorSecret     string
+	MaxThreadDepth   int
+	MaxThreadNodes   int
+	MinPasswordLen   int
+	MaxLoginFailures int
+	LoginLockoutMin  int
+	// окно редактирования комментария после создания, <= 0 - без ограничения
+	CommentEditWindowMin int
 }
 
 // функция которая вернет эти параметры
@@ -30,6 +38,13 @@ func LoadAppConfig() (*AppConfig, error) {
 	defaultListLimit := getEnvIntWithDefault("LIST_LIMIT", 20)
 	maxListLimit := getEnvIntWithDefault("MAX_LIST_LIMIT", 100)
 	minUsernameLen := getEnvIntWithDefault("MIN_USERNAME_LEN", 3)
+	cursorSecret := os.Getenv("CURSOR_SECRET")
+	maxThreadDepth := getEnvIntWithDefault("MAX_THREAD_DEPTH", 20)
+	maxThreadNodes := getEnvIntWithDefault("MAX_THREAD_NODES", 500)
+	minPasswordLen := getEnvIntWithDefault("MIN_PASSWORD_LEN", 8)
+	maxLoginFailures := getEnvIntWithDefault("MAX_LOGIN_FAILURES", 5)
+	loginLockoutMin := getEnvIntWithDefault("LOGIN_LOCKOUT_MIN", 15)
+	commentEditWindowMin := getEnvIntWithDefault("COMMENT_EDIT_WINDOW_MIN", 15)
 
 	return &AppConfig{
 		AppPort:          appPort,
@@ -37,6 +52,14 @@ func LoadAppConfig() (*AppConfig, error) {
 		DefaultListLimit: defaultListLimit,
 		MaxListLimit:     maxListLimit,
 		MinUsernameLen:   minUsernameLen,
+		CursorSecret:     cursorSecret,
+		MaxThreadDepth:   maxThreadDepth,
+		MaxThreadNodes:   maxThreadNodes,
+		MinPasswordLen:   minPasswordLen,
+		MaxLoginFailures: maxLoginFailures,
+		LoginLockoutMin:  loginLockoutMin,
+
+		CommentEditWindowMin: commentEditWindowMin,
 	}, nil
 }
 
@@ -0,0 +1,62 @@
+package config
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/MAPiryazev/OzonTest/internal/customerrors"
+	"github.com/joho/godotenv"
+)
+
+// алгоритмы подписи jwt
+const (
+	JWTAlgHS256 = "HS256"
+	JWTAlgRS256 = "RS256"
+)
+
+// параметры выпуска и проверки токенов, для RS256 публичный ключ можно не указывать, он берется из приватного
+type AuthConfig struct {
+	Algorithm      string
+	Secret         string
+	PrivateKeyPath string
+	PublicKeyPath  string
+	Issuer         string
+	TokenTTLMin    int
+}
+
+func LoadAuthConfig() (*AuthConfig, error) {
+	if err := godotenv.Load(".env"); err != nil {
+		if err2 := godotenv.Load("../environment/.env"); err2 != nil {
+			log.Println("Файл .env не найден, будут использоваться дефолтные значения для авторизации")
+		}
+	}
+
+	algorithm := os.Getenv("JWT_ALGORITHM")
+	if algorithm == "" {
+		algorithm = JWTAlgHS256
+	}
+	if algorithm != JWTAlgHS256 && algorithm != JWTAlgRS256 {
+		return nil, fmt.Errorf("%w: значение JWT_ALGORITHM = %s", customerrors.ErrInvalidEnvValue, algorithm)
+	}
+
+	privateKeyPath := os.Getenv("JWT_PRIVATE_KEY_PATH")
+	publicKeyPath := os.Getenv("JWT_PUBLIC_KEY_PATH")
+	if algorithm == JWTAlgRS256 && privateKeyPath == "" && publicKeyPath == "" {
+		return nil, fmt.Errorf("%w: для RS256 нужен JWT_PRIVATE_KEY_PATH или JWT_PUBLIC_KEY_PATH", customerrors.ErrInvalidEnvValue)
+	}
+
+	issuer := os.Getenv("JWT_ISSUER")
+	if issuer == "" {
+		issuer = "ozon-test"
+	}
+
+	return &AuthConfig{
+		Algorithm:      algorithm,
+		Secret:         os.Getenv("JWT_SECRET"),
+		PrivateKeyPath: privateKeyPath,
+		PublicKeyPath:  publicKeyPath,
+		Issuer:         issuer,
+		TokenTTLMin:    getEnvIntWithDefault("JWT_TTL_MIN", 60),
+	}, nil
+}
@@ -22,7 +22,7 @@ func LoadLaunchMode() (string, error) {
 		log.Println("LAUNCH_MODE не найден в env, установлен дефолт:", mode)
 	}
 
-	if mode != "memory" && mode != "postgres" {
+	if mode != "memory" && mode != "postgres" && mode != "sqlite" {
 		return "", fmt.Errorf("%w: значение LAUNCH_MODE = %s", customerrors.ErrInvalidEnvValue, mode)
 	}
 
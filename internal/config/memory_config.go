@@ -0,0 +1,56 @@
+package config
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/MAPiryazev/OzonTest/internal/customerrors"
+	"github.com/joho/godotenv"
+)
+
+// политики сброса журнала на диск
+const (
+	FsyncAlways   = "always"
+	FsyncInterval = "interval"
+	FsyncNever    = "never"
+)
+
+// параметры сохранения in-memory хранилища на диск, пустой WALPath - хранилище без сохранения
+type MemoryConfig struct {
+	WALPath             string
+	SnapshotPath        string
+	FsyncPolicy         string
+	FsyncIntervalMs     int
+	SnapshotIntervalSec int
+}
+
+func LoadMemoryConfig() (*MemoryConfig, error) {
+	if err := godotenv.Load(".env"); err != nil {
+		if err2 := godotenv.Load("../environment/.env"); err2 != nil {
+			log.Println("Файл .env не найден, будут использоваться дефолтные значения для in-memory хранилища")
+		}
+	}
+
+	walPath := os.Getenv("MEMORY_WAL_PATH")
+	snapshotPath := os.Getenv("MEMORY_SNAPSHOT_PATH")
+	if snapshotPath == "" && walPath != "" {
+		snapshotPath = walPath + ".snapshot"
+	}
+
+	fsyncPolicy := os.Getenv("MEMORY_FSYNC")
+	if fsyncPolicy == "" {
+		fsyncPolicy = FsyncInterval
+	}
+	if fsyncPolicy != FsyncAlways && fsyncPolicy != FsyncInterval && fsyncPolicy != FsyncNever {
+		return nil, fmt.Errorf("%w: значение MEMORY_FSYNC = %s", customerrors.ErrInvalidEnvValue, fsyncPolicy)
+	}
+
+	return &MemoryConfig{
+		WALPath:             walPath,
+		SnapshotPath:        snapshotPath,
+		FsyncPolicy:         fsyncPolicy,
+		FsyncIntervalMs:     getEnvIntWithDefault("MEMORY_FSYNC_INTERVAL_MS", 1000),
+		SnapshotIntervalSec: getEnvIntWithDefault("MEMORY_SNAPSHOT_INTERVAL_SEC", 300),
+	}, nil
+}
@@ -0,0 +1,30 @@
+package config
+
+import (
+	"log"
+
+	"github.com/joho/godotenv"
+)
+
+// параметры ретранслятора событий outbox
+type OutboxConfig struct {
+	PollIntervalMs int
+	// сколько событий потребитель получает за один проход
+	BatchSize int
+	// после стольких неудачных попыток подряд событие пропускается, 0 - повторять без ограничения
+	MaxAttempts int
+}
+
+func LoadOutboxConfig() (*OutboxConfig, error) {
+	if err := godotenv.Load(".env"); err != nil {
+		if err2 := godotenv.Load("../environment/.env"); err2 != nil {
+			log.Println("Файл .env не найден, будут использоваться дефолтные значения для outbox")
+		}
+	}
+
+	return &OutboxConfig{
+		PollIntervalMs: getEnvIntWithDefault("OUTBOX_POLL_INTERVAL_MS", 500),
+		BatchSize:      getEnvIntWithDefault("OUTBOX_BATCH_SIZE", 100),
+		MaxAttempts:    getEnvIntWithDefault("OUTBOX_MAX_ATTEMPTS", 10),
+	}, nil
+}
@@ -0,0 +1,33 @@
+package config
+
+import (
+	"log"
+	"os"
+
+	"github.com/joho/godotenv"
+)
+
+// параметры файла sqlite
+type SQLiteConfig struct {
+	Path          string
+	BusyTimeoutMs int
+}
+
+func LoadSQLiteConfig() (*SQLiteConfig, error) {
+	if err := godotenv.Load(".env"); err != nil {
+		if err2 := godotenv.Load("../environment/.env"); err2 != nil {
+			log.Println("Файл .env не найден, будут использоваться дефолтные значения для sqlite")
+		}
+	}
+
+	path := os.Getenv("SQLITE_PATH")
+	if path == "" {
+		path = "ozon.db"
+		log.Println("SQLITE_PATH не найден в env, установлен дефолт:", path)
+	}
+
+	return &SQLiteConfig{
+		Path:          path,
+		BusyTimeoutMs: getEnvIntWithDefault("SQLITE_BUSY_TIMEOUT_MS", 5000),
+	}, nil
+}
@@ -0,0 +1,36 @@
+package config
+
+import (
+	"log"
+
+	"github.com/joho/godotenv"
+)
+
+// параметры воркера доставки вебхуков
+type WebhookConfig struct {
+	PollIntervalMs int
+	BatchSize      int
+	// после стольких неудачных попыток доставка уходит в dead
+	MaxAttempts int
+	// задержка перед повтором растет как BackoffBaseSec * 2^(попытка-1), но не больше BackoffMaxSec
+	BackoffBaseSec int
+	BackoffMaxSec  int
+	TimeoutSec     int
+}
+
+func LoadWebhookConfig() (*WebhookConfig, error) {
+	if err := godotenv.Load(".env"); err != nil {
+		if err2 := godotenv.Load("../environment/.env"); err2 != nil {
+			log.Println("Файл .env не найден, будут использоваться дефолтные значения для вебхуков")
+		}
+	}
+
+	return &WebhookConfig{
+		PollIntervalMs: getEnvIntWithDefault("WEBHOOK_POLL_INTERVAL_MS", 1000),
+		BatchSize:      getEnvIntWithDefault("WEBHOOK_BATCH_SIZE", 20),
+		MaxAttempts:    getEnvIntWithDefault("WEBHOOK_MAX_ATTEMPTS", 8),
+		BackoffBaseSec: getEnvIntWithDefault("WEBHOOK_BACKOFF_BASE_SEC", 10),
+		BackoffMaxSec:  getEnvIntWithDefault("WEBHOOK_BACKOFF_MAX_SEC", 3600),
+		TimeoutSec:     getEnvIntWithDefault("WEBHOOK_TIMEOUT_SEC", 10),
+	}, nil
+}
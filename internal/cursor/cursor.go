@@ -0,0 +1,106 @@
+package cursor
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/MAPiryazev/OzonTest/internal/customerrors"
+	"github.com/MAPiryazev/OzonTest/internal/models"
+)
+
+// виды курсоров, курсор одного списка нельзя подставить в другой
+const (
+	KindPost         = "post"
+	KindComment      = "comment"
+	KindSearch       = "search"
+	KindNotification = "notification"
+)
+
+type payload struct {
+	Kind      string `json:"k"`
+	CreatedAt int64  `json:"t"`
+	ID        string `json:"id"`
+	// смещение для списков без стабильного ключа, например результатов поиска
+	Offset int `json:"o,omitempty"`
+}
+
+// кодирует позицию keyset пагинации в непрозрачную строку, подписанную HMAC-SHA256
+type Codec struct {
+	secret []byte
+}
+
+// при пустом секрете генерируется случайный, курсоры тогда не переживают рестарт
+func NewCodec(secret string) *Codec {
+	if secret == "" {
+		key := make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			log.Fatalf("не удалось сгенерировать ключ для курсоров: %v", err)
+		}
+		log.Println("CURSOR_SECRET не задан, используется случайный ключ")
+		return &Codec{secret: key}
+	}
+	return &Codec{secret: []byte(secret)}
+}
+
+func (c *Codec) Encode(kind string, key models.PageKey) string {
+	data, _ := json.Marshal(payload{Kind: kind, CreatedAt: key.CreatedAt.UnixNano(), ID: key.ID})
+	mac := c.sign(data)
+	return base64.RawURLEncoding.EncodeToString(append(data, mac...))
+}
+
+// проверяет подпись и вид курсора
+func (c *Codec) Decode(kind, s string) (*models.PageKey, error) {
+	p, err := c.decode(kind, s)
+	if err != nil {
+		return nil, err
+	}
+	return &models.PageKey{CreatedAt: time.Unix(0, p.CreatedAt).UTC(), ID: p.ID}, nil
+}
+
+func (c *Codec) decode(kind, s string) (*payload, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(raw) <= sha256.Size {
+		return nil, fmt.Errorf("%w: не удалось разобрать", customerrors.ErrInvalidCursor)
+	}
+
+	data, mac := raw[:len(raw)-sha256.Size], raw[len(raw)-sha256.Size:]
+	if !hmac.Equal(mac, c.sign(data)) {
+		return nil, fmt.Errorf("%w: неверная подпись", customerrors.ErrInvalidCursor)
+	}
+
+	var p payload
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("%w: %v", customerrors.ErrInvalidCursor, err)
+	}
+	if p.Kind != kind {
+		return nil, fmt.Errorf("%w: курсор от другого списка", customerrors.ErrInvalidCursor)
+	}
+	return &p, nil
+}
+
+// курсор по позиции в списке, kind должен включать все, от чего зависит порядок списка
+func (c *Codec) EncodeOffset(kind string, offset int) string {
+	data, _ := json.Marshal(payload{Kind: kind, Offset: offset})
+	mac := c.sign(data)
+	return base64.RawURLEncoding.EncodeToString(append(data, mac...))
+}
+
+func (c *Codec) DecodeOffset(kind, s string) (int, error) {
+	p, err := c.decode(kind, s)
+	if err != nil {
+		return 0, err
+	}
+	return p.Offset, nil
+}
+
+func (c *Codec) sign(data []byte) []byte {
+	h := hmac.New(sha256.New, c.secret)
+	h.Write(data)
+	return h.Sum(nil)
+}
@@ -11,12 +11,20 @@ var (
 	ErrDBCreation      = errors.New("ошибка при создании БД")
 	ErrDBQuery         = errors.New("ошибка во время исполнения sql запроса")
 	ErrDBScan          = errors.New("ошибка при чтении результата из БД")
+	ErrMigration       = errors.New("ошибка миграции схемы БД")
+	ErrSchemaOutdated  = errors.New("схема БД отстает от версии приложения, выполните migrate up")
 
 	ErrNotFound        = errors.New("объект не найден")
 	ErrAlreadyExists   = errors.New("объект уже существует")
 	ErrParamOutOfRange = errors.New("параметр выходит за допустимые пределы значения")
+	ErrInvalidCursor   = errors.New("некорректный курсор пагинации")
 
 	ErrValidation    = errors.New("ошибка валидации объекта")
 	ErrCommForbidden = errors.New("оставлять комментариев запрещено")
 	ErrForbidden     = errors.New("действие запрещено")
+	ErrUnauthorized  = errors.New("требуется авторизация")
+	ErrInvalidToken  = errors.New("недействительный токен")
+	ErrAccountLocked = errors.New("учетная запись временно заблокирована")
+
+	ErrBrokerClosed = errors.New("брокер событий остановлен")
 )
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/MAPiryazev/OzonTest/internal/auth"
 	"github.com/MAPiryazev/OzonTest/internal/customerrors"
 	"github.com/MAPiryazev/OzonTest/internal/models"
 	"github.com/MAPiryazev/OzonTest/internal/service"
@@ -21,11 +22,11 @@ func NewHandler(svc service.Service) *Handler {
 }
 
 // возвращает посты
-func (h *Handler) ListPosts(ctx context.Context, offset, limit int) ([]*models.Post, error) {
-	postList, err := h.svc.ListPosts(ctx, offset, limit)
+func (h *Handler) ListPosts(ctx context.Context, filter models.PostFilter, sort models.PostSort, offset, limit int) ([]*models.Post, error) {
+	postList, err := h.svc.ListPosts(ctx, filter, sort, offset, limit)
 
 	if err != nil {
-		if errors.Is(err, customerrors.ErrParamOutOfRange) {
+		if errors.Is(err, customerrors.ErrParamOutOfRange) || errors.Is(err, customerrors.ErrValidation) {
 			return nil, err
 		}
 		return nil, fmt.Errorf("ошибка запроса к базе: %w", err)
@@ -33,6 +34,18 @@ func (h *Handler) ListPosts(ctx context.Context, offset, limit int) ([]*models.P
 	return postList, nil
 }
 
+// возвращает страницу постов по курсору
+func (h *Handler) PostsConnection(ctx context.Context, first int, after *string) (*models.PostConnection, error) {
+	conn, err := h.svc.ListPostsConnection(ctx, first, after)
+	if err != nil {
+		if errors.Is(err, customerrors.ErrParamOutOfRange) || errors.Is(err, customerrors.ErrInvalidCursor) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("ошибка запроса к базе: %w", err)
+	}
+	return conn, nil
+}
+
 // возвращает пост по ID
 func (h *Handler) GetPost(ctx context.Context, postID string) (*models.Post, error) {
 	foundPost, err := h.svc.GetPostByID(ctx, postID)
@@ -50,8 +63,8 @@ func (h *Handler) GetPost(ctx context.Context, postID string) (*models.Post, err
 }
 
 // возвращает комментарии поста
-func (h *Handler) ListComments(ctx context.Context, postID string, parentID *string, offset, limit int) ([]*models.Comment, error) {
-	commentList, err := h.svc.ListCommentsByPost(ctx, postID, parentID, offset, limit)
+func (h *Handler) ListComments(ctx context.Context, postID string, parentID *string, sort models.CommentSort, offset, limit int) ([]*models.Comment, error) {
+	commentList, err := h.svc.ListCommentsByPost(ctx, postID, parentID, sort, offset, limit)
 
 	if err != nil {
 		if errors.Is(err, customerrors.ErrValidation) || errors.Is(err, customerrors.ErrParamOutOfRange) {
@@ -65,14 +78,39 @@ func (h *Handler) ListComments(ctx context.Context, postID string, parentID *str
 	return commentList, nil
 }
 
-// создает пользователя
-func (h *Handler) CreateUser(ctx context.Context, username string) (*models.User, error) {
-	newUser := &models.User{
-		ID:       uuid.NewString(),
-		Username: username}
+// возвращает страницу комментариев по курсору
+func (h *Handler) CommentsConnection(ctx context.Context, postID string, parentID *string, first int, after *string) (*models.CommentConnection, error) {
+	conn, err := h.svc.ListCommentsConnection(ctx, postID, parentID, first, after)
+	if err != nil {
+		if errors.Is(err, customerrors.ErrValidation) || errors.Is(err, customerrors.ErrParamOutOfRange) || errors.Is(err, customerrors.ErrInvalidCursor) {
+			return nil, err
+		}
+		if errors.Is(err, customerrors.ErrNotFound) {
+			return nil, customerrors.ErrNotFound
+		}
+		return nil, fmt.Errorf("не удалось получить комментарии: %w", err)
+	}
+	return conn, nil
+}
 
-	err := h.svc.CreateUser(ctx, newUser)
+// возвращает дерево комментариев одним запросом
+func (h *Handler) CommentThread(ctx context.Context, postID string, rootID *string, maxDepth, maxNodes int) (*models.CommentThread, error) {
+	thread, err := h.svc.GetCommentThread(ctx, postID, rootID, maxDepth, maxNodes)
+	if err != nil {
+		if errors.Is(err, customerrors.ErrValidation) || errors.Is(err, customerrors.ErrParamOutOfRange) {
+			return nil, err
+		}
+		if errors.Is(err, customerrors.ErrNotFound) {
+			return nil, customerrors.ErrNotFound
+		}
+		return nil, fmt.Errorf("не удалось получить дерево комментариев: %w", err)
+	}
+	return thread, nil
+}
 
+// регистрирует пользователя с паролем
+func (h *Handler) Register(ctx context.Context, username, password string) (*models.AuthPayload, error) {
+	payload, err := h.svc.Register(ctx, username, password)
 	if err != nil {
 		if errors.Is(err, customerrors.ErrAlreadyExists) {
 			return nil, customerrors.ErrAlreadyExists
@@ -80,18 +118,99 @@ func (h *Handler) CreateUser(ctx context.Context, username string) (*models.User
 		if errors.Is(err, customerrors.ErrValidation) {
 			return nil, err
 		}
-		return nil, fmt.Errorf("не удалось создать пользователя: %w", err)
+		return nil, fmt.Errorf("не удалось зарегистрировать пользователя: %w", err)
+	}
+	return payload, nil
+}
+
+// выдает токен по имени пользователя и паролю
+func (h *Handler) Login(ctx context.Context, username, password string) (*models.AuthPayload, error) {
+	payload, err := h.svc.Login(ctx, username, password)
+	if err != nil {
+		if errors.Is(err, customerrors.ErrUnauthorized) || errors.Is(err, customerrors.ErrAccountLocked) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("не удалось выполнить вход: %w", err)
+	}
+	return payload, nil
+}
+
+// меняет роль пользователя
+func (h *Handler) SetUserRole(ctx context.Context, userID string, role models.Role) (*models.User, error) {
+	user, err := h.svc.SetUserRole(ctx, userID, role)
+	if err != nil {
+		if errors.Is(err, customerrors.ErrValidation) || errors.Is(err, customerrors.ErrForbidden) || errors.Is(err, customerrors.ErrUnauthorized) || errors.Is(err, customerrors.ErrNotFound) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("не удалось изменить роль: %w", err)
+	}
+	return user, nil
+}
+
+// возвращает пользователя, от имени которого выполняется запрос, nil для анонимного
+func (h *Handler) Me(ctx context.Context) (*models.User, error) {
+	actor, ok := auth.FromContext(ctx)
+	if !ok {
+		return nil, nil
+	}
+	user, err := h.svc.GetUserByID(ctx, actor.UserID)
+	if err != nil {
+		if errors.Is(err, customerrors.ErrNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("не удалось получить пользователя: %w", err)
+	}
+	return user, nil
+}
+
+// возвращает пользователя по id, nil если его нет
+func (h *Handler) GetUser(ctx context.Context, id string) (*models.User, error) {
+	user, err := h.svc.GetUserByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, customerrors.ErrNotFound) {
+			return nil, nil
+		}
+		if errors.Is(err, customerrors.ErrValidation) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("не удалось получить пользователя: %w", err)
 	}
-	return newUser, nil
+	return user, nil
 }
 
-// создает пост
-func (h *Handler) CreatePost(ctx context.Context, title, content, creatorID string, commentsEnabled bool) (*models.Post, error) {
+// возвращает пользователя по имени, nil если его нет
+func (h *Handler) GetUserByUsername(ctx context.Context, username string) (*models.User, error) {
+	user, err := h.svc.GetUserByUsername(ctx, username)
+	if err != nil {
+		if errors.Is(err, customerrors.ErrNotFound) {
+			return nil, nil
+		}
+		if errors.Is(err, customerrors.ErrValidation) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("не удалось получить пользователя: %w", err)
+	}
+	return user, nil
+}
+
+// возвращает комментарии пользователя
+func (h *Handler) ListCommentsByAuthor(ctx context.Context, authorID string, offset, limit int) ([]*models.Comment, error) {
+	comments, err := h.svc.ListCommentsByAuthor(ctx, authorID, offset, limit)
+	if err != nil {
+		if errors.Is(err, customerrors.ErrValidation) || errors.Is(err, customerrors.ErrParamOutOfRange) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("не удалось получить комментарии пользователя: %w", err)
+	}
+	return comments, nil
+}
+
+// создает пост от имени текущего пользователя
+func (h *Handler) CreatePost(ctx context.Context, title, content string, commentsEnabled bool) (*models.Post, error) {
 	newPost := &models.Post{
 		ID:              uuid.NewString(),
 		Title:           title,
 		Content:         content,
-		AuthorID:        creatorID,
 		CommentsEnabled: commentsEnabled,
 		CreatedAt:       time.Now().UTC(),
 	}
@@ -100,7 +219,7 @@ func (h *Handler) CreatePost(ctx context.Context, title, content, creatorID stri
 		switch {
 		case errors.Is(err, customerrors.ErrAlreadyExists):
 			return nil, customerrors.ErrAlreadyExists
-		case errors.Is(err, customerrors.ErrValidation):
+		case errors.Is(err, customerrors.ErrValidation), errors.Is(err, customerrors.ErrUnauthorized):
 			return nil, err
 		case errors.Is(err, customerrors.ErrNotFound):
 			return nil, fmt.Errorf("ошибка при создании поста: пользователь не найден: %w", customerrors.ErrEnvNotFound)
@@ -112,40 +231,101 @@ func (h *Handler) CreatePost(ctx context.Context, title, content, creatorID stri
 	return newPost, nil
 }
 
-// обновляет пост
-func (h *Handler) UpdatePost(ctx context.Context, id, title, content, userID string) (*models.Post, error) {
-	needUpdate := &models.Post{
-		ID:      id,
-		Title:   title,
-		Content: content,
+// частично обновляет пост, nil поля остаются без изменений
+func (h *Handler) UpdatePost(ctx context.Context, id string, patch models.PostPatch) (*models.Post, error) {
+	post, err := h.svc.UpdatePost(ctx, id, patch)
+	if err != nil {
+		if errors.Is(err, customerrors.ErrValidation) || errors.Is(err, customerrors.ErrForbidden) || errors.Is(err, customerrors.ErrNotFound) || errors.Is(err, customerrors.ErrUnauthorized) { //опознанные ошибки
+			return nil, err
+		}
+		return nil, fmt.Errorf("ошибка обновления поста: %w", err)
 	}
+	return post, nil
+}
 
-	err := h.svc.UpdatePost(ctx, needUpdate, userID)
+// разрешает или запрещает комментарии к посту
+func (h *Handler) SetCommentsEnabled(ctx context.Context, postID string, enabled bool) (*models.Post, error) {
+	post, err := h.svc.SetCommentsEnabled(ctx, postID, enabled)
+	if err != nil {
+		if errors.Is(err, customerrors.ErrValidation) || errors.Is(err, customerrors.ErrForbidden) || errors.Is(err, customerrors.ErrNotFound) || errors.Is(err, customerrors.ErrUnauthorized) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("ошибка изменения комментариев поста: %w", err)
+	}
+	return post, nil
+}
 
+// история правок поста
+func (h *Handler) ListPostRevisions(ctx context.Context, postID string) ([]*models.PostRevision, error) {
+	revisions, err := h.svc.ListPostRevisions(ctx, postID)
 	if err != nil {
-		if errors.Is(err, customerrors.ErrValidation) || errors.Is(err, customerrors.ErrForbidden) || errors.Is(err, customerrors.ErrNotFound) { //опознанные ошибки
+		if errors.Is(err, customerrors.ErrValidation) {
 			return nil, err
 		}
-		return nil, fmt.Errorf("ошибка обновления поста: %w", err)
+		return nil, fmt.Errorf("не удалось получить историю поста: %w", err)
 	}
+	return revisions, nil
+}
 
-	return h.svc.GetPostByID(ctx, id)
+// меняет текст комментария
+func (h *Handler) UpdateComment(ctx context.Context, id, text string) (*models.Comment, error) {
+	comment, err := h.svc.UpdateComment(ctx, id, text)
+	if err != nil {
+		if errors.Is(err, customerrors.ErrValidation) || errors.Is(err, customerrors.ErrParamOutOfRange) || errors.Is(err, customerrors.ErrForbidden) || errors.Is(err, customerrors.ErrNotFound) || errors.Is(err, customerrors.ErrUnauthorized) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("ошибка редактирования комментария: %w", err)
+	}
+	return comment, nil
+}
+
+// история правок комментария
+func (h *Handler) ListCommentRevisions(ctx context.Context, commentID string) ([]*models.CommentRevision, error) {
+	revisions, err := h.svc.ListCommentRevisions(ctx, commentID)
+	if err != nil {
+		if errors.Is(err, customerrors.ErrValidation) || errors.Is(err, customerrors.ErrNotFound) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("не удалось получить историю комментария: %w", err)
+	}
+	return revisions, nil
 }
 
-// создает комментарий к посту
-func (h *Handler) CreateComment(ctx context.Context, postID, text, authorID string, parentID *string) (*models.Comment, error) {
+// удаляет пост, hard - физически вместе с комментариями
+func (h *Handler) DeletePost(ctx context.Context, id string, hard bool) (bool, error) {
+	if err := h.svc.DeletePost(ctx, id, hard); err != nil {
+		if errors.Is(err, customerrors.ErrValidation) || errors.Is(err, customerrors.ErrForbidden) || errors.Is(err, customerrors.ErrNotFound) || errors.Is(err, customerrors.ErrUnauthorized) {
+			return false, err
+		}
+		return false, fmt.Errorf("ошибка удаления поста: %w", err)
+	}
+	return true, nil
+}
+
+// удаляет комментарий, hard - физически вместе с ответами
+func (h *Handler) DeleteComment(ctx context.Context, id string, hard bool) (bool, error) {
+	if err := h.svc.DeleteComment(ctx, id, hard); err != nil {
+		if errors.Is(err, customerrors.ErrValidation) || errors.Is(err, customerrors.ErrForbidden) || errors.Is(err, customerrors.ErrNotFound) || errors.Is(err, customerrors.ErrUnauthorized) {
+			return false, err
+		}
+		return false, fmt.Errorf("ошибка удаления комментария: %w", err)
+	}
+	return true, nil
+}
+
+// создает комментарий к посту от имени текущего пользователя
+func (h *Handler) CreateComment(ctx context.Context, postID, text string, parentID *string) (*models.Comment, error) {
 	comm := &models.Comment{
 		ID:        uuid.NewString(),
 		PostID:    postID,
 		ParentID:  parentID,
-		AuthorID:  authorID,
 		Text:      text,
 		CreatedAt: time.Now().UTC(),
 	}
 
 	err := h.svc.CreateComment(ctx, comm)
 	if err != nil {
-		if errors.Is(err, customerrors.ErrValidation) || errors.Is(err, customerrors.ErrCommForbidden) || errors.Is(err, customerrors.ErrNotFound) {
+		if errors.Is(err, customerrors.ErrValidation) || errors.Is(err, customerrors.ErrCommForbidden) || errors.Is(err, customerrors.ErrNotFound) || errors.Is(err, customerrors.ErrUnauthorized) {
 			return nil, err
 		}
 		return nil, fmt.Errorf("ошибка при создании комментария: %w", err)
@@ -153,3 +333,242 @@ func (h *Handler) CreateComment(ctx context.Context, postID, text, authorID stri
 
 	return comm, nil
 }
+
+// подписка на новые комментарии поста
+func (h *Handler) CommentAdded(ctx context.Context, postID string) (<-chan *models.Comment, error) {
+	comments, err := h.svc.SubscribeCommentAdded(ctx, postID)
+	if err != nil {
+		if errors.Is(err, customerrors.ErrValidation) || errors.Is(err, customerrors.ErrNotFound) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("не удалось подписаться на комментарии: %w", err)
+	}
+	return comments, nil
+}
+
+// пакетно возвращает пользователей
+func (h *Handler) GetUsersByIDs(ctx context.Context, ids []string) ([]*models.User, error) {
+	users, err := h.svc.GetUsersByIDs(ctx, ids)
+	if err != nil {
+		if errors.Is(err, customerrors.ErrValidation) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("не удалось получить пользователей: %w", err)
+	}
+	return users, nil
+}
+
+// пакетно возвращает посты
+func (h *Handler) GetPostsByIDs(ctx context.Context, ids []string) ([]*models.Post, error) {
+	posts, err := h.svc.GetPostsByIDs(ctx, ids)
+	if err != nil {
+		if errors.Is(err, customerrors.ErrValidation) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("не удалось получить посты: %w", err)
+	}
+	return posts, nil
+}
+
+// пакетно возвращает ответы для нескольких комментариев
+func (h *Handler) ListRepliesByParentIDs(ctx context.Context, parentIDs []string, sort models.CommentSort, offset, limit int) (map[string][]*models.Comment, error) {
+	replies, err := h.svc.ListRepliesByParentIDs(ctx, parentIDs, sort, offset, limit)
+	if err != nil {
+		if errors.Is(err, customerrors.ErrValidation) || errors.Is(err, customerrors.ErrParamOutOfRange) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("не удалось получить ответы: %w", err)
+	}
+	return replies, nil
+}
+
+// ставит реакцию на пост или комментарий и возвращает новый агрегат
+func (h *Handler) SetReaction(ctx context.Context, target models.ReactionTarget, targetID string, kind models.ReactionKind) (*models.ReactionSummary, error) {
+	summary, err := h.svc.SetReaction(ctx, target, targetID, kind)
+	if err != nil {
+		if errors.Is(err, customerrors.ErrValidation) || errors.Is(err, customerrors.ErrForbidden) || errors.Is(err, customerrors.ErrNotFound) || errors.Is(err, customerrors.ErrUnauthorized) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("не удалось поставить реакцию: %w", err)
+	}
+	return summary, nil
+}
+
+// снимает реакцию и возвращает новый агрегат
+func (h *Handler) RemoveReaction(ctx context.Context, target models.ReactionTarget, targetID string) (*models.ReactionSummary, error) {
+	summary, err := h.svc.RemoveReaction(ctx, target, targetID)
+	if err != nil {
+		if errors.Is(err, customerrors.ErrValidation) || errors.Is(err, customerrors.ErrNotFound) || errors.Is(err, customerrors.ErrUnauthorized) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("не удалось снять реакцию: %w", err)
+	}
+	return summary, nil
+}
+
+// пакетное получение агрегатов реакций, используется загрузчиками graphql
+func (h *Handler) GetReactionSummaries(ctx context.Context, target models.ReactionTarget, ids []string) (map[string]*models.ReactionSummary, error) {
+	summaries, err := h.svc.GetReactionSummaries(ctx, target, ids)
+	if err != nil {
+		if errors.Is(err, customerrors.ErrValidation) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("не удалось получить реакции: %w", err)
+	}
+	return summaries, nil
+}
+
+// пакетное получение реакций текущего пользователя
+func (h *Handler) GetMyReactions(ctx context.Context, target models.ReactionTarget, ids []string) (map[string]models.ReactionKind, error) {
+	reactions, err := h.svc.GetMyReactions(ctx, target, ids)
+	if err != nil {
+		if errors.Is(err, customerrors.ErrValidation) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("не удалось получить реакции пользователя: %w", err)
+	}
+	return reactions, nil
+}
+
+// пакетное получение упоминаний
+func (h *Handler) GetMentions(ctx context.Context, target models.ReactionTarget, ids []string) (map[string][]models.Mention, error) {
+	mentions, err := h.svc.GetMentions(ctx, target, ids)
+	if err != nil {
+		if errors.Is(err, customerrors.ErrValidation) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("не удалось получить упоминания: %w", err)
+	}
+	return mentions, nil
+}
+
+// полнотекстовый поиск по постам и комментариям
+func (h *Handler) Search(ctx context.Context, query string, searchType models.SearchType, first int, after *string) (*models.SearchConnection, error) {
+	conn, err := h.svc.Search(ctx, query, searchType, first, after)
+	if err != nil {
+		if errors.Is(err, customerrors.ErrValidation) || errors.Is(err, customerrors.ErrParamOutOfRange) || errors.Is(err, customerrors.ErrInvalidCursor) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("не удалось выполнить поиск: %w", err)
+	}
+	return conn, nil
+}
+
+// возвращает страницу уведомлений текущего пользователя
+func (h *Handler) Notifications(ctx context.Context, first int, after *string, unreadOnly bool) (*models.NotificationConnection, error) {
+	conn, err := h.svc.ListNotifications(ctx, first, after, unreadOnly)
+	if err != nil {
+		if errors.Is(err, customerrors.ErrUnauthorized) || errors.Is(err, customerrors.ErrParamOutOfRange) || errors.Is(err, customerrors.ErrInvalidCursor) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("не удалось получить уведомления: %w", err)
+	}
+	return conn, nil
+}
+
+// отмечает прочитанными уведомления из списка
+func (h *Handler) MarkNotificationsRead(ctx context.Context, ids []string) (int, error) {
+	marked, err := h.svc.MarkNotificationsRead(ctx, ids)
+	if err != nil {
+		if errors.Is(err, customerrors.ErrUnauthorized) || errors.Is(err, customerrors.ErrValidation) || errors.Is(err, customerrors.ErrParamOutOfRange) {
+			return 0, err
+		}
+		return 0, fmt.Errorf("не удалось отметить уведомления: %w", err)
+	}
+	return marked, nil
+}
+
+// отмечает прочитанными все уведомления
+func (h *Handler) MarkAllNotificationsRead(ctx context.Context) (int, error) {
+	marked, err := h.svc.MarkAllNotificationsRead(ctx)
+	if err != nil {
+		if errors.Is(err, customerrors.ErrUnauthorized) {
+			return 0, err
+		}
+		return 0, fmt.Errorf("не удалось отметить уведомления: %w", err)
+	}
+	return marked, nil
+}
+
+// подписка на новые уведомления текущего пользователя
+func (h *Handler) NotificationAdded(ctx context.Context) (<-chan *models.Notification, error) {
+	notifications, err := h.svc.SubscribeNotifications(ctx)
+	if err != nil {
+		if errors.Is(err, customerrors.ErrUnauthorized) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("не удалось подписаться на уведомления: %w", err)
+	}
+	return notifications, nil
+}
+
+// создает вебхук, ключ подписи возвращается только здесь
+func (h *Handler) CreateWebhook(ctx context.Context, url string, events []models.WebhookEvent, secret string) (*models.Webhook, error) {
+	webhook, err := h.svc.CreateWebhook(ctx, url, events, secret)
+	if err != nil {
+		if errors.Is(err, customerrors.ErrValidation) || errors.Is(err, customerrors.ErrForbidden) || errors.Is(err, customerrors.ErrUnauthorized) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("не удалось создать вебхук: %w", err)
+	}
+	return webhook, nil
+}
+
+// список вебхуков
+func (h *Handler) ListWebhooks(ctx context.Context) ([]*models.Webhook, error) {
+	webhooks, err := h.svc.ListWebhooks(ctx)
+	if err != nil {
+		if errors.Is(err, customerrors.ErrForbidden) || errors.Is(err, customerrors.ErrUnauthorized) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("не удалось получить вебхуки: %w", err)
+	}
+	return webhooks, nil
+}
+
+// включает или отключает вебхук
+func (h *Handler) SetWebhookActive(ctx context.Context, id string, active bool) (*models.Webhook, error) {
+	webhook, err := h.svc.SetWebhookActive(ctx, id, active)
+	if err != nil {
+		if errors.Is(err, customerrors.ErrValidation) || errors.Is(err, customerrors.ErrForbidden) || errors.Is(err, customerrors.ErrUnauthorized) || errors.Is(err, customerrors.ErrNotFound) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("не удалось изменить вебхук: %w", err)
+	}
+	return webhook, nil
+}
+
+// удаляет вебхук вместе с историей доставок
+func (h *Handler) DeleteWebhook(ctx context.Context, id string) error {
+	if err := h.svc.DeleteWebhook(ctx, id); err != nil {
+		if errors.Is(err, customerrors.ErrValidation) || errors.Is(err, customerrors.ErrForbidden) || errors.Is(err, customerrors.ErrUnauthorized) || errors.Is(err, customerrors.ErrNotFound) {
+			return err
+		}
+		return fmt.Errorf("не удалось удалить вебхук: %w", err)
+	}
+	return nil
+}
+
+// история доставок вебхука
+func (h *Handler) ListWebhookDeliveries(ctx context.Context, webhookID string, status models.WebhookDeliveryStatus, offset, limit int) ([]*models.WebhookDelivery, error) {
+	deliveries, err := h.svc.ListWebhookDeliveries(ctx, webhookID, status, offset, limit)
+	if err != nil {
+		if errors.Is(err, customerrors.ErrValidation) || errors.Is(err, customerrors.ErrForbidden) || errors.Is(err, customerrors.ErrUnauthorized) || errors.Is(err, customerrors.ErrParamOutOfRange) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("не удалось получить доставки вебхука: %w", err)
+	}
+	return deliveries, nil
+}
+
+// возвращает исчерпавшую попытки доставку в очередь
+func (h *Handler) RetryWebhookDelivery(ctx context.Context, id string) (*models.WebhookDelivery, error) {
+	delivery, err := h.svc.RetryWebhookDelivery(ctx, id)
+	if err != nil {
+		if errors.Is(err, customerrors.ErrValidation) || errors.Is(err, customerrors.ErrForbidden) || errors.Is(err, customerrors.ErrUnauthorized) || errors.Is(err, customerrors.ErrNotFound) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("не удалось повторить доставку: %w", err)
+	}
+	return delivery, nil
+}
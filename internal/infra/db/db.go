@@ -8,14 +8,27 @@ import (
 	"github.com/MAPiryazev/OzonTest/internal/repository"
 	"github.com/MAPiryazev/OzonTest/internal/repository/inmemory"
 	"github.com/MAPiryazev/OzonTest/internal/repository/postgres"
+	"github.com/MAPiryazev/OzonTest/internal/repository/sqlite"
 )
 
-// инициализирует хранилище по режиму: memory или postgres
+// инициализирует хранилище по режиму: memory, postgres или sqlite
 func InitStorage(mode string) (repository.Storage, error) {
 	switch mode {
 	case "memory":
-		log.Println("Используется in-memory хранилище")
-		return inmemory.NewMemoryStorage(), nil
+		cfg, err := config.LoadMemoryConfig()
+		if err != nil {
+			return nil, err
+		}
+		if cfg.WALPath == "" {
+			log.Println("Используется in-memory хранилище")
+			return inmemory.NewMemoryStorage(), nil
+		}
+		strg, err := inmemory.OpenMemoryStorage(cfg)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("Используется in-memory хранилище с журналом %s", cfg.WALPath)
+		return strg, nil
 	case "postgres":
 		cfg, err := config.LoadDBConfig()
 		if err != nil {
@@ -27,6 +40,17 @@ func InitStorage(mode string) (repository.Storage, error) {
 		}
 		log.Println("Используется Postgres хранилище")
 		return strg, nil
+	case "sqlite":
+		cfg, err := config.LoadSQLiteConfig()
+		if err != nil {
+			return nil, err
+		}
+		strg, err := sqlite.NewSQLiteStorage(cfg)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("Используется SQLite хранилище %s", cfg.Path)
+		return strg, nil
 	default:
 		return nil, fmt.Errorf("неверный режим хранения: %s", mode)
 	}
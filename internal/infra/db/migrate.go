@@ -0,0 +1,49 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/MAPiryazev/OzonTest/internal/config"
+	"github.com/MAPiryazev/OzonTest/internal/migrate"
+	"github.com/MAPiryazev/OzonTest/internal/repository/postgres"
+	"github.com/MAPiryazev/OzonTest/internal/repository/sqlite"
+)
+
+// открывает БД режима mode для команды migrate, соединение закрывает вызывающий
+func InitMigrator(mode string) (*migrate.Migrator, *sql.DB, error) {
+	var (
+		conn       *sql.DB
+		newMigrate func(*sql.DB) (*migrate.Migrator, error)
+		err        error
+	)
+
+	switch mode {
+	case "postgres":
+		cfg, cfgErr := config.LoadDBConfig()
+		if cfgErr != nil {
+			return nil, nil, cfgErr
+		}
+		conn, err = postgres.OpenDB(cfg)
+		newMigrate = postgres.NewMigrator
+	case "sqlite":
+		cfg, cfgErr := config.LoadSQLiteConfig()
+		if cfgErr != nil {
+			return nil, nil, cfgErr
+		}
+		conn, err = sqlite.OpenDB(cfg)
+		newMigrate = sqlite.NewMigrator
+	default:
+		return nil, nil, fmt.Errorf("в режиме хранения %s нет схемы для миграций", mode)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	migrator, err := newMigrate(conn)
+	if err != nil {
+		_ = conn.Close()
+		return nil, nil, err
+	}
+	return migrator, conn, nil
+}
@@ -0,0 +1,93 @@
+package loaders
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// функция пакетной загрузки: по списку ключей возвращает найденные значения
+type batchFunc[K comparable, V any] func(ctx context.Context, keys []K) (map[K]V, error)
+
+type batch[K comparable, V any] struct {
+	keys    []K
+	done    chan struct{}
+	results map[K]V
+	err     error
+}
+
+// собирает Load вызовы за короткое окно в один запрос и кеширует результат в рамках запроса
+type Loader[K comparable, V any] struct {
+	ctx      context.Context
+	fetch    batchFunc[K, V]
+	wait     time.Duration
+	maxBatch int
+
+	mu      sync.Mutex
+	current *batch[K, V]
+	cache   map[K]*batch[K, V]
+}
+
+func newLoader[K comparable, V any](ctx context.Context, fetch batchFunc[K, V], wait time.Duration, maxBatch int) *Loader[K, V] {
+	return &Loader[K, V]{
+		ctx:      ctx,
+		fetch:    fetch,
+		wait:     wait,
+		maxBatch: maxBatch,
+		cache:    make(map[K]*batch[K, V]),
+	}
+}
+
+// возвращает значение по ключу, если значение не найдено - нулевое значение без ошибки
+func (l *Loader[K, V]) Load(ctx context.Context, key K) (V, error) {
+	l.mu.Lock()
+	b, ok := l.cache[key]
+	if !ok {
+		if l.current == nil {
+			l.current = &batch[K, V]{done: make(chan struct{})}
+			go l.dispatchAfterWait(l.current)
+		}
+		b = l.current
+		b.keys = append(b.keys, key)
+		l.cache[key] = b
+
+		// пачка заполнена - отправляем сразу, не дожидаясь таймера
+		if len(b.keys) >= l.maxBatch {
+			l.current = nil
+			go l.run(b)
+		}
+	}
+	l.mu.Unlock()
+
+	var zero V
+	select {
+	case <-b.done:
+	case <-ctx.Done():
+		return zero, ctx.Err()
+	}
+
+	if b.err != nil {
+		return zero, b.err
+	}
+	return b.results[key], nil
+}
+
+func (l *Loader[K, V]) dispatchAfterWait(b *batch[K, V]) {
+	time.Sleep(l.wait)
+
+	l.mu.Lock()
+	if l.current != b {
+		// уже отправлена по переполнению
+		l.mu.Unlock()
+		return
+	}
+	l.current = nil
+	l.mu.Unlock()
+
+	l.run(b)
+}
+
+func (l *Loader[K, V]) run(b *batch[K, V]) {
+	b.results, b.err = l.fetch(l.ctx, b.keys)
+	close(b.done)
+}
@@ -0,0 +1,161 @@
+package loaders
+
+import (
+	"context"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+
+	"github.com/MAPiryazev/OzonTest/internal/handler"
+	"github.com/MAPiryazev/OzonTest/internal/models"
+)
+
+const (
+	// сколько ждать остальные ключи перед отправкой пачки
+	batchWait = 2 * time.Millisecond
+	maxBatch  = 100
+)
+
+type ctxKey struct{}
+
+// ключ для ответов: страница считается для каждого родителя отдельно
+type RepliesKey struct {
+	ParentID string
+	Sort     models.CommentSort
+	Offset   int
+	Limit    int
+}
+
+// ключ для реакций и упоминаний: посты и комментарии загружаются отдельными запросами
+type ReactionKey struct {
+	Target models.ReactionTarget
+	ID     string
+}
+
+// загрузчики живут в рамках одного ответа graphql
+type Loaders struct {
+	Users     *Loader[string, *models.User]
+	Posts     *Loader[string, *models.Post]
+	Replies   *Loader[RepliesKey, []*models.Comment]
+	Reactions *Loader[ReactionKey, *models.ReactionSummary]
+	// реакции пользователя из контекста запроса
+	MyReactions *Loader[ReactionKey, models.ReactionKind]
+	Mentions    *Loader[ReactionKey, []models.Mention]
+}
+
+func NewLoaders(ctx context.Context, h *handler.Handler) *Loaders {
+	return &Loaders{
+		Users:       newLoader(ctx, usersBatch(h), batchWait, maxBatch),
+		Posts:       newLoader(ctx, postsBatch(h), batchWait, maxBatch),
+		Replies:     newLoader(ctx, repliesBatch(h), batchWait, maxBatch),
+		Reactions:   newLoader(ctx, targetBatch(h.GetReactionSummaries), batchWait, maxBatch),
+		MyReactions: newLoader(ctx, targetBatch(h.GetMyReactions), batchWait, maxBatch),
+		Mentions:    newLoader(ctx, targetBatch(h.GetMentions), batchWait, maxBatch),
+	}
+}
+
+// расширение gqlgen, которое кладет новые загрузчики в контекст каждого ответа: запроса, мутации
+// и каждого события подписки. контекст http запроса для websocket живет все соединение,
+// и загрузчики в нем отдавали бы закешированные данные всем последующим событиям
+type Extension struct {
+	handler *handler.Handler
+}
+
+func NewExtension(h *handler.Handler) Extension {
+	return Extension{handler: h}
+}
+
+func (Extension) ExtensionName() string {
+	return "Loaders"
+}
+
+func (Extension) Validate(graphql.ExecutableSchema) error {
+	return nil
+}
+
+func (e Extension) InterceptResponse(ctx context.Context, next graphql.ResponseHandler) *graphql.Response {
+	return next(context.WithValue(ctx, ctxKey{}, NewLoaders(ctx, e.handler)))
+}
+
+// возвращает загрузчики из контекста или nil, если расширение не подключено
+func For(ctx context.Context) *Loaders {
+	l, _ := ctx.Value(ctxKey{}).(*Loaders)
+	return l
+}
+
+func usersBatch(h *handler.Handler) batchFunc[string, *models.User] {
+	return func(ctx context.Context, ids []string) (map[string]*models.User, error) {
+		users, err := h.GetUsersByIDs(ctx, ids)
+		if err != nil {
+			return nil, err
+		}
+		res := make(map[string]*models.User, len(users))
+		for _, u := range users {
+			res[u.ID] = u
+		}
+		return res, nil
+	}
+}
+
+func postsBatch(h *handler.Handler) batchFunc[string, *models.Post] {
+	return func(ctx context.Context, ids []string) (map[string]*models.Post, error) {
+		posts, err := h.GetPostsByIDs(ctx, ids)
+		if err != nil {
+			return nil, err
+		}
+		res := make(map[string]*models.Post, len(posts))
+		for _, p := range posts {
+			res[p.ID] = p
+		}
+		return res, nil
+	}
+}
+
+// ключи с разной пагинацией уходят отдельными запросами, обычно такая группа одна
+func repliesBatch(h *handler.Handler) batchFunc[RepliesKey, []*models.Comment] {
+	return func(ctx context.Context, keys []RepliesKey) (map[RepliesKey][]*models.Comment, error) {
+		type page struct {
+			sort          models.CommentSort
+			offset, limit int
+		}
+		groups := make(map[page][]string)
+		for _, k := range keys {
+			p := page{k.Sort, k.Offset, k.Limit}
+			groups[p] = append(groups[p], k.ParentID)
+		}
+
+		res := make(map[RepliesKey][]*models.Comment, len(keys))
+		for p, parentIDs := range groups {
+			replies, err := h.ListRepliesByParentIDs(ctx, parentIDs, p.sort, p.offset, p.limit)
+			if err != nil {
+				return nil, err
+			}
+			for parentID, comments := range replies {
+				res[RepliesKey{ParentID: parentID, Sort: p.sort, Offset: p.offset, Limit: p.limit}] = comments
+			}
+		}
+		return res, nil
+	}
+}
+
+// группирует ключи по виду объекта и загружает каждую группу одним запросом
+func targetBatch[V any](fetch func(ctx context.Context, target models.ReactionTarget, ids []string) (map[string]V, error)) batchFunc[ReactionKey, V] {
+	return func(ctx context.Context, keys []ReactionKey) (map[ReactionKey]V, error) {
+		groups := make(map[models.ReactionTarget][]string)
+		for _, k := range keys {
+			groups[k.Target] = append(groups[k.Target], k.ID)
+		}
+
+		res := make(map[ReactionKey]V, len(keys))
+		for target, ids := range groups {
+			values, err := fetch(ctx, target, ids)
+			if err != nil {
+				return nil, err
+			}
+			for id, v := range values {
+				res[ReactionKey{Target: target, ID: id}] = v
+			}
+		}
+		return res, nil
+	}
+}
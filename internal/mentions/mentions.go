@@ -0,0 +1,74 @@
+package mentions
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// разбор упоминаний @username в тексте постов и комментариев
+
+// больше упоминаний в одном тексте не учитывается, чтобы текст не стал рассылкой уведомлений
+const MaxMentions = 20
+
+// символы, из которых может состоять упоминание; точка и дефис не могут быть последними,
+// чтобы "@vasya." в конце предложения читалось как vasya
+func isNameRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '.' || r == '-'
+}
+
+// имя, которое Parse прочитает из "@name" целиком: только символы упоминания и без точки или дефиса в конце
+func ValidName(name string) bool {
+	if name == "" || strings.TrimRight(name, ".-") != name {
+		return false
+	}
+	for _, r := range name {
+		if !isNameRune(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// имена из упоминаний в порядке первого появления, без повторов;
+// @ внутри слова (например, в адресе почты) упоминанием не считается
+func Parse(text string) []string {
+	var names []string
+	seen := make(map[string]struct{})
+
+	prev := ' '
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		if r != '@' || isNameRune(prev) || prev == '@' {
+			prev = r
+			i += size
+			continue
+		}
+
+		end := i + size
+		for end < len(text) {
+			next, nextSize := utf8.DecodeRuneInString(text[end:])
+			if !isNameRune(next) {
+				break
+			}
+			end += nextSize
+		}
+		name := strings.TrimRight(text[i+size:end], ".-")
+
+		if name != "" {
+			if _, ok := seen[name]; !ok {
+				seen[name] = struct{}{}
+				names = append(names, name)
+				if len(names) == MaxMentions {
+					return names
+				}
+			}
+		}
+		prev = '@'
+		if end > i+size {
+			prev, _ = utf8.DecodeLastRuneInString(text[:end])
+		}
+		i = end
+	}
+	return names
+}
@@ -0,0 +1,248 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/MAPiryazev/OzonTest/internal/customerrors"
+)
+
+// версионные миграции схемы: файлы <версия>_<имя>.up.sql / .down.sql и таблица schema_migrations
+
+// диалект определяет только формат плейсхолдеров
+type Dialect string
+
+const (
+	Postgres Dialect = "postgres"
+	SQLite   Dialect = "sqlite"
+)
+
+func (d Dialect) arg(n int) string {
+	if d == Postgres {
+		return "$" + strconv.Itoa(n)
+	}
+	return "?"
+}
+
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+type Status struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	AppliedAt *time.Time
+}
+
+var fileName = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// читает миграции из каталога dir, у каждой версии обязателен up файл
+func Load(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("%w: не удалось прочитать каталог %s: %v", customerrors.ErrMigration, dir, err)
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		match := fileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("%w: некорректное имя файла миграции %s", customerrors.ErrMigration, entry.Name())
+		}
+
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("%w: некорректная версия в файле %s", customerrors.ErrMigration, entry.Name())
+		}
+		body, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("%w: не удалось прочитать %s: %v", customerrors.ErrMigration, entry.Name(), err)
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		}
+		if m.Name != match[2] {
+			return nil, fmt.Errorf("%w: у версии %d разные имена: %s и %s", customerrors.ErrMigration, version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			m.Up = string(body)
+		} else {
+			m.Down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("%w: у версии %d нет up файла", customerrors.ErrMigration, m.Version)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+type Migrator struct {
+	db         *sql.DB
+	dialect    Dialect
+	migrations []Migration
+}
+
+func New(db *sql.DB, dialect Dialect, migrations []Migration) *Migrator {
+	return &Migrator{db: db, dialect: dialect, migrations: migrations}
+}
+
+func (m *Migrator) ensureTable(ctx context.Context) error {
+	query := `create table if not exists schema_migrations(
+		version bigint primary key,
+		name varchar(255) not null,
+		applied_at timestamp not null default current_timestamp
+	)`
+	if _, err := m.db.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("%w: не удалось создать schema_migrations: %v", customerrors.ErrMigration, err)
+	}
+	return nil
+}
+
+func (m *Migrator) applied(ctx context.Context) (map[int64]time.Time, error) {
+	if err := m.ensureTable(ctx); err != nil {
+		return nil, err
+	}
+
+	rows, err := m.db.QueryContext(ctx, `select version, applied_at from schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("%w: не удалось прочитать schema_migrations: %v", customerrors.ErrMigration, err)
+	}
+	defer rows.Close()
+
+	result := make(map[int64]time.Time)
+	for rows.Next() {
+		var version int64
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, fmt.Errorf("%w: %v", customerrors.ErrDBScan, err)
+		}
+		result[version] = appliedAt
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %v", customerrors.ErrDBScan, err)
+	}
+	return result, nil
+}
+
+// миграции, которые еще не применены, по возрастанию версии
+func (m *Migrator) Pending(ctx context.Context) ([]Migration, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	pending := []Migration{}
+	for _, mig := range m.migrations {
+		if _, ok := applied[mig.Version]; !ok {
+			pending = append(pending, mig)
+		}
+	}
+	return pending, nil
+}
+
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, 0, len(m.migrations))
+	for _, mig := range m.migrations {
+		st := Status{Version: mig.Version, Name: mig.Name}
+		if at, ok := applied[mig.Version]; ok {
+			st.Applied = true
+			st.AppliedAt = &at
+		}
+		statuses = append(statuses, st)
+	}
+	return statuses, nil
+}
+
+// применяет все неприменённые миграции, каждую в своей транзакции, возвращает их количество
+func (m *Migrator) Up(ctx context.Context) (int, error) {
+	pending, err := m.Pending(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	insert := fmt.Sprintf(`insert into schema_migrations (version, name) values (%s, %s)`, m.dialect.arg(1), m.dialect.arg(2))
+	for i, mig := range pending {
+		err := m.inTx(ctx, mig, mig.Up, insert, mig.Version, mig.Name)
+		if err != nil {
+			return i, err
+		}
+	}
+	return len(pending), nil
+}
+
+// откатывает steps последних применённых миграций
+func (m *Migrator) Down(ctx context.Context, steps int) (int, error) {
+	if steps <= 0 {
+		return 0, fmt.Errorf("%w: количество шагов отката должно быть положительным", customerrors.ErrParamOutOfRange)
+	}
+
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	remove := fmt.Sprintf(`delete from schema_migrations where version = %s`, m.dialect.arg(1))
+	done := 0
+	for i := len(m.migrations) - 1; i >= 0 && done < steps; i-- {
+		mig := m.migrations[i]
+		if _, ok := applied[mig.Version]; !ok {
+			continue
+		}
+		if mig.Down == "" {
+			return done, fmt.Errorf("%w: у версии %d нет down файла", customerrors.ErrMigration, mig.Version)
+		}
+		if err := m.inTx(ctx, mig, mig.Down, remove, mig.Version); err != nil {
+			return done, err
+		}
+		done++
+	}
+	return done, nil
+}
+
+// выполняет тело миграции и запись в schema_migrations атомарно
+func (m *Migrator) inTx(ctx context.Context, mig Migration, body, bookkeeping string, args ...any) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%w: не удалось начать транзакцию: %v", customerrors.ErrMigration, err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, body); err != nil {
+		return fmt.Errorf("%w: версия %d (%s): %v", customerrors.ErrMigration, mig.Version, mig.Name, err)
+	}
+	if _, err := tx.ExecContext(ctx, bookkeeping, args...); err != nil {
+		return fmt.Errorf("%w: версия %d: не удалось обновить schema_migrations: %v", customerrors.ErrMigration, mig.Version, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%w: версия %d: %v", customerrors.ErrMigration, mig.Version, err)
+	}
+	return nil
+}
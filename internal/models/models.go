@@ -4,25 +4,341 @@ import (
 	"time"
 )
 
+// роли по возрастанию прав
+type Role string
+
+const (
+	RoleUser      Role = "user"
+	RoleModerator Role = "moderator"
+	RoleAdmin     Role = "admin"
+)
+
+// PasswordHash и состояние блокировки не отдаются наружу, json теги нужны для журнала in-memory хранилища
 type User struct {
-	ID       string `json:"id"`
-	Username string `json:"username"`
+	ID           string     `json:"id"`
+	Username     string     `json:"username"`
+	Role         Role       `json:"role,omitempty"`
+	PasswordHash string     `json:"passwordHash,omitempty"`
+	FailedLogins int        `json:"failedLogins,omitempty"`
+	LockedUntil  *time.Time `json:"lockedUntil,omitempty"`
+}
+
+// результат входа: токен и пользователь, которому он выдан
+type AuthPayload struct {
+	Token     string
+	ExpiresAt time.Time
+	User      *User
 }
 
 type Post struct {
-	ID              string    `json:"id"`
-	Title           string    `json:"title"`
-	Content         string    `json:"content"`
-	AuthorID        string    `json:"authorId"`
-	CommentsEnabled bool      `json:"commentsEnabled"`
-	CreatedAt       time.Time `json:"createdAt"`
+	ID              string     `json:"id"`
+	Title           string     `json:"title"`
+	Content         string     `json:"content"`
+	AuthorID        string     `json:"authorId"`
+	CommentsEnabled bool       `json:"commentsEnabled"`
+	CreatedAt       time.Time  `json:"createdAt"`
+	EditedAt        *time.Time `json:"editedAt,omitempty"`
+	DeletedAt       *time.Time `json:"deletedAt,omitempty"`
 }
 
-type Comment struct {
+// предыдущая версия поста, сохраняется при каждом изменении заголовка или текста
+type PostRevision struct {
 	ID        string    `json:"id"`
 	PostID    string    `json:"postId"`
-	ParentID  *string   `json:"parentId"`
-	AuthorID  string    `json:"authorId"`
+	Title     string    `json:"title"`
+	Content   string    `json:"content"`
+	EditorID  string    `json:"editorId"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// условия выборки постов, nil - условие не применяется; CreatedAfter включительно, CreatedBefore нет
+type PostFilter struct {
+	AuthorID        *string
+	CreatedAfter    *time.Time
+	CreatedBefore   *time.Time
+	CommentsEnabled *bool
+	// есть хотя бы один неудаленный комментарий
+	HasComments *bool
+}
+
+// порядок списка постов, при равенстве ключа более новые посты идут первыми
+type PostSort string
+
+const (
+	PostSortNewest PostSort = "newest"
+	PostSortOldest PostSort = "oldest"
+	// по числу неудаленных комментариев
+	PostSortMostCommented PostSort = "most_commented"
+	// по времени последнего комментария, пост без комментариев - по времени создания
+	PostSortRecentlyActive PostSort = "recently_active"
+)
+
+// частичное обновление поста, nil - поле не меняется
+type PostPatch struct {
+	Title           *string
+	Content         *string
+	CommentsEnabled *bool
+}
+
+type Comment struct {
+	ID        string     `json:"id"`
+	PostID    string     `json:"postId"`
+	ParentID  *string    `json:"parentId"`
+	AuthorID  string     `json:"authorId"`
+	Text      string     `json:"text"`
+	CreatedAt time.Time  `json:"createdAt"`
+	EditedAt  *time.Time `json:"editedAt,omitempty"`
+	DeletedAt *time.Time `json:"deletedAt,omitempty"`
+}
+
+// порядок выдачи комментариев одного уровня, при равенстве ключа порядок задают created_at и id
+type CommentSort string
+
+const (
+	// по времени создания, от старых к новым - порядок по умолчанию
+	CommentSortOld CommentSort = "old"
+	CommentSortNew CommentSort = "new"
+	// по нижней границе интервала Уилсона для голосов up/down
+	CommentSortTop CommentSort = "top"
+	// много голосов за и против примерно поровну
+	CommentSortControversial CommentSort = "controversial"
+)
+
+// предыдущий текст комментария, CreatedAt - момент правки
+type CommentRevision struct {
+	ID        string    `json:"id"`
+	CommentID string    `json:"commentId"`
 	Text      string    `json:"text"`
+	EditorID  string    `json:"editorId"`
 	CreatedAt time.Time `json:"createdAt"`
 }
+
+// объект, к которому ставится реакция
+type ReactionTarget string
+
+const (
+	ReactionTargetPost    ReactionTarget = "post"
+	ReactionTargetComment ReactionTarget = "comment"
+)
+
+// голоса up/down и эмодзи, у пользователя одна реакция на объект
+type ReactionKind string
+
+const (
+	ReactionUp    ReactionKind = "up"
+	ReactionDown  ReactionKind = "down"
+	ReactionLike  ReactionKind = "like"
+	ReactionHeart ReactionKind = "heart"
+	ReactionLaugh ReactionKind = "laugh"
+	ReactionSad   ReactionKind = "sad"
+)
+
+// все виды реакций в порядке вывода
+var ReactionKinds = []ReactionKind{ReactionUp, ReactionDown, ReactionLike, ReactionHeart, ReactionLaugh, ReactionSad}
+
+type Reaction struct {
+	Target    ReactionTarget `json:"target"`
+	TargetID  string         `json:"targetId"`
+	UserID    string         `json:"userId"`
+	Kind      ReactionKind   `json:"kind"`
+	CreatedAt time.Time      `json:"createdAt"`
+}
+
+type ReactionCount struct {
+	Kind  ReactionKind
+	Count int
+}
+
+// агрегат реакций объекта, Score = up - down
+type ReactionSummary struct {
+	Score  int
+	Counts []ReactionCount
+}
+
+// упоминание @username, сопоставленное с пользователем
+type Mention struct {
+	UserID   string `json:"userId"`
+	Username string `json:"username"`
+}
+
+// позиция в keyset пагинации: сортировка идет по (created_at, id)
+type PageKey struct {
+	CreatedAt time.Time
+	ID        string
+}
+
+type PageInfo struct {
+	StartCursor     *string
+	EndCursor       *string
+	HasNextPage     bool
+	HasPreviousPage bool
+}
+
+type PostEdge struct {
+	Cursor string
+	Node   *Post
+}
+
+type PostConnection struct {
+	Edges    []*PostEdge
+	PageInfo PageInfo
+}
+
+type CommentEdge struct {
+	Cursor string
+	Node   *Comment
+}
+
+type CommentConnection struct {
+	Edges    []*CommentEdge
+	PageInfo PageInfo
+}
+
+// повод уведомления
+type NotificationKind string
+
+const (
+	// комментарий к посту получателя
+	NotificationPostComment NotificationKind = "post_comment"
+	// ответ на комментарий получателя
+	NotificationCommentReply NotificationKind = "comment_reply"
+	// упоминание получателя в посте или комментарии
+	NotificationMention NotificationKind = "mention"
+)
+
+// уведомление получателя UserID о действии ActorID, CommentID == nil для упоминания в посте
+type Notification struct {
+	ID        string           `json:"id"`
+	UserID    string           `json:"userId"`
+	Kind      NotificationKind `json:"kind"`
+	ActorID   string           `json:"actorId"`
+	PostID    string           `json:"postId"`
+	CommentID *string          `json:"commentId,omitempty"`
+	CreatedAt time.Time        `json:"createdAt"`
+	ReadAt    *time.Time       `json:"readAt,omitempty"`
+}
+
+type NotificationEdge struct {
+	Cursor string
+	Node   *Notification
+}
+
+// UnreadCount считается по всем уведомлениям пользователя, а не только по странице
+type NotificationConnection struct {
+	Edges       []*NotificationEdge
+	PageInfo    PageInfo
+	UnreadCount int
+}
+
+// комментарий в развернутом дереве, depth считается от корня выборки
+type ThreadComment struct {
+	Comment *Comment
+	Depth   int
+}
+
+type CommentThread struct {
+	Items     []*ThreadComment
+	Truncated bool
+}
+
+// по каким объектам идет поиск
+type SearchType string
+
+const (
+	SearchAll      SearchType = "all"
+	SearchPosts    SearchType = "post"
+	SearchComments SearchType = "comment"
+)
+
+// результат поиска: заполнен Post или Comment в зависимости от Type,
+// Snippet - фрагмент текста с совпадениями в маркерах <b></b>
+type SearchHit struct {
+	Type    SearchType
+	Post    *Post
+	Comment *Comment
+	Rank    float64
+	Snippet string
+}
+
+type SearchEdge struct {
+	Cursor string
+	Node   *SearchHit
+}
+
+type SearchConnection struct {
+	Edges    []*SearchEdge
+	PageInfo PageInfo
+}
+
+// событие, на которое подписывается вебхук
+type WebhookEvent string
+
+const (
+	WebhookPostCreated    WebhookEvent = "post_created"
+	WebhookPostUpdated    WebhookEvent = "post_updated"
+	WebhookCommentCreated WebhookEvent = "comment_created"
+	WebhookCommentUpdated WebhookEvent = "comment_updated"
+)
+
+// подписка внешнего сервиса, тело каждой доставки подписывается HMAC-SHA256 ключом Secret
+type Webhook struct {
+	ID        string         `json:"id"`
+	URL       string         `json:"url"`
+	Secret    string         `json:"secret"`
+	Events    []WebhookEvent `json:"events"`
+	Active    bool           `json:"active"`
+	CreatedBy string         `json:"createdBy"`
+	CreatedAt time.Time      `json:"createdAt"`
+}
+
+type WebhookDeliveryStatus string
+
+const (
+	// ждет первой или повторной попытки
+	WebhookDeliveryPending   WebhookDeliveryStatus = "pending"
+	WebhookDeliveryDelivered WebhookDeliveryStatus = "delivered"
+	// попытки исчерпаны, повторить можно только вручную
+	WebhookDeliveryDead WebhookDeliveryStatus = "dead"
+)
+
+// доставка одного события одному вебхуку, Payload - готовое json тело запроса
+type WebhookDelivery struct {
+	ID            string                `json:"id"`
+	WebhookID     string                `json:"webhookId"`
+	Event         WebhookEvent          `json:"event"`
+	Payload       string                `json:"payload"`
+	Status        WebhookDeliveryStatus `json:"status"`
+	Attempts      int                   `json:"attempts"`
+	NextAttemptAt time.Time             `json:"nextAttemptAt"`
+	LastError     string                `json:"lastError,omitempty"`
+	CreatedAt     time.Time             `json:"createdAt"`
+	DeliveredAt   *time.Time            `json:"deliveredAt,omitempty"`
+}
+
+// доменное событие, которое пишется в outbox вместе с изменением данных
+type EventType string
+
+const (
+	EventUserCreated    EventType = "user_created"
+	EventPostCreated    EventType = "post_created"
+	EventPostUpdated    EventType = "post_updated"
+	EventCommentCreated EventType = "comment_created"
+	EventCommentUpdated EventType = "comment_updated"
+)
+
+// событие outbox: Seq растет в порядке фиксации изменений и задает порядок доставки,
+// Payload - json объекта в том виде, в котором он сохранен
+type OutboxEvent struct {
+	Seq         int64     `json:"seq"`
+	Type        EventType `json:"type"`
+	AggregateID string    `json:"aggregateId"`
+	Payload     string    `json:"payload"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+// тело события user_created: только публичные поля, без хеша пароля и состояния входа
+type UserCreatedPayload struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+	Role     Role   `json:"role"`
+}
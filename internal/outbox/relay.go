@@ -0,0 +1,159 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/MAPiryazev/OzonTest/internal/config"
+	"github.com/MAPiryazev/OzonTest/internal/models"
+	"github.com/MAPiryazev/OzonTest/internal/repository"
+)
+
+// обработчик событий потребителя; ошибка означает, что событие нужно доставить еще раз,
+// поэтому обработчик должен быть идемпотентным. повторы ограничены OutboxConfig.MaxAttempts
+type Handler func(ctx context.Context, event *models.OutboxEvent) error
+
+type consumer struct {
+	name    string
+	handler Handler
+
+	// событие, на котором потребитель остановился с ошибкой, и число неудачных попыток подряд;
+	// счетчик живет в памяти и после перезапуска начинается заново
+	failedSeq int64
+	failures  int
+}
+
+// ретранслятор: читает outbox и передает события потребителям строго по возрастанию seq.
+// у каждого потребителя свое смещение в хранилище, оно сдвигается после успешной обработки события,
+// так что при ошибке или перезапуске событие придет повторно (at-least-once). событие, которое
+// не удалось обработать cfg.MaxAttempts раз подряд, пропускается с записью в лог, чтобы оно
+// не держало потребителя и очистку outbox
+type Relay struct {
+	storage   repository.Storage
+	cfg       *config.OutboxConfig
+	consumers []*consumer
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+	once sync.Once
+}
+
+func NewRelay(storage repository.Storage, cfg *config.OutboxConfig) *Relay {
+	return &Relay{storage: storage, cfg: cfg, stop: make(chan struct{})}
+}
+
+// регистрирует потребителя, вызывается до Start; имя служит ключом смещения и не должно меняться
+func (r *Relay) Register(name string, handler Handler) {
+	r.consumers = append(r.consumers, &consumer{name: name, handler: handler})
+}
+
+// запускает опрос outbox в отдельной горутине
+func (r *Relay) Start() {
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		ticker := time.NewTicker(time.Duration(r.cfg.PollIntervalMs) * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				// пока кто-то из потребителей получает полные пачки, читаем дальше без ожидания тика
+				for {
+					processed, err := r.RunOnce(context.Background())
+					if err != nil {
+						log.Printf("ошибка при разборе outbox: %v", err)
+					}
+					if err != nil || processed < r.cfg.BatchSize || r.stopped() {
+						break
+					}
+				}
+			case <-r.stop:
+				return
+			}
+		}
+	}()
+}
+
+// останавливает опрос и дожидается текущего прохода
+func (r *Relay) Stop() {
+	r.once.Do(func() { close(r.stop) })
+	r.wg.Wait()
+}
+
+func (r *Relay) stopped() bool {
+	select {
+	case <-r.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// один проход по всем потребителям, возвращает наибольшее число событий, обработанных одним потребителем;
+// после прохода удаляет события, которые обработали все потребители
+func (r *Relay) RunOnce(ctx context.Context) (int, error) {
+	if len(r.consumers) == 0 {
+		return 0, nil
+	}
+
+	processed := 0
+	var firstErr error
+	var minOffset int64 = -1
+	for _, c := range r.consumers {
+		n, offset, err := r.deliver(ctx, c)
+		processed = max(processed, n)
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+		if minOffset < 0 || offset < minOffset {
+			minOffset = offset
+		}
+	}
+
+	if minOffset > 0 {
+		if err := r.storage.PruneOutbox(ctx, minOffset); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return processed, firstErr
+}
+
+// передает потребителю пачку событий после его смещения; на первой ошибке останавливается,
+// чтобы следующие события не обогнали неудачное. возвращает число обработанных и итоговое смещение
+func (r *Relay) deliver(ctx context.Context, c *consumer) (int, int64, error) {
+	offset, err := r.storage.GetConsumerOffset(ctx, c.name)
+	if err != nil {
+		return 0, 0, err
+	}
+	events, err := r.storage.ListOutboxEvents(ctx, offset, r.cfg.BatchSize)
+	if err != nil {
+		return 0, offset, err
+	}
+
+	for i, event := range events {
+		if err := c.handler(ctx, event); err != nil {
+			if !r.giveUp(c, event.Seq) {
+				return i, offset, fmt.Errorf("потребитель %s, событие %d: %w", c.name, event.Seq, err)
+			}
+			log.Printf("потребитель %s пропускает событие %d после %d неудачных попыток: %v", c.name, event.Seq, r.cfg.MaxAttempts, err)
+		}
+		if err := r.storage.SetConsumerOffset(ctx, c.name, event.Seq); err != nil {
+			return i, offset, err
+		}
+		offset = event.Seq
+		c.failures = 0
+	}
+	return len(events), offset, nil
+}
+
+// считает неудачную попытку обработать событие seq и сообщает, пора ли его пропустить
+func (r *Relay) giveUp(c *consumer, seq int64) bool {
+	if c.failedSeq != seq {
+		c.failedSeq, c.failures = seq, 0
+	}
+	c.failures++
+	return r.cfg.MaxAttempts > 0 && c.failures >= r.cfg.MaxAttempts
+}
@@ -0,0 +1,89 @@
+package policy
+
+import (
+	"fmt"
+
+	"github.com/MAPiryazev/OzonTest/internal/auth"
+	"github.com/MAPiryazev/OzonTest/internal/customerrors"
+	"github.com/MAPiryazev/OzonTest/internal/models"
+)
+
+// единая политика доступа: сервисный слой спрашивает ее перед каждой мутацией
+
+type Action string
+
+const (
+	ActionCreatePost         Action = "create_post"
+	ActionUpdatePost         Action = "update_post"
+	ActionSetCommentsEnabled Action = "set_comments_enabled"
+	ActionCreateComment      Action = "create_comment"
+	ActionUpdateComment      Action = "update_comment"
+	ActionReact              Action = "react"
+	ActionDeletePost         Action = "delete_post"
+	ActionDeleteComment      Action = "delete_comment"
+	ActionPurge              Action = "purge"
+	ActionManageRoles        Action = "manage_roles"
+	ActionManageWebhooks     Action = "manage_webhooks"
+)
+
+// правило: минимальная роль для любого объекта и разрешено ли владельцу
+type rule struct {
+	minRole    models.Role
+	allowOwner bool
+}
+
+var rules = map[Action]rule{
+	ActionCreatePost:         {minRole: models.RoleUser},
+	ActionCreateComment:      {minRole: models.RoleUser},
+	ActionUpdateComment:      {minRole: models.RoleModerator, allowOwner: true},
+	ActionReact:              {minRole: models.RoleUser},
+	ActionUpdatePost:         {minRole: models.RoleModerator, allowOwner: true},
+	ActionSetCommentsEnabled: {minRole: models.RoleModerator, allowOwner: true},
+	ActionDeletePost:         {minRole: models.RoleModerator, allowOwner: true},
+	ActionDeleteComment:      {minRole: models.RoleModerator, allowOwner: true},
+	ActionPurge:              {minRole: models.RoleAdmin},
+	ActionManageRoles:        {minRole: models.RoleAdmin},
+	ActionManageWebhooks:     {minRole: models.RoleAdmin},
+}
+
+var rank = map[models.Role]int{
+	models.RoleUser:      1,
+	models.RoleModerator: 2,
+	models.RoleAdmin:     3,
+}
+
+func ValidRole(role models.Role) bool {
+	_, ok := rank[role]
+	return ok
+}
+
+// роль не ниже required, пустая роль считается обычным пользователем
+func HasRole(actor *auth.Identity, required models.Role) bool {
+	if actor == nil {
+		return false
+	}
+	role := actor.Role
+	if role == "" {
+		role = models.RoleUser
+	}
+	return rank[role] >= rank[required]
+}
+
+// ownerID - автор объекта, пустой для действий без владельца
+func Authorize(actor *auth.Identity, action Action, ownerID string) error {
+	if actor == nil {
+		return fmt.Errorf("%w: действие %s", customerrors.ErrUnauthorized, action)
+	}
+
+	r, ok := rules[action]
+	if !ok {
+		return fmt.Errorf("%w: неизвестное действие %s", customerrors.ErrForbidden, action)
+	}
+	if r.allowOwner && ownerID != "" && ownerID == actor.UserID {
+		return nil
+	}
+	if HasRole(actor, r.minRole) {
+		return nil
+	}
+	return fmt.Errorf("%w: недостаточно прав для %s", customerrors.ErrForbidden, action)
+}
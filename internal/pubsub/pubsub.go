@@ -0,0 +1,100 @@
+package pubsub
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/MAPiryazev/OzonTest/internal/customerrors"
+)
+
+// размер буфера канала одного подписчика, при переполнении сообщения отбрасываются
+const subscriberBuffer = 16
+
+type subscriber[T any] struct {
+	ch chan T
+}
+
+// in-process брокер событий: подписчики получают только сообщения своего топика
+type Broker[T any] struct {
+	mu     sync.RWMutex
+	topics map[string]map[*subscriber[T]]struct{}
+	closed bool
+}
+
+func NewBroker[T any]() *Broker[T] {
+	return &Broker[T]{topics: make(map[string]map[*subscriber[T]]struct{})}
+}
+
+// подписывает на топик, канал закрывается при отмене ctx или закрытии брокера
+func (b *Broker[T]) Subscribe(ctx context.Context, topic string) (<-chan T, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return nil, fmt.Errorf("%w: подписка на %s", customerrors.ErrBrokerClosed, topic)
+	}
+
+	sub := &subscriber[T]{ch: make(chan T, subscriberBuffer)}
+	if b.topics[topic] == nil {
+		b.topics[topic] = make(map[*subscriber[T]]struct{})
+	}
+	b.topics[topic][sub] = struct{}{}
+
+	go func() {
+		<-ctx.Done()
+		b.unsubscribe(topic, sub)
+	}()
+
+	return sub.ch, nil
+}
+
+// рассылает сообщение всем подписчикам топика, не блокируется на медленных подписчиках
+func (b *Broker[T]) Publish(topic string, msg T) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for sub := range b.topics[topic] {
+		select {
+		case sub.ch <- msg:
+		default:
+			log.Printf("подписчик топика %s не успевает читать, сообщение отброшено", topic)
+		}
+	}
+}
+
+// закрывает все подписки, после этого Subscribe возвращает ошибку
+func (b *Broker[T]) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return
+	}
+	b.closed = true
+	for topic, subs := range b.topics {
+		for sub := range subs {
+			close(sub.ch)
+		}
+		delete(b.topics, topic)
+	}
+}
+
+func (b *Broker[T]) unsubscribe(topic string, sub *subscriber[T]) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	subs, ok := b.topics[topic]
+	if !ok {
+		return
+	}
+	if _, ok := subs[sub]; !ok {
+		return
+	}
+	delete(subs, sub)
+	close(sub.ch)
+	if len(subs) == 0 {
+		delete(b.topics, topic)
+	}
+}
@@ -0,0 +1,28 @@
+package ranking
+
+import "math"
+
+// ранжирование комментариев по голосам up/down, те же формулы лежат в sql функциях миграций postgres
+
+// квантиль нормального распределения для доверия 95%
+const z = 1.96
+
+// нижняя граница доверительного интервала Уилсона для доли голосов up,
+// комментарий с 1 голосом из 1 оказывается ниже комментария с 90 из 100
+func WilsonLowerBound(up, down int) float64 {
+	n := float64(up + down)
+	if n == 0 {
+		return 0
+	}
+	p := float64(up) / n
+	return (p + z*z/(2*n) - z*math.Sqrt((p*(1-p)+z*z/(4*n))/n)) / (1 + z*z/n)
+}
+
+// спорность: много голосов и близкое к равному соотношение up и down, без голосов против - 0
+func Controversy(up, down int) float64 {
+	if up <= 0 || down <= 0 {
+		return 0
+	}
+	balance := float64(min(up, down)) / float64(max(up, down))
+	return math.Pow(float64(up+down), balance)
+}
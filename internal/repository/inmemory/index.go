@@ -0,0 +1,232 @@
+package inmemory
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/MAPiryazev/OzonTest/internal/customerrors"
+	"github.com/MAPiryazev/OzonTest/internal/models"
+	"github.com/MAPiryazev/OzonTest/internal/ranking"
+)
+
+// вторичные индексы in-memory хранилища, порядок совпадает с postgres:
+// посты по убыванию (created_at, id), комментарии внутри (post_id, parent_id) по возрастанию
+
+// группа комментариев одного поста с общим родителем, parentID == "" для верхнего уровня
+type commentGroup struct {
+	postID   string
+	parentID string
+}
+
+func groupOf(c *models.Comment) commentGroup {
+	g := commentGroup{postID: c.PostID}
+	if c.ParentID != nil {
+		g.parentID = *c.ParentID
+	}
+	return g
+}
+
+func groupFor(postID string, parentID *string) commentGroup {
+	g := commentGroup{postID: postID}
+	if parentID != nil {
+		g.parentID = *parentID
+	}
+	return g
+}
+
+func postKey(p *models.Post) models.PageKey {
+	return models.PageKey{CreatedAt: p.CreatedAt, ID: p.ID}
+}
+
+func commentKey(c *models.Comment) models.PageKey {
+	return models.PageKey{CreatedAt: c.CreatedAt, ID: c.ID}
+}
+
+// порядок (created_at, id) как в postgres
+func pageKeyLess(a, b models.PageKey) bool {
+	if a.CreatedAt.Equal(b.CreatedAt) {
+		return a.ID < b.ID
+	}
+	return a.CreatedAt.Before(b.CreatedAt)
+}
+
+// позиция первого поста, который идет после key в порядке убывания
+func postsAfter(posts []*models.Post, key models.PageKey) int {
+	return sort.Search(len(posts), func(i int) bool {
+		return pageKeyLess(postKey(posts[i]), key)
+	})
+}
+
+// позиция первого комментария, который идет после key в порядке возрастания
+func commentsAfter(comments []*models.Comment, key models.PageKey) int {
+	return sort.Search(len(comments), func(i int) bool {
+		return pageKeyLess(key, commentKey(comments[i]))
+	})
+}
+
+func insertPost(posts []*models.Post, post *models.Post) []*models.Post {
+	i := postsAfter(posts, postKey(post))
+	posts = append(posts, nil)
+	copy(posts[i+1:], posts[i:])
+	posts[i] = post
+	return posts
+}
+
+// заменяет пост с тем же ключом, ключ при обновлении не меняется
+func replacePost(posts []*models.Post, post *models.Post) {
+	i := sort.Search(len(posts), func(i int) bool {
+		return !pageKeyLess(postKey(post), postKey(posts[i]))
+	})
+	if i < len(posts) && posts[i].ID == post.ID {
+		posts[i] = post
+	}
+}
+
+// убирает пост из индекса, например после удаления
+func removePost(posts []*models.Post, post *models.Post) []*models.Post {
+	i := sort.Search(len(posts), func(i int) bool {
+		return !pageKeyLess(postKey(post), postKey(posts[i]))
+	})
+	if i < len(posts) && posts[i].ID == post.ID {
+		return append(posts[:i:i], posts[i+1:]...)
+	}
+	return posts
+}
+
+func insertComment(comments []*models.Comment, comment *models.Comment) []*models.Comment {
+	i := commentsAfter(comments, commentKey(comment))
+	comments = append(comments, nil)
+	copy(comments[i+1:], comments[i:])
+	comments[i] = comment
+	return comments
+}
+
+// позиция комментария с тем же ключом или -1
+func commentIndex(comments []*models.Comment, comment *models.Comment) int {
+	i := sort.Search(len(comments), func(i int) bool {
+		return !pageKeyLess(commentKey(comments[i]), commentKey(comment))
+	})
+	if i < len(comments) && comments[i].ID == comment.ID {
+		return i
+	}
+	return -1
+}
+
+func replaceComment(comments []*models.Comment, comment *models.Comment) {
+	if i := commentIndex(comments, comment); i >= 0 {
+		comments[i] = comment
+	}
+}
+
+func removeComment(comments []*models.Comment, comment *models.Comment) []*models.Comment {
+	if i := commentIndex(comments, comment); i >= 0 {
+		return append(comments[:i:i], comments[i+1:]...)
+	}
+	return comments
+}
+
+func pageOf[T any](items []T, start, limit int) []T {
+	if start >= len(items) {
+		return []T{}
+	}
+	end := start + limit
+	if end > len(items) {
+		end = len(items)
+	}
+	// копия, чтобы вставки в индекс не меняли уже отданную страницу
+	page := make([]T, end-start)
+	copy(page, items[start:end])
+	return page
+}
+
+// группа комментариев в порядке sort; индекс хранит порядок old, остальные порядки строятся на копии
+func (m *MemoryStorage) sortedComments(group []*models.Comment, sortBy models.CommentSort) ([]*models.Comment, error) {
+	var rank func(up, down int) float64
+	switch sortBy {
+	case models.CommentSortOld, "":
+		return group, nil
+	case models.CommentSortNew:
+		sorted := slices.Clone(group)
+		slices.Reverse(sorted)
+		return sorted, nil
+	case models.CommentSortTop:
+		rank = ranking.WilsonLowerBound
+	case models.CommentSortControversial:
+		rank = ranking.Controversy
+	default:
+		return nil, fmt.Errorf("%w: неизвестная сортировка комментариев %s", customerrors.ErrValidation, sortBy)
+	}
+
+	ranks := make(map[string]float64, len(group))
+	for _, c := range group {
+		counts := m.reactionCounts[reactionTarget{target: models.ReactionTargetComment, id: c.ID}]
+		ranks[c.ID] = rank(counts[models.ReactionUp], counts[models.ReactionDown])
+	}
+	// стабильная сортировка сохраняет порядок (created_at, id) при равных рангах
+	sorted := slices.Clone(group)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return ranks[sorted[i].ID] > ranks[sorted[j].ID]
+	})
+	return sorted, nil
+}
+
+// число неудаленных комментариев поста и время последнего из них
+type postStats struct {
+	comments      int
+	lastCommentAt time.Time
+}
+
+func (m *MemoryStorage) postStats(postID string) postStats {
+	live := m.liveComments[postID]
+	if len(live) == 0 {
+		return postStats{}
+	}
+	return postStats{comments: len(live), lastCommentAt: live[len(live)-1].CreatedAt}
+}
+
+func matchPost(post *models.Post, filter models.PostFilter, stats func(postID string) postStats) bool {
+	if filter.AuthorID != nil && post.AuthorID != *filter.AuthorID {
+		return false
+	}
+	if filter.CreatedAfter != nil && post.CreatedAt.Before(*filter.CreatedAfter) {
+		return false
+	}
+	if filter.CreatedBefore != nil && !post.CreatedAt.Before(*filter.CreatedBefore) {
+		return false
+	}
+	if filter.CommentsEnabled != nil && post.CommentsEnabled != *filter.CommentsEnabled {
+		return false
+	}
+	if filter.HasComments != nil && (stats(post.ID).comments > 0) != *filter.HasComments {
+		return false
+	}
+	return true
+}
+
+// posts приходят в порядке newest, стабильная сортировка сохраняет его при равных ключах
+func sortPosts(posts []*models.Post, sortBy models.PostSort, stats func(postID string) postStats) ([]*models.Post, error) {
+	switch sortBy {
+	case models.PostSortNewest, "":
+	case models.PostSortOldest:
+		slices.Reverse(posts)
+	case models.PostSortMostCommented:
+		sort.SliceStable(posts, func(i, j int) bool {
+			return stats(posts[i].ID).comments > stats(posts[j].ID).comments
+		})
+	case models.PostSortRecentlyActive:
+		activity := func(p *models.Post) time.Time {
+			if last := stats(p.ID).lastCommentAt; last.After(p.CreatedAt) {
+				return last
+			}
+			return p.CreatedAt
+		}
+		sort.SliceStable(posts, func(i, j int) bool {
+			return activity(posts[i]).After(activity(posts[j]))
+		})
+	default:
+		return nil, fmt.Errorf("%w: неизвестная сортировка постов %s", customerrors.ErrValidation, sortBy)
+	}
+	return posts, nil
+}
@@ -3,6 +3,7 @@ package inmemory
 import (
 	"context"
 	"fmt"
+	"slices"
 	"sync"
 	"time"
 
@@ -12,39 +13,107 @@ import (
 
 // in-memory хранилище
 type MemoryStorage struct {
+	*memoryState
+	// не nil у хранилища, которое WithTx передает в функцию: блокировка уже взята на всю транзакцию,
+	// методы ее не берут, а записи копятся до фиксации
+	tx *memoryTx
+}
+
+// данные хранилища, общие для него и всех его транзакций
+type memoryState struct {
 	mu          sync.RWMutex
 	usersByID   map[string]*models.User
 	usersByName map[string]*models.User
 	posts       map[string]*models.Post
 	comments    map[string]*models.Comment
+
+	// история правок, ревизии от старых к новым
+	postRevisions    map[string][]*models.PostRevision
+	commentRevisions map[string][]*models.CommentRevision
+
+	// реакции по объекту и пользователю, счетчики поддерживаются вместе с ними
+	reactions      map[reactionTarget]map[string]*models.Reaction
+	reactionCounts map[reactionTarget]map[models.ReactionKind]int
+
+	// id упомянутых пользователей в порядке появления в тексте
+	mentions map[reactionTarget][]string
+
+	// уведомления по получателю
+	notifications map[string][]*models.Notification
+
+	// вебхуки и очередь их доставок по id
+	webhooks   map[string]*models.Webhook
+	deliveries map[string]*models.WebhookDelivery
+
+	// события outbox по возрастанию seq, outboxSeq - последний выданный seq, не уменьшается после очистки
+	outbox          []*models.OutboxEvent
+	outboxSeq       int64
+	consumerOffsets map[string]int64
+
+	// полнотекстовый индекс постов и комментариев
+	search *searchIndex
+
+	// индексы, поддерживаются отсортированными при каждой записи
+	postsByDate   []*models.Post
+	commentGroups map[commentGroup][]*models.Comment
+	// неудаленные комментарии поста по возрастанию (created_at, id), из них берутся число комментариев и последняя активность
+	liveComments map[string][]*models.Comment
+	// неудаленные комментарии неудаленных постов по автору, по возрастанию (created_at, id)
+	commentsByAuthor map[string][]*models.Comment
+
+	// nil, если сохранение на диск выключено
+	persist *persistence
 }
 
 func NewMemoryStorage() *MemoryStorage {
-	return &MemoryStorage{
-		usersByID:   make(map[string]*models.User),
-		usersByName: make(map[string]*models.User),
-		posts:       make(map[string]*models.Post),
-		comments:    make(map[string]*models.Comment),
-	}
+	return &MemoryStorage{memoryState: &memoryState{
+		usersByID:        make(map[string]*models.User),
+		usersByName:      make(map[string]*models.User),
+		posts:            make(map[string]*models.Post),
+		comments:         make(map[string]*models.Comment),
+		postRevisions:    make(map[string][]*models.PostRevision),
+		commentRevisions: make(map[string][]*models.CommentRevision),
+		reactions:        make(map[reactionTarget]map[string]*models.Reaction),
+		reactionCounts:   make(map[reactionTarget]map[models.ReactionKind]int),
+		commentGroups:    make(map[commentGroup][]*models.Comment),
+		liveComments:     make(map[string][]*models.Comment),
+		commentsByAuthor: make(map[string][]*models.Comment),
+		notifications:    make(map[string][]*models.Notification),
+		mentions:         make(map[reactionTarget][]string),
+		webhooks:         make(map[string]*models.Webhook),
+		deliveries:       make(map[string]*models.WebhookDelivery),
+		consumerOffsets:  make(map[string]int64),
+		search:           newSearchIndex(),
+	}}
 }
 
 // создаёт пользователя с  проверкой уникальности имени
 func (m *MemoryStorage) CreateUser(ctx context.Context, user *models.User) error {
-	m.mu.Lock()
-	defer m.mu.Unlock()
+	m.lock()
+	defer m.unlock()
 
 	if _, exists := m.usersByName[user.Username]; exists {
 		return fmt.Errorf("%w: пользователь с именем %s уже существует", customerrors.ErrAlreadyExists, user.Username)
 	}
+	if user.Role == "" {
+		user.Role = models.RoleUser
+	}
 
-	m.usersByID[user.ID] = user
-	m.usersByName[user.Username] = user
-	return nil
+	created := models.UserCreatedPayload{ID: user.ID, Username: user.Username, Role: user.Role}
+	event, err := m.newEvent(models.EventUserCreated, user.ID, created)
+	if err != nil {
+		return err
+	}
+	return m.write(opCreateUser, user, event, func() { m.applyCreateUser(user) })
+}
+
+func (m *MemoryStorage) applyCreateUser(user *models.User) {
+	m.putUser(user)
 }
 
 func (m *MemoryStorage) GetUserByID(ctx context.Context, id string) (*models.User, error) {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
+	m.rlock()
+	defer m.runlock()
 
 	user, ok := m.usersByID[id]
 	if !ok {
@@ -53,84 +122,495 @@ func (m *MemoryStorage) GetUserByID(ctx context.Context, id string) (*models.Use
 	return user, nil
 }
 
+func (m *MemoryStorage) GetUserByUsername(ctx context.Context, username string) (*models.User, error) {
+	m.rlock()
+	defer m.runlock()
+
+	user, ok := m.usersByName[username]
+	if !ok {
+		return nil, fmt.Errorf("%w: пользователь с именем %s", customerrors.ErrNotFound, username)
+	}
+	return user, nil
+}
+
+func (m *MemoryStorage) RegisterLoginFailure(ctx context.Context, userID string, maxFailures int, lockUntil time.Time) error {
+	m.lock()
+	defer m.unlock()
+
+	user, ok := m.usersByID[userID]
+	if !ok {
+		return fmt.Errorf("%w: пользователь с id %s", customerrors.ErrNotFound, userID)
+	}
+
+	// в журнал пишется итоговое состояние, чтобы воспроизведение не зависело от конфига
+	state := loginState{UserID: userID, FailedLogins: user.FailedLogins + 1, LockedUntil: user.LockedUntil}
+	if state.FailedLogins >= maxFailures {
+		until := lockUntil.UTC()
+		state.FailedLogins = 0
+		state.LockedUntil = &until
+	}
+
+	return m.write(opSetLoginState, state, nil, func() { m.applySetLoginState(state) })
+}
+
+func (m *MemoryStorage) ResetLoginFailures(ctx context.Context, userID string) error {
+	m.lock()
+	defer m.unlock()
+
+	if _, ok := m.usersByID[userID]; !ok {
+		return fmt.Errorf("%w: пользователь с id %s", customerrors.ErrNotFound, userID)
+	}
+
+	state := loginState{UserID: userID}
+	return m.write(opSetLoginState, state, nil, func() { m.applySetLoginState(state) })
+}
+
+func (m *MemoryStorage) SetUserRole(ctx context.Context, userID string, role models.Role) error {
+	m.lock()
+	defer m.unlock()
+
+	if _, ok := m.usersByID[userID]; !ok {
+		return fmt.Errorf("%w: пользователь с id %s", customerrors.ErrNotFound, userID)
+	}
+
+	change := roleChange{UserID: userID, Role: role}
+	return m.write(opSetRole, change, nil, func() { m.applySetRole(change) })
+}
+
+func (m *MemoryStorage) applySetRole(change roleChange) {
+	current, ok := m.usersByID[change.UserID]
+	if !ok {
+		return
+	}
+
+	updated := *current
+	updated.Role = change.Role
+	m.putUser(&updated)
+}
+
+func (m *MemoryStorage) applySetLoginState(state loginState) {
+	current, ok := m.usersByID[state.UserID]
+	if !ok {
+		return
+	}
+
+	// копия, как и у постов: отданный читателю пользователь не меняется
+	updated := *current
+	updated.FailedLogins = state.FailedLogins
+	updated.LockedUntil = state.LockedUntil
+	m.putUser(&updated)
+}
+
+// кладет пользователя в оба индекса, имя пользователя не меняется
+func (m *MemoryStorage) putUser(user *models.User) {
+	u := m.undo()
+	keep(u, m.usersByID, user.ID)
+	keep(u, m.usersByName, user.Username)
+	m.usersByID[user.ID] = user
+	m.usersByName[user.Username] = user
+}
+
+// возвращает найденных пользователей, отсутствующие id пропускаются
+func (m *MemoryStorage) GetUsersByIDs(ctx context.Context, ids []string) ([]*models.User, error) {
+	m.rlock()
+	defer m.runlock()
+
+	users := make([]*models.User, 0, len(ids))
+	for _, id := range ids {
+		if user, ok := m.usersByID[id]; ok {
+			users = append(users, user)
+		}
+	}
+	return users, nil
+}
+
 func (m *MemoryStorage) GetPostByID(ctx context.Context, id string) (*models.Post, error) {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
+	m.rlock()
+	defer m.runlock()
 
 	post, ok := m.posts[id]
-	if !ok {
+	if !ok || post.DeletedAt != nil {
 		return nil, fmt.Errorf("%w: пост с id %s", customerrors.ErrNotFound, id)
 	}
 	return post, nil
 }
 
-// возвращает список постов
-func (m *MemoryStorage) ListPosts(ctx context.Context, offset, limit int) ([]*models.Post, error) {
+// возвращает найденные посты, отсутствующие id пропускаются
+func (m *MemoryStorage) GetPostsByIDs(ctx context.Context, ids []string) ([]*models.Post, error) {
+	m.rlock()
+	defer m.runlock()
+
+	posts := make([]*models.Post, 0, len(ids))
+	for _, id := range ids {
+		if post, ok := m.posts[id]; ok && post.DeletedAt == nil {
+			posts = append(posts, post)
+		}
+	}
+	return posts, nil
+}
+
+// возвращает список постов; индекс по дате уже отсортирован, остальные порядки строятся на отфильтрованной копии
+func (m *MemoryStorage) ListPosts(ctx context.Context, filter models.PostFilter, sort models.PostSort, offset, limit int) ([]*models.Post, error) {
 	if offset < 0 || limit <= 0 {
 		return nil, fmt.Errorf("%w: неправильный параметр пагинации", customerrors.ErrParamOutOfRange)
 	}
 
-	m.mu.RLock()
-	defer m.mu.RUnlock()
+	m.rlock()
+	defer m.runlock()
 
-	allPosts := make([]*models.Post, 0, len(m.posts))
-	for _, val := range m.posts {
-		allPosts = append(allPosts, val)
+	posts := make([]*models.Post, 0, len(m.postsByDate))
+	for _, post := range m.postsByDate {
+		if matchPost(post, filter, m.postStats) {
+			posts = append(posts, post)
+		}
+	}
+
+	posts, err := sortPosts(posts, sort, m.postStats)
+	if err != nil {
+		return nil, err
 	}
+	return pageOf(posts, offset, limit), nil
+}
 
-	if offset >= len(allPosts) {
-		return []*models.Post{}, nil
+func (m *MemoryStorage) ListPostsAfter(ctx context.Context, after *models.PageKey, limit int) ([]*models.Post, error) {
+	if limit <= 0 {
+		return nil, fmt.Errorf("%w: неправильный параметр пагинации", customerrors.ErrParamOutOfRange)
 	}
 
-	end := offset + limit
-	if end > len(allPosts) {
-		end = len(allPosts)
+	m.rlock()
+	defer m.runlock()
+
+	start := 0
+	if after != nil {
+		start = postsAfter(m.postsByDate, *after)
 	}
-	return allPosts[offset:end], nil
+	return pageOf(m.postsByDate, start, limit), nil
 }
 
 func (m *MemoryStorage) CreatePost(ctx context.Context, post *models.Post) error {
-	m.mu.Lock()
-	defer m.mu.Unlock()
+	m.lock()
+	defer m.unlock()
 
 	if _, exists := m.posts[post.ID]; exists {
 		return fmt.Errorf("%w: пост с id %s уже существует", customerrors.ErrAlreadyExists, post.ID)
 	}
 
 	post.CreatedAt = time.Now().UTC()
+	event, err := m.newEvent(models.EventPostCreated, post.ID, post)
+	if err != nil {
+		return err
+	}
+	return m.write(opCreatePost, post, event, func() { m.applyCreatePost(post) })
+}
+
+func (m *MemoryStorage) applyCreatePost(post *models.Post) {
+	u := m.undo()
+	keep(u, m.posts, post.ID)
 	m.posts[post.ID] = post
-	return nil
+	m.indexPost(post)
+	// удаленный пост мог прийти из снапшота, в индекс для выдачи он не попадает
+	if post.DeletedAt == nil {
+		keepSliceVar(u, &m.postsByDate)
+		m.postsByDate = insertPost(m.postsByDate, post)
+	}
 }
 
-// обновляет пост
-func (m *MemoryStorage) UpdatePost(ctx context.Context, post *models.Post) error {
-	m.mu.Lock()
-	defer m.mu.Unlock()
+// обновляет пост, как и в postgres меняются только title, content, comments_enabled и edited_at
+func (m *MemoryStorage) UpdatePost(ctx context.Context, post *models.Post, revision *models.PostRevision) error {
+	m.lock()
+	defer m.unlock()
 
-	if _, exists := m.posts[post.ID]; !exists {
+	if current, exists := m.posts[post.ID]; !exists || current.DeletedAt != nil {
 		return fmt.Errorf("%w: пост с id %s не найден", customerrors.ErrNotFound, post.ID)
 	}
 
-	m.posts[post.ID] = post
-	return nil
+	update := postUpdate{Post: *post, Revision: revision}
+	event, err := m.newEvent(models.EventPostUpdated, post.ID, post)
+	if err != nil {
+		return err
+	}
+	return m.write(opUpdatePost, update, event, func() { m.applyUpdatePost(update) })
+}
+
+func (m *MemoryStorage) applyUpdatePost(update postUpdate) {
+	current, exists := m.posts[update.ID]
+	if !exists {
+		return
+	}
+
+	// новая копия, чтобы не менять пост, который уже отдан читателям
+	updated := *current
+	updated.Title = update.Title
+	updated.Content = update.Content
+	updated.CommentsEnabled = update.CommentsEnabled
+	updated.EditedAt = update.EditedAt
+
+	u := m.undo()
+	keep(u, m.posts, update.ID)
+	keepSliceVar(u, &m.postsByDate)
+	m.posts[update.ID] = &updated
+	replacePost(m.postsByDate, &updated)
+	m.indexPost(&updated)
+	if update.Revision != nil {
+		keep(u, m.postRevisions, update.ID)
+		m.postRevisions[update.ID] = append(m.postRevisions[update.ID], update.Revision)
+	}
+}
+
+func (m *MemoryStorage) ListPostRevisions(ctx context.Context, postID string) ([]*models.PostRevision, error) {
+	m.rlock()
+	defer m.runlock()
+
+	return pageOf(m.postRevisions[postID], 0, len(m.postRevisions[postID])), nil
+}
+
+func (m *MemoryStorage) SoftDeletePost(ctx context.Context, id string, at time.Time) error {
+	m.lock()
+	defer m.unlock()
+
+	if post, exists := m.posts[id]; !exists || post.DeletedAt != nil {
+		return fmt.Errorf("%w: пост с id %s не найден", customerrors.ErrNotFound, id)
+	}
+	return m.deleteOp(opSoftDeletePost, deletion{ID: id, At: at.UTC()})
+}
+
+// удаляет пост вместе с комментариями, как каскад в postgres
+func (m *MemoryStorage) PurgePost(ctx context.Context, id string) error {
+	m.lock()
+	defer m.unlock()
+
+	if _, exists := m.posts[id]; !exists {
+		return fmt.Errorf("%w: пост с id %s не найден", customerrors.ErrNotFound, id)
+	}
+	return m.deleteOp(opPurgePost, deletion{ID: id})
+}
+
+func (m *MemoryStorage) SoftDeleteComment(ctx context.Context, id string, at time.Time) error {
+	m.lock()
+	defer m.unlock()
+
+	if comment, exists := m.comments[id]; !exists || comment.DeletedAt != nil {
+		return fmt.Errorf("%w: комментарий с id %s не найден", customerrors.ErrNotFound, id)
+	}
+	return m.deleteOp(opSoftDeleteComment, deletion{ID: id, At: at.UTC()})
+}
+
+// удаляет комментарий вместе со всеми ответами
+func (m *MemoryStorage) PurgeComment(ctx context.Context, id string) error {
+	m.lock()
+	defer m.unlock()
+
+	if _, exists := m.comments[id]; !exists {
+		return fmt.Errorf("%w: комментарий с id %s не найден", customerrors.ErrNotFound, id)
+	}
+	return m.deleteOp(opPurgeComment, deletion{ID: id})
+}
+
+func (m *MemoryStorage) deleteOp(op string, d deletion) error {
+	return m.write(op, d, nil, func() { m.applyDeletion(op, d) })
+}
+
+func (m *MemoryStorage) applyDeletion(op string, d deletion) {
+	u := m.undo()
+	switch op {
+	case opSoftDeletePost:
+		current, exists := m.posts[d.ID]
+		if !exists {
+			return
+		}
+		deleted := *current
+		deleted.DeletedAt = &d.At
+		keep(u, m.posts, d.ID)
+		keepVar(u, &m.postsByDate)
+		m.posts[d.ID] = &deleted
+		m.postsByDate = removePost(m.postsByDate, current)
+		m.unindexAuthors(d.ID)
+	case opPurgePost:
+		current, exists := m.posts[d.ID]
+		if !exists {
+			return
+		}
+		postTarget := reactionTarget{target: models.ReactionTargetPost, id: d.ID}
+		keep(u, m.posts, d.ID)
+		keep(u, m.postRevisions, d.ID)
+		keep(u, m.mentions, postTarget)
+		keepVar(u, &m.postsByDate)
+		delete(m.posts, d.ID)
+		delete(m.postRevisions, d.ID)
+		m.search.remove(u, searchDoc{kind: models.SearchPosts, id: d.ID})
+		m.dropReactions(models.ReactionTargetPost, d.ID)
+		delete(m.mentions, postTarget)
+		m.postsByDate = removePost(m.postsByDate, current)
+		m.unindexAuthors(d.ID)
+		m.dropNotifications(func(n *models.Notification) bool { return n.PostID == d.ID })
+		for id, c := range m.comments {
+			if c.PostID == d.ID {
+				commentTarget := reactionTarget{target: models.ReactionTargetComment, id: id}
+				keep(u, m.comments, id)
+				keep(u, m.commentRevisions, id)
+				keep(u, m.mentions, commentTarget)
+				delete(m.comments, id)
+				delete(m.commentRevisions, id)
+				m.search.remove(u, searchDoc{kind: models.SearchComments, id: id})
+				m.dropReactions(models.ReactionTargetComment, id)
+				delete(m.mentions, commentTarget)
+			}
+		}
+		for g := range m.commentGroups {
+			if g.postID == d.ID {
+				keep(u, m.commentGroups, g)
+				delete(m.commentGroups, g)
+			}
+		}
+		keep(u, m.liveComments, d.ID)
+		delete(m.liveComments, d.ID)
+	case opSoftDeleteComment:
+		current, exists := m.comments[d.ID]
+		if !exists {
+			return
+		}
+		deleted := *current
+		deleted.DeletedAt = &d.At
+		keep(u, m.comments, d.ID)
+		keepSlice(u, m.commentGroups, groupOf(current))
+		keep(u, m.liveComments, current.PostID)
+		keep(u, m.commentsByAuthor, current.AuthorID)
+		m.comments[d.ID] = &deleted
+		replaceComment(m.commentGroups[groupOf(current)], &deleted)
+		m.liveComments[current.PostID] = removeComment(m.liveComments[current.PostID], current)
+		m.commentsByAuthor[current.AuthorID] = removeComment(m.commentsByAuthor[current.AuthorID], current)
+	case opPurgeComment:
+		current, exists := m.comments[d.ID]
+		if !exists {
+			return
+		}
+		g := groupOf(current)
+		keep(u, m.commentGroups, g)
+		m.commentGroups[g] = removeComment(m.commentGroups[g], current)
+		m.purgeSubtree(current)
+	}
+}
+
+func (m *MemoryStorage) purgeSubtree(c *models.Comment) {
+	g := commentGroup{postID: c.PostID, parentID: c.ID}
+	for _, reply := range m.commentGroups[g] {
+		m.purgeSubtree(reply)
+	}
+	target := reactionTarget{target: models.ReactionTargetComment, id: c.ID}
+	u := m.undo()
+	keep(u, m.commentGroups, g)
+	keep(u, m.liveComments, c.PostID)
+	keep(u, m.commentsByAuthor, c.AuthorID)
+	keep(u, m.comments, c.ID)
+	keep(u, m.commentRevisions, c.ID)
+	keep(u, m.mentions, target)
+	delete(m.commentGroups, g)
+	m.liveComments[c.PostID] = removeComment(m.liveComments[c.PostID], c)
+	m.commentsByAuthor[c.AuthorID] = removeComment(m.commentsByAuthor[c.AuthorID], c)
+	delete(m.comments, c.ID)
+	delete(m.commentRevisions, c.ID)
+	m.search.remove(u, searchDoc{kind: models.SearchComments, id: c.ID})
+	m.dropReactions(models.ReactionTargetComment, c.ID)
+	delete(m.mentions, target)
+	m.dropNotifications(func(n *models.Notification) bool { return n.CommentID != nil && *n.CommentID == c.ID })
 }
 
 func (m *MemoryStorage) CreateComment(ctx context.Context, comment *models.Comment) error {
-	m.mu.Lock()
-	defer m.mu.Unlock()
+	m.lock()
+	defer m.unlock()
 
 	if _, exists := m.comments[comment.ID]; exists {
 		return fmt.Errorf("%w: комментарий с id %s уже существует", customerrors.ErrAlreadyExists, comment.ID)
 	}
 
 	comment.CreatedAt = time.Now().UTC()
+	event, err := m.newEvent(models.EventCommentCreated, comment.ID, comment)
+	if err != nil {
+		return err
+	}
+	return m.write(opCreateComment, comment, event, func() { m.applyCreateComment(comment) })
+}
+
+func (m *MemoryStorage) applyCreateComment(comment *models.Comment) {
+	u := m.undo()
+	keep(u, m.comments, comment.ID)
 	m.comments[comment.ID] = comment
-	return nil
+	m.indexComment(comment)
+	g := groupOf(comment)
+	keepSlice(u, m.commentGroups, g)
+	m.commentGroups[g] = insertComment(m.commentGroups[g], comment)
+	// из снимка приходят и удаленные комментарии
+	if comment.DeletedAt == nil {
+		keepSlice(u, m.liveComments, comment.PostID)
+		m.liveComments[comment.PostID] = insertComment(m.liveComments[comment.PostID], comment)
+		if post, ok := m.posts[comment.PostID]; ok && post.DeletedAt == nil {
+			keepSlice(u, m.commentsByAuthor, comment.AuthorID)
+			m.commentsByAuthor[comment.AuthorID] = insertComment(m.commentsByAuthor[comment.AuthorID], comment)
+		}
+	}
+}
+
+// убирает комментарии удаляемого поста из индекса по автору
+func (m *MemoryStorage) unindexAuthors(postID string) {
+	u := m.undo()
+	for _, c := range m.liveComments[postID] {
+		keep(u, m.commentsByAuthor, c.AuthorID)
+		m.commentsByAuthor[c.AuthorID] = removeComment(m.commentsByAuthor[c.AuthorID], c)
+	}
+}
+
+func (m *MemoryStorage) UpdateComment(ctx context.Context, comment *models.Comment, revision *models.CommentRevision) error {
+	m.lock()
+	defer m.unlock()
+
+	if current, exists := m.comments[comment.ID]; !exists || current.DeletedAt != nil {
+		return fmt.Errorf("%w: комментарий с id %s не найден", customerrors.ErrNotFound, comment.ID)
+	}
+
+	update := commentUpdate{Comment: *comment, Revision: revision}
+	event, err := m.newEvent(models.EventCommentUpdated, comment.ID, comment)
+	if err != nil {
+		return err
+	}
+	return m.write(opUpdateComment, update, event, func() { m.applyUpdateComment(update) })
+}
+
+func (m *MemoryStorage) applyUpdateComment(update commentUpdate) {
+	current, exists := m.comments[update.ID]
+	if !exists {
+		return
+	}
+
+	updated := *current
+	updated.Text = update.Text
+	updated.EditedAt = update.EditedAt
+
+	u := m.undo()
+	keep(u, m.comments, update.ID)
+	keepSlice(u, m.commentGroups, groupOf(current))
+	keepSlice(u, m.liveComments, current.PostID)
+	keepSlice(u, m.commentsByAuthor, current.AuthorID)
+	m.comments[update.ID] = &updated
+	replaceComment(m.commentGroups[groupOf(current)], &updated)
+	replaceComment(m.liveComments[current.PostID], &updated)
+	replaceComment(m.commentsByAuthor[current.AuthorID], &updated)
+	m.indexComment(&updated)
+	if update.Revision != nil {
+		keep(u, m.commentRevisions, update.ID)
+		m.commentRevisions[update.ID] = append(m.commentRevisions[update.ID], update.Revision)
+	}
+}
+
+func (m *MemoryStorage) ListCommentRevisions(ctx context.Context, commentID string) ([]*models.CommentRevision, error) {
+	m.rlock()
+	defer m.runlock()
+
+	return pageOf(m.commentRevisions[commentID], 0, len(m.commentRevisions[commentID])), nil
 }
 
 func (m *MemoryStorage) GetCommentByID(ctx context.Context, id string) (*models.Comment, error) {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
+	m.rlock()
+	defer m.runlock()
 
 	comment, ok := m.comments[id]
 	if !ok {
@@ -139,38 +619,125 @@ func (m *MemoryStorage) GetCommentByID(ctx context.Context, id string) (*models.
 	return comment, nil
 }
 
-func (m *MemoryStorage) ListCommentsByPost(ctx context.Context, postID string, parentID *string, offset, limit int) ([]*models.Comment, error) {
+func (m *MemoryStorage) ListCommentsByPost(ctx context.Context, postID string, parentID *string, sort models.CommentSort, offset, limit int) ([]*models.Comment, error) {
 	if offset < 0 || limit <= 0 {
 		return nil, fmt.Errorf("%w: неправильный параметр пагинации", customerrors.ErrParamOutOfRange)
 	}
 
-	m.mu.RLock()
-	defer m.mu.RUnlock()
+	m.rlock()
+	defer m.runlock()
 
-	result := []*models.Comment{}
-	for _, val := range m.comments {
-		if val.PostID != postID {
-			continue
+	group, err := m.sortedComments(m.commentGroups[groupFor(postID, parentID)], sort)
+	if err != nil {
+		return nil, err
+	}
+	return pageOf(group, offset, limit), nil
+}
+
+// комментарии автора берутся из индекса commentsByAuthor, комментарии удаленных постов в него не попадают
+func (m *MemoryStorage) ListCommentsByAuthor(ctx context.Context, authorID string, offset, limit int) ([]*models.Comment, error) {
+	if offset < 0 || limit <= 0 {
+		return nil, fmt.Errorf("%w: неправильный параметр пагинации", customerrors.ErrParamOutOfRange)
+	}
+
+	m.rlock()
+	defer m.runlock()
+
+	// индекс хранит порядок по возрастанию, а выдача идет от новых к старым
+	byAuthor := m.commentsByAuthor[authorID]
+	end := len(byAuthor) - offset
+	if end <= 0 {
+		return []*models.Comment{}, nil
+	}
+	page := slices.Clone(byAuthor[max(end-limit, 0):end])
+	slices.Reverse(page)
+	return page, nil
+}
+
+func (m *MemoryStorage) ListCommentsAfter(ctx context.Context, postID string, parentID *string, after *models.PageKey, limit int) ([]*models.Comment, error) {
+	if limit <= 0 {
+		return nil, fmt.Errorf("%w: неправильный параметр пагинации", customerrors.ErrParamOutOfRange)
+	}
+
+	m.rlock()
+	defer m.runlock()
+
+	group := m.commentGroups[groupFor(postID, parentID)]
+	start := 0
+	if after != nil {
+		start = commentsAfter(group, *after)
+	}
+	return pageOf(group, start, limit), nil
+}
+
+// обход в глубину от корней, дети каждого узла по возрастанию (created_at, id)
+func (m *MemoryStorage) GetCommentThread(ctx context.Context, postID string, rootID *string, maxDepth, maxNodes int) ([]*models.ThreadComment, error) {
+	if maxDepth < 0 || maxNodes <= 0 {
+		return nil, fmt.Errorf("%w: неправильные ограничения дерева", customerrors.ErrParamOutOfRange)
+	}
+
+	m.rlock()
+	defer m.runlock()
+
+	roots := m.commentGroups[commentGroup{postID: postID}]
+	if rootID != nil {
+		root, ok := m.comments[*rootID]
+		if !ok || root.PostID != postID {
+			return []*models.ThreadComment{}, nil
+		}
+		roots = []*models.Comment{root}
+	}
+
+	thread := []*models.ThreadComment{}
+	var walk func(c *models.Comment, depth int)
+	walk = func(c *models.Comment, depth int) {
+		if len(thread) >= maxNodes {
+			return
+		}
+		thread = append(thread, &models.ThreadComment{Comment: c, Depth: depth})
+		if depth >= maxDepth {
+			return
 		}
-		if parentID == nil && val.ParentID == nil {
-			result = append(result, val)
-		} else if parentID != nil && val.ParentID != nil && *parentID == *val.ParentID {
-			result = append(result, val)
+		for _, reply := range m.commentGroups[commentGroup{postID: postID, parentID: c.ID}] {
+			walk(reply, depth+1)
 		}
 	}
+	for _, root := range roots {
+		walk(root, 0)
+	}
+	return thread, nil
+}
 
-	if offset >= len(result) {
-		return []*models.Comment{}, nil
+func (m *MemoryStorage) ListRepliesByParentIDs(ctx context.Context, parentIDs []string, sort models.CommentSort, offset, limit int) (map[string][]*models.Comment, error) {
+	if offset < 0 || limit <= 0 {
+		return nil, fmt.Errorf("%w: неправильный параметр пагинации", customerrors.ErrParamOutOfRange)
 	}
 
-	end := offset + limit
-	if end > len(result) {
-		end = len(result)
+	m.rlock()
+	defer m.runlock()
+
+	result := make(map[string][]*models.Comment, len(parentIDs))
+	for _, parentID := range parentIDs {
+		parent, ok := m.comments[parentID]
+		if !ok {
+			continue
+		}
+		replies, err := m.sortedComments(m.commentGroups[commentGroup{postID: parent.PostID, parentID: parentID}], sort)
+		if err != nil {
+			return nil, err
+		}
+		if offset < len(replies) {
+			result[parentID] = pageOf(replies, offset, limit)
+		}
 	}
-	return result[offset:end], nil
+	return result, nil
 }
 
-// Close здесь просто чтобы интерфейс был реализован
+// при включенном сохранении делает финальный снапшот, иначе ничего не делает
 func (m *MemoryStorage) Close() error {
-	return nil
+	// хранилище транзакции не владеет данными, закрывается только исходное
+	if m.tx != nil || m.persist == nil {
+		return nil
+	}
+	return m.closePersistence()
 }
@@ -0,0 +1,91 @@
+package inmemory
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/MAPiryazev/OzonTest/internal/customerrors"
+	"github.com/MAPiryazev/OzonTest/internal/models"
+)
+
+// упоминания объекта в журнале и снапшоте
+type mentionSet struct {
+	Target   models.ReactionTarget `json:"target"`
+	TargetID string                `json:"targetId"`
+	UserIDs  []string              `json:"userIds"`
+}
+
+// хранилище отдает копии без учетных данных, как select id, username, role
+func (m *MemoryStorage) GetUsersByUsernames(ctx context.Context, usernames []string) ([]*models.User, error) {
+	m.rlock()
+	defer m.runlock()
+
+	users := make([]*models.User, 0, len(usernames))
+	for _, name := range usernames {
+		if u, ok := m.usersByName[name]; ok {
+			users = append(users, &models.User{ID: u.ID, Username: u.Username, Role: u.Role})
+		}
+	}
+	return users, nil
+}
+
+func (m *MemoryStorage) ReplaceMentions(ctx context.Context, target models.ReactionTarget, targetID string, userIDs []string) ([]string, error) {
+	m.lock()
+	defer m.unlock()
+
+	if err := m.checkReactionTarget(target, targetID); err != nil {
+		return nil, err
+	}
+	for _, userID := range userIDs {
+		if _, ok := m.usersByID[userID]; !ok {
+			return nil, fmt.Errorf("%w: пользователь с id %s", customerrors.ErrNotFound, userID)
+		}
+	}
+
+	key := reactionTarget{target: target, id: targetID}
+	previous := make(map[string]struct{}, len(m.mentions[key]))
+	for _, userID := range m.mentions[key] {
+		previous[userID] = struct{}{}
+	}
+	added := []string{}
+	for _, userID := range userIDs {
+		if _, ok := previous[userID]; !ok {
+			added = append(added, userID)
+		}
+	}
+	if slices.Equal(m.mentions[key], userIDs) {
+		return added, nil
+	}
+
+	set := mentionSet{Target: target, TargetID: targetID, UserIDs: userIDs}
+	if err := m.write(opReplaceMentions, set, nil, func() { m.applyReplaceMentions(set) }); err != nil {
+		return nil, err
+	}
+	return added, nil
+}
+
+func (m *MemoryStorage) applyReplaceMentions(set mentionSet) {
+	key := reactionTarget{target: set.Target, id: set.TargetID}
+	keep(m.undo(), m.mentions, key)
+	if len(set.UserIDs) == 0 {
+		delete(m.mentions, key)
+		return
+	}
+	m.mentions[key] = append([]string(nil), set.UserIDs...)
+}
+
+func (m *MemoryStorage) GetMentions(ctx context.Context, target models.ReactionTarget, targetIDs []string) (map[string][]models.Mention, error) {
+	m.rlock()
+	defer m.runlock()
+
+	result := make(map[string][]models.Mention, len(targetIDs))
+	for _, id := range targetIDs {
+		for _, userID := range m.mentions[reactionTarget{target: target, id: id}] {
+			if u, ok := m.usersByID[userID]; ok {
+				result[id] = append(result[id], models.Mention{UserID: u.ID, Username: u.Username})
+			}
+		}
+	}
+	return result, nil
+}
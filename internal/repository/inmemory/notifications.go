@@ -0,0 +1,184 @@
+package inmemory
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/MAPiryazev/OzonTest/internal/customerrors"
+	"github.com/MAPiryazev/OzonTest/internal/models"
+)
+
+// отметка о прочтении, IDs == nil - все уведомления пользователя
+type notificationsRead struct {
+	UserID string    `json:"userId"`
+	IDs    []string  `json:"ids"`
+	At     time.Time `json:"at"`
+}
+
+func notificationKey(n *models.Notification) models.PageKey {
+	return models.PageKey{CreatedAt: n.CreatedAt, ID: n.ID}
+}
+
+func (m *MemoryStorage) CreateNotifications(ctx context.Context, notifications []*models.Notification) error {
+	if len(notifications) == 0 {
+		return nil
+	}
+
+	m.lock()
+	defer m.unlock()
+
+	// проверки вместо внешних ключей, уже сохраненные уведомления пропускаются
+	created := make([]*models.Notification, 0, len(notifications))
+	for _, n := range notifications {
+		if m.hasNotification(n) {
+			continue
+		}
+		created = append(created, n)
+		if _, ok := m.usersByID[n.UserID]; !ok {
+			return fmt.Errorf("%w: пользователь с id %s", customerrors.ErrNotFound, n.UserID)
+		}
+		if _, ok := m.posts[n.PostID]; !ok {
+			return fmt.Errorf("%w: пост с id %s", customerrors.ErrNotFound, n.PostID)
+		}
+		if n.CommentID == nil {
+			continue
+		}
+		if _, ok := m.comments[*n.CommentID]; !ok {
+			return fmt.Errorf("%w: комментарий с id %s", customerrors.ErrNotFound, *n.CommentID)
+		}
+	}
+
+	if len(created) == 0 {
+		return nil
+	}
+	return m.write(opCreateNotifications, created, nil, func() {
+		for _, n := range created {
+			m.applyCreateNotification(n)
+		}
+	})
+}
+
+// повтор того же уведомления имеет те же id и время, поэтому ищется по ключу сортировки
+func (m *MemoryStorage) hasNotification(n *models.Notification) bool {
+	list := m.notifications[n.UserID]
+	key := notificationKey(n)
+	i := sort.Search(len(list), func(i int) bool {
+		return !pageKeyLess(notificationKey(list[i]), key)
+	})
+	return i < len(list) && list[i].ID == n.ID
+}
+
+// уведомления пользователя хранятся по возрастанию (created_at, id)
+func (m *MemoryStorage) applyCreateNotification(n *models.Notification) {
+	list := m.notifications[n.UserID]
+	i := sort.Search(len(list), func(i int) bool {
+		return pageKeyLess(notificationKey(n), notificationKey(list[i]))
+	})
+	keepSlice(m.undo(), m.notifications, n.UserID)
+	list = append(list, nil)
+	copy(list[i+1:], list[i:])
+	list[i] = n
+	m.notifications[n.UserID] = list
+}
+
+func (m *MemoryStorage) ListNotifications(ctx context.Context, userID string, unreadOnly bool, after *models.PageKey, limit int) ([]*models.Notification, error) {
+	if limit <= 0 {
+		return nil, fmt.Errorf("%w: неправильный параметр пагинации", customerrors.ErrParamOutOfRange)
+	}
+
+	m.rlock()
+	defer m.runlock()
+
+	list := m.notifications[userID]
+	result := make([]*models.Notification, 0, min(limit, len(list)))
+	for i := len(list) - 1; i >= 0 && len(result) < limit; i-- {
+		n := list[i]
+		if after != nil && !pageKeyLess(notificationKey(n), *after) {
+			continue
+		}
+		if unreadOnly && n.ReadAt != nil {
+			continue
+		}
+		copied := *n
+		result = append(result, &copied)
+	}
+	return result, nil
+}
+
+func (m *MemoryStorage) CountUnreadNotifications(ctx context.Context, userID string) (int, error) {
+	m.rlock()
+	defer m.runlock()
+
+	count := 0
+	for _, n := range m.notifications[userID] {
+		if n.ReadAt == nil {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (m *MemoryStorage) MarkNotificationsRead(ctx context.Context, userID string, ids []string, at time.Time) (int, error) {
+	m.lock()
+	defer m.unlock()
+
+	read := notificationsRead{UserID: userID, IDs: ids, At: at}
+	marked := len(m.unreadNotifications(read))
+	if marked == 0 {
+		return 0, nil
+	}
+
+	if err := m.write(opMarkNotificationsRead, read, nil, func() { m.applyMarkNotificationsRead(read) }); err != nil {
+		return 0, err
+	}
+	return marked, nil
+}
+
+// непрочитанные уведомления, которые затрагивает отметка
+func (m *MemoryStorage) unreadNotifications(read notificationsRead) []*models.Notification {
+	var wanted map[string]bool
+	if read.IDs != nil {
+		wanted = make(map[string]bool, len(read.IDs))
+		for _, id := range read.IDs {
+			wanted[id] = true
+		}
+	}
+
+	var result []*models.Notification
+	for _, n := range m.notifications[read.UserID] {
+		if n.ReadAt == nil && (wanted == nil || wanted[n.ID]) {
+			result = append(result, n)
+		}
+	}
+	return result
+}
+
+func (m *MemoryStorage) applyMarkNotificationsRead(read notificationsRead) {
+	at := read.At
+	u := m.undo()
+	for _, n := range m.unreadNotifications(read) {
+		keepVar(u, n)
+		n.ReadAt = &at
+	}
+}
+
+// удаляет уведомления, подходящие под match, вызывается при физическом удалении
+// списки не меняются на месте, чтобы откат транзакции мог вернуть прежние
+func (m *MemoryStorage) dropNotifications(match func(n *models.Notification) bool) {
+	u := m.undo()
+	for userID, list := range m.notifications {
+		if !slices.ContainsFunc(list, match) {
+			continue
+		}
+		keep(u, m.notifications, userID)
+		kept := slices.DeleteFunc(slices.Clone(list), match)
+		if len(kept) == 0 {
+			delete(m.notifications, userID)
+			continue
+		}
+		m.notifications[userID] = kept
+	}
+}
@@ -0,0 +1,93 @@
+package inmemory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/MAPiryazev/OzonTest/internal/models"
+	"github.com/MAPiryazev/OzonTest/internal/repository"
+)
+
+// смещение потребителя outbox в журнале
+type consumerOffset struct {
+	Consumer string `json:"consumer"`
+	Seq      int64  `json:"seq"`
+}
+
+type outboxPrune struct {
+	UptoSeq int64 `json:"uptoSeq"`
+}
+
+// собирает событие со следующим seq, вызывается под m.mu; seq занимается только в applyOutboxEvent,
+// поэтому при ошибке записи в журнал номер не теряется
+func (m *MemoryStorage) newEvent(eventType models.EventType, aggregateID string, entity any) (*models.OutboxEvent, error) {
+	event, err := repository.NewOutboxEvent(eventType, aggregateID, entity, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	event.Seq = m.outboxSeq + 1
+	return event, nil
+}
+
+func (m *MemoryStorage) applyOutboxEvent(event *models.OutboxEvent) {
+	u := m.undo()
+	keepVar(u, &m.outbox)
+	keepVar(u, &m.outboxSeq)
+	m.outbox = append(m.outbox, event)
+	if event.Seq > m.outboxSeq {
+		m.outboxSeq = event.Seq
+	}
+}
+
+func (m *MemoryStorage) applyPruneOutbox(uptoSeq int64) {
+	n := sort.Search(len(m.outbox), func(i int) bool { return m.outbox[i].Seq > uptoSeq })
+	keepVar(m.undo(), &m.outbox)
+	m.outbox = append([]*models.OutboxEvent(nil), m.outbox[n:]...)
+}
+
+func (m *MemoryStorage) ListOutboxEvents(ctx context.Context, afterSeq int64, limit int) ([]*models.OutboxEvent, error) {
+	m.rlock()
+	defer m.runlock()
+
+	start := sort.Search(len(m.outbox), func(i int) bool { return m.outbox[i].Seq > afterSeq })
+	end := min(start+limit, len(m.outbox))
+
+	events := make([]*models.OutboxEvent, 0, end-start)
+	for _, event := range m.outbox[start:end] {
+		copied := *event
+		events = append(events, &copied)
+	}
+	return events, nil
+}
+
+func (m *MemoryStorage) GetConsumerOffset(ctx context.Context, consumer string) (int64, error) {
+	m.rlock()
+	defer m.runlock()
+
+	return m.consumerOffsets[consumer], nil
+}
+
+func (m *MemoryStorage) SetConsumerOffset(ctx context.Context, consumer string, seq int64) error {
+	m.lock()
+	defer m.unlock()
+
+	if seq <= m.consumerOffsets[consumer] {
+		return nil
+	}
+	offset := consumerOffset{Consumer: consumer, Seq: seq}
+	return m.write(opSetConsumerOffset, offset, nil, func() {
+		keep(m.undo(), m.consumerOffsets, consumer)
+		m.consumerOffsets[consumer] = seq
+	})
+}
+
+func (m *MemoryStorage) PruneOutbox(ctx context.Context, uptoSeq int64) error {
+	m.lock()
+	defer m.unlock()
+
+	if len(m.outbox) == 0 || m.outbox[0].Seq > uptoSeq {
+		return nil
+	}
+	return m.write(opPruneOutbox, outboxPrune{UptoSeq: uptoSeq}, nil, func() { m.applyPruneOutbox(uptoSeq) })
+}
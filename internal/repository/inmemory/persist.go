@@ -0,0 +1,607 @@
+package inmemory
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/MAPiryazev/OzonTest/internal/config"
+	"github.com/MAPiryazev/OzonTest/internal/models"
+)
+
+// сохранение in-memory хранилища: журнал операций (WAL) в формате json lines
+// и периодический снапшот, после которого журнал обнуляется
+
+const (
+	opCreateUser    = "create_user"
+	opCreatePost    = "create_post"
+	opUpdatePost    = "update_post"
+	opCreateComment = "create_comment"
+	opUpdateComment = "update_comment"
+	opSetLoginState = "set_login_state"
+	opSetRole       = "set_role"
+
+	opSetReaction    = "set_reaction"
+	opRemoveReaction = "remove_reaction"
+
+	opReplaceMentions = "replace_mentions"
+
+	opCreateNotifications   = "create_notifications"
+	opMarkNotificationsRead = "mark_notifications_read"
+
+	opCreateWebhook           = "create_webhook"
+	opSetWebhookActive        = "set_webhook_active"
+	opDeleteWebhook           = "delete_webhook"
+	opCreateWebhookDeliveries = "create_webhook_deliveries"
+	opUpdateWebhookDelivery   = "update_webhook_delivery"
+
+	opSetConsumerOffset = "set_consumer_offset"
+	opPruneOutbox       = "prune_outbox"
+
+	opSoftDeletePost    = "soft_delete_post"
+	opPurgePost         = "purge_post"
+	opSoftDeleteComment = "soft_delete_comment"
+	opPurgeComment      = "purge_comment"
+
+	// операции одной транзакции, применяются все вместе
+	opTx = "tx"
+)
+
+// удаление поста или комментария, At пустое для физического удаления
+type deletion struct {
+	ID string    `json:"id"`
+	At time.Time `json:"at"`
+}
+
+// поля поста на верхнем уровне, поэтому старые записи update_post без ревизии читаются так же
+type postUpdate struct {
+	models.Post
+	Revision *models.PostRevision `json:"revision,omitempty"`
+}
+
+type commentUpdate struct {
+	models.Comment
+	Revision *models.CommentRevision `json:"revision,omitempty"`
+}
+
+type roleChange struct {
+	UserID string      `json:"userId"`
+	Role   models.Role `json:"role"`
+}
+
+// состояние блокировки пользователя после неудачного или успешного входа
+type loginState struct {
+	UserID       string     `json:"userId"`
+	FailedLogins int        `json:"failedLogins"`
+	LockedUntil  *time.Time `json:"lockedUntil,omitempty"`
+}
+
+// Event - событие outbox, записанное вместе с операцией: запись в журнал одна, поэтому они применяются вместе
+type walRecord struct {
+	Seq   uint64              `json:"seq"`
+	Op    string              `json:"op"`
+	Data  json.RawMessage     `json:"data"`
+	Event *models.OutboxEvent `json:"event,omitempty"`
+}
+
+// LastSeq нужен, чтобы не применить повторно записи журнала, уже попавшие в снапшот
+type snapshot struct {
+	LastSeq  uint64            `json:"lastSeq"`
+	Users    []*models.User    `json:"users"`
+	Posts    []*models.Post    `json:"posts"`
+	Comments []*models.Comment `json:"comments"`
+
+	PostRevisions    []*models.PostRevision    `json:"postRevisions,omitempty"`
+	CommentRevisions []*models.CommentRevision `json:"commentRevisions,omitempty"`
+	Reactions        []*models.Reaction        `json:"reactions,omitempty"`
+	Mentions         []mentionSet              `json:"mentions,omitempty"`
+	Notifications    []*models.Notification    `json:"notifications,omitempty"`
+	Webhooks         []*models.Webhook         `json:"webhooks,omitempty"`
+	Deliveries       []*models.WebhookDelivery `json:"webhookDeliveries,omitempty"`
+	Outbox           []*models.OutboxEvent     `json:"outbox,omitempty"`
+	OutboxSeq        int64                     `json:"outboxSeq,omitempty"`
+	ConsumerOffsets  map[string]int64          `json:"consumerOffsets,omitempty"`
+}
+
+type persistence struct {
+	cfg *config.MemoryConfig
+
+	mu    sync.Mutex // защищает файл журнала
+	file  *os.File
+	seq   uint64
+	dirty bool
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// создает хранилище и восстанавливает состояние из снапшота и журнала
+func OpenMemoryStorage(cfg *config.MemoryConfig) (*MemoryStorage, error) {
+	m := NewMemoryStorage()
+
+	lastSeq, err := m.loadSnapshot(cfg.SnapshotPath)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(cfg.WALPath, os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось открыть журнал %s: %w", cfg.WALPath, err)
+	}
+
+	seq, err := m.replayWAL(file, lastSeq)
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+
+	m.persist = &persistence{cfg: cfg, file: file, seq: seq, stop: make(chan struct{})}
+	m.startBackground()
+
+	log.Printf("in-memory хранилище восстановлено: %d пользователей, %d постов, %d комментариев", len(m.usersByID), len(m.posts), len(m.comments))
+	return m, nil
+}
+
+func (m *MemoryStorage) loadSnapshot(path string) (uint64, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("не удалось прочитать снапшот %s: %w", path, err)
+	}
+
+	var snap snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return 0, fmt.Errorf("снапшот %s поврежден: %w", path, err)
+	}
+
+	for _, u := range snap.Users {
+		m.applyCreateUser(u)
+	}
+	for _, p := range snap.Posts {
+		m.applyCreatePost(p)
+	}
+	for _, c := range snap.Comments {
+		m.applyCreateComment(c)
+	}
+	for _, rev := range snap.PostRevisions {
+		m.postRevisions[rev.PostID] = append(m.postRevisions[rev.PostID], rev)
+	}
+	for _, rev := range snap.CommentRevisions {
+		m.commentRevisions[rev.CommentID] = append(m.commentRevisions[rev.CommentID], rev)
+	}
+	// счетчики не хранятся в снапшоте, а пересчитываются по реакциям
+	for _, reaction := range snap.Reactions {
+		m.applySetReaction(reaction)
+	}
+	for _, set := range snap.Mentions {
+		m.applyReplaceMentions(set)
+	}
+	for _, n := range snap.Notifications {
+		m.applyCreateNotification(n)
+	}
+	for _, webhook := range snap.Webhooks {
+		m.webhooks[webhook.ID] = webhook
+	}
+	for _, d := range snap.Deliveries {
+		m.deliveries[d.ID] = d
+	}
+	for _, event := range snap.Outbox {
+		m.applyOutboxEvent(event)
+	}
+	// события могли быть очищены, а seq переиспользовать нельзя
+	if snap.OutboxSeq > m.outboxSeq {
+		m.outboxSeq = snap.OutboxSeq
+	}
+	for consumer, seq := range snap.ConsumerOffsets {
+		m.consumerOffsets[consumer] = seq
+	}
+	return snap.LastSeq, nil
+}
+
+// применяет записи после снапшота, недописанный хвост журнала (падение во время записи) отрезается
+func (m *MemoryStorage) replayWAL(file *os.File, lastSeq uint64) (uint64, error) {
+	seq := lastSeq
+	reader := bufio.NewReader(file)
+	var offset int64
+
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			if len(bytes.TrimSpace(line)) > 0 {
+				log.Printf("в журнале найдена недописанная запись, она будет отброшена")
+			}
+			break
+		}
+		if err != nil {
+			return 0, fmt.Errorf("ошибка чтения журнала: %w", err)
+		}
+
+		var rec walRecord
+		if err := json.Unmarshal(line, &rec); err != nil {
+			return 0, fmt.Errorf("журнал поврежден на смещении %d: %w", offset, err)
+		}
+		offset += int64(len(line))
+
+		if rec.Seq <= lastSeq {
+			continue
+		}
+		if err := m.applyRecord(rec); err != nil {
+			return 0, fmt.Errorf("не удалось применить запись %d журнала: %w", rec.Seq, err)
+		}
+		seq = rec.Seq
+	}
+
+	if err := file.Truncate(offset); err != nil {
+		return 0, fmt.Errorf("не удалось обрезать журнал: %w", err)
+	}
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return 0, fmt.Errorf("ошибка позиционирования в журнале: %w", err)
+	}
+	return seq, nil
+}
+
+func (m *MemoryStorage) applyRecord(rec walRecord) error {
+	switch rec.Op {
+	case opCreateUser:
+		var u models.User
+		if err := json.Unmarshal(rec.Data, &u); err != nil {
+			return err
+		}
+		m.applyCreateUser(&u)
+	case opCreatePost:
+		var p models.Post
+		if err := json.Unmarshal(rec.Data, &p); err != nil {
+			return err
+		}
+		m.applyCreatePost(&p)
+	case opUpdatePost:
+		var update postUpdate
+		if err := json.Unmarshal(rec.Data, &update); err != nil {
+			return err
+		}
+		m.applyUpdatePost(update)
+	case opUpdateComment:
+		var update commentUpdate
+		if err := json.Unmarshal(rec.Data, &update); err != nil {
+			return err
+		}
+		m.applyUpdateComment(update)
+	case opCreateComment:
+		var c models.Comment
+		if err := json.Unmarshal(rec.Data, &c); err != nil {
+			return err
+		}
+		m.applyCreateComment(&c)
+	case opSetLoginState:
+		var state loginState
+		if err := json.Unmarshal(rec.Data, &state); err != nil {
+			return err
+		}
+		m.applySetLoginState(state)
+	case opSetRole:
+		var change roleChange
+		if err := json.Unmarshal(rec.Data, &change); err != nil {
+			return err
+		}
+		m.applySetRole(change)
+	case opSetReaction:
+		var reaction models.Reaction
+		if err := json.Unmarshal(rec.Data, &reaction); err != nil {
+			return err
+		}
+		m.applySetReaction(&reaction)
+	case opRemoveReaction:
+		var reaction models.Reaction
+		if err := json.Unmarshal(rec.Data, &reaction); err != nil {
+			return err
+		}
+		m.removeReaction(reactionTarget{target: reaction.Target, id: reaction.TargetID}, reaction.UserID)
+	case opReplaceMentions:
+		var set mentionSet
+		if err := json.Unmarshal(rec.Data, &set); err != nil {
+			return err
+		}
+		m.applyReplaceMentions(set)
+	case opCreateNotifications:
+		var notifications []*models.Notification
+		if err := json.Unmarshal(rec.Data, &notifications); err != nil {
+			return err
+		}
+		for _, n := range notifications {
+			m.applyCreateNotification(n)
+		}
+	case opMarkNotificationsRead:
+		var read notificationsRead
+		if err := json.Unmarshal(rec.Data, &read); err != nil {
+			return err
+		}
+		m.applyMarkNotificationsRead(read)
+	case opCreateWebhook:
+		var webhook models.Webhook
+		if err := json.Unmarshal(rec.Data, &webhook); err != nil {
+			return err
+		}
+		m.webhooks[webhook.ID] = &webhook
+	case opSetWebhookActive:
+		var state webhookState
+		if err := json.Unmarshal(rec.Data, &state); err != nil {
+			return err
+		}
+		m.applySetWebhookActive(state)
+	case opDeleteWebhook:
+		var d deletion
+		if err := json.Unmarshal(rec.Data, &d); err != nil {
+			return err
+		}
+		m.applyDeleteWebhook(d.ID)
+	case opCreateWebhookDeliveries:
+		var deliveries []*models.WebhookDelivery
+		if err := json.Unmarshal(rec.Data, &deliveries); err != nil {
+			return err
+		}
+		for _, d := range deliveries {
+			m.deliveries[d.ID] = d
+		}
+	case opUpdateWebhookDelivery:
+		var delivery models.WebhookDelivery
+		if err := json.Unmarshal(rec.Data, &delivery); err != nil {
+			return err
+		}
+		m.applyUpdateWebhookDelivery(&delivery)
+	case opSetConsumerOffset:
+		var offset consumerOffset
+		if err := json.Unmarshal(rec.Data, &offset); err != nil {
+			return err
+		}
+		m.consumerOffsets[offset.Consumer] = offset.Seq
+	case opPruneOutbox:
+		var prune outboxPrune
+		if err := json.Unmarshal(rec.Data, &prune); err != nil {
+			return err
+		}
+		m.applyPruneOutbox(prune.UptoSeq)
+	case opTx:
+		var records []walRecord
+		if err := json.Unmarshal(rec.Data, &records); err != nil {
+			return err
+		}
+		for _, r := range records {
+			if err := m.applyRecord(r); err != nil {
+				return err
+			}
+		}
+	case opSoftDeletePost, opPurgePost, opSoftDeleteComment, opPurgeComment:
+		var d deletion
+		if err := json.Unmarshal(rec.Data, &d); err != nil {
+			return err
+		}
+		m.applyDeletion(rec.Op, d)
+	default:
+		return fmt.Errorf("неизвестная операция %s", rec.Op)
+	}
+	if rec.Event != nil {
+		m.applyOutboxEvent(rec.Event)
+	}
+	return nil
+}
+
+// пишет операцию в журнал до изменения данных, вызывается под m.mu;
+// событие outbox, если есть, попадает в ту же запись журнала
+func (m *MemoryStorage) logEvent(op string, data any, event *models.OutboxEvent) error {
+	if m.persist == nil {
+		return nil
+	}
+	p := m.persist
+
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("не удалось сериализовать операцию %s: %w", op, err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	line, err := json.Marshal(walRecord{Seq: p.seq + 1, Op: op, Data: payload, Event: event})
+	if err != nil {
+		return fmt.Errorf("не удалось сериализовать операцию %s: %w", op, err)
+	}
+	if _, err := p.file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("не удалось записать операцию %s в журнал: %w", op, err)
+	}
+	p.seq++
+
+	switch p.cfg.FsyncPolicy {
+	case config.FsyncAlways:
+		if err := p.file.Sync(); err != nil {
+			return fmt.Errorf("не удалось сбросить журнал на диск: %w", err)
+		}
+	case config.FsyncInterval:
+		p.dirty = true
+	}
+	return nil
+}
+
+func (m *MemoryStorage) startBackground() {
+	p := m.persist
+
+	if p.cfg.FsyncPolicy == config.FsyncInterval && p.cfg.FsyncIntervalMs > 0 {
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			ticker := time.NewTicker(time.Duration(p.cfg.FsyncIntervalMs) * time.Millisecond)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ticker.C:
+					p.sync()
+				case <-p.stop:
+					return
+				}
+			}
+		}()
+	}
+
+	if p.cfg.SnapshotIntervalSec > 0 {
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			ticker := time.NewTicker(time.Duration(p.cfg.SnapshotIntervalSec) * time.Second)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ticker.C:
+					if err := m.Snapshot(); err != nil {
+						log.Printf("ошибка при создании снапшота: %v", err)
+					}
+				case <-p.stop:
+					return
+				}
+			}
+		}()
+	}
+}
+
+func (p *persistence) sync() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.dirty {
+		return
+	}
+	if err := p.file.Sync(); err != nil {
+		log.Printf("не удалось сбросить журнал на диск: %v", err)
+		return
+	}
+	p.dirty = false
+}
+
+// сохраняет компактный снапшот и обнуляет журнал, записи на это время блокируются
+func (m *MemoryStorage) Snapshot() error {
+	if m.persist == nil {
+		return nil
+	}
+	p := m.persist
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	snap := snapshot{
+		LastSeq:  p.seq,
+		Users:    make([]*models.User, 0, len(m.usersByID)),
+		Posts:    make([]*models.Post, 0, len(m.posts)),
+		Comments: make([]*models.Comment, 0, len(m.comments)),
+	}
+	for _, u := range m.usersByID {
+		snap.Users = append(snap.Users, u)
+	}
+	// по карте, а не по индексу: мягко удаленные посты в индекс не входят
+	for _, post := range m.posts {
+		snap.Posts = append(snap.Posts, post)
+	}
+	for _, c := range m.comments {
+		snap.Comments = append(snap.Comments, c)
+	}
+	// порядок внутри поста или комментария сохраняется, при загрузке ревизии дописываются в конец
+	for _, revisions := range m.postRevisions {
+		snap.PostRevisions = append(snap.PostRevisions, revisions...)
+	}
+	for _, revisions := range m.commentRevisions {
+		snap.CommentRevisions = append(snap.CommentRevisions, revisions...)
+	}
+	for _, byUser := range m.reactions {
+		for _, reaction := range byUser {
+			snap.Reactions = append(snap.Reactions, reaction)
+		}
+	}
+	for key, userIDs := range m.mentions {
+		snap.Mentions = append(snap.Mentions, mentionSet{Target: key.target, TargetID: key.id, UserIDs: userIDs})
+	}
+	for _, list := range m.notifications {
+		snap.Notifications = append(snap.Notifications, list...)
+	}
+	for _, webhook := range m.webhooks {
+		snap.Webhooks = append(snap.Webhooks, webhook)
+	}
+	for _, d := range m.deliveries {
+		snap.Deliveries = append(snap.Deliveries, d)
+	}
+	snap.Outbox = m.outbox
+	snap.OutboxSeq = m.outboxSeq
+	snap.ConsumerOffsets = m.consumerOffsets
+
+	if err := writeFileAtomic(p.cfg.SnapshotPath, snap); err != nil {
+		return err
+	}
+
+	// если упадем до обнуления, записи журнала отсеются по LastSeq
+	if err := p.file.Truncate(0); err != nil {
+		return fmt.Errorf("не удалось обнулить журнал: %w", err)
+	}
+	if _, err := p.file.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("ошибка позиционирования в журнале: %w", err)
+	}
+	if err := p.file.Sync(); err != nil {
+		return fmt.Errorf("не удалось сбросить журнал на диск: %w", err)
+	}
+	p.dirty = false
+	return nil
+}
+
+// пишет во временный файл и переименовывает, чтобы снапшот никогда не был наполовину записан
+func writeFileAtomic(path string, data any) error {
+	tmpPath := path + ".tmp"
+	tmp, err := os.Create(tmpPath)
+	if err != nil {
+		return fmt.Errorf("не удалось создать файл снапшота: %w", err)
+	}
+
+	if err := json.NewEncoder(tmp).Encode(data); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("не удалось записать снапшот: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("не удалось сбросить снапшот на диск: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("не удалось закрыть файл снапшота: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("не удалось заменить снапшот: %w", err)
+	}
+
+	// fsync каталога, чтобы переименование пережило падение
+	if dir, err := os.Open(filepath.Dir(path)); err == nil {
+		_ = dir.Sync()
+		_ = dir.Close()
+	}
+	return nil
+}
+
+// останавливает фоновые задачи, делает финальный снапшот и закрывает журнал
+func (m *MemoryStorage) closePersistence() error {
+	p := m.persist
+	close(p.stop)
+	p.wg.Wait()
+
+	snapErr := m.Snapshot()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.file.Close(); err != nil {
+		return fmt.Errorf("не удалось закрыть журнал: %w", err)
+	}
+	return snapErr
+}
@@ -0,0 +1,130 @@
+package inmemory
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/MAPiryazev/OzonTest/internal/customerrors"
+	"github.com/MAPiryazev/OzonTest/internal/models"
+)
+
+// объект реакции, ключ для реакций и счетчиков
+type reactionTarget struct {
+	target models.ReactionTarget
+	id     string
+}
+
+// проверка объекта и пользователя вместо внешних ключей, вызывается под m.mu
+func (m *MemoryStorage) checkReactionTarget(target models.ReactionTarget, targetID string) error {
+	switch target {
+	case models.ReactionTargetPost:
+		if _, ok := m.posts[targetID]; !ok {
+			return fmt.Errorf("%w: пост с id %s", customerrors.ErrNotFound, targetID)
+		}
+	case models.ReactionTargetComment:
+		if _, ok := m.comments[targetID]; !ok {
+			return fmt.Errorf("%w: комментарий с id %s", customerrors.ErrNotFound, targetID)
+		}
+	default:
+		return fmt.Errorf("%w: неизвестный объект реакции %s", customerrors.ErrValidation, target)
+	}
+	return nil
+}
+
+func (m *MemoryStorage) SetReaction(ctx context.Context, reaction *models.Reaction) error {
+	m.lock()
+	defer m.unlock()
+
+	if err := m.checkReactionTarget(reaction.Target, reaction.TargetID); err != nil {
+		return err
+	}
+	if _, ok := m.usersByID[reaction.UserID]; !ok {
+		return fmt.Errorf("%w: пользователь с id %s", customerrors.ErrNotFound, reaction.UserID)
+	}
+	key := reactionTarget{target: reaction.Target, id: reaction.TargetID}
+	if previous, ok := m.reactions[key][reaction.UserID]; ok && previous.Kind == reaction.Kind {
+		return nil
+	}
+
+	return m.write(opSetReaction, reaction, nil, func() { m.applySetReaction(reaction) })
+}
+
+func (m *MemoryStorage) applySetReaction(reaction *models.Reaction) {
+	key := reactionTarget{target: reaction.Target, id: reaction.TargetID}
+	m.removeReaction(key, reaction.UserID)
+
+	u := m.undo()
+	if m.reactions[key] == nil {
+		keep(u, m.reactions, key)
+		keep(u, m.reactionCounts, key)
+		m.reactions[key] = make(map[string]*models.Reaction)
+		m.reactionCounts[key] = make(map[models.ReactionKind]int)
+	}
+	keep(u, m.reactions[key], reaction.UserID)
+	keep(u, m.reactionCounts[key], reaction.Kind)
+	m.reactions[key][reaction.UserID] = reaction
+	m.reactionCounts[key][reaction.Kind]++
+}
+
+func (m *MemoryStorage) RemoveReaction(ctx context.Context, target models.ReactionTarget, targetID, userID string) error {
+	m.lock()
+	defer m.unlock()
+
+	key := reactionTarget{target: target, id: targetID}
+	if _, ok := m.reactions[key][userID]; !ok {
+		return nil
+	}
+
+	removal := &models.Reaction{Target: target, TargetID: targetID, UserID: userID}
+	return m.write(opRemoveReaction, removal, nil, func() { m.removeReaction(key, userID) })
+}
+
+func (m *MemoryStorage) removeReaction(key reactionTarget, userID string) {
+	previous, ok := m.reactions[key][userID]
+	if !ok {
+		return
+	}
+	u := m.undo()
+	keep(u, m.reactions[key], userID)
+	keep(u, m.reactionCounts[key], previous.Kind)
+	delete(m.reactions[key], userID)
+	m.reactionCounts[key][previous.Kind]--
+}
+
+// удаляет реакции вместе с объектом, как каскад в postgres
+func (m *MemoryStorage) dropReactions(target models.ReactionTarget, targetID string) {
+	key := reactionTarget{target: target, id: targetID}
+	u := m.undo()
+	keep(u, m.reactions, key)
+	keep(u, m.reactionCounts, key)
+	delete(m.reactions, key)
+	delete(m.reactionCounts, key)
+}
+
+func (m *MemoryStorage) GetReactionCounts(ctx context.Context, target models.ReactionTarget, targetIDs []string) (map[string][]models.ReactionCount, error) {
+	m.rlock()
+	defer m.runlock()
+
+	result := make(map[string][]models.ReactionCount, len(targetIDs))
+	for _, id := range targetIDs {
+		for kind, count := range m.reactionCounts[reactionTarget{target: target, id: id}] {
+			if count > 0 {
+				result[id] = append(result[id], models.ReactionCount{Kind: kind, Count: count})
+			}
+		}
+	}
+	return result, nil
+}
+
+func (m *MemoryStorage) GetUserReactions(ctx context.Context, target models.ReactionTarget, userID string, targetIDs []string) (map[string]models.ReactionKind, error) {
+	m.rlock()
+	defer m.runlock()
+
+	result := make(map[string]models.ReactionKind, len(targetIDs))
+	for _, id := range targetIDs {
+		if reaction, ok := m.reactions[reactionTarget{target: target, id: id}][userID]; ok {
+			result[id] = reaction.Kind
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,163 @@
+package inmemory
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/MAPiryazev/OzonTest/internal/customerrors"
+	"github.com/MAPiryazev/OzonTest/internal/models"
+	"github.com/MAPiryazev/OzonTest/internal/search"
+)
+
+type searchDoc struct {
+	kind models.SearchType
+	id   string
+}
+
+// инвертированный индекс: терм -> документ -> число вхождений,
+// удаленные объекты остаются в индексе и отсеиваются при поиске, как и в postgres
+type searchIndex struct {
+	postings map[string]map[searchDoc]int
+	docTerms map[searchDoc]map[string]int
+}
+
+func newSearchIndex() *searchIndex {
+	return &searchIndex{
+		postings: make(map[string]map[searchDoc]int),
+		docTerms: make(map[searchDoc]map[string]int),
+	}
+}
+
+// заменяет термы документа, u запоминает прежнее состояние для отката транзакции
+func (ix *searchIndex) add(u *undoLog, doc searchDoc, terms []string) {
+	ix.remove(u, doc)
+	counts := make(map[string]int, len(terms))
+	for _, term := range terms {
+		counts[term]++
+	}
+	for term, count := range counts {
+		if ix.postings[term] == nil {
+			keep(u, ix.postings, term)
+			ix.postings[term] = make(map[searchDoc]int)
+		}
+		keep(u, ix.postings[term], doc)
+		ix.postings[term][doc] = count
+	}
+	keep(u, ix.docTerms, doc)
+	ix.docTerms[doc] = counts
+}
+
+func (ix *searchIndex) remove(u *undoLog, doc searchDoc) {
+	for term := range ix.docTerms[doc] {
+		keep(u, ix.postings[term], doc)
+		delete(ix.postings[term], doc)
+		if len(ix.postings[term]) == 0 {
+			keep(u, ix.postings, term)
+			delete(ix.postings, term)
+		}
+	}
+	keep(u, ix.docTerms, doc)
+	delete(ix.docTerms, doc)
+}
+
+// заголовок индексируется дважды, чтобы совпадение в нем весило больше, чем в тексте
+func (m *MemoryStorage) indexPost(post *models.Post) {
+	terms := search.Terms(post.Title)
+	terms = append(terms, terms...)
+	terms = append(terms, search.Terms(post.Content)...)
+	m.search.add(m.undo(), searchDoc{kind: models.SearchPosts, id: post.ID}, terms)
+}
+
+func (m *MemoryStorage) indexComment(comment *models.Comment) {
+	m.search.add(m.undo(), searchDoc{kind: models.SearchComments, id: comment.ID}, search.Terms(comment.Text))
+}
+
+// документы, содержащие все термы запроса, ранжируются по сумме tf/(tf+1) * idf
+func (m *MemoryStorage) Search(ctx context.Context, query string, searchType models.SearchType, offset, limit int) ([]*models.SearchHit, error) {
+	if offset < 0 || limit <= 0 {
+		return nil, fmt.Errorf("%w: неправильный параметр пагинации", customerrors.ErrParamOutOfRange)
+	}
+	terms := search.QueryTerms(query)
+	if len(terms) == 0 {
+		return []*models.SearchHit{}, nil
+	}
+
+	m.rlock()
+	defer m.runlock()
+
+	// перебор начинается с самого редкого терма
+	rarest := terms[0]
+	for _, term := range terms[1:] {
+		if len(m.search.postings[term]) < len(m.search.postings[rarest]) {
+			rarest = term
+		}
+	}
+
+	total := float64(len(m.search.docTerms))
+	hits := []*models.SearchHit{}
+	for doc := range m.search.postings[rarest] {
+		if searchType != models.SearchAll && doc.kind != searchType {
+			continue
+		}
+		rank, ok := 0.0, true
+		for _, term := range terms {
+			tf, found := m.search.postings[term][doc]
+			if !found {
+				ok = false
+				break
+			}
+			idf := math.Log(1 + total/float64(len(m.search.postings[term])))
+			rank += float64(tf) / float64(tf+1) * idf
+		}
+		if !ok {
+			continue
+		}
+		if hit := m.searchHit(doc, terms); hit != nil {
+			hit.Rank = rank
+			hits = append(hits, hit)
+		}
+	}
+
+	sort.Slice(hits, func(i, j int) bool {
+		a, b := hits[i], hits[j]
+		if a.Rank != b.Rank {
+			return a.Rank > b.Rank
+		}
+		if a.Type != b.Type {
+			return a.Type > b.Type
+		}
+		return hitID(a) < hitID(b)
+	})
+	return pageOf(hits, offset, limit), nil
+}
+
+// результат для видимого документа или nil, если документ или его пост удален
+func (m *MemoryStorage) searchHit(doc searchDoc, terms []string) *models.SearchHit {
+	switch doc.kind {
+	case models.SearchPosts:
+		post, ok := m.posts[doc.id]
+		if !ok || post.DeletedAt != nil {
+			return nil
+		}
+		return &models.SearchHit{Type: doc.kind, Post: post, Snippet: search.Snippet(post.Title+" "+post.Content, terms, search.SnippetWords)}
+	case models.SearchComments:
+		comment, ok := m.comments[doc.id]
+		if !ok || comment.DeletedAt != nil {
+			return nil
+		}
+		if post, ok := m.posts[comment.PostID]; !ok || post.DeletedAt != nil {
+			return nil
+		}
+		return &models.SearchHit{Type: doc.kind, Comment: comment, Snippet: search.Snippet(comment.Text, terms, search.SnippetWords)}
+	}
+	return nil
+}
+
+func hitID(hit *models.SearchHit) string {
+	if hit.Post != nil {
+		return hit.Post.ID
+	}
+	return hit.Comment.ID
+}
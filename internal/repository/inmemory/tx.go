@@ -0,0 +1,185 @@
+package inmemory
+
+import (
+	"context"
+	"slices"
+
+	"github.com/MAPiryazev/OzonTest/internal/models"
+	"github.com/MAPiryazev/OzonTest/internal/repository"
+)
+
+// внутри транзакции блокировка уже взята WithTx, поэтому методы хранилища транзакции ее не берут
+
+func (m *MemoryStorage) lock() {
+	if m.tx == nil {
+		m.mu.Lock()
+	}
+}
+
+func (m *MemoryStorage) unlock() {
+	if m.tx == nil {
+		m.mu.Unlock()
+	}
+}
+
+func (m *MemoryStorage) rlock() {
+	if m.tx == nil {
+		m.mu.RLock()
+	}
+}
+
+func (m *MemoryStorage) runlock() {
+	if m.tx == nil {
+		m.mu.RUnlock()
+	}
+}
+
+// операция транзакции в журнале, читается обратно как walRecord
+type txRecord struct {
+	Op    string              `json:"op"`
+	Data  any                 `json:"data"`
+	Event *models.OutboxEvent `json:"event,omitempty"`
+}
+
+// записи транзакции в порядке вызова и журнал их отката
+type memoryTx struct {
+	records []txRecord
+	undo    undoLog
+}
+
+// шаги отката в порядке изменений, выполняются в обратном
+type undoLog struct {
+	steps []func()
+}
+
+func (u *undoLog) add(step func()) {
+	if u != nil {
+		u.steps = append(u.steps, step)
+	}
+}
+
+func (u *undoLog) rollback() {
+	for i := len(u.steps) - 1; i >= 0; i-- {
+		u.steps[i]()
+	}
+	u.steps = nil
+}
+
+// журнал отката текущей транзакции или nil вне ее, в том числе при восстановлении из журнала
+func (m *MemoryStorage) undo() *undoLog {
+	if m.tx == nil {
+		return nil
+	}
+	return &m.tx.undo
+}
+
+// вызываются перед изменением данных и запоминают прежнее значение для отката, вне транзакции ничего не делают
+
+// значение ключа key в mp, отсутствующий ключ при откате удаляется
+func keep[K comparable, V any](u *undoLog, mp map[K]V, key K) {
+	if u == nil {
+		return
+	}
+	old, ok := mp[key]
+	u.add(func() {
+		if ok {
+			mp[key] = old
+		} else {
+			delete(mp, key)
+		}
+	})
+}
+
+// срез по ключу key в mp, который меняется на месте: запоминается копия элементов
+func keepSlice[K comparable, T any](u *undoLog, mp map[K][]T, key K) {
+	if u == nil {
+		return
+	}
+	old, ok := mp[key]
+	old = slices.Clone(old)
+	u.add(func() {
+		if ok {
+			mp[key] = old
+		} else {
+			delete(mp, key)
+		}
+	})
+}
+
+// переменная или объект, который меняется на месте; срез в p должен меняться только через append
+func keepVar[T any](u *undoLog, p *T) {
+	if u == nil {
+		return
+	}
+	old := *p
+	u.add(func() { *p = old })
+}
+
+// срез, который меняется на месте: запоминается копия элементов
+func keepSliceVar[T any](u *undoLog, p *[]T) {
+	if u == nil {
+		return
+	}
+	old := slices.Clone(*p)
+	u.add(func() { *p = old })
+}
+
+// пишет операцию в журнал и применяет ее, вызывается под m.mu после всех проверок.
+// в транзакции операция применяется сразу, чтобы следующие чтения и проверки транзакции ее видели,
+// а в журнал попадает только при фиксации
+func (m *MemoryStorage) write(op string, data any, event *models.OutboxEvent, apply func()) error {
+	if m.tx == nil {
+		if err := m.logEvent(op, data, event); err != nil {
+			return err
+		}
+	} else {
+		m.tx.records = append(m.tx.records, txRecord{Op: op, Data: data, Event: event})
+	}
+	apply()
+	if event != nil {
+		m.applyOutboxEvent(event)
+	}
+	return nil
+}
+
+// выполняет fn под блокировкой на запись всего хранилища, поэтому между чтениями и записями fn
+// не вклинивается ни одна другая операция. записи fn применяются сразу и видны ее следующим чтениям;
+// при успехе они попадают в журнал одной записью, а при ошибке или панике fn откатываются.
+// вложенный WithTx выполняется в той же транзакции
+func (m *MemoryStorage) WithTx(ctx context.Context, fn func(tx repository.Storage) error) error {
+	if m.tx != nil {
+		return fn(m)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tx := &MemoryStorage{memoryState: m.memoryState, tx: &memoryTx{}}
+	defer func() {
+		if p := recover(); p != nil {
+			tx.tx.undo.rollback()
+			panic(p)
+		}
+	}()
+
+	if err := fn(tx); err != nil {
+		tx.tx.undo.rollback()
+		return err
+	}
+	if err := m.commit(tx.tx.records); err != nil {
+		tx.tx.undo.rollback()
+		return err
+	}
+	return nil
+}
+
+// пишет записи транзакции в журнал одной записью
+func (m *MemoryStorage) commit(records []txRecord) error {
+	switch len(records) {
+	case 0:
+		return nil
+	case 1:
+		return m.logEvent(records[0].Op, records[0].Data, records[0].Event)
+	default:
+		return m.logEvent(opTx, records, nil)
+	}
+}
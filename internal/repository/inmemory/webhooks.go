@@ -0,0 +1,260 @@
+package inmemory
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/MAPiryazev/OzonTest/internal/customerrors"
+	"github.com/MAPiryazev/OzonTest/internal/models"
+)
+
+// включение или отключение вебхука в журнале
+type webhookState struct {
+	ID     string `json:"id"`
+	Active bool   `json:"active"`
+}
+
+// копия, чтобы вызывающий код не менял состояние хранилища в обход журнала
+func copyWebhook(w *models.Webhook) *models.Webhook {
+	copied := *w
+	copied.Events = slices.Clone(w.Events)
+	return &copied
+}
+
+func copyDelivery(d *models.WebhookDelivery) *models.WebhookDelivery {
+	copied := *d
+	if d.DeliveredAt != nil {
+		at := *d.DeliveredAt
+		copied.DeliveredAt = &at
+	}
+	return &copied
+}
+
+func (m *MemoryStorage) CreateWebhook(ctx context.Context, webhook *models.Webhook) error {
+	m.lock()
+	defer m.unlock()
+
+	if _, exists := m.webhooks[webhook.ID]; exists {
+		return fmt.Errorf("%w: вебхук с id %s", customerrors.ErrAlreadyExists, webhook.ID)
+	}
+	if _, ok := m.usersByID[webhook.CreatedBy]; !ok {
+		return fmt.Errorf("%w: пользователь с id %s", customerrors.ErrNotFound, webhook.CreatedBy)
+	}
+
+	return m.write(opCreateWebhook, webhook, nil, func() {
+		keep(m.undo(), m.webhooks, webhook.ID)
+		m.webhooks[webhook.ID] = copyWebhook(webhook)
+	})
+}
+
+func (m *MemoryStorage) GetWebhookByID(ctx context.Context, id string) (*models.Webhook, error) {
+	m.rlock()
+	defer m.runlock()
+
+	webhook, ok := m.webhooks[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: вебхук с id %s", customerrors.ErrNotFound, id)
+	}
+	return copyWebhook(webhook), nil
+}
+
+func (m *MemoryStorage) ListWebhooks(ctx context.Context) ([]*models.Webhook, error) {
+	m.rlock()
+	defer m.runlock()
+
+	webhooks := make([]*models.Webhook, 0, len(m.webhooks))
+	for _, webhook := range m.webhooks {
+		webhooks = append(webhooks, copyWebhook(webhook))
+	}
+	sort.Slice(webhooks, func(i, j int) bool {
+		return pageKeyLess(models.PageKey{CreatedAt: webhooks[i].CreatedAt, ID: webhooks[i].ID},
+			models.PageKey{CreatedAt: webhooks[j].CreatedAt, ID: webhooks[j].ID})
+	})
+	return webhooks, nil
+}
+
+func (m *MemoryStorage) SetWebhookActive(ctx context.Context, id string, active bool) error {
+	m.lock()
+	defer m.unlock()
+
+	webhook, ok := m.webhooks[id]
+	if !ok {
+		return fmt.Errorf("%w: вебхук с id %s", customerrors.ErrNotFound, id)
+	}
+	if webhook.Active == active {
+		return nil
+	}
+
+	state := webhookState{ID: id, Active: active}
+	return m.write(opSetWebhookActive, state, nil, func() { m.applySetWebhookActive(state) })
+}
+
+func (m *MemoryStorage) applySetWebhookActive(state webhookState) {
+	if webhook, ok := m.webhooks[state.ID]; ok {
+		keepVar(m.undo(), webhook)
+		webhook.Active = state.Active
+	}
+}
+
+func (m *MemoryStorage) DeleteWebhook(ctx context.Context, id string) error {
+	m.lock()
+	defer m.unlock()
+
+	if _, ok := m.webhooks[id]; !ok {
+		return fmt.Errorf("%w: вебхук с id %s", customerrors.ErrNotFound, id)
+	}
+
+	return m.write(opDeleteWebhook, deletion{ID: id}, nil, func() { m.applyDeleteWebhook(id) })
+}
+
+// вместо внешнего ключа on delete cascade
+func (m *MemoryStorage) applyDeleteWebhook(id string) {
+	u := m.undo()
+	keep(u, m.webhooks, id)
+	delete(m.webhooks, id)
+	for deliveryID, d := range m.deliveries {
+		if d.WebhookID == id {
+			keep(u, m.deliveries, deliveryID)
+			delete(m.deliveries, deliveryID)
+		}
+	}
+}
+
+func (m *MemoryStorage) CreateWebhookDeliveries(ctx context.Context, deliveries []*models.WebhookDelivery) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+
+	m.lock()
+	defer m.unlock()
+
+	created := make([]*models.WebhookDelivery, 0, len(deliveries))
+	for _, d := range deliveries {
+		if _, ok := m.webhooks[d.WebhookID]; !ok {
+			return fmt.Errorf("%w: вебхук с id %s", customerrors.ErrNotFound, d.WebhookID)
+		}
+		if _, exists := m.deliveries[d.ID]; !exists {
+			created = append(created, d)
+		}
+	}
+	if len(created) == 0 {
+		return nil
+	}
+
+	return m.write(opCreateWebhookDeliveries, created, nil, func() {
+		u := m.undo()
+		for _, d := range created {
+			keep(u, m.deliveries, d.ID)
+			m.deliveries[d.ID] = copyDelivery(d)
+		}
+	})
+}
+
+// перенос попытки не пишется в журнал: после перезапуска доставка просто станет доступна чуть позже
+func (m *MemoryStorage) ClaimWebhookDeliveries(ctx context.Context, now, leaseUntil time.Time, limit int) ([]*models.WebhookDelivery, error) {
+	if limit <= 0 {
+		return nil, fmt.Errorf("%w: неправильный размер пачки", customerrors.ErrParamOutOfRange)
+	}
+
+	m.lock()
+	defer m.unlock()
+
+	due := make([]*models.WebhookDelivery, 0)
+	for _, d := range m.deliveries {
+		if d.Status != models.WebhookDeliveryPending || d.NextAttemptAt.After(now) {
+			continue
+		}
+		if webhook, ok := m.webhooks[d.WebhookID]; !ok || !webhook.Active {
+			continue
+		}
+		due = append(due, d)
+	}
+	sort.Slice(due, func(i, j int) bool {
+		return pageKeyLess(models.PageKey{CreatedAt: due[i].NextAttemptAt, ID: due[i].ID},
+			models.PageKey{CreatedAt: due[j].NextAttemptAt, ID: due[j].ID})
+	})
+	if len(due) > limit {
+		due = due[:limit]
+	}
+
+	u := m.undo()
+	claimed := make([]*models.WebhookDelivery, len(due))
+	for i, d := range due {
+		keepVar(u, d)
+		d.NextAttemptAt = leaseUntil
+		claimed[i] = copyDelivery(d)
+	}
+	return claimed, nil
+}
+
+func (m *MemoryStorage) UpdateWebhookDelivery(ctx context.Context, delivery *models.WebhookDelivery) error {
+	m.lock()
+	defer m.unlock()
+
+	if _, ok := m.deliveries[delivery.ID]; !ok {
+		return fmt.Errorf("%w: доставка с id %s", customerrors.ErrNotFound, delivery.ID)
+	}
+
+	return m.write(opUpdateWebhookDelivery, delivery, nil, func() { m.applyUpdateWebhookDelivery(delivery) })
+}
+
+// меняются только поля результата попытки, как в update хранилищ на sql
+func (m *MemoryStorage) applyUpdateWebhookDelivery(delivery *models.WebhookDelivery) {
+	d, ok := m.deliveries[delivery.ID]
+	if !ok {
+		return
+	}
+	updated := copyDelivery(delivery)
+	keepVar(m.undo(), d)
+	d.Status = updated.Status
+	d.Attempts = updated.Attempts
+	d.NextAttemptAt = updated.NextAttemptAt
+	d.LastError = updated.LastError
+	d.DeliveredAt = updated.DeliveredAt
+}
+
+func (m *MemoryStorage) GetWebhookDeliveryByID(ctx context.Context, id string) (*models.WebhookDelivery, error) {
+	m.rlock()
+	defer m.runlock()
+
+	d, ok := m.deliveries[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: доставка с id %s", customerrors.ErrNotFound, id)
+	}
+	return copyDelivery(d), nil
+}
+
+func (m *MemoryStorage) ListWebhookDeliveries(ctx context.Context, webhookID string, status models.WebhookDeliveryStatus, offset, limit int) ([]*models.WebhookDelivery, error) {
+	if offset < 0 || limit <= 0 {
+		return nil, fmt.Errorf("%w: неправильный параметр пагинации", customerrors.ErrParamOutOfRange)
+	}
+
+	m.rlock()
+	defer m.runlock()
+
+	matched := make([]*models.WebhookDelivery, 0)
+	for _, d := range m.deliveries {
+		if d.WebhookID == webhookID && (status == "" || d.Status == status) {
+			matched = append(matched, d)
+		}
+	}
+	// от новых к старым
+	sort.Slice(matched, func(i, j int) bool {
+		return pageKeyLess(models.PageKey{CreatedAt: matched[j].CreatedAt, ID: matched[j].ID},
+			models.PageKey{CreatedAt: matched[i].CreatedAt, ID: matched[i].ID})
+	})
+
+	if offset >= len(matched) {
+		return []*models.WebhookDelivery{}, nil
+	}
+	matched = matched[offset:min(offset+limit, len(matched))]
+
+	result := make([]*models.WebhookDelivery, len(matched))
+	for i, d := range matched {
+		result[i] = copyDelivery(d)
+	}
+	return result, nil
+}
@@ -7,8 +7,10 @@ package mocks
 import (
 	context "context"
 	reflect "reflect"
+	time "time"
 
 	models "github.com/MAPiryazev/OzonTest/internal/models"
+	repository "github.com/MAPiryazev/OzonTest/internal/repository"
 	gomock "github.com/golang/mock/gomock"
 )
 
@@ -35,6 +37,21 @@ func (m *MockStorage) EXPECT() *MockStorageMockRecorder {
 	return m.recorder
 }
 
+// ClaimWebhookDeliveries mocks base method.
+func (m *MockStorage) ClaimWebhookDeliveries(ctx context.Context, now, leaseUntil time.Time, limit int) ([]*models.WebhookDelivery, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ClaimWebhookDeliveries", ctx, now, leaseUntil, limit)
+	ret0, _ := ret[0].([]*models.WebhookDelivery)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ClaimWebhookDeliveries indicates an expected call of ClaimWebhookDeliveries.
+func (mr *MockStorageMockRecorder) ClaimWebhookDeliveries(ctx, now, leaseUntil, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ClaimWebhookDeliveries", reflect.TypeOf((*MockStorage)(nil).ClaimWebhookDeliveries), ctx, now, leaseUntil, limit)
+}
+
 // Close mocks base method.
 func (m *MockStorage) Close() error {
 	m.ctrl.T.Helper()
@@ -49,6 +66,21 @@ func (mr *MockStorageMockRecorder) Close() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Close", reflect.TypeOf((*MockStorage)(nil).Close))
 }
 
+// CountUnreadNotifications mocks base method.
+func (m *MockStorage) CountUnreadNotifications(ctx context.Context, userID string) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CountUnreadNotifications", ctx, userID)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CountUnreadNotifications indicates an expected call of CountUnreadNotifications.
+func (mr *MockStorageMockRecorder) CountUnreadNotifications(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CountUnreadNotifications", reflect.TypeOf((*MockStorage)(nil).CountUnreadNotifications), ctx, userID)
+}
+
 // CreateComment mocks base method.
 func (m *MockStorage) CreateComment(ctx context.Context, comment *models.Comment) error {
 	m.ctrl.T.Helper()
@@ -63,6 +95,20 @@ func (mr *MockStorageMockRecorder) CreateComment(ctx, comment interface{}) *gomo
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateComment", reflect.TypeOf((*MockStorage)(nil).CreateComment), ctx, comment)
 }
 
+// CreateNotifications mocks base method.
+func (m *MockStorage) CreateNotifications(ctx context.Context, notifications []*models.Notification) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateNotifications", ctx, notifications)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateNotifications indicates an expected call of CreateNotifications.
+func (mr *MockStorageMockRecorder) CreateNotifications(ctx, notifications interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateNotifications", reflect.TypeOf((*MockStorage)(nil).CreateNotifications), ctx, notifications)
+}
+
 // CreatePost mocks base method.
 func (m *MockStorage) CreatePost(ctx context.Context, post *models.Post) error {
 	m.ctrl.T.Helper()
@@ -91,6 +137,48 @@ func (mr *MockStorageMockRecorder) CreateUser(ctx, user interface{}) *gomock.Cal
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateUser", reflect.TypeOf((*MockStorage)(nil).CreateUser), ctx, user)
 }
 
+// CreateWebhook mocks base method.
+func (m *MockStorage) CreateWebhook(ctx context.Context, webhook *models.Webhook) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateWebhook", ctx, webhook)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateWebhook indicates an expected call of CreateWebhook.
+func (mr *MockStorageMockRecorder) CreateWebhook(ctx, webhook interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateWebhook", reflect.TypeOf((*MockStorage)(nil).CreateWebhook), ctx, webhook)
+}
+
+// CreateWebhookDeliveries mocks base method.
+func (m *MockStorage) CreateWebhookDeliveries(ctx context.Context, deliveries []*models.WebhookDelivery) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateWebhookDeliveries", ctx, deliveries)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateWebhookDeliveries indicates an expected call of CreateWebhookDeliveries.
+func (mr *MockStorageMockRecorder) CreateWebhookDeliveries(ctx, deliveries interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateWebhookDeliveries", reflect.TypeOf((*MockStorage)(nil).CreateWebhookDeliveries), ctx, deliveries)
+}
+
+// DeleteWebhook mocks base method.
+func (m *MockStorage) DeleteWebhook(ctx context.Context, id string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteWebhook", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteWebhook indicates an expected call of DeleteWebhook.
+func (mr *MockStorageMockRecorder) DeleteWebhook(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteWebhook", reflect.TypeOf((*MockStorage)(nil).DeleteWebhook), ctx, id)
+}
+
 // GetCommentByID mocks base method.
 func (m *MockStorage) GetCommentByID(ctx context.Context, id string) (*models.Comment, error) {
 	m.ctrl.T.Helper()
@@ -106,6 +194,51 @@ func (mr *MockStorageMockRecorder) GetCommentByID(ctx, id interface{}) *gomock.C
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCommentByID", reflect.TypeOf((*MockStorage)(nil).GetCommentByID), ctx, id)
 }
 
+// GetCommentThread mocks base method.
+func (m *MockStorage) GetCommentThread(ctx context.Context, postID string, rootID *string, maxDepth, maxNodes int) ([]*models.ThreadComment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetCommentThread", ctx, postID, rootID, maxDepth, maxNodes)
+	ret0, _ := ret[0].([]*models.ThreadComment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetCommentThread indicates an expected call of GetCommentThread.
+func (mr *MockStorageMockRecorder) GetCommentThread(ctx, postID, rootID, maxDepth, maxNodes interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCommentThread", reflect.TypeOf((*MockStorage)(nil).GetCommentThread), ctx, postID, rootID, maxDepth, maxNodes)
+}
+
+// GetConsumerOffset mocks base method.
+func (m *MockStorage) GetConsumerOffset(ctx context.Context, consumer string) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetConsumerOffset", ctx, consumer)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetConsumerOffset indicates an expected call of GetConsumerOffset.
+func (mr *MockStorageMockRecorder) GetConsumerOffset(ctx, consumer interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetConsumerOffset", reflect.TypeOf((*MockStorage)(nil).GetConsumerOffset), ctx, consumer)
+}
+
+// GetMentions mocks base method.
+func (m *MockStorage) GetMentions(ctx context.Context, target models.ReactionTarget, targetIDs []string) (map[string][]models.Mention, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetMentions", ctx, target, targetIDs)
+	ret0, _ := ret[0].(map[string][]models.Mention)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetMentions indicates an expected call of GetMentions.
+func (mr *MockStorageMockRecorder) GetMentions(ctx, target, targetIDs interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMentions", reflect.TypeOf((*MockStorage)(nil).GetMentions), ctx, target, targetIDs)
+}
+
 // GetPostByID mocks base method.
 func (m *MockStorage) GetPostByID(ctx context.Context, id string) (*models.Post, error) {
 	m.ctrl.T.Helper()
@@ -121,6 +254,36 @@ func (mr *MockStorageMockRecorder) GetPostByID(ctx, id interface{}) *gomock.Call
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPostByID", reflect.TypeOf((*MockStorage)(nil).GetPostByID), ctx, id)
 }
 
+// GetPostsByIDs mocks base method.
+func (m *MockStorage) GetPostsByIDs(ctx context.Context, ids []string) ([]*models.Post, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPostsByIDs", ctx, ids)
+	ret0, _ := ret[0].([]*models.Post)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPostsByIDs indicates an expected call of GetPostsByIDs.
+func (mr *MockStorageMockRecorder) GetPostsByIDs(ctx, ids interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPostsByIDs", reflect.TypeOf((*MockStorage)(nil).GetPostsByIDs), ctx, ids)
+}
+
+// GetReactionCounts mocks base method.
+func (m *MockStorage) GetReactionCounts(ctx context.Context, target models.ReactionTarget, targetIDs []string) (map[string][]models.ReactionCount, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetReactionCounts", ctx, target, targetIDs)
+	ret0, _ := ret[0].(map[string][]models.ReactionCount)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetReactionCounts indicates an expected call of GetReactionCounts.
+func (mr *MockStorageMockRecorder) GetReactionCounts(ctx, target, targetIDs interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetReactionCounts", reflect.TypeOf((*MockStorage)(nil).GetReactionCounts), ctx, target, targetIDs)
+}
+
 // GetUserByID mocks base method.
 func (m *MockStorage) GetUserByID(ctx context.Context, id string) (*models.User, error) {
 	m.ctrl.T.Helper()
@@ -136,46 +299,541 @@ func (mr *MockStorageMockRecorder) GetUserByID(ctx, id interface{}) *gomock.Call
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserByID", reflect.TypeOf((*MockStorage)(nil).GetUserByID), ctx, id)
 }
 
+// GetUserByUsername mocks base method.
+func (m *MockStorage) GetUserByUsername(ctx context.Context, username string) (*models.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserByUsername", ctx, username)
+	ret0, _ := ret[0].(*models.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserByUsername indicates an expected call of GetUserByUsername.
+func (mr *MockStorageMockRecorder) GetUserByUsername(ctx, username interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserByUsername", reflect.TypeOf((*MockStorage)(nil).GetUserByUsername), ctx, username)
+}
+
+// GetUserReactions mocks base method.
+func (m *MockStorage) GetUserReactions(ctx context.Context, target models.ReactionTarget, userID string, targetIDs []string) (map[string]models.ReactionKind, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserReactions", ctx, target, userID, targetIDs)
+	ret0, _ := ret[0].(map[string]models.ReactionKind)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserReactions indicates an expected call of GetUserReactions.
+func (mr *MockStorageMockRecorder) GetUserReactions(ctx, target, userID, targetIDs interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserReactions", reflect.TypeOf((*MockStorage)(nil).GetUserReactions), ctx, target, userID, targetIDs)
+}
+
+// GetUsersByIDs mocks base method.
+func (m *MockStorage) GetUsersByIDs(ctx context.Context, ids []string) ([]*models.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUsersByIDs", ctx, ids)
+	ret0, _ := ret[0].([]*models.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUsersByIDs indicates an expected call of GetUsersByIDs.
+func (mr *MockStorageMockRecorder) GetUsersByIDs(ctx, ids interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUsersByIDs", reflect.TypeOf((*MockStorage)(nil).GetUsersByIDs), ctx, ids)
+}
+
+// GetUsersByUsernames mocks base method.
+func (m *MockStorage) GetUsersByUsernames(ctx context.Context, usernames []string) ([]*models.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUsersByUsernames", ctx, usernames)
+	ret0, _ := ret[0].([]*models.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUsersByUsernames indicates an expected call of GetUsersByUsernames.
+func (mr *MockStorageMockRecorder) GetUsersByUsernames(ctx, usernames interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUsersByUsernames", reflect.TypeOf((*MockStorage)(nil).GetUsersByUsernames), ctx, usernames)
+}
+
+// GetWebhookByID mocks base method.
+func (m *MockStorage) GetWebhookByID(ctx context.Context, id string) (*models.Webhook, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetWebhookByID", ctx, id)
+	ret0, _ := ret[0].(*models.Webhook)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetWebhookByID indicates an expected call of GetWebhookByID.
+func (mr *MockStorageMockRecorder) GetWebhookByID(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWebhookByID", reflect.TypeOf((*MockStorage)(nil).GetWebhookByID), ctx, id)
+}
+
+// GetWebhookDeliveryByID mocks base method.
+func (m *MockStorage) GetWebhookDeliveryByID(ctx context.Context, id string) (*models.WebhookDelivery, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetWebhookDeliveryByID", ctx, id)
+	ret0, _ := ret[0].(*models.WebhookDelivery)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetWebhookDeliveryByID indicates an expected call of GetWebhookDeliveryByID.
+func (mr *MockStorageMockRecorder) GetWebhookDeliveryByID(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWebhookDeliveryByID", reflect.TypeOf((*MockStorage)(nil).GetWebhookDeliveryByID), ctx, id)
+}
+
+// ListCommentRevisions mocks base method.
+func (m *MockStorage) ListCommentRevisions(ctx context.Context, commentID string) ([]*models.CommentRevision, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListCommentRevisions", ctx, commentID)
+	ret0, _ := ret[0].([]*models.CommentRevision)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListCommentRevisions indicates an expected call of ListCommentRevisions.
+func (mr *MockStorageMockRecorder) ListCommentRevisions(ctx, commentID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListCommentRevisions", reflect.TypeOf((*MockStorage)(nil).ListCommentRevisions), ctx, commentID)
+}
+
+// ListCommentsAfter mocks base method.
+func (m *MockStorage) ListCommentsAfter(ctx context.Context, postID string, parentID *string, after *models.PageKey, limit int) ([]*models.Comment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListCommentsAfter", ctx, postID, parentID, after, limit)
+	ret0, _ := ret[0].([]*models.Comment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListCommentsAfter indicates an expected call of ListCommentsAfter.
+func (mr *MockStorageMockRecorder) ListCommentsAfter(ctx, postID, parentID, after, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListCommentsAfter", reflect.TypeOf((*MockStorage)(nil).ListCommentsAfter), ctx, postID, parentID, after, limit)
+}
+
+// ListCommentsByAuthor mocks base method.
+func (m *MockStorage) ListCommentsByAuthor(ctx context.Context, authorID string, offset, limit int) ([]*models.Comment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListCommentsByAuthor", ctx, authorID, offset, limit)
+	ret0, _ := ret[0].([]*models.Comment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListCommentsByAuthor indicates an expected call of ListCommentsByAuthor.
+func (mr *MockStorageMockRecorder) ListCommentsByAuthor(ctx, authorID, offset, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListCommentsByAuthor", reflect.TypeOf((*MockStorage)(nil).ListCommentsByAuthor), ctx, authorID, offset, limit)
+}
+
 // ListCommentsByPost mocks base method.
-func (m *MockStorage) ListCommentsByPost(ctx context.Context, postID string, parentID *string, offset, limit int) ([]*models.Comment, error) {
+func (m *MockStorage) ListCommentsByPost(ctx context.Context, postID string, parentID *string, sort models.CommentSort, offset, limit int) ([]*models.Comment, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "ListCommentsByPost", ctx, postID, parentID, offset, limit)
+	ret := m.ctrl.Call(m, "ListCommentsByPost", ctx, postID, parentID, sort, offset, limit)
 	ret0, _ := ret[0].([]*models.Comment)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // ListCommentsByPost indicates an expected call of ListCommentsByPost.
-func (mr *MockStorageMockRecorder) ListCommentsByPost(ctx, postID, parentID, offset, limit interface{}) *gomock.Call {
+func (mr *MockStorageMockRecorder) ListCommentsByPost(ctx, postID, parentID, sort, offset, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListCommentsByPost", reflect.TypeOf((*MockStorage)(nil).ListCommentsByPost), ctx, postID, parentID, sort, offset, limit)
+}
+
+// ListNotifications mocks base method.
+func (m *MockStorage) ListNotifications(ctx context.Context, userID string, unreadOnly bool, after *models.PageKey, limit int) ([]*models.Notification, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListNotifications", ctx, userID, unreadOnly, after, limit)
+	ret0, _ := ret[0].([]*models.Notification)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListNotifications indicates an expected call of ListNotifications.
+func (mr *MockStorageMockRecorder) ListNotifications(ctx, userID, unreadOnly, after, limit interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListCommentsByPost", reflect.TypeOf((*MockStorage)(nil).ListCommentsByPost), ctx, postID, parentID, offset, limit)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListNotifications", reflect.TypeOf((*MockStorage)(nil).ListNotifications), ctx, userID, unreadOnly, after, limit)
+}
+
+// ListOutboxEvents mocks base method.
+func (m *MockStorage) ListOutboxEvents(ctx context.Context, afterSeq int64, limit int) ([]*models.OutboxEvent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListOutboxEvents", ctx, afterSeq, limit)
+	ret0, _ := ret[0].([]*models.OutboxEvent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListOutboxEvents indicates an expected call of ListOutboxEvents.
+func (mr *MockStorageMockRecorder) ListOutboxEvents(ctx, afterSeq, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListOutboxEvents", reflect.TypeOf((*MockStorage)(nil).ListOutboxEvents), ctx, afterSeq, limit)
+}
+
+// ListPostRevisions mocks base method.
+func (m *MockStorage) ListPostRevisions(ctx context.Context, postID string) ([]*models.PostRevision, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListPostRevisions", ctx, postID)
+	ret0, _ := ret[0].([]*models.PostRevision)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListPostRevisions indicates an expected call of ListPostRevisions.
+func (mr *MockStorageMockRecorder) ListPostRevisions(ctx, postID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListPostRevisions", reflect.TypeOf((*MockStorage)(nil).ListPostRevisions), ctx, postID)
 }
 
 // ListPosts mocks base method.
-func (m *MockStorage) ListPosts(ctx context.Context, offset, limit int) ([]*models.Post, error) {
+func (m *MockStorage) ListPosts(ctx context.Context, filter models.PostFilter, sort models.PostSort, offset, limit int) ([]*models.Post, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "ListPosts", ctx, offset, limit)
+	ret := m.ctrl.Call(m, "ListPosts", ctx, filter, sort, offset, limit)
 	ret0, _ := ret[0].([]*models.Post)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // ListPosts indicates an expected call of ListPosts.
-func (mr *MockStorageMockRecorder) ListPosts(ctx, offset, limit interface{}) *gomock.Call {
+func (mr *MockStorageMockRecorder) ListPosts(ctx, filter, sort, offset, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListPosts", reflect.TypeOf((*MockStorage)(nil).ListPosts), ctx, filter, sort, offset, limit)
+}
+
+// ListPostsAfter mocks base method.
+func (m *MockStorage) ListPostsAfter(ctx context.Context, after *models.PageKey, limit int) ([]*models.Post, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListPostsAfter", ctx, after, limit)
+	ret0, _ := ret[0].([]*models.Post)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListPostsAfter indicates an expected call of ListPostsAfter.
+func (mr *MockStorageMockRecorder) ListPostsAfter(ctx, after, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListPostsAfter", reflect.TypeOf((*MockStorage)(nil).ListPostsAfter), ctx, after, limit)
+}
+
+// ListRepliesByParentIDs mocks base method.
+func (m *MockStorage) ListRepliesByParentIDs(ctx context.Context, parentIDs []string, sort models.CommentSort, offset, limit int) (map[string][]*models.Comment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListRepliesByParentIDs", ctx, parentIDs, sort, offset, limit)
+	ret0, _ := ret[0].(map[string][]*models.Comment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListRepliesByParentIDs indicates an expected call of ListRepliesByParentIDs.
+func (mr *MockStorageMockRecorder) ListRepliesByParentIDs(ctx, parentIDs, sort, offset, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListRepliesByParentIDs", reflect.TypeOf((*MockStorage)(nil).ListRepliesByParentIDs), ctx, parentIDs, sort, offset, limit)
+}
+
+// ListWebhookDeliveries mocks base method.
+func (m *MockStorage) ListWebhookDeliveries(ctx context.Context, webhookID string, status models.WebhookDeliveryStatus, offset, limit int) ([]*models.WebhookDelivery, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListWebhookDeliveries", ctx, webhookID, status, offset, limit)
+	ret0, _ := ret[0].([]*models.WebhookDelivery)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListWebhookDeliveries indicates an expected call of ListWebhookDeliveries.
+func (mr *MockStorageMockRecorder) ListWebhookDeliveries(ctx, webhookID, status, offset, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListWebhookDeliveries", reflect.TypeOf((*MockStorage)(nil).ListWebhookDeliveries), ctx, webhookID, status, offset, limit)
+}
+
+// ListWebhooks mocks base method.
+func (m *MockStorage) ListWebhooks(ctx context.Context) ([]*models.Webhook, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListWebhooks", ctx)
+	ret0, _ := ret[0].([]*models.Webhook)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListWebhooks indicates an expected call of ListWebhooks.
+func (mr *MockStorageMockRecorder) ListWebhooks(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListWebhooks", reflect.TypeOf((*MockStorage)(nil).ListWebhooks), ctx)
+}
+
+// MarkNotificationsRead mocks base method.
+func (m *MockStorage) MarkNotificationsRead(ctx context.Context, userID string, ids []string, at time.Time) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MarkNotificationsRead", ctx, userID, ids, at)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// MarkNotificationsRead indicates an expected call of MarkNotificationsRead.
+func (mr *MockStorageMockRecorder) MarkNotificationsRead(ctx, userID, ids, at interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MarkNotificationsRead", reflect.TypeOf((*MockStorage)(nil).MarkNotificationsRead), ctx, userID, ids, at)
+}
+
+// PruneOutbox mocks base method.
+func (m *MockStorage) PruneOutbox(ctx context.Context, uptoSeq int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PruneOutbox", ctx, uptoSeq)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PruneOutbox indicates an expected call of PruneOutbox.
+func (mr *MockStorageMockRecorder) PruneOutbox(ctx, uptoSeq interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PruneOutbox", reflect.TypeOf((*MockStorage)(nil).PruneOutbox), ctx, uptoSeq)
+}
+
+// PurgeComment mocks base method.
+func (m *MockStorage) PurgeComment(ctx context.Context, id string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PurgeComment", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PurgeComment indicates an expected call of PurgeComment.
+func (mr *MockStorageMockRecorder) PurgeComment(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PurgeComment", reflect.TypeOf((*MockStorage)(nil).PurgeComment), ctx, id)
+}
+
+// PurgePost mocks base method.
+func (m *MockStorage) PurgePost(ctx context.Context, id string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PurgePost", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PurgePost indicates an expected call of PurgePost.
+func (mr *MockStorageMockRecorder) PurgePost(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PurgePost", reflect.TypeOf((*MockStorage)(nil).PurgePost), ctx, id)
+}
+
+// RegisterLoginFailure mocks base method.
+func (m *MockStorage) RegisterLoginFailure(ctx context.Context, userID string, maxFailures int, lockUntil time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RegisterLoginFailure", ctx, userID, maxFailures, lockUntil)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RegisterLoginFailure indicates an expected call of RegisterLoginFailure.
+func (mr *MockStorageMockRecorder) RegisterLoginFailure(ctx, userID, maxFailures, lockUntil interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RegisterLoginFailure", reflect.TypeOf((*MockStorage)(nil).RegisterLoginFailure), ctx, userID, maxFailures, lockUntil)
+}
+
+// RemoveReaction mocks base method.
+func (m *MockStorage) RemoveReaction(ctx context.Context, target models.ReactionTarget, targetID, userID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RemoveReaction", ctx, target, targetID, userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RemoveReaction indicates an expected call of RemoveReaction.
+func (mr *MockStorageMockRecorder) RemoveReaction(ctx, target, targetID, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveReaction", reflect.TypeOf((*MockStorage)(nil).RemoveReaction), ctx, target, targetID, userID)
+}
+
+// ReplaceMentions mocks base method.
+func (m *MockStorage) ReplaceMentions(ctx context.Context, target models.ReactionTarget, targetID string, userIDs []string) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReplaceMentions", ctx, target, targetID, userIDs)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReplaceMentions indicates an expected call of ReplaceMentions.
+func (mr *MockStorageMockRecorder) ReplaceMentions(ctx, target, targetID, userIDs interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReplaceMentions", reflect.TypeOf((*MockStorage)(nil).ReplaceMentions), ctx, target, targetID, userIDs)
+}
+
+// ResetLoginFailures mocks base method.
+func (m *MockStorage) ResetLoginFailures(ctx context.Context, userID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ResetLoginFailures", ctx, userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ResetLoginFailures indicates an expected call of ResetLoginFailures.
+func (mr *MockStorageMockRecorder) ResetLoginFailures(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResetLoginFailures", reflect.TypeOf((*MockStorage)(nil).ResetLoginFailures), ctx, userID)
+}
+
+// Search mocks base method.
+func (m *MockStorage) Search(ctx context.Context, query string, searchType models.SearchType, offset, limit int) ([]*models.SearchHit, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Search", ctx, query, searchType, offset, limit)
+	ret0, _ := ret[0].([]*models.SearchHit)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Search indicates an expected call of Search.
+func (mr *MockStorageMockRecorder) Search(ctx, query, searchType, offset, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Search", reflect.TypeOf((*MockStorage)(nil).Search), ctx, query, searchType, offset, limit)
+}
+
+// SetConsumerOffset mocks base method.
+func (m *MockStorage) SetConsumerOffset(ctx context.Context, consumer string, seq int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetConsumerOffset", ctx, consumer, seq)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetConsumerOffset indicates an expected call of SetConsumerOffset.
+func (mr *MockStorageMockRecorder) SetConsumerOffset(ctx, consumer, seq interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetConsumerOffset", reflect.TypeOf((*MockStorage)(nil).SetConsumerOffset), ctx, consumer, seq)
+}
+
+// SetReaction mocks base method.
+func (m *MockStorage) SetReaction(ctx context.Context, reaction *models.Reaction) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetReaction", ctx, reaction)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetReaction indicates an expected call of SetReaction.
+func (mr *MockStorageMockRecorder) SetReaction(ctx, reaction interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListPosts", reflect.TypeOf((*MockStorage)(nil).ListPosts), ctx, offset, limit)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetReaction", reflect.TypeOf((*MockStorage)(nil).SetReaction), ctx, reaction)
+}
+
+// SetUserRole mocks base method.
+func (m *MockStorage) SetUserRole(ctx context.Context, userID string, role models.Role) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetUserRole", ctx, userID, role)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetUserRole indicates an expected call of SetUserRole.
+func (mr *MockStorageMockRecorder) SetUserRole(ctx, userID, role interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetUserRole", reflect.TypeOf((*MockStorage)(nil).SetUserRole), ctx, userID, role)
+}
+
+// SetWebhookActive mocks base method.
+func (m *MockStorage) SetWebhookActive(ctx context.Context, id string, active bool) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetWebhookActive", ctx, id, active)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetWebhookActive indicates an expected call of SetWebhookActive.
+func (mr *MockStorageMockRecorder) SetWebhookActive(ctx, id, active interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetWebhookActive", reflect.TypeOf((*MockStorage)(nil).SetWebhookActive), ctx, id, active)
+}
+
+// SoftDeleteComment mocks base method.
+func (m *MockStorage) SoftDeleteComment(ctx context.Context, id string, at time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SoftDeleteComment", ctx, id, at)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SoftDeleteComment indicates an expected call of SoftDeleteComment.
+func (mr *MockStorageMockRecorder) SoftDeleteComment(ctx, id, at interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SoftDeleteComment", reflect.TypeOf((*MockStorage)(nil).SoftDeleteComment), ctx, id, at)
+}
+
+// SoftDeletePost mocks base method.
+func (m *MockStorage) SoftDeletePost(ctx context.Context, id string, at time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SoftDeletePost", ctx, id, at)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SoftDeletePost indicates an expected call of SoftDeletePost.
+func (mr *MockStorageMockRecorder) SoftDeletePost(ctx, id, at interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SoftDeletePost", reflect.TypeOf((*MockStorage)(nil).SoftDeletePost), ctx, id, at)
+}
+
+// UpdateComment mocks base method.
+func (m *MockStorage) UpdateComment(ctx context.Context, comment *models.Comment, revision *models.CommentRevision) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateComment", ctx, comment, revision)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateComment indicates an expected call of UpdateComment.
+func (mr *MockStorageMockRecorder) UpdateComment(ctx, comment, revision interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateComment", reflect.TypeOf((*MockStorage)(nil).UpdateComment), ctx, comment, revision)
 }
 
 // UpdatePost mocks base method.
-func (m *MockStorage) UpdatePost(ctx context.Context, post *models.Post) error {
+func (m *MockStorage) UpdatePost(ctx context.Context, post *models.Post, revision *models.PostRevision) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "UpdatePost", ctx, post)
+	ret := m.ctrl.Call(m, "UpdatePost", ctx, post, revision)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // UpdatePost indicates an expected call of UpdatePost.
-func (mr *MockStorageMockRecorder) UpdatePost(ctx, post interface{}) *gomock.Call {
+func (mr *MockStorageMockRecorder) UpdatePost(ctx, post, revision interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdatePost", reflect.TypeOf((*MockStorage)(nil).UpdatePost), ctx, post, revision)
+}
+
+// UpdateWebhookDelivery mocks base method.
+func (m *MockStorage) UpdateWebhookDelivery(ctx context.Context, delivery *models.WebhookDelivery) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateWebhookDelivery", ctx, delivery)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateWebhookDelivery indicates an expected call of UpdateWebhookDelivery.
+func (mr *MockStorageMockRecorder) UpdateWebhookDelivery(ctx, delivery interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateWebhookDelivery", reflect.TypeOf((*MockStorage)(nil).UpdateWebhookDelivery), ctx, delivery)
+}
+
+// WithTx mocks base method.
+func (m *MockStorage) WithTx(ctx context.Context, fn func(repository.Storage) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WithTx", ctx, fn)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// WithTx indicates an expected call of WithTx.
+func (mr *MockStorageMockRecorder) WithTx(ctx, fn interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdatePost", reflect.TypeOf((*MockStorage)(nil).UpdatePost), ctx, post)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WithTx", reflect.TypeOf((*MockStorage)(nil).WithTx), ctx, fn)
 }
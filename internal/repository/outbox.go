@@ -0,0 +1,18 @@
+package repository
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/MAPiryazev/OzonTest/internal/models"
+)
+
+// собирает событие outbox без Seq, его назначает хранилище при записи
+func NewOutboxEvent(eventType models.EventType, aggregateID string, entity any, at time.Time) (*models.OutboxEvent, error) {
+	payload, err := json.Marshal(entity)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось сериализовать событие %s: %w", eventType, err)
+	}
+	return &models.OutboxEvent{Type: eventType, AggregateID: aggregateID, Payload: string(payload), CreatedAt: at.UTC()}, nil
+}
@@ -0,0 +1,125 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/MAPiryazev/OzonTest/internal/customerrors"
+	"github.com/MAPiryazev/OzonTest/internal/models"
+	"github.com/lib/pq"
+)
+
+// таблица упоминаний и колонка объекта, внешние ключи удаляют упоминания вместе с объектом
+func mentionTableFor(target models.ReactionTarget) (table, column string, err error) {
+	switch target {
+	case models.ReactionTargetPost:
+		return "post_mentions", "post_id", nil
+	case models.ReactionTargetComment:
+		return "comment_mentions", "comment_id", nil
+	}
+	return "", "", fmt.Errorf("%w: неизвестный объект упоминания %s", customerrors.ErrValidation, target)
+}
+
+func (p *PostgresStorage) GetUsersByUsernames(ctx context.Context, usernames []string) ([]*models.User, error) {
+	query := `select id, username, role from users where username = any($1)`
+	rows, err := p.conn.QueryContext(ctx, query, pq.Array(usernames))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	defer rows.Close()
+
+	users := make([]*models.User, 0, len(usernames))
+	for rows.Next() {
+		var u models.User
+		if err := rows.Scan(&u.ID, &u.Username, &u.Role); err != nil {
+			return nil, fmt.Errorf("%w: %v", customerrors.ErrDBScan, err)
+		}
+		users = append(users, &u)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	return users, nil
+}
+
+// строки старых упоминаний блокируются до конца транзакции, список новых считается по ним
+func (p *PostgresStorage) ReplaceMentions(ctx context.Context, target models.ReactionTarget, targetID string, userIDs []string) ([]string, error) {
+	table, column, err := mentionTableFor(target)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := p.beginTx(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `select user_id from `+table+` where `+column+` = $1 for update`, targetID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	previous := make(map[string]struct{})
+	for rows.Next() {
+		var userID string
+		if err := rows.Scan(&userID); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("%w: %v", customerrors.ErrDBScan, err)
+		}
+		previous[userID] = struct{}{}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `delete from `+table+` where `+column+` = $1`, targetID); err != nil {
+		return nil, fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	query := `insert into ` + table + ` (` + column + `, user_id, position) values ($1, $2, $3)`
+	added := []string{}
+	for i, userID := range userIDs {
+		if _, err := tx.ExecContext(ctx, query, targetID, userID, i); err != nil {
+			return nil, fmt.Errorf("%w: упоминание %s: %v", customerrors.ErrDBQuery, userID, err)
+		}
+		if _, ok := previous[userID]; !ok {
+			added = append(added, userID)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	return added, nil
+}
+
+func (p *PostgresStorage) GetMentions(ctx context.Context, target models.ReactionTarget, targetIDs []string) (map[string][]models.Mention, error) {
+	table, column, err := mentionTableFor(target)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `select m.` + column + `, m.user_id, u.username from ` + table + ` m
+			join users u on u.id = m.user_id
+			where m.` + column + ` = any($1)
+			order by m.` + column + `, m.position`
+	rows, err := p.conn.QueryContext(ctx, query, pq.Array(targetIDs))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	defer rows.Close()
+
+	result := make(map[string][]models.Mention, len(targetIDs))
+	for rows.Next() {
+		var id string
+		var mention models.Mention
+		if err := rows.Scan(&id, &mention.UserID, &mention.Username); err != nil {
+			return nil, fmt.Errorf("%w: %v", customerrors.ErrDBScan, err)
+		}
+		result[id] = append(result[id], mention)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	return result, nil
+}
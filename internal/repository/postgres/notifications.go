@@ -0,0 +1,114 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/MAPiryazev/OzonTest/internal/customerrors"
+	"github.com/MAPiryazev/OzonTest/internal/models"
+	"github.com/lib/pq"
+)
+
+const notificationColumns = `id, user_id, kind, actor_id, post_id, comment_id, created_at, read_at`
+
+func scanNotification(row scanner) (*models.Notification, error) {
+	var n models.Notification
+	var readAt sql.NullTime
+	if err := row.Scan(&n.ID, &n.UserID, &n.Kind, &n.ActorID, &n.PostID, &n.CommentID, &n.CreatedAt, &readAt); err != nil {
+		return nil, err
+	}
+	n.ReadAt = nullTime(readAt)
+	return &n, nil
+}
+
+// уведомления одного события создаются одной транзакцией, уже сохраненные id пропускаются
+func (p *PostgresStorage) CreateNotifications(ctx context.Context, notifications []*models.Notification) error {
+	if len(notifications) == 0 {
+		return nil
+	}
+
+	tx, err := p.beginTx(ctx)
+	if err != nil {
+		return fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	defer tx.Rollback()
+
+	query := `insert into notifications (` + notificationColumns + `) values ($1, $2, $3, $4, $5, $6, $7, $8) on conflict (id) do nothing`
+	for _, n := range notifications {
+		if _, err := tx.ExecContext(ctx, query, n.ID, n.UserID, n.Kind, n.ActorID, n.PostID, n.CommentID, n.CreatedAt, n.ReadAt); err != nil {
+			return fmt.Errorf("%w: уведомление %s: %v", customerrors.ErrDBQuery, n.ID, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	return nil
+}
+
+func (p *PostgresStorage) ListNotifications(ctx context.Context, userID string, unreadOnly bool, after *models.PageKey, limit int) ([]*models.Notification, error) {
+	if limit <= 0 {
+		return nil, fmt.Errorf("%w: неправильные параметры пагинации", customerrors.ErrParamOutOfRange)
+	}
+
+	query := `select ` + notificationColumns + ` from notifications where user_id = $1`
+	args := []any{userID}
+	if unreadOnly {
+		query += ` and read_at is null`
+	}
+	if after != nil {
+		query += ` and (created_at, id) < ($2, $3::uuid)`
+		args = append(args, after.CreatedAt, after.ID)
+	}
+	query += fmt.Sprintf(` order by created_at desc, id desc limit $%d`, len(args)+1)
+	args = append(args, limit)
+
+	rows, err := p.conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	defer rows.Close()
+
+	notifications := []*models.Notification{}
+	for rows.Next() {
+		n, err := scanNotification(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", customerrors.ErrDBScan, err)
+		}
+		notifications = append(notifications, n)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	return notifications, nil
+}
+
+func (p *PostgresStorage) CountUnreadNotifications(ctx context.Context, userID string) (int, error) {
+	var count int
+	query := `select count(*) from notifications where user_id = $1 and read_at is null`
+	if err := p.conn.QueryRowContext(ctx, query, userID).Scan(&count); err != nil {
+		return 0, fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	return count, nil
+}
+
+// уже прочитанные уведомления не трогаются, поэтому время прочтения не перезаписывается
+func (p *PostgresStorage) MarkNotificationsRead(ctx context.Context, userID string, ids []string, at time.Time) (int, error) {
+	query := `update notifications set read_at = $2 where user_id = $1 and read_at is null`
+	args := []any{userID, at}
+	if ids != nil {
+		query += ` and id = any($3)`
+		args = append(args, pq.Array(ids))
+	}
+
+	res, err := p.conn.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	marked, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	return int(marked), nil
+}
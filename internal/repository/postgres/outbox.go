@@ -0,0 +1,91 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/MAPiryazev/OzonTest/internal/customerrors"
+	"github.com/MAPiryazev/OzonTest/internal/models"
+	"github.com/MAPiryazev/OzonTest/internal/repository"
+)
+
+// пишет событие в outbox внутри транзакции изменения данных. seq выдаются в порядке вставки, а не фиксации,
+// поэтому рядом с событием сохраняется граница видимости: xmax снимка, снятого уже после выдачи seq.
+// все транзакции, получившие меньший seq, к этому моменту имеют xid меньше этой границы
+func writeOutbox(ctx context.Context, tx dbtx, eventType models.EventType, aggregateID string, entity any) error {
+	event, err := repository.NewOutboxEvent(eventType, aggregateID, entity, time.Now())
+	if err != nil {
+		return err
+	}
+	if err := tx.QueryRowContext(ctx, `select nextval(pg_get_serial_sequence('outbox', 'seq'))`).Scan(&event.Seq); err != nil {
+		return fmt.Errorf("%w: seq события %s: %v", customerrors.ErrDBQuery, eventType, err)
+	}
+	// отдельный запрос, чтобы снимок был снят после nextval
+	query := `insert into outbox (seq, type, aggregate_id, payload, created_at, visible_after)
+			values ($1,$2,$3,$4,$5,pg_snapshot_xmax(pg_current_snapshot()))`
+	if _, err := tx.ExecContext(ctx, query, event.Seq, event.Type, event.AggregateID, event.Payload, event.CreatedAt); err != nil {
+		return fmt.Errorf("%w: событие %s: %v", customerrors.ErrDBQuery, eventType, err)
+	}
+	return nil
+}
+
+// отдает события до первого, граница видимости которого еще не пройдена: пока идут транзакции,
+// начатые до его записи, событие с меньшим seq может быть еще не зафиксировано, и смещение потребителя его бы перескочило
+func (p *PostgresStorage) ListOutboxEvents(ctx context.Context, afterSeq int64, limit int) ([]*models.OutboxEvent, error) {
+	query := `select seq, type, aggregate_id, payload, created_at from outbox
+			where seq > $1 and seq < coalesce((
+				select min(seq) from outbox
+				where seq > $1 and visible_after > pg_snapshot_xmin(pg_current_snapshot())
+			), 9223372036854775807)
+			order by seq asc
+			limit $2`
+	rows, err := p.conn.QueryContext(ctx, query, afterSeq, limit)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	defer rows.Close()
+
+	events := []*models.OutboxEvent{}
+	for rows.Next() {
+		var e models.OutboxEvent
+		if err := rows.Scan(&e.Seq, &e.Type, &e.AggregateID, &e.Payload, &e.CreatedAt); err != nil {
+			return nil, fmt.Errorf("%w: %v", customerrors.ErrDBScan, err)
+		}
+		events = append(events, &e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	return events, nil
+}
+
+func (p *PostgresStorage) GetConsumerOffset(ctx context.Context, consumer string) (int64, error) {
+	var seq int64
+	err := p.conn.QueryRowContext(ctx, `select seq from outbox_offsets where consumer = $1`, consumer).Scan(&seq)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	return seq, nil
+}
+
+func (p *PostgresStorage) SetConsumerOffset(ctx context.Context, consumer string, seq int64) error {
+	query := `insert into outbox_offsets (consumer, seq, updated_at) values ($1,$2,now())
+			on conflict (consumer) do update set seq = greatest(outbox_offsets.seq, excluded.seq), updated_at = excluded.updated_at`
+	if _, err := p.conn.ExecContext(ctx, query, consumer, seq); err != nil {
+		return fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	return nil
+}
+
+func (p *PostgresStorage) PruneOutbox(ctx context.Context, uptoSeq int64) error {
+	if _, err := p.conn.ExecContext(ctx, `delete from outbox where seq <= $1`, uptoSeq); err != nil {
+		return fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	return nil
+}
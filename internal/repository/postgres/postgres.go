@@ -10,17 +10,39 @@ import (
 
 	"github.com/MAPiryazev/OzonTest/internal/config"
 	"github.com/MAPiryazev/OzonTest/internal/customerrors"
+	"github.com/MAPiryazev/OzonTest/internal/migrate"
 	"github.com/MAPiryazev/OzonTest/internal/models"
-	_ "github.com/lib/pq"
+	"github.com/MAPiryazev/OzonTest/migrations"
+	"github.com/lib/pq"
 )
 
 // реализация интерфейса storage как хранилища в postgres
 
 type PostgresStorage struct {
 	db *sql.DB
+	// через conn идут все запросы: это db или транзакция WithTx
+	conn dbtx
+	// транзакция WithTx, nil вне ее
+	tx *sql.Tx
 }
 
 func NewPostgresStorage(cfg *config.DBConfig) (*PostgresStorage, error) {
+	db, err := OpenDB(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	// схема не применяется автоматически, ее накатывают командой migrate up
+	if err := checkSchema(db); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+
+	return &PostgresStorage{db: db, conn: db}, nil
+}
+
+// открывает пул соединений без проверки схемы, используется и командой migrate
+func OpenDB(cfg *config.DBConfig) (*sql.DB, error) {
 	dbCredentials := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s", cfg.DBHost, cfg.DBPort, cfg.DBUser, cfg.DBPassword, cfg.DBName, cfg.DBSSLMode)
 
 	db, err := sql.Open("postgres", dbCredentials)
@@ -38,43 +60,85 @@ func NewPostgresStorage(cfg *config.DBConfig) (*PostgresStorage, error) {
 		return nil, fmt.Errorf("ошибка при проверке соединения с БД: %v", err)
 	}
 
-	return &PostgresStorage{db: db}, nil
+	return db, nil
+}
+
+func NewMigrator(db *sql.DB) (*migrate.Migrator, error) {
+	list, err := migrate.Load(migrations.Postgres, "postgres")
+	if err != nil {
+		return nil, err
+	}
+	return migrate.New(db, migrate.Postgres, list), nil
+}
+
+func checkSchema(db *sql.DB) error {
+	migrator, err := NewMigrator(db)
+	if err != nil {
+		return err
+	}
+	pending, err := migrator.Pending(context.Background())
+	if err != nil {
+		return err
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("%w: не применено миграций: %d, первая %d_%s", customerrors.ErrSchemaOutdated, len(pending), pending[0].Version, pending[0].Name)
+	}
+	return nil
 }
 
 func (p *PostgresStorage) Close() error {
-	if p.db == nil {
+	// хранилище транзакции не владеет пулом, закрывается только исходное
+	if p.tx != nil || p.db == nil {
 		return nil
 	}
 	return p.db.Close()
 }
 
-// проверяет есть ли пользователь в БД и если нет, то добавляет
+// проверяет есть ли пользователь в БД и если нет, то добавляет вместе с событием user_created
 func (p *PostgresStorage) CreateUser(ctx context.Context, user *models.User) error {
 	trimmedName := strings.TrimSpace(user.Username)
 
+	tx, err := p.beginTx(ctx)
+	if err != nil {
+		return fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	defer tx.Rollback()
+
 	var exists bool
 	checkQuery := `select exists(select 1 from users where username=$1)`
-	if err := p.db.QueryRow(checkQuery, trimmedName).Scan(&exists); err != nil {
+	if err := tx.QueryRowContext(ctx, checkQuery, trimmedName).Scan(&exists); err != nil {
 		return fmt.Errorf("ошибка проверки существования пользователя: %w", err)
 	}
 	if exists {
 		return fmt.Errorf("пользователь %s уже существует: %w", trimmedName, customerrors.ErrAlreadyExists)
 	}
 
-	insertQuery := `insert into users (id, username) values ($1,$2)`
-	if _, err := p.db.Exec(insertQuery, user.ID, trimmedName); err != nil {
+	if user.Role == "" {
+		user.Role = models.RoleUser
+	}
+
+	insertQuery := `insert into users (id, username, password_hash, role) values ($1,$2,$3,$4)`
+	if _, err := tx.ExecContext(ctx, insertQuery, user.ID, trimmedName, user.PasswordHash, user.Role); err != nil {
 		return fmt.Errorf("не удалось создать пользователя с id %s: %w", user.ID, err)
 	}
 
+	created := models.UserCreatedPayload{ID: user.ID, Username: trimmedName, Role: user.Role}
+	if err := writeOutbox(ctx, tx, models.EventUserCreated, user.ID, created); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
 	return nil
 }
 
 func (p *PostgresStorage) GetUserByID(ctx context.Context, id string) (*models.User, error) {
-	query := `select id, username from users where id = $1`
-	row := p.db.QueryRow(query, id)
+	query := `select id, username, role from users where id = $1`
+	row := p.conn.QueryRowContext(ctx, query, id)
 
 	var u models.User
-	err := row.Scan(&u.ID, &u.Username)
+	err := row.Scan(&u.ID, &u.Username, &u.Role)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, fmt.Errorf("%w: пользователь с id %s", customerrors.ErrNotFound, id)
@@ -85,93 +149,466 @@ func (p *PostgresStorage) GetUserByID(ctx context.Context, id string) (*models.U
 	return &u, nil
 }
 
+func (p *PostgresStorage) GetUserByUsername(ctx context.Context, username string) (*models.User, error) {
+	query := `select id, username, role, password_hash, failed_logins, locked_until from users where username = $1`
+
+	var u models.User
+	var lockedUntil sql.NullTime
+	err := p.conn.QueryRowContext(ctx, query, username).Scan(&u.ID, &u.Username, &u.Role, &u.PasswordHash, &u.FailedLogins, &lockedUntil)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: пользователь с именем %s", customerrors.ErrNotFound, username)
+		}
+		return nil, fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	if lockedUntil.Valid {
+		t := lockedUntil.Time.UTC()
+		u.LockedUntil = &t
+	}
+	return &u, nil
+}
+
+func (p *PostgresStorage) RegisterLoginFailure(ctx context.Context, userID string, maxFailures int, lockUntil time.Time) error {
+	query := `update users set
+		failed_logins = case when failed_logins + 1 >= $2 then 0 else failed_logins + 1 end,
+		locked_until = case when failed_logins + 1 >= $2 then $3 else locked_until end
+		where id = $1`
+	res, err := p.conn.ExecContext(ctx, query, userID, maxFailures, lockUntil.UTC())
+	if err != nil {
+		return fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	return requireAffected(res, "пользователь", userID)
+}
+
+func (p *PostgresStorage) SetUserRole(ctx context.Context, userID string, role models.Role) error {
+	query := `update users set role = $2 where id = $1`
+	res, err := p.conn.ExecContext(ctx, query, userID, role)
+	if err != nil {
+		return fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	return requireAffected(res, "пользователь", userID)
+}
+
+func (p *PostgresStorage) ResetLoginFailures(ctx context.Context, userID string) error {
+	query := `update users set failed_logins = 0, locked_until = null where id = $1`
+	res, err := p.conn.ExecContext(ctx, query, userID)
+	if err != nil {
+		return fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	return requireAffected(res, "пользователь", userID)
+}
+
+// возвращает пользователей одним запросом, отсутствующие id пропускаются
+func (p *PostgresStorage) GetUsersByIDs(ctx context.Context, ids []string) ([]*models.User, error) {
+	query := `select id, username, role from users where id = any($1)`
+	rows, err := p.conn.QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	defer rows.Close()
+
+	users := make([]*models.User, 0, len(ids))
+	for rows.Next() {
+		var u models.User
+		if err := rows.Scan(&u.ID, &u.Username, &u.Role); err != nil {
+			return nil, fmt.Errorf("%w: %v", customerrors.ErrDBScan, err)
+		}
+		users = append(users, &u)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	return users, nil
+}
+
+const postColumns = `id, title, content, author_id, comments_enabled, created_at, edited_at, deleted_at`
+
+// общий интерфейс для *sql.Row и *sql.Rows
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanPost(row scanner) (*models.Post, error) {
+	var post models.Post
+	var editedAt, deletedAt sql.NullTime
+	if err := row.Scan(&post.ID, &post.Title, &post.Content, &post.AuthorID, &post.CommentsEnabled, &post.CreatedAt, &editedAt, &deletedAt); err != nil {
+		return nil, err
+	}
+	post.EditedAt = nullTime(editedAt)
+	post.DeletedAt = nullTime(deletedAt)
+	return &post, nil
+}
+
+const commentColumns = `id, post_id, parent_id, author_id, text, created_at, edited_at, deleted_at`
+
+// extra - дополнительные колонки после commentColumns, например глубина в дереве
+func scanComment(row scanner, extra ...any) (*models.Comment, error) {
+	var comment models.Comment
+	var editedAt, deletedAt sql.NullTime
+	dest := append([]any{&comment.ID, &comment.PostID, &comment.ParentID, &comment.AuthorID, &comment.Text, &comment.CreatedAt, &editedAt, &deletedAt}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+	comment.EditedAt = nullTime(editedAt)
+	comment.DeletedAt = nullTime(deletedAt)
+	return &comment, nil
+}
+
+func nullTime(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	v := t.Time
+	return &v
+}
+
+func collectPosts(rows *sql.Rows) ([]*models.Post, error) {
+	defer rows.Close()
+
+	posts := []*models.Post{}
+	for rows.Next() {
+		post, err := scanPost(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", customerrors.ErrDBScan, err)
+		}
+		posts = append(posts, post)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	return posts, nil
+}
+
+func collectComments(rows *sql.Rows) ([]*models.Comment, error) {
+	defer rows.Close()
+
+	comments := []*models.Comment{}
+	for rows.Next() {
+		comment, err := scanComment(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", customerrors.ErrDBScan, err)
+		}
+		comments = append(comments, comment)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	return comments, nil
+}
+
+// добавляет пост и событие post_created в одной транзакции
 func (p *PostgresStorage) CreatePost(ctx context.Context, post *models.Post) error {
 	if post.CreatedAt.IsZero() {
 		post.CreatedAt = time.Now()
 	}
+	tx, err := p.beginTx(ctx)
+	if err != nil {
+		return fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	defer tx.Rollback()
+
 	query := `insert into posts (id, title, content, author_id, comments_enabled, created_at) values ($1,$2,$3,$4,$5,$6)`
-	_, err := p.db.Exec(query, post.ID, post.Title, post.Content, post.AuthorID, post.CommentsEnabled, post.CreatedAt)
+	_, err = tx.ExecContext(ctx, query, post.ID, post.Title, post.Content, post.AuthorID, post.CommentsEnabled, post.CreatedAt)
 	if err != nil {
 		return fmt.Errorf("ошибка при создании поста с id %s: %w", post.ID, err)
 	}
+	if err := writeOutbox(ctx, tx, models.EventPostCreated, post.ID, post); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
 	return nil
 }
 
+// удаленные посты не отдаются ни одним методом чтения
 func (p *PostgresStorage) GetPostByID(ctx context.Context, id string) (*models.Post, error) {
-	query := `select id, title, content, author_id, comments_enabled, created_at from posts where id = $1`
-	row := p.db.QueryRow(query, id)
+	query := `select ` + postColumns + ` from posts where id = $1 and deleted_at is null` + p.rowLock()
+	row := p.conn.QueryRowContext(ctx, query, id)
 
-	var currPost models.Post
-	err := row.Scan(&currPost.ID, &currPost.Title, &currPost.Content, &currPost.AuthorID, &currPost.CommentsEnabled, &currPost.CreatedAt)
+	currPost, err := scanPost(row)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, fmt.Errorf("%w: пост с id %s", customerrors.ErrNotFound, id)
 		}
 		return nil, fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
 	}
-	return &currPost, nil
+	return currPost, nil
+}
+
+// возвращает посты одним запросом, отсутствующие id пропускаются
+func (p *PostgresStorage) GetPostsByIDs(ctx context.Context, ids []string) ([]*models.Post, error) {
+	query := `select ` + postColumns + ` from posts where id = any($1) and deleted_at is null`
+	rows, err := p.conn.QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	return collectPosts(rows)
+}
+
+// порядок постов, для сортировок по комментариям нужна статистика из подзапроса stats
+func postOrder(sort models.PostSort) (order string, withStats bool, err error) {
+	switch sort {
+	case models.PostSortNewest, "":
+		return `created_at desc, id desc`, false, nil
+	case models.PostSortOldest:
+		return `created_at asc, id asc`, false, nil
+	case models.PostSortMostCommented:
+		return `coalesce(stats.comment_count, 0) desc, created_at desc, id desc`, true, nil
+	case models.PostSortRecentlyActive:
+		// greatest пропускает null, пост без комментариев активен с момента создания
+		return `greatest(created_at, stats.last_comment_at) desc, created_at desc, id desc`, true, nil
+	}
+	return "", false, fmt.Errorf("%w: неизвестная сортировка постов %s", customerrors.ErrValidation, sort)
 }
 
-func (p *PostgresStorage) ListPosts(ctx context.Context, offset, limit int) ([]*models.Post, error) {
+// фильтр по автору использует idx_posts_author_id, статистика комментариев считается только для нужных сортировок
+func (p *PostgresStorage) ListPosts(ctx context.Context, filter models.PostFilter, sort models.PostSort, offset, limit int) ([]*models.Post, error) {
 	if offset < 0 || limit <= 0 {
 		return nil, fmt.Errorf("%w: неправильные параметры пагинации", customerrors.ErrParamOutOfRange)
 	}
+	order, withStats, err := postOrder(sort)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `select ` + postColumns + ` from posts`
+	if withStats {
+		query += ` left join (
+				select post_id, count(*) as comment_count, max(created_at) as last_comment_at
+				from comments where deleted_at is null group by post_id
+			) as stats on stats.post_id = posts.id`
+	}
+	query += ` where posts.deleted_at is null`
+
+	args := []any{}
+	if filter.AuthorID != nil {
+		args = append(args, *filter.AuthorID)
+		query += fmt.Sprintf(` and author_id = $%d`, len(args))
+	}
+	if filter.CreatedAfter != nil {
+		args = append(args, *filter.CreatedAfter)
+		query += fmt.Sprintf(` and created_at >= $%d`, len(args))
+	}
+	if filter.CreatedBefore != nil {
+		args = append(args, *filter.CreatedBefore)
+		query += fmt.Sprintf(` and created_at < $%d`, len(args))
+	}
+	if filter.CommentsEnabled != nil {
+		args = append(args, *filter.CommentsEnabled)
+		query += fmt.Sprintf(` and comments_enabled = $%d`, len(args))
+	}
+	if filter.HasComments != nil {
+		exists := `exists (select 1 from comments c where c.post_id = posts.id and c.deleted_at is null)`
+		if !*filter.HasComments {
+			exists = `not ` + exists
+		}
+		query += ` and ` + exists
+	}
+	args = append(args, offset, limit)
+	query += fmt.Sprintf(` order by %s offset $%d limit $%d`, order, len(args)-1, len(args))
+
+	rows, err := p.conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	return collectPosts(rows)
+}
+
+func (p *PostgresStorage) ListPostsAfter(ctx context.Context, after *models.PageKey, limit int) ([]*models.Post, error) {
+	if limit <= 0 {
+		return nil, fmt.Errorf("%w: неправильные параметры пагинации", customerrors.ErrParamOutOfRange)
+	}
+
+	var rows *sql.Rows
+	var err error
+
+	if after == nil {
+		query := `select ` + postColumns + ` from posts
+				where deleted_at is null
+				order by created_at desc, id desc
+				limit $1`
+		rows, err = p.conn.QueryContext(ctx, query, limit)
+	} else {
+		query := `select ` + postColumns + ` from posts
+				where deleted_at is null and (created_at, id) < ($1, $2::uuid)
+				order by created_at desc, id desc
+				limit $3`
+		rows, err = p.conn.QueryContext(ctx, query, after.CreatedAt, after.ID, limit)
+	}
 
-	query := `select id, title, content, author_id, comments_enabled, created_at from posts order by created_at desc offset $1 limit $2`
-	rows, err := p.db.Query(query, offset, limit)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	return collectPosts(rows)
+}
+
+// обновляет пост и сохраняет ревизию и событие post_updated в одной транзакции
+func (p *PostgresStorage) UpdatePost(ctx context.Context, post *models.Post, revision *models.PostRevision) error {
+	tx, err := p.beginTx(ctx)
+	if err != nil {
+		return fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	defer tx.Rollback()
+
+	query := `update posts set title=$1, content=$2, comments_enabled=$3, edited_at=$4 where id=$5 and deleted_at is null`
+	res, err := tx.ExecContext(ctx, query, post.Title, post.Content, post.CommentsEnabled, post.EditedAt, post.ID)
+	if err != nil {
+		return fmt.Errorf("ошибка при обновлении поста: %w", err)
+	}
+	if err := requireAffected(res, "пост", post.ID); err != nil {
+		return err
+	}
+
+	if revision != nil {
+		query := `insert into post_revisions (id, post_id, title, content, editor_id, created_at) values ($1,$2,$3,$4,$5,$6)`
+		if _, err := tx.ExecContext(ctx, query, revision.ID, revision.PostID, revision.Title, revision.Content, revision.EditorID, revision.CreatedAt); err != nil {
+			return fmt.Errorf("%w: ревизия поста %s: %v", customerrors.ErrDBQuery, post.ID, err)
+		}
+	}
+	if err := writeOutbox(ctx, tx, models.EventPostUpdated, post.ID, post); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	return nil
+}
+
+func (p *PostgresStorage) ListPostRevisions(ctx context.Context, postID string) ([]*models.PostRevision, error) {
+	query := `select id, post_id, title, content, editor_id, created_at from post_revisions
+			where post_id = $1
+			order by created_at asc, id asc`
+	rows, err := p.conn.QueryContext(ctx, query, postID)
 	if err != nil {
 		return nil, fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
 	}
 	defer rows.Close()
 
-	var posts []*models.Post
+	revisions := []*models.PostRevision{}
 	for rows.Next() {
-		var post models.Post
-		if err := rows.Scan(&post.ID, &post.Title, &post.Content, &post.AuthorID, &post.CommentsEnabled, &post.CreatedAt); err != nil {
+		var rev models.PostRevision
+		if err := rows.Scan(&rev.ID, &rev.PostID, &rev.Title, &rev.Content, &rev.EditorID, &rev.CreatedAt); err != nil {
 			return nil, fmt.Errorf("%w: %v", customerrors.ErrDBScan, err)
 		}
-		posts = append(posts, &post)
+		revisions = append(revisions, &rev)
 	}
-	return posts, nil
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	return revisions, nil
 }
 
-// обновляет пост
-func (p *PostgresStorage) UpdatePost(ctx context.Context, post *models.Post) error {
-	query := `update posts set title=$1, content=$2, comments_enabled=$3 where id=$4`
-	res, err := p.db.Exec(query, post.Title, post.Content, post.CommentsEnabled, post.ID)
+// помечает пост удаленным, повторное удаление дает ErrNotFound
+func (p *PostgresStorage) SoftDeletePost(ctx context.Context, id string, at time.Time) error {
+	query := `update posts set deleted_at = $2 where id = $1 and deleted_at is null`
+	res, err := p.conn.ExecContext(ctx, query, id, at)
 	if err != nil {
-		return fmt.Errorf("ошибка при обновлении поста: %w", err)
+		return fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
 	}
+	return requireAffected(res, "пост", id)
+}
 
-	rowsAffected, err := res.RowsAffected()
+// удаляет пост физически, комментарии удаляются каскадом по внешнему ключу
+func (p *PostgresStorage) PurgePost(ctx context.Context, id string) error {
+	res, err := p.conn.ExecContext(ctx, `delete from posts where id = $1`, id)
 	if err != nil {
 		return fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
 	}
-	if rowsAffected == 0 {
-		return fmt.Errorf("%w: пост с id %s", customerrors.ErrNotFound, post.ID)
-	}
-	return nil
+	return requireAffected(res, "пост", id)
 }
 
-// добавляет комментарий в БД, провалидирован в service
+// добавляет комментарий в БД вместе с событием comment_created, провалидирован в service
 func (p *PostgresStorage) CreateComment(ctx context.Context, comment *models.Comment) error {
 	if comment.CreatedAt.IsZero() {
 		comment.CreatedAt = time.Now()
 	}
+	tx, err := p.beginTx(ctx)
+	if err != nil {
+		return fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	defer tx.Rollback()
+
 	query := `insert into comments (id, post_id, parent_id, author_id, text, created_at) values ($1,$2,$3,$4,$5,$6)`
-	_, err := p.db.Exec(query, comment.ID, comment.PostID, comment.ParentID, comment.AuthorID, comment.Text, comment.CreatedAt)
+	_, err = tx.ExecContext(ctx, query, comment.ID, comment.PostID, comment.ParentID, comment.AuthorID, comment.Text, comment.CreatedAt)
 	if err != nil {
 		return fmt.Errorf("ошибка при создании комментария с id %s : %w", comment.ID, err)
 	}
+	if err := writeOutbox(ctx, tx, models.EventCommentCreated, comment.ID, comment); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	return nil
+}
+
+// меняет текст комментария и сохраняет предыдущий текст ревизией и событие comment_updated в одной транзакции
+func (p *PostgresStorage) UpdateComment(ctx context.Context, comment *models.Comment, revision *models.CommentRevision) error {
+	tx, err := p.beginTx(ctx)
+	if err != nil {
+		return fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	defer tx.Rollback()
+
+	query := `update comments set text = $1, edited_at = $2 where id = $3 and deleted_at is null`
+	res, err := tx.ExecContext(ctx, query, comment.Text, comment.EditedAt, comment.ID)
+	if err != nil {
+		return fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	if err := requireAffected(res, "комментарий", comment.ID); err != nil {
+		return err
+	}
+
+	if revision != nil {
+		query := `insert into comment_revisions (id, comment_id, text, editor_id, created_at) values ($1,$2,$3,$4,$5)`
+		if _, err := tx.ExecContext(ctx, query, revision.ID, revision.CommentID, revision.Text, revision.EditorID, revision.CreatedAt); err != nil {
+			return fmt.Errorf("%w: ревизия комментария %s: %v", customerrors.ErrDBQuery, comment.ID, err)
+		}
+	}
+	if err := writeOutbox(ctx, tx, models.EventCommentUpdated, comment.ID, comment); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
 	return nil
 }
 
+func (p *PostgresStorage) ListCommentRevisions(ctx context.Context, commentID string) ([]*models.CommentRevision, error) {
+	query := `select id, comment_id, text, editor_id, created_at from comment_revisions
+			where comment_id = $1
+			order by created_at asc, id asc`
+	rows, err := p.conn.QueryContext(ctx, query, commentID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	defer rows.Close()
+
+	revisions := []*models.CommentRevision{}
+	for rows.Next() {
+		var rev models.CommentRevision
+		if err := rows.Scan(&rev.ID, &rev.CommentID, &rev.Text, &rev.EditorID, &rev.CreatedAt); err != nil {
+			return nil, fmt.Errorf("%w: %v", customerrors.ErrDBScan, err)
+		}
+		revisions = append(revisions, &rev)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	return revisions, nil
+}
+
+// удаленные комментарии возвращаются с DeletedAt, чтобы не ломать дерево ответов
 func (p *PostgresStorage) GetCommentByID(ctx context.Context, id string) (*models.Comment, error) {
-	query := `select id, post_id, parent_id, author_id, text, created_at from comments where id = $1`
-	row := p.db.QueryRow(query, id)
+	query := `select ` + commentColumns + ` from comments where id = $1` + p.rowLock()
+	row := p.conn.QueryRowContext(ctx, query, id)
 
-	var currComment models.Comment
-	err := row.Scan(&currComment.ID, &currComment.PostID, &currComment.ParentID, &currComment.AuthorID, &currComment.Text, &currComment.CreatedAt)
+	currComment, err := scanComment(row)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, fmt.Errorf("%w: комментарий с id %s", customerrors.ErrNotFound, id)
@@ -179,43 +616,226 @@ func (p *PostgresStorage) GetCommentByID(ctx context.Context, id string) (*model
 		return nil, fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
 	}
 
-	return &currComment, nil
+	return currComment, nil
+}
+
+// порядок комментариев для сортировки, id в конце делает порядок полным и страницы стабильными
+func commentOrder(sort models.CommentSort) (string, error) {
+	switch sort {
+	case models.CommentSortOld, "":
+		return `created_at asc, id asc`, nil
+	case models.CommentSortNew:
+		return `created_at desc, id desc`, nil
+	case models.CommentSortTop:
+		return `rank_top desc, created_at asc, id asc`, nil
+	case models.CommentSortControversial:
+		return `rank_controversial desc, created_at asc, id asc`, nil
+	}
+	return "", fmt.Errorf("%w: неизвестная сортировка комментариев %s", customerrors.ErrValidation, sort)
 }
 
-func (p *PostgresStorage) ListCommentsByPost(ctx context.Context, postID string, parentID *string, offset, limit int) ([]*models.Comment, error) {
+func (p *PostgresStorage) ListCommentsByPost(ctx context.Context, postID string, parentID *string, sort models.CommentSort, offset, limit int) ([]*models.Comment, error) {
 	if offset < 0 || limit <= 0 {
 		return nil, fmt.Errorf("%w: неправильные параметры пагинации", customerrors.ErrParamOutOfRange)
 	}
+	order, err := commentOrder(sort)
+	if err != nil {
+		return nil, err
+	}
 
 	var rows *sql.Rows
-	var err error
-
 	if parentID == nil {
-		query := `select id, post_id, parent_id, author_id, text, created_at from comments
+		query := `select ` + commentColumns + ` from comments
 				where post_id = $1 and parent_id is null
-				order by created_at asc
+				order by ` + order + `
 				offset $2 limit $3`
-		rows, err = p.db.Query(query, postID, offset, limit)
+		rows, err = p.conn.QueryContext(ctx, query, postID, offset, limit)
 	} else {
-		query := `select id, post_id, parent_id, author_id, text, created_at from comments
+		query := `select ` + commentColumns + ` from comments
 				where post_id = $1 and parent_id = $2
-				order by created_at asc
+				order by ` + order + `
 				offset $3 limit $4`
-		rows, err = p.db.Query(query, postID, *parentID, offset, limit)
+		rows, err = p.conn.QueryContext(ctx, query, postID, *parentID, offset, limit)
 	}
 
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	return collectComments(rows)
+}
+
+func (p *PostgresStorage) ListCommentsByAuthor(ctx context.Context, authorID string, offset, limit int) ([]*models.Comment, error) {
+	if offset < 0 || limit <= 0 {
+		return nil, fmt.Errorf("%w: неправильные параметры пагинации", customerrors.ErrParamOutOfRange)
+	}
+
+	query := `select ` + commentColumns + ` from comments
+			where author_id = $1 and deleted_at is null
+				and exists (select 1 from posts where posts.id = comments.post_id and posts.deleted_at is null)
+			order by created_at desc, id desc
+			offset $2 limit $3`
+	rows, err := p.conn.QueryContext(ctx, query, authorID, offset, limit)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	return collectComments(rows)
+}
+
+func (p *PostgresStorage) ListCommentsAfter(ctx context.Context, postID string, parentID *string, after *models.PageKey, limit int) ([]*models.Comment, error) {
+	if limit <= 0 {
+		return nil, fmt.Errorf("%w: неправильные параметры пагинации", customerrors.ErrParamOutOfRange)
+	}
+
+	query := `select ` + commentColumns + ` from comments
+			where post_id = $1`
+	args := []any{postID}
+	if parentID == nil {
+		query += ` and parent_id is null`
+	} else {
+		args = append(args, *parentID)
+		query += fmt.Sprintf(` and parent_id = $%d`, len(args))
+	}
+	if after != nil {
+		args = append(args, after.CreatedAt, after.ID)
+		query += fmt.Sprintf(` and (created_at, id) > ($%d, $%d::uuid)`, len(args)-1, len(args))
+	}
+	query += fmt.Sprintf(` order by created_at asc, id asc limit $%d`, len(args)+1)
+	args = append(args, limit)
+
+	rows, err := p.conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	return collectComments(rows)
+}
+
+// ответы для нескольких родителей одним запросом, страница считается внутри каждого родителя
+func (p *PostgresStorage) ListRepliesByParentIDs(ctx context.Context, parentIDs []string, sort models.CommentSort, offset, limit int) (map[string][]*models.Comment, error) {
+	if offset < 0 || limit <= 0 {
+		return nil, fmt.Errorf("%w: неправильные параметры пагинации", customerrors.ErrParamOutOfRange)
+	}
+	order, err := commentOrder(sort)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `select ` + commentColumns + ` from (
+				select ` + commentColumns + `,
+					row_number() over (partition by parent_id order by ` + order + `) as rn
+				from comments
+				where parent_id = any($1)
+			) as replies
+			where rn > $2 and rn <= $2 + $3
+			order by parent_id, rn`
+	rows, err := p.conn.QueryContext(ctx, query, pq.Array(parentIDs), offset, limit)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+
+	comments, err := collectComments(rows)
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string][]*models.Comment, len(parentIDs))
+	for _, comment := range comments {
+		result[*comment.ParentID] = append(result[*comment.ParentID], comment)
+	}
+	return result, nil
+}
+
+// строит дерево одним рекурсивным запросом, path задает порядок обхода в глубину.
+// первые maxNodes узлов обхода - это не больше maxNodes первых детей у каждого узла и не больше maxNodes
+// первых узлов каждого уровня, поэтому рекурсия берет детей через lateral с limit и раскрывает только узлы
+// с pos <= maxNodes: запрос читает не больше (maxDepth+1)*maxNodes^2 строк, а не все поддерево
+func (p *PostgresStorage) GetCommentThread(ctx context.Context, postID string, rootID *string, maxDepth, maxNodes int) ([]*models.ThreadComment, error) {
+	if maxDepth < 0 || maxNodes <= 0 {
+		return nil, fmt.Errorf("%w: неправильные ограничения дерева", customerrors.ErrParamOutOfRange)
+	}
+
+	// элемент пути фиксированной длины из цифр и hex, поэтому сортировка не зависит от collation
+	rootCond := `post_id = $1 and parent_id is null`
+	args := []any{postID, maxDepth, maxNodes}
+	if rootID != nil {
+		rootCond = `post_id = $1 and id = $4`
+		args = append(args, *rootID)
+	}
+
+	// pos - номер узла на своем уровне в порядке обхода: по номеру родителя, затем по (created_at, id)
+	query := `with recursive thread as (
+				(select ` + commentColumns + `, 0 as depth,
+					array[to_char(created_at, 'YYYYMMDDHH24MISSUS') || replace(id::text, '-', '')] as path,
+					row_number() over (order by created_at, id) as pos
+				from comments
+				where ` + rootCond + `
+				order by created_at, id
+				limit $3)
+				union all
+				select c.id, c.post_id, c.parent_id, c.author_id, c.text, c.created_at, c.edited_at, c.deleted_at, t.depth + 1,
+					t.path || (to_char(c.created_at, 'YYYYMMDDHH24MISSUS') || replace(c.id::text, '-', '')),
+					row_number() over (order by t.pos, c.created_at, c.id)
+				from thread t
+				cross join lateral (
+					select ` + commentColumns + `
+					from comments
+					where post_id = $1 and parent_id = t.id
+					order by created_at, id
+					limit $3
+				) c
+				where t.depth < $2 and t.pos <= $3
+			)
+			select ` + commentColumns + `, depth
+			from thread
+			order by path
+			limit $3`
+
+	rows, err := p.conn.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
 	}
 	defer rows.Close()
 
-	var comments []*models.Comment
+	thread := []*models.ThreadComment{}
 	for rows.Next() {
-		var comment models.Comment
-		if err := rows.Scan(&comment.ID, &comment.PostID, &comment.ParentID, &comment.AuthorID, &comment.Text, &comment.CreatedAt); err != nil {
+		var depth int
+		comment, err := scanComment(rows, &depth)
+		if err != nil {
 			return nil, fmt.Errorf("%w: %v", customerrors.ErrDBScan, err)
 		}
-		comments = append(comments, &comment)
+		thread = append(thread, &models.ThreadComment{Comment: comment, Depth: depth})
 	}
-	return comments, nil
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	return thread, nil
+}
+
+// помечает комментарий удаленным, строка остается, чтобы ответы не потеряли родителя
+func (p *PostgresStorage) SoftDeleteComment(ctx context.Context, id string, at time.Time) error {
+	query := `update comments set deleted_at = $2 where id = $1 and deleted_at is null`
+	res, err := p.conn.ExecContext(ctx, query, id, at)
+	if err != nil {
+		return fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	return requireAffected(res, "комментарий", id)
+}
+
+// удаляет комментарий физически, ответы удаляются каскадом по comments.parent_id
+func (p *PostgresStorage) PurgeComment(ctx context.Context, id string) error {
+	res, err := p.conn.ExecContext(ctx, `delete from comments where id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	return requireAffected(res, "комментарий", id)
+}
+
+// превращает обновление без затронутых строк в ErrNotFound
+func requireAffected(res sql.Result, what, id string) error {
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("%w: %s с id %s", customerrors.ErrNotFound, what, id)
+	}
+	return nil
 }
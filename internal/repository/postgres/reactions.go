@@ -0,0 +1,202 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/MAPiryazev/OzonTest/internal/customerrors"
+	"github.com/MAPiryazev/OzonTest/internal/models"
+	"github.com/lib/pq"
+)
+
+// таблицы реакций и счетчиков для каждого вида объекта, внешние ключи удаляют их вместе с объектом
+type reactionTables struct {
+	reactions string
+	counts    string
+	column    string
+	// голоса за комментарий меняют его ранги для сортировок top и controversial
+	ranked bool
+}
+
+func tablesFor(target models.ReactionTarget) (reactionTables, error) {
+	switch target {
+	case models.ReactionTargetPost:
+		return reactionTables{reactions: "post_reactions", counts: "post_reaction_counts", column: "post_id"}, nil
+	case models.ReactionTargetComment:
+		return reactionTables{reactions: "comment_reactions", counts: "comment_reaction_counts", column: "comment_id", ranked: true}, nil
+	}
+	return reactionTables{}, fmt.Errorf("%w: неизвестный объект реакции %s", customerrors.ErrValidation, target)
+}
+
+// реакция и счетчики меняются в одной транзакции, строка реакции блокируется до конца транзакции
+func (p *PostgresStorage) SetReaction(ctx context.Context, reaction *models.Reaction) error {
+	t, err := tablesFor(reaction.Target)
+	if err != nil {
+		return err
+	}
+
+	tx, err := p.beginTx(ctx)
+	if err != nil {
+		return fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	defer tx.Rollback()
+
+	var previous models.ReactionKind
+	query := `select kind from ` + t.reactions + ` where ` + t.column + ` = $1 and user_id = $2 for update`
+	err = tx.QueryRowContext(ctx, query, reaction.TargetID, reaction.UserID).Scan(&previous)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		query := `insert into ` + t.reactions + ` (` + t.column + `, user_id, kind, created_at) values ($1, $2, $3, $4)`
+		if _, err := tx.ExecContext(ctx, query, reaction.TargetID, reaction.UserID, reaction.Kind, reaction.CreatedAt); err != nil {
+			return fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+		}
+	case err != nil:
+		return fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	case previous == reaction.Kind:
+		return nil
+	default:
+		query := `update ` + t.reactions + ` set kind = $3, created_at = $4 where ` + t.column + ` = $1 and user_id = $2`
+		if _, err := tx.ExecContext(ctx, query, reaction.TargetID, reaction.UserID, reaction.Kind, reaction.CreatedAt); err != nil {
+			return fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+		}
+		if err := addReactionCount(ctx, tx, t, reaction.TargetID, previous, -1); err != nil {
+			return err
+		}
+	}
+
+	if err := addReactionCount(ctx, tx, t, reaction.TargetID, reaction.Kind, 1); err != nil {
+		return err
+	}
+	if err := refreshRanks(ctx, tx, t, reaction.TargetID); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	return nil
+}
+
+func (p *PostgresStorage) RemoveReaction(ctx context.Context, target models.ReactionTarget, targetID, userID string) error {
+	t, err := tablesFor(target)
+	if err != nil {
+		return err
+	}
+
+	tx, err := p.beginTx(ctx)
+	if err != nil {
+		return fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	defer tx.Rollback()
+
+	var previous models.ReactionKind
+	query := `delete from ` + t.reactions + ` where ` + t.column + ` = $1 and user_id = $2 returning kind`
+	err = tx.QueryRowContext(ctx, query, targetID, userID).Scan(&previous)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+
+	if err := addReactionCount(ctx, tx, t, targetID, previous, -1); err != nil {
+		return err
+	}
+	if err := refreshRanks(ctx, tx, t, targetID); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	return nil
+}
+
+// check на count проверяется до разрешения конфликта, поэтому уменьшение идет обычным update
+func addReactionCount(ctx context.Context, tx dbtx, t reactionTables, targetID string, kind models.ReactionKind, delta int) error {
+	query := `insert into ` + t.counts + ` (` + t.column + `, kind, count) values ($1, $2, $3)
+		on conflict (` + t.column + `, kind) do update set count = ` + t.counts + `.count + excluded.count`
+	if delta < 0 {
+		query = `update ` + t.counts + ` set count = count + $3 where ` + t.column + ` = $1 and kind = $2`
+	}
+	if _, err := tx.ExecContext(ctx, query, targetID, kind, delta); err != nil {
+		return fmt.Errorf("%w: счетчик реакций: %v", customerrors.ErrDBQuery, err)
+	}
+	return nil
+}
+
+// пересчитывает ранги по текущим счетчикам up и down той же транзакцией
+func refreshRanks(ctx context.Context, tx dbtx, t reactionTables, targetID string) error {
+	if !t.ranked {
+		return nil
+	}
+	query := `update comments set
+			rank_top = wilson_lower_bound(votes.up, votes.down),
+			rank_controversial = controversy(votes.up, votes.down)
+		from (
+			select
+				coalesce((select count from comment_reaction_counts where comment_id = $1 and kind = 'up'), 0) as up,
+				coalesce((select count from comment_reaction_counts where comment_id = $1 and kind = 'down'), 0) as down
+		) as votes
+		where id = $1`
+	if _, err := tx.ExecContext(ctx, query, targetID); err != nil {
+		return fmt.Errorf("%w: ранг комментария: %v", customerrors.ErrDBQuery, err)
+	}
+	return nil
+}
+
+func (p *PostgresStorage) GetReactionCounts(ctx context.Context, target models.ReactionTarget, targetIDs []string) (map[string][]models.ReactionCount, error) {
+	t, err := tablesFor(target)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `select ` + t.column + `, kind, count from ` + t.counts + ` where ` + t.column + ` = any($1) and count > 0`
+	rows, err := p.conn.QueryContext(ctx, query, pq.Array(targetIDs))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	defer rows.Close()
+
+	result := make(map[string][]models.ReactionCount, len(targetIDs))
+	for rows.Next() {
+		var id string
+		var count models.ReactionCount
+		if err := rows.Scan(&id, &count.Kind, &count.Count); err != nil {
+			return nil, fmt.Errorf("%w: %v", customerrors.ErrDBScan, err)
+		}
+		result[id] = append(result[id], count)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	return result, nil
+}
+
+func (p *PostgresStorage) GetUserReactions(ctx context.Context, target models.ReactionTarget, userID string, targetIDs []string) (map[string]models.ReactionKind, error) {
+	t, err := tablesFor(target)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `select ` + t.column + `, kind from ` + t.reactions + ` where user_id = $1 and ` + t.column + ` = any($2)`
+	rows, err := p.conn.QueryContext(ctx, query, userID, pq.Array(targetIDs))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	defer rows.Close()
+
+	result := make(map[string]models.ReactionKind, len(targetIDs))
+	for rows.Next() {
+		var id string
+		var kind models.ReactionKind
+		if err := rows.Scan(&id, &kind); err != nil {
+			return nil, fmt.Errorf("%w: %v", customerrors.ErrDBScan, err)
+		}
+		result[id] = kind
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	return result, nil
+}
@@ -0,0 +1,89 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/MAPiryazev/OzonTest/internal/customerrors"
+	"github.com/MAPiryazev/OzonTest/internal/models"
+	"github.com/MAPiryazev/OzonTest/internal/search"
+)
+
+// параметры ts_headline: фрагмент до 30 слов, совпадения во временных маркерах, которые после
+// экранирования текста заменяются на <b></b>
+const headlineOptions = `StartSel="` + search.RawStart + `", StopSel="` + search.RawStop + `", MaxWords=30, MinWords=10, MaxFragments=2, FragmentDelimiter=" … "`
+
+// websearch_to_tsquery понимает синтаксис пользователя (кавычки, or, -слово) и не падает на произвольной строке,
+// фрагменты строятся только для страницы результатов
+func (p *PostgresStorage) Search(ctx context.Context, query string, searchType models.SearchType, offset, limit int) ([]*models.SearchHit, error) {
+	if offset < 0 || limit <= 0 {
+		return nil, fmt.Errorf("%w: неправильные параметры пагинации", customerrors.ErrParamOutOfRange)
+	}
+
+	sqlQuery := `with q as (select websearch_to_tsquery('russian', $1) as query),
+			hits as (
+				select 'post' as kind, p.id, ts_rank(p.search_vector, q.query) as rank
+				from posts p, q
+				where $2 in ('all', 'post') and p.deleted_at is null and p.search_vector @@ q.query
+				union all
+				select 'comment', c.id, ts_rank(c.search_vector, q.query)
+				from comments c join posts p on p.id = c.post_id, q
+				where $2 in ('all', 'comment') and c.deleted_at is null and p.deleted_at is null and c.search_vector @@ q.query
+				order by rank desc, kind desc, id
+				offset $3 limit $4
+			)
+			select h.kind, h.id, h.rank,
+				ts_headline('russian', translate(coalesce(p.title || ' ' || p.content, c.text), '` + search.RawStart + search.RawStop + `', ''),
+					q.query, '` + headlineOptions + `'),
+				` + searchHitColumns + `
+			from hits h cross join q
+			left join posts p on h.kind = 'post' and p.id = h.id
+			left join comments c on h.kind = 'comment' and c.id = h.id
+			order by h.rank desc, h.kind desc, h.id`
+	rows, err := p.conn.QueryContext(ctx, sqlQuery, query, string(searchType), offset, limit)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	defer rows.Close()
+
+	hits := []*models.SearchHit{}
+	for rows.Next() {
+		hit, err := scanSearchHit(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", customerrors.ErrDBScan, err)
+		}
+		hit.Snippet = search.EscapeHighlighted(hit.Snippet)
+		hits = append(hits, hit)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	return hits, nil
+}
+
+// поля поста или комментария в общих колонках: у поста post_id - его id, у комментария пустые title и comments_enabled.
+// объекты берутся тем же запросом, что и страница, поэтому удаленные после поиска строки не выпадают из нее
+const searchHitColumns = `coalesce(p.id, c.post_id), c.parent_id, coalesce(p.author_id, c.author_id), coalesce(p.title, ''),
+				coalesce(p.content, c.text), coalesce(p.comments_enabled, false), coalesce(p.created_at, c.created_at), coalesce(p.edited_at, c.edited_at)`
+
+func scanSearchHit(row scanner) (*models.SearchHit, error) {
+	var (
+		hit                               models.SearchHit
+		id, postID, authorID, title, text string
+		parentID                          *string
+		commentsEnabled                   bool
+		createdAt                         time.Time
+		editedAt                          sql.NullTime
+	)
+	if err := row.Scan(&hit.Type, &id, &hit.Rank, &hit.Snippet, &postID, &parentID, &authorID, &title, &text, &commentsEnabled, &createdAt, &editedAt); err != nil {
+		return nil, err
+	}
+	if hit.Type == models.SearchPosts {
+		hit.Post = &models.Post{ID: id, Title: title, Content: text, AuthorID: authorID, CommentsEnabled: commentsEnabled, CreatedAt: createdAt, EditedAt: nullTime(editedAt)}
+	} else {
+		hit.Comment = &models.Comment{ID: id, PostID: postID, ParentID: parentID, AuthorID: authorID, Text: text, CreatedAt: createdAt, EditedAt: nullTime(editedAt)}
+	}
+	return &hit, nil
+}
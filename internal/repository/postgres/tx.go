@@ -0,0 +1,92 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/MAPiryazev/OzonTest/internal/customerrors"
+	"github.com/MAPiryazev/OzonTest/internal/repository"
+)
+
+// общие методы *sql.DB и *sql.Tx, поэтому одни и те же методы хранилища работают и вне транзакции, и в ней
+type dbtx interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// транзакция метода хранилища из нескольких запросов. внутри WithTx это точка сохранения во внешней транзакции:
+// ошибка метода откатывает только его запросы, а фиксирует все вместе WithTx
+type txScope struct {
+	*sql.Tx
+	ctx       context.Context
+	savepoint bool
+	done      bool
+}
+
+func (p *PostgresStorage) beginTx(ctx context.Context) (*txScope, error) {
+	if p.tx == nil {
+		tx, err := p.db.BeginTx(ctx, nil)
+		if err != nil {
+			return nil, err
+		}
+		return &txScope{Tx: tx, ctx: ctx}, nil
+	}
+	if _, err := p.tx.ExecContext(ctx, `savepoint storage_op`); err != nil {
+		return nil, err
+	}
+	return &txScope{Tx: p.tx, ctx: ctx, savepoint: true}, nil
+}
+
+func (t *txScope) Commit() error {
+	if !t.savepoint {
+		return t.Tx.Commit()
+	}
+	t.done = true
+	_, err := t.Tx.ExecContext(t.ctx, `release savepoint storage_op`)
+	return err
+}
+
+// вызывается через defer и после Commit ничего не делает
+func (t *txScope) Rollback() error {
+	if !t.savepoint {
+		return t.Tx.Rollback()
+	}
+	if t.done {
+		return nil
+	}
+	t.done = true
+	_, err := t.Tx.ExecContext(t.ctx, `rollback to savepoint storage_op`)
+	return err
+}
+
+// внутри WithTx прочитанный пост или комментарий блокируется до конца транзакции,
+// чтобы его не изменили и не удалили между проверкой и записью
+func (p *PostgresStorage) rowLock() string {
+	if p.tx == nil {
+		return ""
+	}
+	return ` for no key update`
+}
+
+// вложенный WithTx выполняется в той же транзакции
+func (p *PostgresStorage) WithTx(ctx context.Context, fn func(tx repository.Storage) error) error {
+	if p.tx != nil {
+		return fn(p)
+	}
+
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	defer tx.Rollback()
+
+	if err := fn(&PostgresStorage{db: p.db, conn: tx, tx: tx}); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	return nil
+}
@@ -0,0 +1,209 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/MAPiryazev/OzonTest/internal/customerrors"
+	"github.com/MAPiryazev/OzonTest/internal/models"
+	"github.com/lib/pq"
+)
+
+const webhookColumns = `id, url, secret, events, active, created_by, created_at`
+
+const deliveryColumns = `id, webhook_id, event, payload, status, attempts, next_attempt_at, last_error, created_at, delivered_at`
+
+func scanWebhook(row scanner) (*models.Webhook, error) {
+	var w models.Webhook
+	var events []string
+	if err := row.Scan(&w.ID, &w.URL, &w.Secret, pq.Array(&events), &w.Active, &w.CreatedBy, &w.CreatedAt); err != nil {
+		return nil, err
+	}
+	w.Events = make([]models.WebhookEvent, len(events))
+	for i, event := range events {
+		w.Events[i] = models.WebhookEvent(event)
+	}
+	return &w, nil
+}
+
+func scanDelivery(row scanner) (*models.WebhookDelivery, error) {
+	var d models.WebhookDelivery
+	var deliveredAt sql.NullTime
+	if err := row.Scan(&d.ID, &d.WebhookID, &d.Event, &d.Payload, &d.Status, &d.Attempts, &d.NextAttemptAt, &d.LastError, &d.CreatedAt, &deliveredAt); err != nil {
+		return nil, err
+	}
+	d.DeliveredAt = nullTime(deliveredAt)
+	return &d, nil
+}
+
+func collectDeliveries(rows *sql.Rows) ([]*models.WebhookDelivery, error) {
+	defer rows.Close()
+
+	deliveries := []*models.WebhookDelivery{}
+	for rows.Next() {
+		d, err := scanDelivery(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", customerrors.ErrDBScan, err)
+		}
+		deliveries = append(deliveries, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	return deliveries, nil
+}
+
+func (p *PostgresStorage) CreateWebhook(ctx context.Context, webhook *models.Webhook) error {
+	events := make([]string, len(webhook.Events))
+	for i, event := range webhook.Events {
+		events[i] = string(event)
+	}
+
+	query := `insert into webhooks (` + webhookColumns + `) values ($1, $2, $3, $4, $5, $6, $7)`
+	_, err := p.conn.ExecContext(ctx, query, webhook.ID, webhook.URL, webhook.Secret, pq.Array(events), webhook.Active, webhook.CreatedBy, webhook.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("%w: вебхук %s: %v", customerrors.ErrDBQuery, webhook.ID, err)
+	}
+	return nil
+}
+
+func (p *PostgresStorage) GetWebhookByID(ctx context.Context, id string) (*models.Webhook, error) {
+	query := `select ` + webhookColumns + ` from webhooks where id = $1`
+	webhook, err := scanWebhook(p.conn.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: вебхук с id %s", customerrors.ErrNotFound, id)
+		}
+		return nil, fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	return webhook, nil
+}
+
+func (p *PostgresStorage) ListWebhooks(ctx context.Context) ([]*models.Webhook, error) {
+	query := `select ` + webhookColumns + ` from webhooks order by created_at, id`
+	rows, err := p.conn.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	defer rows.Close()
+
+	webhooks := []*models.Webhook{}
+	for rows.Next() {
+		webhook, err := scanWebhook(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", customerrors.ErrDBScan, err)
+		}
+		webhooks = append(webhooks, webhook)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	return webhooks, nil
+}
+
+func (p *PostgresStorage) SetWebhookActive(ctx context.Context, id string, active bool) error {
+	res, err := p.conn.ExecContext(ctx, `update webhooks set active = $2 where id = $1`, id, active)
+	if err != nil {
+		return fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	return requireAffected(res, "вебхук", id)
+}
+
+// доставки удаляются внешним ключом
+func (p *PostgresStorage) DeleteWebhook(ctx context.Context, id string) error {
+	res, err := p.conn.ExecContext(ctx, `delete from webhooks where id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	return requireAffected(res, "вебхук", id)
+}
+
+func (p *PostgresStorage) CreateWebhookDeliveries(ctx context.Context, deliveries []*models.WebhookDelivery) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+
+	tx, err := p.beginTx(ctx)
+	if err != nil {
+		return fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	defer tx.Rollback()
+
+	query := `insert into webhook_deliveries (` + deliveryColumns + `) values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+			on conflict (id) do nothing`
+	for _, d := range deliveries {
+		_, err := tx.ExecContext(ctx, query, d.ID, d.WebhookID, d.Event, d.Payload, d.Status, d.Attempts, d.NextAttemptAt, d.LastError, d.CreatedAt, d.DeliveredAt)
+		if err != nil {
+			return fmt.Errorf("%w: доставка %s: %v", customerrors.ErrDBQuery, d.ID, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	return nil
+}
+
+// skip locked позволяет нескольким воркерам разбирать очередь параллельно, не дожидаясь друг друга
+func (p *PostgresStorage) ClaimWebhookDeliveries(ctx context.Context, now, leaseUntil time.Time, limit int) ([]*models.WebhookDelivery, error) {
+	if limit <= 0 {
+		return nil, fmt.Errorf("%w: неправильный размер пачки", customerrors.ErrParamOutOfRange)
+	}
+
+	query := `update webhook_deliveries set next_attempt_at = $2
+		where id in (
+			select d.id from webhook_deliveries d
+			join webhooks w on w.id = d.webhook_id
+			where d.status = 'pending' and d.next_attempt_at <= $1 and w.active
+			order by d.next_attempt_at, d.id
+			limit $3
+			for update of d skip locked
+		)
+		returning ` + deliveryColumns
+	rows, err := p.conn.QueryContext(ctx, query, now, leaseUntil, limit)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	return collectDeliveries(rows)
+}
+
+func (p *PostgresStorage) UpdateWebhookDelivery(ctx context.Context, delivery *models.WebhookDelivery) error {
+	query := `update webhook_deliveries
+		set status = $2, attempts = $3, next_attempt_at = $4, last_error = $5, delivered_at = $6
+		where id = $1`
+	res, err := p.conn.ExecContext(ctx, query, delivery.ID, delivery.Status, delivery.Attempts, delivery.NextAttemptAt, delivery.LastError, delivery.DeliveredAt)
+	if err != nil {
+		return fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	return requireAffected(res, "доставка", delivery.ID)
+}
+
+func (p *PostgresStorage) GetWebhookDeliveryByID(ctx context.Context, id string) (*models.WebhookDelivery, error) {
+	query := `select ` + deliveryColumns + ` from webhook_deliveries where id = $1`
+	delivery, err := scanDelivery(p.conn.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: доставка с id %s", customerrors.ErrNotFound, id)
+		}
+		return nil, fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	return delivery, nil
+}
+
+func (p *PostgresStorage) ListWebhookDeliveries(ctx context.Context, webhookID string, status models.WebhookDeliveryStatus, offset, limit int) ([]*models.WebhookDelivery, error) {
+	if offset < 0 || limit <= 0 {
+		return nil, fmt.Errorf("%w: неправильные параметры пагинации", customerrors.ErrParamOutOfRange)
+	}
+
+	query := `select ` + deliveryColumns + ` from webhook_deliveries
+		where webhook_id = $1 and ($2::text = '' or status = $2::text)
+		order by created_at desc, id desc
+		offset $3 limit $4`
+	rows, err := p.conn.QueryContext(ctx, query, webhookID, status, offset, limit)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	return collectDeliveries(rows)
+}
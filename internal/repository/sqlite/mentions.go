@@ -0,0 +1,132 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/MAPiryazev/OzonTest/internal/customerrors"
+	"github.com/MAPiryazev/OzonTest/internal/models"
+)
+
+// таблица упоминаний и колонка объекта, внешние ключи удаляют упоминания вместе с объектом
+func mentionTableFor(target models.ReactionTarget) (table, column string, err error) {
+	switch target {
+	case models.ReactionTargetPost:
+		return "post_mentions", "post_id", nil
+	case models.ReactionTargetComment:
+		return "comment_mentions", "comment_id", nil
+	}
+	return "", "", fmt.Errorf("%w: неизвестный объект упоминания %s", customerrors.ErrValidation, target)
+}
+
+func (s *SQLiteStorage) GetUsersByUsernames(ctx context.Context, usernames []string) ([]*models.User, error) {
+	if len(usernames) == 0 {
+		return []*models.User{}, nil
+	}
+
+	placeholders, args := inList(usernames)
+	rows, err := s.conn.QueryContext(ctx, `select id, username, role from users where username in (`+placeholders+`)`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	defer rows.Close()
+
+	users := make([]*models.User, 0, len(usernames))
+	for rows.Next() {
+		var u models.User
+		if err := rows.Scan(&u.ID, &u.Username, &u.Role); err != nil {
+			return nil, fmt.Errorf("%w: %v", customerrors.ErrDBScan, err)
+		}
+		users = append(users, &u)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	return users, nil
+}
+
+// sqlite сериализует пишущие транзакции, поэтому чтение старых упоминаний без блокировки
+func (s *SQLiteStorage) ReplaceMentions(ctx context.Context, target models.ReactionTarget, targetID string, userIDs []string) ([]string, error) {
+	table, column, err := mentionTableFor(target)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.beginTx(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `select user_id from `+table+` where `+column+` = ?`, targetID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	previous := make(map[string]struct{})
+	for rows.Next() {
+		var userID string
+		if err := rows.Scan(&userID); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("%w: %v", customerrors.ErrDBScan, err)
+		}
+		previous[userID] = struct{}{}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `delete from `+table+` where `+column+` = ?`, targetID); err != nil {
+		return nil, fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	query := `insert into ` + table + ` (` + column + `, user_id, position) values (?, ?, ?)`
+	added := []string{}
+	for i, userID := range userIDs {
+		if _, err := tx.ExecContext(ctx, query, targetID, userID, i); err != nil {
+			return nil, mapError(err, "упоминание "+userID)
+		}
+		if _, ok := previous[userID]; !ok {
+			added = append(added, userID)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	return added, nil
+}
+
+func (s *SQLiteStorage) GetMentions(ctx context.Context, target models.ReactionTarget, targetIDs []string) (map[string][]models.Mention, error) {
+	table, column, err := mentionTableFor(target)
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string][]models.Mention, len(targetIDs))
+	if len(targetIDs) == 0 {
+		return result, nil
+	}
+
+	placeholders, args := inList(targetIDs)
+	query := `select m.` + column + `, m.user_id, u.username from ` + table + ` m
+			join users u on u.id = m.user_id
+			where m.` + column + ` in (` + placeholders + `)
+			order by m.` + column + `, m.position`
+	rows, err := s.conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id string
+		var mention models.Mention
+		if err := rows.Scan(&id, &mention.UserID, &mention.Username); err != nil {
+			return nil, fmt.Errorf("%w: %v", customerrors.ErrDBScan, err)
+		}
+		result[id] = append(result[id], mention)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	return result, nil
+}
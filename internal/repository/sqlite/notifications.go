@@ -0,0 +1,127 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/MAPiryazev/OzonTest/internal/customerrors"
+	"github.com/MAPiryazev/OzonTest/internal/models"
+)
+
+const notificationColumns = `id, user_id, kind, actor_id, post_id, comment_id, created_at, read_at`
+
+func scanNotification(row scanner) (*models.Notification, error) {
+	var n models.Notification
+	var createdAt string
+	var readAt sql.NullString
+	if err := row.Scan(&n.ID, &n.UserID, &n.Kind, &n.ActorID, &n.PostID, &n.CommentID, &createdAt, &readAt); err != nil {
+		return nil, err
+	}
+	t, err := parseTime(createdAt)
+	if err != nil {
+		return nil, err
+	}
+	n.CreatedAt = t
+	if n.ReadAt, err = parseNullTime(readAt); err != nil {
+		return nil, err
+	}
+	return &n, nil
+}
+
+// уведомления одного события создаются одной транзакцией, уже сохраненные id пропускаются
+func (s *SQLiteStorage) CreateNotifications(ctx context.Context, notifications []*models.Notification) error {
+	if len(notifications) == 0 {
+		return nil
+	}
+
+	tx, err := s.beginTx(ctx)
+	if err != nil {
+		return fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	defer tx.Rollback()
+
+	query := `insert into notifications (` + notificationColumns + `) values (?, ?, ?, ?, ?, ?, ?, ?) on conflict (id) do nothing`
+	for _, n := range notifications {
+		_, err := tx.ExecContext(ctx, query, n.ID, n.UserID, n.Kind, n.ActorID, n.PostID, n.CommentID, formatTime(n.CreatedAt), formatNullTime(n.ReadAt))
+		if err != nil {
+			return mapError(err, "уведомление "+n.ID)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	return nil
+}
+
+func (s *SQLiteStorage) ListNotifications(ctx context.Context, userID string, unreadOnly bool, after *models.PageKey, limit int) ([]*models.Notification, error) {
+	if limit <= 0 {
+		return nil, fmt.Errorf("%w: неправильные параметры пагинации", customerrors.ErrParamOutOfRange)
+	}
+
+	query := `select ` + notificationColumns + ` from notifications where user_id = ?`
+	args := []any{userID}
+	if unreadOnly {
+		query += ` and read_at is null`
+	}
+	if after != nil {
+		query += ` and (created_at, id) < (?, ?)`
+		args = append(args, formatTime(after.CreatedAt), after.ID)
+	}
+	query += ` order by created_at desc, id desc limit ?`
+	args = append(args, limit)
+
+	rows, err := s.conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	defer rows.Close()
+
+	notifications := []*models.Notification{}
+	for rows.Next() {
+		n, err := scanNotification(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", customerrors.ErrDBScan, err)
+		}
+		notifications = append(notifications, n)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	return notifications, nil
+}
+
+func (s *SQLiteStorage) CountUnreadNotifications(ctx context.Context, userID string) (int, error) {
+	var count int
+	query := `select count(*) from notifications where user_id = ? and read_at is null`
+	if err := s.conn.QueryRowContext(ctx, query, userID).Scan(&count); err != nil {
+		return 0, fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	return count, nil
+}
+
+// уже прочитанные уведомления не трогаются, поэтому время прочтения не перезаписывается
+func (s *SQLiteStorage) MarkNotificationsRead(ctx context.Context, userID string, ids []string, at time.Time) (int, error) {
+	if ids != nil && len(ids) == 0 {
+		return 0, nil
+	}
+
+	query := `update notifications set read_at = ? where user_id = ? and read_at is null`
+	args := []any{formatTime(at), userID}
+	if ids != nil {
+		placeholders, idArgs := inList(ids)
+		query += ` and id in (` + placeholders + `)`
+		args = append(args, idArgs...)
+	}
+
+	res, err := s.conn.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	marked, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	return int(marked), nil
+}
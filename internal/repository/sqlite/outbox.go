@@ -0,0 +1,84 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/MAPiryazev/OzonTest/internal/customerrors"
+	"github.com/MAPiryazev/OzonTest/internal/models"
+	"github.com/MAPiryazev/OzonTest/internal/repository"
+)
+
+// пишет событие в outbox внутри транзакции изменения данных; запись в sqlite идет в один поток,
+// поэтому seq выдаются в порядке фиксации
+func writeOutbox(ctx context.Context, tx dbtx, eventType models.EventType, aggregateID string, entity any) error {
+	event, err := repository.NewOutboxEvent(eventType, aggregateID, entity, time.Now())
+	if err != nil {
+		return err
+	}
+	query := `insert into outbox (type, aggregate_id, payload, created_at) values (?, ?, ?, ?)`
+	if _, err := tx.ExecContext(ctx, query, event.Type, event.AggregateID, event.Payload, formatTime(event.CreatedAt)); err != nil {
+		return fmt.Errorf("%w: событие %s: %v", customerrors.ErrDBQuery, eventType, err)
+	}
+	return nil
+}
+
+func (s *SQLiteStorage) ListOutboxEvents(ctx context.Context, afterSeq int64, limit int) ([]*models.OutboxEvent, error) {
+	query := `select seq, type, aggregate_id, payload, created_at from outbox
+			where seq > ?
+			order by seq asc
+			limit ?`
+	rows, err := s.conn.QueryContext(ctx, query, afterSeq, limit)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	defer rows.Close()
+
+	events := []*models.OutboxEvent{}
+	for rows.Next() {
+		var e models.OutboxEvent
+		var createdAt string
+		if err := rows.Scan(&e.Seq, &e.Type, &e.AggregateID, &e.Payload, &createdAt); err != nil {
+			return nil, fmt.Errorf("%w: %v", customerrors.ErrDBScan, err)
+		}
+		if e.CreatedAt, err = parseTime(createdAt); err != nil {
+			return nil, err
+		}
+		events = append(events, &e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	return events, nil
+}
+
+func (s *SQLiteStorage) GetConsumerOffset(ctx context.Context, consumer string) (int64, error) {
+	var seq int64
+	err := s.conn.QueryRowContext(ctx, `select seq from outbox_offsets where consumer = ?`, consumer).Scan(&seq)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	return seq, nil
+}
+
+func (s *SQLiteStorage) SetConsumerOffset(ctx context.Context, consumer string, seq int64) error {
+	query := `insert into outbox_offsets (consumer, seq, updated_at) values (?, ?, ?)
+			on conflict (consumer) do update set seq = max(outbox_offsets.seq, excluded.seq), updated_at = excluded.updated_at`
+	if _, err := s.conn.ExecContext(ctx, query, consumer, seq, formatTime(time.Now())); err != nil {
+		return fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	return nil
+}
+
+func (s *SQLiteStorage) PruneOutbox(ctx context.Context, uptoSeq int64) error {
+	if _, err := s.conn.ExecContext(ctx, `delete from outbox where seq <= ?`, uptoSeq); err != nil {
+		return fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"fmt"
+	"strings"
+
+	"github.com/MAPiryazev/OzonTest/internal/customerrors"
+	"github.com/MAPiryazev/OzonTest/internal/models"
+	"github.com/MAPiryazev/OzonTest/internal/search"
+)
+
+// в fts4 нет стемминга, поэтому запрос строится из стемов с префиксным поиском: "постами" ищется как пост*
+func matchQuery(query string) string {
+	terms := search.QueryTerms(query)
+	for i, term := range terms {
+		terms[i] = term + "*"
+	}
+	return strings.Join(terms, " ")
+}
+
+// релевантность по matchinfo(..., 'pcx'): для каждой фразы и колонки доля ее вхождений в строке
+// от вхождений во всей таблице, редкие слова весят больше частых
+func searchRank(info []byte) float64 {
+	ints := make([]uint32, len(info)/4)
+	for i := range ints {
+		ints[i] = binary.NativeEndian.Uint32(info[i*4:])
+	}
+	if len(ints) < 2 {
+		return 0
+	}
+
+	phrases, columns := int(ints[0]), int(ints[1])
+	var rank float64
+	for i := 0; i < phrases; i++ {
+		for j := 0; j < columns; j++ {
+			k := 2 + 3*(i*columns+j)
+			if k+1 >= len(ints) || ints[k+1] == 0 {
+				continue
+			}
+			rank += float64(ints[k]) / float64(ints[k+1])
+		}
+	}
+	return rank
+}
+
+func (s *SQLiteStorage) Search(ctx context.Context, query string, searchType models.SearchType, offset, limit int) ([]*models.SearchHit, error) {
+	if offset < 0 || limit <= 0 {
+		return nil, fmt.Errorf("%w: неправильные параметры пагинации", customerrors.ErrParamOutOfRange)
+	}
+	match := matchQuery(query)
+	if match == "" {
+		return []*models.SearchHit{}, nil
+	}
+
+	// snippet() из fts4 не экранирует текст, поэтому фрагмент строится в go, как в in-memory хранилище
+	sqlQuery := `select h.kind, h.id, h.rank, ` + searchHitColumns + `
+			from (
+				select 'post' as kind, p.id as id, search_rank(matchinfo(posts_fts, 'pcx')) as rank
+				from posts_fts join posts p on p.rowid = posts_fts.docid
+				where ?1 in ('all', 'post') and posts_fts match ?2 and p.deleted_at is null
+				union all
+				select 'comment', c.id, search_rank(matchinfo(comments_fts, 'pcx'))
+				from comments_fts join comments c on c.rowid = comments_fts.docid join posts p on p.id = c.post_id
+				where ?1 in ('all', 'comment') and comments_fts match ?2 and c.deleted_at is null and p.deleted_at is null
+				order by rank desc, kind desc, id
+				limit ?3 offset ?4
+			) as h
+			left join posts p on h.kind = 'post' and p.id = h.id
+			left join comments c on h.kind = 'comment' and c.id = h.id
+			order by h.rank desc, h.kind desc, h.id`
+	rows, err := s.conn.QueryContext(ctx, sqlQuery, string(searchType), match, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	defer rows.Close()
+
+	terms := search.QueryTerms(query)
+	hits := []*models.SearchHit{}
+	for rows.Next() {
+		hit, err := scanSearchHit(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", customerrors.ErrDBScan, err)
+		}
+		if hit.Post != nil {
+			hit.Snippet = search.Snippet(hit.Post.Title+" "+hit.Post.Content, terms, search.SnippetWords)
+		} else {
+			hit.Snippet = search.Snippet(hit.Comment.Text, terms, search.SnippetWords)
+		}
+		hits = append(hits, hit)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	return hits, nil
+}
+
+// поля поста или комментария в общих колонках: у поста post_id - его id, у комментария пустые title и comments_enabled.
+// объекты берутся тем же запросом, что и страница, поэтому удаленные после поиска строки не выпадают из нее
+const searchHitColumns = `coalesce(p.id, c.post_id), c.parent_id, coalesce(p.author_id, c.author_id), coalesce(p.title, ''),
+				coalesce(p.content, c.text), coalesce(p.comments_enabled, 0), coalesce(p.created_at, c.created_at), coalesce(p.edited_at, c.edited_at)`
+
+func scanSearchHit(row scanner) (*models.SearchHit, error) {
+	var (
+		hit                               models.SearchHit
+		id, postID, authorID, title, text string
+		parentID                          *string
+		commentsEnabled                   bool
+		createdAt                         string
+		editedAt                          sql.NullString
+	)
+	if err := row.Scan(&hit.Type, &id, &hit.Rank, &postID, &parentID, &authorID, &title, &text, &commentsEnabled, &createdAt, &editedAt); err != nil {
+		return nil, err
+	}
+	created, err := parseTime(createdAt)
+	if err != nil {
+		return nil, err
+	}
+	edited, err := parseNullTime(editedAt)
+	if err != nil {
+		return nil, err
+	}
+	if hit.Type == models.SearchPosts {
+		hit.Post = &models.Post{ID: id, Title: title, Content: text, AuthorID: authorID, CommentsEnabled: commentsEnabled, CreatedAt: created, EditedAt: edited}
+	} else {
+		hit.Comment = &models.Comment{ID: id, PostID: postID, ParentID: parentID, AuthorID: authorID, Text: text, CreatedAt: created, EditedAt: edited}
+	}
+	return &hit, nil
+}
@@ -0,0 +1,885 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/MAPiryazev/OzonTest/internal/config"
+	"github.com/MAPiryazev/OzonTest/internal/customerrors"
+	"github.com/MAPiryazev/OzonTest/internal/migrate"
+	"github.com/MAPiryazev/OzonTest/internal/models"
+	"github.com/MAPiryazev/OzonTest/internal/ranking"
+	"github.com/MAPiryazev/OzonTest/migrations"
+	"github.com/mattn/go-sqlite3"
+)
+
+// реализация интерфейса storage в одном локальном файле sqlite
+
+// время хранится текстом фиксированной ширины, поэтому сравнение строк совпадает со сравнением времени
+const timeLayout = "2006-01-02T15:04:05.000000000Z"
+
+// драйвер sqlite3 с функциями ранжирования комментариев и релевантности поиска
+const driverName = "sqlite3_ranking"
+
+func init() {
+	sql.Register(driverName, &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			if err := conn.RegisterFunc("wilson_lower_bound", ranking.WilsonLowerBound, true); err != nil {
+				return err
+			}
+			if err := conn.RegisterFunc("controversy", ranking.Controversy, true); err != nil {
+				return err
+			}
+			return conn.RegisterFunc("search_rank", searchRank, true)
+		},
+	})
+}
+
+type SQLiteStorage struct {
+	db *sql.DB
+	// через conn идут все запросы: это db или транзакция WithTx
+	conn dbtx
+	// транзакция WithTx, nil вне ее
+	tx *sql.Tx
+}
+
+func NewSQLiteStorage(cfg *config.SQLiteConfig) (*SQLiteStorage, error) {
+	db, err := OpenDB(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	// локальный файл принадлежит только этому процессу, поэтому схема обновляется сразу при старте
+	migrator, err := NewMigrator(db)
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	if _, err := migrator.Up(context.Background()); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+
+	return &SQLiteStorage{db: db, conn: db}, nil
+}
+
+func OpenDB(cfg *config.SQLiteConfig) (*sql.DB, error) {
+	dsn := fmt.Sprintf("file:%s?_foreign_keys=on&_journal_mode=WAL&_busy_timeout=%d", cfg.Path, cfg.BusyTimeoutMs)
+
+	db, err := sql.Open(driverName, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", customerrors.ErrDBCreation, err)
+	}
+
+	// sqlite пишет в один поток, одно соединение избавляет от ошибок database is locked
+	db.SetMaxOpenConns(1)
+
+	if err := db.Ping(); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("ошибка при открытии файла sqlite %s: %v", cfg.Path, err)
+	}
+	return db, nil
+}
+
+func NewMigrator(db *sql.DB) (*migrate.Migrator, error) {
+	list, err := migrate.Load(migrations.SQLite, "sqlite")
+	if err != nil {
+		return nil, err
+	}
+	return migrate.New(db, migrate.SQLite, list), nil
+}
+
+func (s *SQLiteStorage) Close() error {
+	// хранилище транзакции не владеет соединением, закрывается только исходное
+	if s.tx != nil || s.db == nil {
+		return nil
+	}
+	return s.db.Close()
+}
+
+// переводит ошибки ограничений sqlite в ошибки customerrors
+func mapError(err error, what string) error {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) {
+		switch sqliteErr.ExtendedCode {
+		case sqlite3.ErrConstraintUnique, sqlite3.ErrConstraintPrimaryKey:
+			return fmt.Errorf("%w: %s", customerrors.ErrAlreadyExists, what)
+		case sqlite3.ErrConstraintForeignKey:
+			return fmt.Errorf("%w: связанный объект для %s", customerrors.ErrNotFound, what)
+		case sqlite3.ErrConstraintCheck:
+			return fmt.Errorf("%w: %s", customerrors.ErrValidation, what)
+		}
+	}
+	return fmt.Errorf("%w: %s: %v", customerrors.ErrDBQuery, what, err)
+}
+
+// превращает обновление без затронутых строк в ErrNotFound
+func requireAffected(res sql.Result, what, id string) error {
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("%w: %s с id %s", customerrors.ErrNotFound, what, id)
+	}
+	return nil
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(timeLayout)
+}
+
+func parseTime(s string) (time.Time, error) {
+	t, err := time.Parse(timeLayout, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: некорректное время %q", customerrors.ErrDBScan, s)
+	}
+	return t, nil
+}
+
+// общий интерфейс для *sql.Row и *sql.Rows
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// nullable время удаления
+func parseNullTime(s sql.NullString) (*time.Time, error) {
+	if !s.Valid {
+		return nil, nil
+	}
+	t, err := parseTime(s.String)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func formatNullTime(t *time.Time) any {
+	if t == nil {
+		return nil
+	}
+	return formatTime(*t)
+}
+
+const postColumns = `id, title, content, author_id, comments_enabled, created_at, edited_at, deleted_at`
+
+func scanPost(row scanner) (*models.Post, error) {
+	var post models.Post
+	var createdAt string
+	var editedAt, deletedAt sql.NullString
+	if err := row.Scan(&post.ID, &post.Title, &post.Content, &post.AuthorID, &post.CommentsEnabled, &createdAt, &editedAt, &deletedAt); err != nil {
+		return nil, err
+	}
+	t, err := parseTime(createdAt)
+	if err != nil {
+		return nil, err
+	}
+	post.CreatedAt = t
+	if post.EditedAt, err = parseNullTime(editedAt); err != nil {
+		return nil, err
+	}
+	if post.DeletedAt, err = parseNullTime(deletedAt); err != nil {
+		return nil, err
+	}
+	return &post, nil
+}
+
+const commentColumns = `id, post_id, parent_id, author_id, text, created_at, edited_at, deleted_at`
+
+func scanComment(row scanner, extra ...any) (*models.Comment, error) {
+	var comment models.Comment
+	var createdAt string
+	var editedAt, deletedAt sql.NullString
+	dest := append([]any{&comment.ID, &comment.PostID, &comment.ParentID, &comment.AuthorID, &comment.Text, &createdAt, &editedAt, &deletedAt}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+	t, err := parseTime(createdAt)
+	if err != nil {
+		return nil, err
+	}
+	comment.CreatedAt = t
+	if comment.EditedAt, err = parseNullTime(editedAt); err != nil {
+		return nil, err
+	}
+	if comment.DeletedAt, err = parseNullTime(deletedAt); err != nil {
+		return nil, err
+	}
+	return &comment, nil
+}
+
+func collectPosts(rows *sql.Rows) ([]*models.Post, error) {
+	defer rows.Close()
+
+	posts := []*models.Post{}
+	for rows.Next() {
+		post, err := scanPost(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", customerrors.ErrDBScan, err)
+		}
+		posts = append(posts, post)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	return posts, nil
+}
+
+func collectComments(rows *sql.Rows) ([]*models.Comment, error) {
+	defer rows.Close()
+
+	comments := []*models.Comment{}
+	for rows.Next() {
+		comment, err := scanComment(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", customerrors.ErrDBScan, err)
+		}
+		comments = append(comments, comment)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	return comments, nil
+}
+
+// плейсхолдеры ?,?,? и аргументы для условия in (...)
+func inList(ids []string) (string, []any) {
+	args := make([]any, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	return strings.TrimSuffix(strings.Repeat("?,", len(ids)), ","), args
+}
+
+func (s *SQLiteStorage) CreateUser(ctx context.Context, user *models.User) error {
+	trimmedName := strings.TrimSpace(user.Username)
+
+	if user.Role == "" {
+		user.Role = models.RoleUser
+	}
+
+	tx, err := s.beginTx(ctx)
+	if err != nil {
+		return fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	defer tx.Rollback()
+
+	query := `insert into users (id, username, password_hash, role) values (?, ?, ?, ?)`
+	if _, err := tx.ExecContext(ctx, query, user.ID, trimmedName, user.PasswordHash, user.Role); err != nil {
+		return mapError(err, fmt.Sprintf("пользователь %s", trimmedName))
+	}
+	created := models.UserCreatedPayload{ID: user.ID, Username: trimmedName, Role: user.Role}
+	if err := writeOutbox(ctx, tx, models.EventUserCreated, user.ID, created); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	return nil
+}
+
+func (s *SQLiteStorage) GetUserByID(ctx context.Context, id string) (*models.User, error) {
+	query := `select id, username, role from users where id = ?`
+
+	var u models.User
+	err := s.conn.QueryRowContext(ctx, query, id).Scan(&u.ID, &u.Username, &u.Role)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: пользователь с id %s", customerrors.ErrNotFound, id)
+		}
+		return nil, fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	return &u, nil
+}
+
+func (s *SQLiteStorage) GetUserByUsername(ctx context.Context, username string) (*models.User, error) {
+	query := `select id, username, role, password_hash, failed_logins, locked_until from users where username = ?`
+
+	var u models.User
+	var lockedUntil sql.NullString
+	err := s.conn.QueryRowContext(ctx, query, username).Scan(&u.ID, &u.Username, &u.Role, &u.PasswordHash, &u.FailedLogins, &lockedUntil)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: пользователь с именем %s", customerrors.ErrNotFound, username)
+		}
+		return nil, fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	if lockedUntil.Valid {
+		t, err := parseTime(lockedUntil.String)
+		if err != nil {
+			return nil, err
+		}
+		u.LockedUntil = &t
+	}
+	return &u, nil
+}
+
+func (s *SQLiteStorage) RegisterLoginFailure(ctx context.Context, userID string, maxFailures int, lockUntil time.Time) error {
+	query := `update users set
+		failed_logins = case when failed_logins + 1 >= ?2 then 0 else failed_logins + 1 end,
+		locked_until = case when failed_logins + 1 >= ?2 then ?3 else locked_until end
+		where id = ?1`
+	res, err := s.conn.ExecContext(ctx, query, userID, maxFailures, formatTime(lockUntil))
+	if err != nil {
+		return mapError(err, fmt.Sprintf("пользователь %s", userID))
+	}
+	return requireAffected(res, "пользователь", userID)
+}
+
+func (s *SQLiteStorage) SetUserRole(ctx context.Context, userID string, role models.Role) error {
+	query := `update users set role = ? where id = ?`
+	res, err := s.conn.ExecContext(ctx, query, role, userID)
+	if err != nil {
+		return mapError(err, fmt.Sprintf("роль пользователя %s", userID))
+	}
+	return requireAffected(res, "пользователь", userID)
+}
+
+func (s *SQLiteStorage) ResetLoginFailures(ctx context.Context, userID string) error {
+	query := `update users set failed_logins = 0, locked_until = null where id = ?`
+	res, err := s.conn.ExecContext(ctx, query, userID)
+	if err != nil {
+		return mapError(err, fmt.Sprintf("пользователь %s", userID))
+	}
+	return requireAffected(res, "пользователь", userID)
+}
+
+func (s *SQLiteStorage) GetUsersByIDs(ctx context.Context, ids []string) ([]*models.User, error) {
+	if len(ids) == 0 {
+		return []*models.User{}, nil
+	}
+
+	placeholders, args := inList(ids)
+	rows, err := s.conn.QueryContext(ctx, `select id, username, role from users where id in (`+placeholders+`)`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	defer rows.Close()
+
+	users := make([]*models.User, 0, len(ids))
+	for rows.Next() {
+		var u models.User
+		if err := rows.Scan(&u.ID, &u.Username, &u.Role); err != nil {
+			return nil, fmt.Errorf("%w: %v", customerrors.ErrDBScan, err)
+		}
+		users = append(users, &u)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	return users, nil
+}
+
+func (s *SQLiteStorage) CreatePost(ctx context.Context, post *models.Post) error {
+	if post.CreatedAt.IsZero() {
+		post.CreatedAt = time.Now().UTC()
+	}
+
+	tx, err := s.beginTx(ctx)
+	if err != nil {
+		return fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	defer tx.Rollback()
+
+	query := `insert into posts (` + postColumns + `) values (?, ?, ?, ?, ?, ?, ?, ?)`
+	_, err = tx.ExecContext(ctx, query, post.ID, post.Title, post.Content, post.AuthorID, post.CommentsEnabled,
+		formatTime(post.CreatedAt), formatNullTime(post.EditedAt), formatNullTime(post.DeletedAt))
+	if err != nil {
+		return mapError(err, fmt.Sprintf("пост с id %s", post.ID))
+	}
+	if err := writeOutbox(ctx, tx, models.EventPostCreated, post.ID, post); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	return nil
+}
+
+func (s *SQLiteStorage) GetPostByID(ctx context.Context, id string) (*models.Post, error) {
+	row := s.conn.QueryRowContext(ctx, `select `+postColumns+` from posts where id = ? and deleted_at is null`, id)
+
+	post, err := scanPost(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: пост с id %s", customerrors.ErrNotFound, id)
+		}
+		return nil, fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	return post, nil
+}
+
+func (s *SQLiteStorage) GetPostsByIDs(ctx context.Context, ids []string) ([]*models.Post, error) {
+	if len(ids) == 0 {
+		return []*models.Post{}, nil
+	}
+
+	placeholders, args := inList(ids)
+	rows, err := s.conn.QueryContext(ctx, `select `+postColumns+` from posts where deleted_at is null and id in (`+placeholders+`)`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	return collectPosts(rows)
+}
+
+// порядок постов, для сортировок по комментариям нужна статистика из подзапроса stats
+func postOrder(sort models.PostSort) (order string, withStats bool, err error) {
+	switch sort {
+	case models.PostSortNewest, "":
+		return `created_at desc, id desc`, false, nil
+	case models.PostSortOldest:
+		return `created_at asc, id asc`, false, nil
+	case models.PostSortMostCommented:
+		return `coalesce(stats.comment_count, 0) desc, created_at desc, id desc`, true, nil
+	case models.PostSortRecentlyActive:
+		// max в sqlite возвращает null, если есть null аргумент
+		return `max(created_at, coalesce(stats.last_comment_at, created_at)) desc, created_at desc, id desc`, true, nil
+	}
+	return "", false, fmt.Errorf("%w: неизвестная сортировка постов %s", customerrors.ErrValidation, sort)
+}
+
+func (s *SQLiteStorage) ListPosts(ctx context.Context, filter models.PostFilter, sort models.PostSort, offset, limit int) ([]*models.Post, error) {
+	if offset < 0 || limit <= 0 {
+		return nil, fmt.Errorf("%w: неправильные параметры пагинации", customerrors.ErrParamOutOfRange)
+	}
+	order, withStats, err := postOrder(sort)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `select ` + postColumns + ` from posts`
+	if withStats {
+		query += ` left join (
+				select post_id, count(*) as comment_count, max(created_at) as last_comment_at
+				from comments where deleted_at is null group by post_id
+			) as stats on stats.post_id = posts.id`
+	}
+	query += ` where posts.deleted_at is null`
+
+	args := []any{}
+	if filter.AuthorID != nil {
+		query += ` and author_id = ?`
+		args = append(args, *filter.AuthorID)
+	}
+	if filter.CreatedAfter != nil {
+		query += ` and created_at >= ?`
+		args = append(args, formatTime(*filter.CreatedAfter))
+	}
+	if filter.CreatedBefore != nil {
+		query += ` and created_at < ?`
+		args = append(args, formatTime(*filter.CreatedBefore))
+	}
+	if filter.CommentsEnabled != nil {
+		query += ` and comments_enabled = ?`
+		args = append(args, *filter.CommentsEnabled)
+	}
+	if filter.HasComments != nil {
+		exists := `exists (select 1 from comments c where c.post_id = posts.id and c.deleted_at is null)`
+		if !*filter.HasComments {
+			exists = `not ` + exists
+		}
+		query += ` and ` + exists
+	}
+	query += ` order by ` + order + ` limit ? offset ?`
+	args = append(args, limit, offset)
+
+	rows, err := s.conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	return collectPosts(rows)
+}
+
+func (s *SQLiteStorage) ListPostsAfter(ctx context.Context, after *models.PageKey, limit int) ([]*models.Post, error) {
+	if limit <= 0 {
+		return nil, fmt.Errorf("%w: неправильные параметры пагинации", customerrors.ErrParamOutOfRange)
+	}
+
+	var rows *sql.Rows
+	var err error
+
+	if after == nil {
+		query := `select ` + postColumns + ` from posts where deleted_at is null order by created_at desc, id desc limit ?`
+		rows, err = s.conn.QueryContext(ctx, query, limit)
+	} else {
+		query := `select ` + postColumns + ` from posts
+				where deleted_at is null and (created_at, id) < (?, ?)
+				order by created_at desc, id desc
+				limit ?`
+		rows, err = s.conn.QueryContext(ctx, query, formatTime(after.CreatedAt), after.ID, limit)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	return collectPosts(rows)
+}
+
+func (s *SQLiteStorage) UpdatePost(ctx context.Context, post *models.Post, revision *models.PostRevision) error {
+	tx, err := s.beginTx(ctx)
+	if err != nil {
+		return fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	defer tx.Rollback()
+
+	query := `update posts set title = ?, content = ?, comments_enabled = ?, edited_at = ? where id = ? and deleted_at is null`
+	res, err := tx.ExecContext(ctx, query, post.Title, post.Content, post.CommentsEnabled, formatNullTime(post.EditedAt), post.ID)
+	if err != nil {
+		return mapError(err, fmt.Sprintf("пост с id %s", post.ID))
+	}
+	if err := requireAffected(res, "пост", post.ID); err != nil {
+		return err
+	}
+
+	if revision != nil {
+		query := `insert into post_revisions (id, post_id, title, content, editor_id, created_at) values (?, ?, ?, ?, ?, ?)`
+		_, err := tx.ExecContext(ctx, query, revision.ID, revision.PostID, revision.Title, revision.Content, revision.EditorID, formatTime(revision.CreatedAt))
+		if err != nil {
+			return mapError(err, fmt.Sprintf("ревизия поста %s", post.ID))
+		}
+	}
+	if err := writeOutbox(ctx, tx, models.EventPostUpdated, post.ID, post); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	return nil
+}
+
+func (s *SQLiteStorage) ListPostRevisions(ctx context.Context, postID string) ([]*models.PostRevision, error) {
+	query := `select id, post_id, title, content, editor_id, created_at from post_revisions
+			where post_id = ?
+			order by created_at asc, id asc`
+	rows, err := s.conn.QueryContext(ctx, query, postID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	defer rows.Close()
+
+	revisions := []*models.PostRevision{}
+	for rows.Next() {
+		var rev models.PostRevision
+		var createdAt string
+		if err := rows.Scan(&rev.ID, &rev.PostID, &rev.Title, &rev.Content, &rev.EditorID, &createdAt); err != nil {
+			return nil, fmt.Errorf("%w: %v", customerrors.ErrDBScan, err)
+		}
+		if rev.CreatedAt, err = parseTime(createdAt); err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, &rev)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	return revisions, nil
+}
+
+func (s *SQLiteStorage) SoftDeletePost(ctx context.Context, id string, at time.Time) error {
+	res, err := s.conn.ExecContext(ctx, `update posts set deleted_at = ? where id = ? and deleted_at is null`, formatTime(at), id)
+	if err != nil {
+		return fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	return requireAffected(res, "пост", id)
+}
+
+// комментарии удаляются каскадом, внешние ключи включены в dsn
+func (s *SQLiteStorage) PurgePost(ctx context.Context, id string) error {
+	res, err := s.conn.ExecContext(ctx, `delete from posts where id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	return requireAffected(res, "пост", id)
+}
+
+func (s *SQLiteStorage) CreateComment(ctx context.Context, comment *models.Comment) error {
+	if comment.CreatedAt.IsZero() {
+		comment.CreatedAt = time.Now().UTC()
+	}
+
+	tx, err := s.beginTx(ctx)
+	if err != nil {
+		return fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	defer tx.Rollback()
+
+	query := `insert into comments (` + commentColumns + `) values (?, ?, ?, ?, ?, ?, ?, ?)`
+	_, err = tx.ExecContext(ctx, query, comment.ID, comment.PostID, comment.ParentID, comment.AuthorID, comment.Text,
+		formatTime(comment.CreatedAt), formatNullTime(comment.EditedAt), formatNullTime(comment.DeletedAt))
+	if err != nil {
+		return mapError(err, fmt.Sprintf("комментарий с id %s", comment.ID))
+	}
+	if err := writeOutbox(ctx, tx, models.EventCommentCreated, comment.ID, comment); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	return nil
+}
+
+func (s *SQLiteStorage) GetCommentByID(ctx context.Context, id string) (*models.Comment, error) {
+	row := s.conn.QueryRowContext(ctx, `select `+commentColumns+` from comments where id = ?`, id)
+
+	comment, err := scanComment(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: комментарий с id %s", customerrors.ErrNotFound, id)
+		}
+		return nil, fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	return comment, nil
+}
+
+// порядок комментариев для сортировки, id в конце делает порядок полным и страницы стабильными
+func commentOrder(sort models.CommentSort) (string, error) {
+	switch sort {
+	case models.CommentSortOld, "":
+		return `created_at asc, id asc`, nil
+	case models.CommentSortNew:
+		return `created_at desc, id desc`, nil
+	case models.CommentSortTop:
+		return `rank_top desc, created_at asc, id asc`, nil
+	case models.CommentSortControversial:
+		return `rank_controversial desc, created_at asc, id asc`, nil
+	}
+	return "", fmt.Errorf("%w: неизвестная сортировка комментариев %s", customerrors.ErrValidation, sort)
+}
+
+func (s *SQLiteStorage) ListCommentsByPost(ctx context.Context, postID string, parentID *string, sort models.CommentSort, offset, limit int) ([]*models.Comment, error) {
+	if offset < 0 || limit <= 0 {
+		return nil, fmt.Errorf("%w: неправильные параметры пагинации", customerrors.ErrParamOutOfRange)
+	}
+	order, err := commentOrder(sort)
+	if err != nil {
+		return nil, err
+	}
+
+	// "is" в sqlite сравнивает и с null, поэтому один запрос покрывает оба случая
+	query := `select ` + commentColumns + ` from comments
+			where post_id = ? and parent_id is ?
+			order by ` + order + `
+			limit ? offset ?`
+	rows, err := s.conn.QueryContext(ctx, query, postID, parentID, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	return collectComments(rows)
+}
+
+func (s *SQLiteStorage) ListCommentsByAuthor(ctx context.Context, authorID string, offset, limit int) ([]*models.Comment, error) {
+	if offset < 0 || limit <= 0 {
+		return nil, fmt.Errorf("%w: неправильные параметры пагинации", customerrors.ErrParamOutOfRange)
+	}
+
+	query := `select ` + commentColumns + ` from comments
+			where author_id = ? and deleted_at is null
+				and exists (select 1 from posts where posts.id = comments.post_id and posts.deleted_at is null)
+			order by created_at desc, id desc
+			limit ? offset ?`
+	rows, err := s.conn.QueryContext(ctx, query, authorID, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	return collectComments(rows)
+}
+
+func (s *SQLiteStorage) ListCommentsAfter(ctx context.Context, postID string, parentID *string, after *models.PageKey, limit int) ([]*models.Comment, error) {
+	if limit <= 0 {
+		return nil, fmt.Errorf("%w: неправильные параметры пагинации", customerrors.ErrParamOutOfRange)
+	}
+
+	query := `select ` + commentColumns + ` from comments where post_id = ? and parent_id is ?`
+	args := []any{postID, parentID}
+	if after != nil {
+		query += ` and (created_at, id) > (?, ?)`
+		args = append(args, formatTime(after.CreatedAt), after.ID)
+	}
+	query += ` order by created_at asc, id asc limit ?`
+	args = append(args, limit)
+
+	rows, err := s.conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	return collectComments(rows)
+}
+
+// рекурсивный запрос как в postgres, путь - строка из (created_at, id) с разделителем char(1),
+// который меньше любого символа id, поэтому поддерево идет сразу за своим корнем.
+// order by в рекурсивной части делает очередь sqlite очередью с приоритетом по path, то есть
+// обходом в глубину, а limit останавливает рекурсию после maxNodes узлов
+func (s *SQLiteStorage) GetCommentThread(ctx context.Context, postID string, rootID *string, maxDepth, maxNodes int) ([]*models.ThreadComment, error) {
+	if maxDepth < 0 || maxNodes <= 0 {
+		return nil, fmt.Errorf("%w: неправильные ограничения дерева", customerrors.ErrParamOutOfRange)
+	}
+
+	rootCond := `post_id = ?1 and parent_id is null`
+	args := []any{postID, maxDepth, maxNodes}
+	if rootID != nil {
+		rootCond = `post_id = ?1 and id = ?4`
+		args = append(args, *rootID)
+	}
+
+	query := `with recursive thread as (
+				select ` + commentColumns + `, 0 as depth, created_at || id as path
+				from comments
+				where ` + rootCond + `
+				union all
+				select c.id, c.post_id, c.parent_id, c.author_id, c.text, c.created_at, c.edited_at, c.deleted_at, t.depth + 1,
+					t.path || char(1) || c.created_at || c.id
+				from comments c
+				join thread t on c.parent_id = t.id
+				where t.depth < ?2
+				order by path
+				limit ?3
+			)
+			select ` + commentColumns + `, depth
+			from thread
+			order by path
+			limit ?3`
+
+	rows, err := s.conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	defer rows.Close()
+
+	thread := []*models.ThreadComment{}
+	for rows.Next() {
+		var depth int
+		comment, err := scanComment(rows, &depth)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", customerrors.ErrDBScan, err)
+		}
+		thread = append(thread, &models.ThreadComment{Comment: comment, Depth: depth})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	return thread, nil
+}
+
+func (s *SQLiteStorage) ListRepliesByParentIDs(ctx context.Context, parentIDs []string, sort models.CommentSort, offset, limit int) (map[string][]*models.Comment, error) {
+	if offset < 0 || limit <= 0 {
+		return nil, fmt.Errorf("%w: неправильные параметры пагинации", customerrors.ErrParamOutOfRange)
+	}
+	order, err := commentOrder(sort)
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string][]*models.Comment, len(parentIDs))
+	if len(parentIDs) == 0 {
+		return result, nil
+	}
+
+	placeholders, args := inList(parentIDs)
+	query := `select ` + commentColumns + ` from (
+				select ` + commentColumns + `,
+					row_number() over (partition by parent_id order by ` + order + `) as rn
+				from comments
+				where parent_id in (` + placeholders + `)
+			)
+			where rn > ? and rn <= ? + ?
+			order by parent_id, rn`
+	args = append(args, offset, offset, limit)
+
+	rows, err := s.conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	comments, err := collectComments(rows)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range comments {
+		result[*c.ParentID] = append(result[*c.ParentID], c)
+	}
+	return result, nil
+}
+
+func (s *SQLiteStorage) SoftDeleteComment(ctx context.Context, id string, at time.Time) error {
+	res, err := s.conn.ExecContext(ctx, `update comments set deleted_at = ? where id = ? and deleted_at is null`, formatTime(at), id)
+	if err != nil {
+		return fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	return requireAffected(res, "комментарий", id)
+}
+
+// ответы удаляются каскадом по comments.parent_id
+func (s *SQLiteStorage) PurgeComment(ctx context.Context, id string) error {
+	res, err := s.conn.ExecContext(ctx, `delete from comments where id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	return requireAffected(res, "комментарий", id)
+}
+
+func (s *SQLiteStorage) UpdateComment(ctx context.Context, comment *models.Comment, revision *models.CommentRevision) error {
+	tx, err := s.beginTx(ctx)
+	if err != nil {
+		return fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	defer tx.Rollback()
+
+	query := `update comments set text = ?, edited_at = ? where id = ? and deleted_at is null`
+	res, err := tx.ExecContext(ctx, query, comment.Text, formatNullTime(comment.EditedAt), comment.ID)
+	if err != nil {
+		return mapError(err, fmt.Sprintf("комментарий с id %s", comment.ID))
+	}
+	if err := requireAffected(res, "комментарий", comment.ID); err != nil {
+		return err
+	}
+
+	if revision != nil {
+		query := `insert into comment_revisions (id, comment_id, text, editor_id, created_at) values (?, ?, ?, ?, ?)`
+		_, err := tx.ExecContext(ctx, query, revision.ID, revision.CommentID, revision.Text, revision.EditorID, formatTime(revision.CreatedAt))
+		if err != nil {
+			return mapError(err, fmt.Sprintf("ревизия комментария %s", comment.ID))
+		}
+	}
+	if err := writeOutbox(ctx, tx, models.EventCommentUpdated, comment.ID, comment); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	return nil
+}
+
+func (s *SQLiteStorage) ListCommentRevisions(ctx context.Context, commentID string) ([]*models.CommentRevision, error) {
+	query := `select id, comment_id, text, editor_id, created_at from comment_revisions
+			where comment_id = ?
+			order by created_at asc, id asc`
+	rows, err := s.conn.QueryContext(ctx, query, commentID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	defer rows.Close()
+
+	revisions := []*models.CommentRevision{}
+	for rows.Next() {
+		var rev models.CommentRevision
+		var createdAt string
+		if err := rows.Scan(&rev.ID, &rev.CommentID, &rev.Text, &rev.EditorID, &createdAt); err != nil {
+			return nil, fmt.Errorf("%w: %v", customerrors.ErrDBScan, err)
+		}
+		if rev.CreatedAt, err = parseTime(createdAt); err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, &rev)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	return revisions, nil
+}
@@ -0,0 +1,225 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/MAPiryazev/OzonTest/internal/customerrors"
+	"github.com/MAPiryazev/OzonTest/internal/models"
+)
+
+const webhookColumns = `id, url, secret, events, active, created_by, created_at`
+
+const deliveryColumns = `id, webhook_id, event, payload, status, attempts, next_attempt_at, last_error, created_at, delivered_at`
+
+func scanWebhook(row scanner) (*models.Webhook, error) {
+	var w models.Webhook
+	var events, createdAt string
+	if err := row.Scan(&w.ID, &w.URL, &w.Secret, &events, &w.Active, &w.CreatedBy, &createdAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(events), &w.Events); err != nil {
+		return nil, fmt.Errorf("%w: события вебхука %s: %v", customerrors.ErrDBScan, w.ID, err)
+	}
+	t, err := parseTime(createdAt)
+	if err != nil {
+		return nil, err
+	}
+	w.CreatedAt = t
+	return &w, nil
+}
+
+func scanDelivery(row scanner) (*models.WebhookDelivery, error) {
+	var d models.WebhookDelivery
+	var nextAttemptAt, createdAt string
+	var deliveredAt sql.NullString
+	if err := row.Scan(&d.ID, &d.WebhookID, &d.Event, &d.Payload, &d.Status, &d.Attempts, &nextAttemptAt, &d.LastError, &createdAt, &deliveredAt); err != nil {
+		return nil, err
+	}
+	var err error
+	if d.NextAttemptAt, err = parseTime(nextAttemptAt); err != nil {
+		return nil, err
+	}
+	if d.CreatedAt, err = parseTime(createdAt); err != nil {
+		return nil, err
+	}
+	if d.DeliveredAt, err = parseNullTime(deliveredAt); err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+func collectDeliveries(rows *sql.Rows) ([]*models.WebhookDelivery, error) {
+	defer rows.Close()
+
+	deliveries := []*models.WebhookDelivery{}
+	for rows.Next() {
+		d, err := scanDelivery(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", customerrors.ErrDBScan, err)
+		}
+		deliveries = append(deliveries, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	return deliveries, nil
+}
+
+// события хранятся json массивом
+func (s *SQLiteStorage) CreateWebhook(ctx context.Context, webhook *models.Webhook) error {
+	events, err := json.Marshal(webhook.Events)
+	if err != nil {
+		return fmt.Errorf("%w: события вебхука: %v", customerrors.ErrValidation, err)
+	}
+
+	query := `insert into webhooks (` + webhookColumns + `) values (?, ?, ?, ?, ?, ?, ?)`
+	_, err = s.conn.ExecContext(ctx, query, webhook.ID, webhook.URL, webhook.Secret, string(events), webhook.Active, webhook.CreatedBy, formatTime(webhook.CreatedAt))
+	if err != nil {
+		return mapError(err, "вебхук "+webhook.ID)
+	}
+	return nil
+}
+
+func (s *SQLiteStorage) GetWebhookByID(ctx context.Context, id string) (*models.Webhook, error) {
+	query := `select ` + webhookColumns + ` from webhooks where id = ?`
+	webhook, err := scanWebhook(s.conn.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: вебхук с id %s", customerrors.ErrNotFound, id)
+		}
+		return nil, fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	return webhook, nil
+}
+
+func (s *SQLiteStorage) ListWebhooks(ctx context.Context) ([]*models.Webhook, error) {
+	query := `select ` + webhookColumns + ` from webhooks order by created_at, id`
+	rows, err := s.conn.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	defer rows.Close()
+
+	webhooks := []*models.Webhook{}
+	for rows.Next() {
+		webhook, err := scanWebhook(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", customerrors.ErrDBScan, err)
+		}
+		webhooks = append(webhooks, webhook)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	return webhooks, nil
+}
+
+func (s *SQLiteStorage) SetWebhookActive(ctx context.Context, id string, active bool) error {
+	res, err := s.conn.ExecContext(ctx, `update webhooks set active = ? where id = ?`, active, id)
+	if err != nil {
+		return mapError(err, "вебхук "+id)
+	}
+	return requireAffected(res, "вебхук", id)
+}
+
+// доставки удаляются внешним ключом
+func (s *SQLiteStorage) DeleteWebhook(ctx context.Context, id string) error {
+	res, err := s.conn.ExecContext(ctx, `delete from webhooks where id = ?`, id)
+	if err != nil {
+		return mapError(err, "вебхук "+id)
+	}
+	return requireAffected(res, "вебхук", id)
+}
+
+func (s *SQLiteStorage) CreateWebhookDeliveries(ctx context.Context, deliveries []*models.WebhookDelivery) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+
+	tx, err := s.beginTx(ctx)
+	if err != nil {
+		return fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	defer tx.Rollback()
+
+	query := `insert into webhook_deliveries (` + deliveryColumns + `) values (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			on conflict (id) do nothing`
+	for _, d := range deliveries {
+		_, err := tx.ExecContext(ctx, query, d.ID, d.WebhookID, d.Event, d.Payload, d.Status, d.Attempts,
+			formatTime(d.NextAttemptAt), d.LastError, formatTime(d.CreatedAt), formatNullTime(d.DeliveredAt))
+		if err != nil {
+			return mapError(err, "доставка "+d.ID)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	return nil
+}
+
+// sqlite сериализует пишущие транзакции, поэтому выборка и перенос попытки одним update не пересекаются с другим воркером
+func (s *SQLiteStorage) ClaimWebhookDeliveries(ctx context.Context, now, leaseUntil time.Time, limit int) ([]*models.WebhookDelivery, error) {
+	if limit <= 0 {
+		return nil, fmt.Errorf("%w: неправильный размер пачки", customerrors.ErrParamOutOfRange)
+	}
+
+	query := `update webhook_deliveries set next_attempt_at = ?2
+		where id in (
+			select d.id from webhook_deliveries d
+			join webhooks w on w.id = d.webhook_id
+			where d.status = 'pending' and d.next_attempt_at <= ?1 and w.active
+			order by d.next_attempt_at, d.id
+			limit ?3
+		)
+		returning ` + deliveryColumns
+	rows, err := s.conn.QueryContext(ctx, query, formatTime(now), formatTime(leaseUntil), limit)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	return collectDeliveries(rows)
+}
+
+func (s *SQLiteStorage) UpdateWebhookDelivery(ctx context.Context, delivery *models.WebhookDelivery) error {
+	query := `update webhook_deliveries
+		set status = ?2, attempts = ?3, next_attempt_at = ?4, last_error = ?5, delivered_at = ?6
+		where id = ?1`
+	res, err := s.conn.ExecContext(ctx, query, delivery.ID, delivery.Status, delivery.Attempts,
+		formatTime(delivery.NextAttemptAt), delivery.LastError, formatNullTime(delivery.DeliveredAt))
+	if err != nil {
+		return mapError(err, "доставка "+delivery.ID)
+	}
+	return requireAffected(res, "доставка", delivery.ID)
+}
+
+func (s *SQLiteStorage) GetWebhookDeliveryByID(ctx context.Context, id string) (*models.WebhookDelivery, error) {
+	query := `select ` + deliveryColumns + ` from webhook_deliveries where id = ?`
+	delivery, err := scanDelivery(s.conn.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: доставка с id %s", customerrors.ErrNotFound, id)
+		}
+		return nil, fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	return delivery, nil
+}
+
+func (s *SQLiteStorage) ListWebhookDeliveries(ctx context.Context, webhookID string, status models.WebhookDeliveryStatus, offset, limit int) ([]*models.WebhookDelivery, error) {
+	if offset < 0 || limit <= 0 {
+		return nil, fmt.Errorf("%w: неправильные параметры пагинации", customerrors.ErrParamOutOfRange)
+	}
+
+	query := `select ` + deliveryColumns + ` from webhook_deliveries
+		where webhook_id = ?1 and (?2 = '' or status = ?2)
+		order by created_at desc, id desc
+		limit ?4 offset ?3`
+	rows, err := s.conn.QueryContext(ctx, query, webhookID, status, offset, limit)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", customerrors.ErrDBQuery, err)
+	}
+	return collectDeliveries(rows)
+}
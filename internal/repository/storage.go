@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"time"
 
 	"github.com/MAPiryazev/OzonTest/internal/models"
 )
@@ -10,15 +11,114 @@ import (
 type Storage interface {
 	CreatePost(ctx context.Context, post *models.Post) error
 	GetPostByID(ctx context.Context, id string) (*models.Post, error)
-	ListPosts(ctx context.Context, offset, limit int) ([]*models.Post, error)
-	UpdatePost(ctx context.Context, post *models.Post) error
+	// offset пагинация с фильтром, пустой sort - от новых к старым
+	ListPosts(ctx context.Context, filter models.PostFilter, sort models.PostSort, offset, limit int) ([]*models.Post, error)
+	// keyset пагинация по (created_at, id) desc, after == nil - с начала
+	ListPostsAfter(ctx context.Context, after *models.PageKey, limit int) ([]*models.Post, error)
+	// revision - предыдущая версия поста, nil если заголовок и текст не менялись; пишется вместе с обновлением
+	UpdatePost(ctx context.Context, post *models.Post, revision *models.PostRevision) error
+	// ревизии поста от старых к новым
+	ListPostRevisions(ctx context.Context, postID string) ([]*models.PostRevision, error)
+	// мягкое удаление: пост пропадает из всех методов чтения
+	SoftDeletePost(ctx context.Context, id string, at time.Time) error
+	// физическое удаление вместе со всеми комментариями
+	PurgePost(ctx context.Context, id string) error
+	GetPostsByIDs(ctx context.Context, ids []string) ([]*models.Post, error)
 
 	CreateComment(ctx context.Context, comment *models.Comment) error
+	// меняет текст и edited_at, предыдущий текст сохраняется ревизией
+	UpdateComment(ctx context.Context, comment *models.Comment, revision *models.CommentRevision) error
+	// ревизии комментария от старых к новым
+	ListCommentRevisions(ctx context.Context, commentID string) ([]*models.CommentRevision, error)
+	// мягкое удаление: комментарий остается в выдаче с DeletedAt, чтобы дерево ответов не разваливалось
+	SoftDeleteComment(ctx context.Context, id string, at time.Time) error
+	// физическое удаление вместе со всеми ответами
+	PurgeComment(ctx context.Context, id string) error
 	GetCommentByID(ctx context.Context, id string) (*models.Comment, error)
-	ListCommentsByPost(ctx context.Context, postID string, parentID *string, offset, limit int) ([]*models.Comment, error)
+	// offset пагинация в порядке sort, пустой sort - по времени создания
+	ListCommentsByPost(ctx context.Context, postID string, parentID *string, sort models.CommentSort, offset, limit int) ([]*models.Comment, error)
+	// keyset пагинация по (created_at, id) asc, after == nil - с начала
+	ListCommentsAfter(ctx context.Context, postID string, parentID *string, after *models.PageKey, limit int) ([]*models.Comment, error)
+	// дерево комментариев в порядке обхода в глубину, rootID == nil - от комментариев верхнего уровня
+	GetCommentThread(ctx context.Context, postID string, rootID *string, maxDepth, maxNodes int) ([]*models.ThreadComment, error)
+	// ответы сразу для нескольких родителей, offset и limit применяются к каждому родителю отдельно
+	ListRepliesByParentIDs(ctx context.Context, parentIDs []string, sort models.CommentSort, offset, limit int) (map[string][]*models.Comment, error)
+	// комментарии пользователя от новых к старым, без удаленных и без комментариев удаленных постов
+	ListCommentsByAuthor(ctx context.Context, authorID string, offset, limit int) ([]*models.Comment, error)
+
+	// заменяет упоминания объекта пользователями userIDs в порядке появления в тексте,
+	// возвращает тех, кого среди упоминаний раньше не было
+	ReplaceMentions(ctx context.Context, target models.ReactionTarget, targetID string, userIDs []string) ([]string, error)
+	// упоминания нескольких объектов с именами пользователей, объекты без упоминаний в карту не попадают
+	GetMentions(ctx context.Context, target models.ReactionTarget, targetIDs []string) (map[string][]models.Mention, error)
+
+	// уведомления удаляются вместе с комментарием, к которому относятся;
+	// уведомления с уже существующим id пропускаются, чтобы повторная обработка события не создавала дублей
+	CreateNotifications(ctx context.Context, notifications []*models.Notification) error
+	// keyset пагинация по (created_at, id) desc, after == nil - с самых новых
+	ListNotifications(ctx context.Context, userID string, unreadOnly bool, after *models.PageKey, limit int) ([]*models.Notification, error)
+	CountUnreadNotifications(ctx context.Context, userID string) (int, error)
+	// отмечает прочитанными уведомления пользователя из ids, ids == nil - все; возвращает число отмеченных
+	MarkNotificationsRead(ctx context.Context, userID string, ids []string, at time.Time) (int, error)
+
+	CreateWebhook(ctx context.Context, webhook *models.Webhook) error
+	GetWebhookByID(ctx context.Context, id string) (*models.Webhook, error)
+	// все вебхуки от старых к новым
+	ListWebhooks(ctx context.Context) ([]*models.Webhook, error)
+	SetWebhookActive(ctx context.Context, id string, active bool) error
+	// удаляет вебхук вместе с его доставками
+	DeleteWebhook(ctx context.Context, id string) error
+	// доставки с уже существующими id пропускаются, поэтому повторная обработка события не дублирует их
+	CreateWebhookDeliveries(ctx context.Context, deliveries []*models.WebhookDelivery) error
+	// забирает до limit ожидающих доставок активных вебхуков с next_attempt_at <= now в порядке очереди
+	// и переносит их попытку на leaseUntil, чтобы доставку не взял другой воркер, пока идет запрос
+	ClaimWebhookDeliveries(ctx context.Context, now, leaseUntil time.Time, limit int) ([]*models.WebhookDelivery, error)
+	// сохраняет результат попытки: статус, число попыток, время следующей попытки, ошибку и время доставки
+	UpdateWebhookDelivery(ctx context.Context, delivery *models.WebhookDelivery) error
+	GetWebhookDeliveryByID(ctx context.Context, id string) (*models.WebhookDelivery, error)
+	// доставки вебхука от новых к старым, пустой status - все
+	ListWebhookDeliveries(ctx context.Context, webhookID string, status models.WebhookDeliveryStatus, offset, limit int) ([]*models.WebhookDelivery, error)
+
+	// события outbox с seq > afterSeq по возрастанию seq; CreateUser, CreatePost, UpdatePost, CreateComment
+	// и UpdateComment пишут событие той же транзакцией, что и данные
+	ListOutboxEvents(ctx context.Context, afterSeq int64, limit int) ([]*models.OutboxEvent, error)
+	// seq последнего обработанного потребителем события, 0 - если он еще ничего не обработал
+	GetConsumerOffset(ctx context.Context, consumer string) (int64, error)
+	// смещение только растет: меньший seq от отставшего экземпляра ретранслятора игнорируется
+	SetConsumerOffset(ctx context.Context, consumer string, seq int64) error
+	// удаляет события с seq <= uptoSeq, вызывается, когда их обработали все потребители
+	PruneOutbox(ctx context.Context, uptoSeq int64) error
+
+	// полнотекстовый поиск по видимым постам и комментариям, результаты по убыванию релевантности
+	Search(ctx context.Context, query string, searchType models.SearchType, offset, limit int) ([]*models.SearchHit, error)
 
 	CreateUser(ctx context.Context, user *models.User) error
 	GetUserByID(ctx context.Context, id string) (*models.User, error)
+	// в отличие от GetUserByID возвращает хеш пароля и состояние блокировки
+	GetUserByUsername(ctx context.Context, username string) (*models.User, error)
+	// атомарно увеличивает счетчик неудачных входов, при достижении maxFailures блокирует до lockUntil и обнуляет счетчик
+	RegisterLoginFailure(ctx context.Context, userID string, maxFailures int, lockUntil time.Time) error
+	ResetLoginFailures(ctx context.Context, userID string) error
+	SetUserRole(ctx context.Context, userID string, role models.Role) error
+	GetUsersByIDs(ctx context.Context, ids []string) ([]*models.User, error)
+	// пользователи с точно совпадающими именами, несуществующие имена пропускаются
+	GetUsersByUsernames(ctx context.Context, usernames []string) ([]*models.User, error)
+
+	// ставит или меняет реакцию пользователя, счетчики и ранги комментария меняются вместе с реакцией
+	SetReaction(ctx context.Context, reaction *models.Reaction) error
+	// снимает реакцию пользователя, если реакции нет - ничего не делает
+	RemoveReaction(ctx context.Context, target models.ReactionTarget, targetID, userID string) error
+	// счетчики реакций для нескольких объектов без подсчета строк, нулевые счетчики не возвращаются
+	GetReactionCounts(ctx context.Context, target models.ReactionTarget, targetIDs []string) (map[string][]models.ReactionCount, error)
+	// реакции одного пользователя на несколько объектов
+	GetUserReactions(ctx context.Context, target models.ReactionTarget, userID string, targetIDs []string) (map[string]models.ReactionKind, error)
+
+	// выполняет fn в одной транзакции: ошибка fn откатывает все ее изменения, иначе они фиксируются вместе.
+	// fn работает только через переданное tx, вложенный WithTx выполняется в той же транзакции.
+	// в postgres посты и комментарии, прочитанные через tx, блокируются от изменения до ее конца;
+	// во всех хранилищах чтения внутри fn видят ее собственные записи. in-memory хранилище держит
+	// блокировку на все время fn, применяет записи сразу и при ошибке или панике откатывает их
+	WithTx(ctx context.Context, fn func(tx Storage) error) error
 
 	Close() error
 }
@@ -0,0 +1,150 @@
+package search
+
+import (
+	"html"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// разбор текста для полнотекстового поиска in-memory и sqlite хранилищ,
+// postgres использует встроенную конфигурацию russian
+
+// маркеры подсветки совпадений, такие же по умолчанию ставит ts_headline.
+// остальной текст фрагмента экранирован для html, поэтому других тегов в нем нет
+const (
+	HighlightStart = "<b>"
+	HighlightStop  = "</b>"
+	Ellipsis       = "…"
+)
+
+// временные маркеры подсветки из области частного использования unicode для фрагментов,
+// которые строит база: из исходного текста они вырезаются, поэтому после экранирования
+// их можно однозначно заменить на HighlightStart и HighlightStop
+const (
+	RawStart = "\ue000"
+	RawStop  = "\ue001"
+)
+
+// число слов во фрагменте результата
+const SnippetWords = 30
+
+// частые слова, которые не несут смысла для поиска
+var stopWords = map[string]struct{}{
+	"и": {}, "в": {}, "во": {}, "не": {}, "что": {}, "он": {}, "на": {}, "я": {}, "с": {}, "со": {}, "как": {},
+	"а": {}, "то": {}, "все": {}, "она": {}, "так": {}, "его": {}, "но": {}, "да": {}, "ты": {}, "к": {}, "у": {},
+	"же": {}, "вы": {}, "за": {}, "бы": {}, "по": {}, "ее": {}, "мне": {}, "было": {}, "вот": {}, "от": {},
+	"меня": {}, "еще": {}, "нет": {}, "о": {}, "из": {}, "ему": {}, "ли": {}, "если": {}, "или": {}, "для": {},
+	"это": {}, "a": {}, "an": {}, "the": {}, "and": {}, "or": {}, "of": {}, "to": {}, "in": {}, "is": {},
+}
+
+// окончания русских слов от длинных к коротким, отрезается самое длинное подходящее
+var endings = func() []string {
+	list := []string{
+		"иями", "ями", "ами", "иях", "ием", "иев", "ого", "его", "ому", "ему", "ыми", "ими", "ешь", "ией",
+		"ии", "ия", "ие", "ий", "ию", "ях", "ах", "ов", "ев", "ей", "ый", "ой", "ая", "яя", "ое", "ее", "ые",
+		"ую", "юю", "ом", "ем", "ам", "ям", "ью", "ть", "ет", "ют", "ут", "ит", "ат", "ят", "ла", "ли", "ло",
+		"а", "я", "о", "е", "ы", "и", "у", "ю", "ь", "й",
+	}
+	sort.SliceStable(list, func(i, j int) bool { return utf8.RuneCountInString(list[i]) > utf8.RuneCountInString(list[j]) })
+	return list
+}()
+
+// стем короче этого не отрезается, чтобы короткие слова не склеивались
+const minStemLen = 3
+
+// грубый стеммер: разные формы одного слова обычно дают один стем ("пост", "постами", "постов")
+func Stem(word string) string {
+	word = strings.ReplaceAll(strings.ToLower(word), "ё", "е")
+	for _, ending := range endings {
+		if !strings.HasSuffix(word, ending) {
+			continue
+		}
+		stem := strings.TrimSuffix(word, ending)
+		if utf8.RuneCountInString(stem) >= minStemLen {
+			return stem
+		}
+	}
+	return word
+}
+
+// стемы значимых слов текста в порядке появления, с повторами
+func Terms(text string) []string {
+	terms := []string{}
+	for _, word := range words(text) {
+		if _, stop := stopWords[strings.ToLower(word)]; stop {
+			continue
+		}
+		terms = append(terms, Stem(word))
+	}
+	return terms
+}
+
+// уникальные стемы запроса
+func QueryTerms(query string) []string {
+	seen := make(map[string]struct{})
+	terms := []string{}
+	for _, term := range Terms(query) {
+		if _, ok := seen[term]; !ok {
+			seen[term] = struct{}{}
+			terms = append(terms, term)
+		}
+	}
+	return terms
+}
+
+func words(text string) []string {
+	return strings.FieldsFunc(text, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) })
+}
+
+// фрагмент текста до maxWords слов вокруг первого совпадения, совпавшие слова обрамляются маркерами
+func Snippet(text string, terms []string, maxWords int) string {
+	set := make(map[string]struct{}, len(terms))
+	for _, term := range terms {
+		set[term] = struct{}{}
+	}
+	matches := func(field string) bool {
+		for _, word := range words(field) {
+			if _, ok := set[Stem(word)]; ok {
+				return true
+			}
+		}
+		return false
+	}
+
+	fields := strings.Fields(text)
+	first := 0
+	for i, field := range fields {
+		if matches(field) {
+			first = i
+			break
+		}
+	}
+
+	start := max(0, first-maxWords/3)
+	end := min(len(fields), start+maxWords)
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString(Ellipsis + " ")
+	}
+	for i := start; i < end; i++ {
+		if i > start {
+			b.WriteByte(' ')
+		}
+		if matches(fields[i]) {
+			b.WriteString(HighlightStart + html.EscapeString(fields[i]) + HighlightStop)
+		} else {
+			b.WriteString(html.EscapeString(fields[i]))
+		}
+	}
+	if end < len(fields) {
+		b.WriteString(" " + Ellipsis)
+	}
+	return b.String()
+}
+
+// экранирует фрагмент с маркерами RawStart и RawStop и заменяет их на теги подсветки
+func EscapeHighlighted(raw string) string {
+	return strings.NewReplacer(RawStart, HighlightStart, RawStop, HighlightStop).Replace(html.EscapeString(raw))
+}
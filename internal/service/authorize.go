@@ -0,0 +1,54 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/MAPiryazev/OzonTest/internal/auth"
+	"github.com/MAPiryazev/OzonTest/internal/customerrors"
+	"github.com/MAPiryazev/OzonTest/internal/models"
+	"github.com/MAPiryazev/OzonTest/internal/policy"
+	"github.com/MAPiryazev/OzonTest/internal/repository"
+)
+
+// роль в токене остается прежней до его истечения, поэтому если действие разрешает роль, а не авторство,
+// она перепроверяется по хранилищу repo: понижение и удаление пользователя действуют сразу
+func authorize(ctx context.Context, repo repository.Storage, actor *auth.Identity, action policy.Action, ownerID string) error {
+	if err := policy.Authorize(actor, action, ownerID); err != nil {
+		return err
+	}
+	// автору и обычному пользователю роль не нужна
+	if policy.Authorize(&auth.Identity{UserID: actor.UserID, Role: models.RoleUser}, action, ownerID) == nil {
+		return nil
+	}
+
+	stored, err := storedIdentity(ctx, repo, actor)
+	if err != nil {
+		return err
+	}
+	return policy.Authorize(stored, action, ownerID)
+}
+
+// роль не ниже required и по токену, и по хранилищу
+func hasRole(ctx context.Context, repo repository.Storage, actor *auth.Identity, required models.Role) (bool, error) {
+	if !policy.HasRole(actor, required) {
+		return false, nil
+	}
+	stored, err := storedIdentity(ctx, repo, actor)
+	if err != nil {
+		return false, err
+	}
+	return policy.HasRole(stored, required), nil
+}
+
+func storedIdentity(ctx context.Context, repo repository.Storage, actor *auth.Identity) (*auth.Identity, error) {
+	user, err := repo.GetUserByID(ctx, actor.UserID)
+	if err != nil {
+		if errors.Is(err, customerrors.ErrNotFound) {
+			return nil, fmt.Errorf("%w: пользователь %s не найден", customerrors.ErrUnauthorized, actor.UserID)
+		}
+		return nil, err
+	}
+	return &auth.Identity{UserID: user.ID, Username: user.Username, Role: user.Role}, nil
+}
@@ -0,0 +1,179 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"strconv"
+
+	"github.com/google/uuid"
+
+	"github.com/MAPiryazev/OzonTest/internal/customerrors"
+	"github.com/MAPiryazev/OzonTest/internal/models"
+	"github.com/MAPiryazev/OzonTest/internal/repository"
+)
+
+// потребители outbox регистрируются в ретрансляторе (internal/outbox) и получают каждое событие
+// хотя бы один раз, в том числе после падения процесса сразу после записи
+
+// рассылает новые комментарии подписчикам commentAdded
+func (s *service) PublishCommentEvent(ctx context.Context, event *models.OutboxEvent) error {
+	if event.Type != models.EventCommentCreated {
+		return nil
+	}
+	var comment models.Comment
+	if !decodeEvent(event, &comment) {
+		return nil
+	}
+	s.commentsAdded.Publish(comment.PostID, &comment)
+	return nil
+}
+
+// сохраняет упоминания и создает уведомления о новых и отредактированных постах и комментариях.
+// id уведомления выводится из seq события и получателя, поэтому повторная обработка не создает дублей
+func (s *service) NotifyEvent(ctx context.Context, event *models.OutboxEvent) error {
+	switch event.Type {
+	case models.EventPostCreated, models.EventPostUpdated:
+		var post models.Post
+		if !decodeEvent(event, &post) {
+			return nil
+		}
+		return s.notifyPost(ctx, event, &post)
+	case models.EventCommentCreated, models.EventCommentUpdated:
+		var comment models.Comment
+		if !decodeEvent(event, &comment) {
+			return nil
+		}
+		return s.notifyComment(ctx, event, &comment)
+	}
+	return nil
+}
+
+// в посте уведомляются только впервые упомянутые
+func (s *service) notifyPost(ctx context.Context, event *models.OutboxEvent, post *models.Post) error {
+	var notifications []*models.Notification
+	err := s.repository.WithTx(ctx, func(tx repository.Storage) error {
+		// пост успели удалить, уведомлять не о чем
+		if _, err := tx.GetPostByID(ctx, post.ID); err != nil {
+			if errors.Is(err, customerrors.ErrNotFound) {
+				return nil
+			}
+			return err
+		}
+
+		mentioned, err := saveMentions(ctx, tx, models.ReactionTargetPost, post.ID, post.Content, event.Type == models.EventPostCreated)
+		if err != nil {
+			return err
+		}
+		recipients := make(map[string]models.NotificationKind, len(mentioned))
+		for _, userID := range mentioned {
+			recipients[userID] = models.NotificationMention
+		}
+		notifications = eventNotifications(event, recipients, post.AuthorID, post.ID, nil)
+		if len(notifications) == 0 {
+			return nil
+		}
+		return tx.CreateNotifications(ctx, notifications)
+	})
+	if err != nil {
+		return err
+	}
+	s.publishNotifications(notifications)
+	return nil
+}
+
+// о новом комментарии уведомляются автор поста, автор родительского комментария и упомянутые;
+// каждый получает одно уведомление: ответ важнее комментария к посту, а тот - упоминания.
+// при правке уведомляются только впервые упомянутые
+func (s *service) notifyComment(ctx context.Context, event *models.OutboxEvent, comment *models.Comment) error {
+	var notifications []*models.Notification
+	err := s.repository.WithTx(ctx, func(tx repository.Storage) error {
+		// комментарий или пост успели удалить, уведомлять не о чем
+		current, err := tx.GetCommentByID(ctx, comment.ID)
+		if errors.Is(err, customerrors.ErrNotFound) || (err == nil && current.DeletedAt != nil) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		post, err := tx.GetPostByID(ctx, comment.PostID)
+		if err != nil {
+			if errors.Is(err, customerrors.ErrNotFound) {
+				return nil
+			}
+			return err
+		}
+
+		created := event.Type == models.EventCommentCreated
+		mentioned, err := saveMentions(ctx, tx, models.ReactionTargetComment, comment.ID, comment.Text, created)
+		if err != nil {
+			return err
+		}
+		recipients := make(map[string]models.NotificationKind, len(mentioned)+2)
+		for _, userID := range mentioned {
+			recipients[userID] = models.NotificationMention
+		}
+		if created {
+			recipients[post.AuthorID] = models.NotificationPostComment
+			if comment.ParentID != nil {
+				parent, err := tx.GetCommentByID(ctx, *comment.ParentID)
+				if err != nil {
+					return err
+				}
+				recipients[parent.AuthorID] = models.NotificationCommentReply
+			}
+		}
+
+		commentID := comment.ID
+		notifications = eventNotifications(event, recipients, comment.AuthorID, comment.PostID, &commentID)
+		if len(notifications) == 0 {
+			return nil
+		}
+		return tx.CreateNotifications(ctx, notifications)
+	})
+	if err != nil {
+		return err
+	}
+	s.publishNotifications(notifications)
+	return nil
+}
+
+// уведомления о событии, себе уведомления не приходят
+func eventNotifications(event *models.OutboxEvent, recipients map[string]models.NotificationKind, actorID, postID string, commentID *string) []*models.Notification {
+	delete(recipients, actorID)
+	notifications := make([]*models.Notification, 0, len(recipients))
+	for userID, kind := range recipients {
+		notifications = append(notifications, &models.Notification{
+			ID:        NotificationID(event.Seq, userID),
+			UserID:    userID,
+			Kind:      kind,
+			ActorID:   actorID,
+			PostID:    postID,
+			CommentID: commentID,
+			CreatedAt: event.CreatedAt,
+		})
+	}
+	return notifications
+}
+
+// детерминированный id уведомления о событии seq для пользователя userID
+func NotificationID(seq int64, userID string) string {
+	return uuid.NewSHA1(uuid.NameSpaceOID, []byte("notification/"+strconv.FormatInt(seq, 10)+"/"+userID)).String()
+}
+
+// отправляет сохраненные уведомления подписчикам, при повторной обработке события они могут прийти еще раз
+func (s *service) publishNotifications(notifications []*models.Notification) {
+	for _, n := range notifications {
+		s.notifications.Publish(n.UserID, n)
+	}
+}
+
+// повтор не исправит битое событие, поэтому оно логируется и пропускается
+func decodeEvent(event *models.OutboxEvent, entity any) bool {
+	if err := json.Unmarshal([]byte(event.Payload), entity); err != nil {
+		log.Printf("не удалось разобрать событие %d (%s): %v", event.Seq, event.Type, err)
+		return false
+	}
+	return true
+}
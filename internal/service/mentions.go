@@ -0,0 +1,46 @@
+package service
+
+import (
+	"context"
+
+	"github.com/MAPiryazev/OzonTest/internal/mentions"
+	"github.com/MAPiryazev/OzonTest/internal/models"
+	"github.com/MAPiryazev/OzonTest/internal/repository"
+)
+
+// сохраняет упоминания из text через repo и возвращает впервые упомянутых пользователей;
+// у нового объекта без упоминаний хранилище не трогается, при правке список заменяется всегда
+func saveMentions(ctx context.Context, repo repository.Storage, target models.ReactionTarget, targetID, text string, created bool) ([]string, error) {
+	names := mentions.Parse(text)
+	if created && len(names) == 0 {
+		return nil, nil
+	}
+
+	// неизвестные имена остаются обычным текстом
+	var userIDs []string
+	if len(names) > 0 {
+		users, err := repo.GetUsersByUsernames(ctx, names)
+		if err != nil {
+			return nil, err
+		}
+		byName := make(map[string]string, len(users))
+		for _, u := range users {
+			byName[u.Username] = u.ID
+		}
+		for _, name := range names {
+			if id, ok := byName[name]; ok {
+				userIDs = append(userIDs, id)
+			}
+		}
+	}
+	return repo.ReplaceMentions(ctx, target, targetID, userIDs)
+}
+
+// упоминания для нескольких объектов, используется загрузчиками graphql
+func (s *service) GetMentions(ctx context.Context, target models.ReactionTarget, ids []string) (map[string][]models.Mention, error) {
+	trIDs, err := trimIDs(ids)
+	if err != nil {
+		return nil, err
+	}
+	return s.repository.GetMentions(ctx, target, trIDs)
+}
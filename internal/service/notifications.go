@@ -0,0 +1,85 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/MAPiryazev/OzonTest/internal/auth"
+	"github.com/MAPiryazev/OzonTest/internal/cursor"
+	"github.com/MAPiryazev/OzonTest/internal/customerrors"
+	"github.com/MAPiryazev/OzonTest/internal/models"
+)
+
+// уведомления текущего пользователя от новых к старым
+func (s *service) ListNotifications(ctx context.Context, first int, after *string, unreadOnly bool) (*models.NotificationConnection, error) {
+	actor, err := auth.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if first <= 0 || first > s.cfg.MaxListLimit {
+		return nil, fmt.Errorf("%w: неправильные параметры пагинации", customerrors.ErrParamOutOfRange)
+	}
+
+	afterKey, err := s.decodeCursor(cursor.KindNotification, after)
+	if err != nil {
+		return nil, err
+	}
+
+	notifications, err := s.repository.ListNotifications(ctx, actor.UserID, unreadOnly, afterKey, first+1)
+	if err != nil {
+		return nil, err
+	}
+	unread, err := s.repository.CountUnreadNotifications(ctx, actor.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	hasNext := len(notifications) > first
+	if hasNext {
+		notifications = notifications[:first]
+	}
+
+	conn := &models.NotificationConnection{Edges: make([]*models.NotificationEdge, len(notifications)), UnreadCount: unread}
+	for i, n := range notifications {
+		conn.Edges[i] = &models.NotificationEdge{
+			Cursor: s.cursors.Encode(cursor.KindNotification, models.PageKey{CreatedAt: n.CreatedAt, ID: n.ID}),
+			Node:   n,
+		}
+	}
+	conn.PageInfo = pageInfo(len(conn.Edges), func(i int) string { return conn.Edges[i].Cursor }, hasNext, after != nil)
+	return conn, nil
+}
+
+// чужие и уже прочитанные id пропускаются, возвращается число отмеченных
+func (s *service) MarkNotificationsRead(ctx context.Context, ids []string) (int, error) {
+	actor, err := auth.Require(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if len(ids) > s.cfg.MaxListLimit {
+		return 0, fmt.Errorf("%w: за раз можно отметить не больше %d уведомлений", customerrors.ErrParamOutOfRange, s.cfg.MaxListLimit)
+	}
+	trIDs, err := trimIDs(ids)
+	if err != nil {
+		return 0, err
+	}
+	return s.repository.MarkNotificationsRead(ctx, actor.UserID, trIDs, time.Now().UTC())
+}
+
+func (s *service) MarkAllNotificationsRead(ctx context.Context) (int, error) {
+	actor, err := auth.Require(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return s.repository.MarkNotificationsRead(ctx, actor.UserID, nil, time.Now().UTC())
+}
+
+// новые уведомления текущего пользователя, канал закрывается при отмене ctx
+func (s *service) SubscribeNotifications(ctx context.Context) (<-chan *models.Notification, error) {
+	actor, err := auth.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.notifications.Subscribe(ctx, actor.UserID)
+}
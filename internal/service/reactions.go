@@ -0,0 +1,164 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/MAPiryazev/OzonTest/internal/auth"
+	"github.com/MAPiryazev/OzonTest/internal/customerrors"
+	"github.com/MAPiryazev/OzonTest/internal/models"
+	"github.com/MAPiryazev/OzonTest/internal/policy"
+	"github.com/MAPiryazev/OzonTest/internal/repository"
+)
+
+// ставит реакцию текущего пользователя, предыдущая реакция на тот же объект заменяется
+func (s *service) SetReaction(ctx context.Context, target models.ReactionTarget, targetID string, kind models.ReactionKind) (*models.ReactionSummary, error) {
+	actor, err := auth.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := policy.Authorize(actor, policy.ActionReact, ""); err != nil {
+		return nil, err
+	}
+	if !validReactionKind(kind) {
+		return nil, fmt.Errorf("%w: неизвестная реакция %s", customerrors.ErrValidation, kind)
+	}
+
+	// проверка объекта и запись реакции в одной транзакции, чтобы не отреагировать на только что удаленный объект
+	var trID string
+	err = s.repository.WithTx(ctx, func(tx repository.Storage) error {
+		var err error
+		trID, err = checkReactionTarget(ctx, tx, target, targetID)
+		if err != nil {
+			return err
+		}
+		reaction := &models.Reaction{Target: target, TargetID: trID, UserID: actor.UserID, Kind: kind, CreatedAt: time.Now().UTC()}
+		return tx.SetReaction(ctx, reaction)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.reactionSummary(ctx, target, trID)
+}
+
+// снимает реакцию текущего пользователя, повторное снятие не ошибка
+func (s *service) RemoveReaction(ctx context.Context, target models.ReactionTarget, targetID string) (*models.ReactionSummary, error) {
+	actor, err := auth.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var trID string
+	err = s.repository.WithTx(ctx, func(tx repository.Storage) error {
+		var err error
+		trID, err = checkReactionTarget(ctx, tx, target, targetID)
+		if err != nil {
+			return err
+		}
+		return tx.RemoveReaction(ctx, target, trID, actor.UserID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.reactionSummary(ctx, target, trID)
+}
+
+// агрегаты реакций для нескольких объектов, у объекта без реакций пустой агрегат
+func (s *service) GetReactionSummaries(ctx context.Context, target models.ReactionTarget, ids []string) (map[string]*models.ReactionSummary, error) {
+	trIDs, err := trimIDs(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	counts, err := s.repository.GetReactionCounts(ctx, target, trIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]*models.ReactionSummary, len(trIDs))
+	for _, id := range trIDs {
+		result[id] = summarize(counts[id])
+	}
+	return result, nil
+}
+
+// реакции текущего пользователя, для анонимного запроса пустой результат
+func (s *service) GetMyReactions(ctx context.Context, target models.ReactionTarget, ids []string) (map[string]models.ReactionKind, error) {
+	actor, ok := auth.FromContext(ctx)
+	if !ok {
+		return map[string]models.ReactionKind{}, nil
+	}
+
+	trIDs, err := trimIDs(ids)
+	if err != nil {
+		return nil, err
+	}
+	return s.repository.GetUserReactions(ctx, target, actor.UserID, trIDs)
+}
+
+func (s *service) reactionSummary(ctx context.Context, target models.ReactionTarget, id string) (*models.ReactionSummary, error) {
+	summaries, err := s.GetReactionSummaries(ctx, target, []string{id})
+	if err != nil {
+		return nil, err
+	}
+	return summaries[id], nil
+}
+
+// реагировать можно только на видимые объекты: удаленный пост или комментарий считается отсутствующим
+func checkReactionTarget(ctx context.Context, repo repository.Storage, target models.ReactionTarget, targetID string) (string, error) {
+	trID := strings.TrimSpace(targetID)
+	if trID == "" {
+		return "", fmt.Errorf("%w: id объекта реакции обязателен", customerrors.ErrValidation)
+	}
+
+	switch target {
+	case models.ReactionTargetPost:
+		if _, err := repo.GetPostByID(ctx, trID); err != nil {
+			return "", err
+		}
+	case models.ReactionTargetComment:
+		comment, err := repo.GetCommentByID(ctx, trID)
+		if err != nil {
+			return "", err
+		}
+		if comment.DeletedAt != nil {
+			return "", fmt.Errorf("%w: комментарий с id %s удален", customerrors.ErrNotFound, trID)
+		}
+		if _, err := repo.GetPostByID(ctx, comment.PostID); err != nil {
+			return "", err
+		}
+	default:
+		return "", fmt.Errorf("%w: неизвестный объект реакции %s", customerrors.ErrValidation, target)
+	}
+	return trID, nil
+}
+
+func validReactionKind(kind models.ReactionKind) bool {
+	for _, k := range models.ReactionKinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// счетчики в порядке models.ReactionKinds, Score = up - down
+func summarize(counts []models.ReactionCount) *models.ReactionSummary {
+	byKind := make(map[models.ReactionKind]int, len(counts))
+	for _, c := range counts {
+		byKind[c.Kind] = c.Count
+	}
+
+	summary := &models.ReactionSummary{
+		Score:  byKind[models.ReactionUp] - byKind[models.ReactionDown],
+		Counts: []models.ReactionCount{},
+	}
+	for _, kind := range models.ReactionKinds {
+		if byKind[kind] > 0 {
+			summary.Counts = append(summary.Counts, models.ReactionCount{Kind: kind, Count: byKind[kind]})
+		}
+	}
+	return summary
+}
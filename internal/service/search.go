@@ -0,0 +1,63 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/MAPiryazev/OzonTest/internal/cursor"
+	"github.com/MAPiryazev/OzonTest/internal/customerrors"
+	"github.com/MAPiryazev/OzonTest/internal/models"
+)
+
+const maxSearchQueryLen = 200
+
+// полнотекстовый поиск с курсорной пагинацией, курсор привязан к запросу и типу поиска
+func (s *service) Search(ctx context.Context, query string, searchType models.SearchType, first int, after *string) (*models.SearchConnection, error) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return nil, fmt.Errorf("%w: поисковый запрос не может быть пустым", customerrors.ErrValidation)
+	}
+	if utf8.RuneCountInString(query) > maxSearchQueryLen {
+		return nil, fmt.Errorf("%w: поисковый запрос длиннее %d символов", customerrors.ErrValidation, maxSearchQueryLen)
+	}
+	switch searchType {
+	case "":
+		searchType = models.SearchAll
+	case models.SearchAll, models.SearchPosts, models.SearchComments:
+	default:
+		return nil, fmt.Errorf("%w: неизвестный тип поиска %s", customerrors.ErrValidation, searchType)
+	}
+
+	if first <= 0 || first > s.cfg.MaxListLimit {
+		return nil, fmt.Errorf("%w: неправильные параметры пагинации", customerrors.ErrParamOutOfRange)
+	}
+
+	kind := cursor.KindSearch + ":" + string(searchType) + ":" + query
+	offset := 0
+	if after != nil {
+		decoded, err := s.cursors.DecodeOffset(kind, *after)
+		if err != nil {
+			return nil, err
+		}
+		offset = decoded
+	}
+
+	hits, err := s.repository.Search(ctx, query, searchType, offset, first+1)
+	if err != nil {
+		return nil, err
+	}
+
+	hasNext := len(hits) > first
+	if hasNext {
+		hits = hits[:first]
+	}
+
+	conn := &models.SearchConnection{Edges: make([]*models.SearchEdge, len(hits))}
+	for i, hit := range hits {
+		conn.Edges[i] = &models.SearchEdge{Cursor: s.cursors.EncodeOffset(kind, offset+i+1), Node: hit}
+	}
+	conn.PageInfo = pageInfo(len(conn.Edges), func(i int) string { return conn.Edges[i].Cursor }, hasNext, after != nil)
+	return conn, nil
+}
@@ -9,38 +9,109 @@ import (
 
 	"github.com/google/uuid"
 
+	"github.com/MAPiryazev/OzonTest/internal/auth"
 	"github.com/MAPiryazev/OzonTest/internal/config"
+	"github.com/MAPiryazev/OzonTest/internal/cursor"
 	"github.com/MAPiryazev/OzonTest/internal/customerrors"
+	"github.com/MAPiryazev/OzonTest/internal/mentions"
 	"github.com/MAPiryazev/OzonTest/internal/models"
+	"github.com/MAPiryazev/OzonTest/internal/policy"
+	"github.com/MAPiryazev/OzonTest/internal/pubsub"
 	"github.com/MAPiryazev/OzonTest/internal/repository"
 )
 
 const MAX_COMMENT_LENGTH = 2000
 
+// текст, который отдается вместо удаленного комментария
+const DeletedCommentText = "[deleted]"
+
 type Service interface {
-	CreateUser(ctx context.Context, user *models.User) error
 	GetUserByID(ctx context.Context, id string) (*models.User, error)
+	GetUserByUsername(ctx context.Context, username string) (*models.User, error)
+	GetUsersByIDs(ctx context.Context, ids []string) ([]*models.User, error)
+	Register(ctx context.Context, username, password string) (*models.AuthPayload, error)
+	Login(ctx context.Context, username, password string) (*models.AuthPayload, error)
+	SetUserRole(ctx context.Context, userID string, role models.Role) (*models.User, error)
 
 	CreatePost(ctx context.Context, post *models.Post) error
 	GetPostByID(ctx context.Context, id string) (*models.Post, error)
-	ListPosts(ctx context.Context, offset int, limit int) ([]*models.Post, error)
-	UpdatePost(ctx context.Context, post *models.Post, userID string) error
+	ListPosts(ctx context.Context, filter models.PostFilter, sort models.PostSort, offset int, limit int) ([]*models.Post, error)
+	ListPostsConnection(ctx context.Context, first int, after *string) (*models.PostConnection, error)
+	UpdatePost(ctx context.Context, id string, patch models.PostPatch) (*models.Post, error)
+	SetCommentsEnabled(ctx context.Context, postID string, enabled bool) (*models.Post, error)
+	ListPostRevisions(ctx context.Context, postID string) ([]*models.PostRevision, error)
+	GetPostsByIDs(ctx context.Context, ids []string) ([]*models.Post, error)
+	DeletePost(ctx context.Context, id string, hard bool) error
 
 	CreateComment(ctx context.Context, comment *models.Comment) error
 	GetCommentByID(ctx context.Context, id string) (*models.Comment, error)
-	ListCommentsByPost(ctx context.Context, postID string, parentID *string, offset, limit int) ([]*models.Comment, error)
+	UpdateComment(ctx context.Context, id, text string) (*models.Comment, error)
+	ListCommentRevisions(ctx context.Context, commentID string) ([]*models.CommentRevision, error)
+	ListCommentsByPost(ctx context.Context, postID string, parentID *string, sort models.CommentSort, offset, limit int) ([]*models.Comment, error)
+	ListCommentsConnection(ctx context.Context, postID string, parentID *string, first int, after *string) (*models.CommentConnection, error)
+	GetCommentThread(ctx context.Context, postID string, rootID *string, maxDepth, maxNodes int) (*models.CommentThread, error)
+	ListRepliesByParentIDs(ctx context.Context, parentIDs []string, sort models.CommentSort, offset, limit int) (map[string][]*models.Comment, error)
+	ListCommentsByAuthor(ctx context.Context, authorID string, offset, limit int) ([]*models.Comment, error)
+	SubscribeCommentAdded(ctx context.Context, postID string) (<-chan *models.Comment, error)
+	DeleteComment(ctx context.Context, id string, hard bool) error
+
+	SetReaction(ctx context.Context, target models.ReactionTarget, targetID string, kind models.ReactionKind) (*models.ReactionSummary, error)
+	RemoveReaction(ctx context.Context, target models.ReactionTarget, targetID string) (*models.ReactionSummary, error)
+	GetReactionSummaries(ctx context.Context, target models.ReactionTarget, ids []string) (map[string]*models.ReactionSummary, error)
+	GetMyReactions(ctx context.Context, target models.ReactionTarget, ids []string) (map[string]models.ReactionKind, error)
+	GetMentions(ctx context.Context, target models.ReactionTarget, ids []string) (map[string][]models.Mention, error)
+
+	Search(ctx context.Context, query string, searchType models.SearchType, first int, after *string) (*models.SearchConnection, error)
+
+	ListNotifications(ctx context.Context, first int, after *string, unreadOnly bool) (*models.NotificationConnection, error)
+	MarkNotificationsRead(ctx context.Context, ids []string) (int, error)
+	MarkAllNotificationsRead(ctx context.Context) (int, error)
+	SubscribeNotifications(ctx context.Context) (<-chan *models.Notification, error)
+
+	// потребители событий outbox
+	PublishCommentEvent(ctx context.Context, event *models.OutboxEvent) error
+	NotifyEvent(ctx context.Context, event *models.OutboxEvent) error
+
+	CreateWebhook(ctx context.Context, url string, events []models.WebhookEvent, secret string) (*models.Webhook, error)
+	ListWebhooks(ctx context.Context) ([]*models.Webhook, error)
+	SetWebhookActive(ctx context.Context, id string, active bool) (*models.Webhook, error)
+	DeleteWebhook(ctx context.Context, id string) error
+	ListWebhookDeliveries(ctx context.Context, webhookID string, status models.WebhookDeliveryStatus, offset, limit int) ([]*models.WebhookDelivery, error)
+	RetryWebhookDelivery(ctx context.Context, id string) (*models.WebhookDelivery, error)
+
+	Close()
 }
 
 type service struct {
-	repository repository.Storage
-	cfg        *config.AppConfig
+	repository    repository.Storage
+	cfg           *config.AppConfig
+	cursors       *cursor.Codec
+	commentsAdded *pubsub.Broker[*models.Comment]
+	// топик - id получателя
+	notifications *pubsub.Broker[*models.Notification]
+	tokens        *auth.TokenManager
 }
 
-func NewService(repo repository.Storage, cfg *config.AppConfig) Service {
-	return &service{repository: repo, cfg: cfg}
+// автор действий берется из контекста (auth.WithIdentity), tokens нужен только для Login
+func NewService(repo repository.Storage, cfg *config.AppConfig, tokens *auth.TokenManager) Service {
+	return &service{
+		repository:    repo,
+		cfg:           cfg,
+		tokens:        tokens,
+		cursors:       cursor.NewCodec(cfg.CursorSecret),
+		commentsAdded: pubsub.NewBroker[*models.Comment](),
+		notifications: pubsub.NewBroker[*models.Notification](),
+	}
 }
 
-func (s *service) CreateUser(ctx context.Context, user *models.User) error {
+// закрывает подписки, вызывается при остановке сервера
+func (s *service) Close() {
+	s.commentsAdded.Close()
+	s.notifications.Close()
+}
+
+// проверяет имя и сохраняет пользователя, аккаунт создается только через Register, чтобы у него всегда был пароль
+func (s *service) createUser(ctx context.Context, user *models.User) error {
 	if user == nil {
 		return fmt.Errorf("%w: пользователь не может быть nil", customerrors.ErrValidation)
 	}
@@ -54,6 +125,12 @@ func (s *service) CreateUser(ctx context.Context, user *models.User) error {
 	if len(user.Username) < s.cfg.MinUsernameLen {
 		return fmt.Errorf("%w: Имя пользователя должно быть >= %d букв", customerrors.ErrValidation, s.cfg.MinUsernameLen)
 	}
+	// иначе пользователя нельзя будет упомянуть через @username
+	if !mentions.ValidName(user.Username) {
+		return fmt.Errorf("%w: имя пользователя может состоять только из букв, цифр, _, . и - и не может заканчиваться на . или -", customerrors.ErrValidation)
+	}
+	// повысить роль можно только через SetUserRole
+	user.Role = models.RoleUser
 
 	return s.repository.CreateUser(ctx, user)
 }
@@ -67,11 +144,148 @@ func (s *service) GetUserByID(ctx context.Context, id string) (*models.User, err
 	return s.repository.GetUserByID(ctx, trId)
 }
 
-// создает пост (и валидирует) и передает в БД
+// хранилище отдает по имени и учетные данные, наружу уходят только публичные поля
+func (s *service) GetUserByUsername(ctx context.Context, username string) (*models.User, error) {
+	trUsername := strings.TrimSpace(username)
+	if trUsername == "" {
+		return nil, fmt.Errorf("%w: имя пользователя не может быть пустым", customerrors.ErrValidation)
+	}
+
+	user, err := s.repository.GetUserByUsername(ctx, trUsername)
+	if err != nil {
+		return nil, err
+	}
+	return &models.User{ID: user.ID, Username: user.Username, Role: user.Role}, nil
+}
+
+// пакетное получение пользователей, используется загрузчиками graphql
+func (s *service) GetUsersByIDs(ctx context.Context, ids []string) ([]*models.User, error) {
+	trIDs, err := trimIDs(ids)
+	if err != nil {
+		return nil, err
+	}
+	return s.repository.GetUsersByIDs(ctx, trIDs)
+}
+
+// длиннее пароли не принимаем, чтобы хеширование не стало способом нагрузить сервер
+const maxPasswordLen = 256
+
+// создает пользователя с паролем и сразу выдает токен
+func (s *service) Register(ctx context.Context, username, password string) (*models.AuthPayload, error) {
+	if len(password) < s.cfg.MinPasswordLen || len(password) > maxPasswordLen {
+		return nil, fmt.Errorf("%w: длина пароля должна быть от %d до %d символов", customerrors.ErrValidation, s.cfg.MinPasswordLen, maxPasswordLen)
+	}
+
+	hash, err := auth.HashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+
+	user := &models.User{Username: username, PasswordHash: hash}
+	if err := s.createUser(ctx, user); err != nil {
+		return nil, err
+	}
+	return s.issueToken(user)
+}
+
+// проверяет пароль и выдает токен, после MaxLoginFailures неудач подряд вход блокируется на LoginLockoutMin минут
+func (s *service) Login(ctx context.Context, username, password string) (*models.AuthPayload, error) {
+	errBadCredentials := fmt.Errorf("%w: неверное имя пользователя или пароль", customerrors.ErrUnauthorized)
+
+	trUsername := strings.TrimSpace(username)
+	if trUsername == "" || len(password) > maxPasswordLen {
+		return nil, errBadCredentials
+	}
+
+	user, err := s.repository.GetUserByUsername(ctx, trUsername)
+	if err != nil {
+		if errors.Is(err, customerrors.ErrNotFound) {
+			// хеш все равно считается, чтобы время ответа не выдавало существование имени
+			auth.CheckPassword(password, "")
+			return nil, errBadCredentials
+		}
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	if user.LockedUntil != nil && now.Before(*user.LockedUntil) {
+		return nil, fmt.Errorf("%w: до %s", customerrors.ErrAccountLocked, user.LockedUntil.Format(time.RFC3339))
+	}
+
+	if !auth.CheckPassword(password, user.PasswordHash) {
+		// MaxLoginFailures <= 0 выключает блокировку
+		if s.cfg.MaxLoginFailures > 0 {
+			lockUntil := now.Add(time.Duration(s.cfg.LoginLockoutMin) * time.Minute)
+			if err := s.repository.RegisterLoginFailure(ctx, user.ID, s.cfg.MaxLoginFailures, lockUntil); err != nil {
+				return nil, err
+			}
+		}
+		return nil, errBadCredentials
+	}
+
+	if user.FailedLogins > 0 || user.LockedUntil != nil {
+		if err := s.repository.ResetLoginFailures(ctx, user.ID); err != nil {
+			return nil, err
+		}
+	}
+	return s.issueToken(user)
+}
+
+// меняет роль пользователя, доступно только администраторам; понижение действует сразу, повышение - со следующего входа
+func (s *service) SetUserRole(ctx context.Context, userID string, role models.Role) (*models.User, error) {
+	actor, err := auth.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := authorize(ctx, s.repository, actor, policy.ActionManageRoles, ""); err != nil {
+		return nil, err
+	}
+
+	trUserID := strings.TrimSpace(userID)
+	if trUserID == "" {
+		return nil, fmt.Errorf("%w: id пользователя обязателен", customerrors.ErrValidation)
+	}
+	if !policy.ValidRole(role) {
+		return nil, fmt.Errorf("%w: неизвестная роль %s", customerrors.ErrValidation, role)
+	}
+	// иначе можно случайно остаться без единого администратора
+	if trUserID == actor.UserID {
+		return nil, fmt.Errorf("%w: нельзя менять собственную роль", customerrors.ErrForbidden)
+	}
+
+	if err := s.repository.SetUserRole(ctx, trUserID, role); err != nil {
+		return nil, err
+	}
+	return s.repository.GetUserByID(ctx, trUserID)
+}
+
+func (s *service) issueToken(user *models.User) (*models.AuthPayload, error) {
+	// пользователь без пароля (создан через createUser) войти не может
+	if user.PasswordHash == "" {
+		return nil, fmt.Errorf("%w: у пользователя не задан пароль", customerrors.ErrUnauthorized)
+	}
+	token, expiresAt, err := s.tokens.Issue(user)
+	if err != nil {
+		return nil, err
+	}
+	// хеш и счетчики не покидают сервисный слой
+	public := &models.User{ID: user.ID, Username: user.Username, Role: user.Role}
+	return &models.AuthPayload{Token: token, ExpiresAt: expiresAt, User: public}, nil
+}
+
+// создает пост от имени пользователя из контекста (и валидирует) и передает в БД
 func (s *service) CreatePost(ctx context.Context, post *models.Post) error {
 	if post == nil {
 		return fmt.Errorf("%w: пост при создании не может быть nil", customerrors.ErrValidation)
 	}
+	actor, err := auth.Require(ctx)
+	if err != nil {
+		return err
+	}
+	if err := policy.Authorize(actor, policy.ActionCreatePost, ""); err != nil {
+		return err
+	}
+	post.AuthorID = actor.UserID
 	post.ID = strings.TrimSpace(post.ID)
 	if post.ID == "" {
 		post.ID = uuid.NewString()
@@ -87,11 +301,8 @@ func (s *service) CreatePost(ctx context.Context, post *models.Post) error {
 	if post.Content == "" {
 		return fmt.Errorf("%w: обязательно нужен контент для создания поста", customerrors.ErrValidation)
 	}
-	if post.AuthorID == "" {
-		return fmt.Errorf("%w: нужен id автора", customerrors.ErrValidation)
-	}
-
-	_, err := s.GetUserByID(ctx, post.AuthorID)
+	// токен мог пережить пользователя
+	_, err = s.GetUserByID(ctx, post.AuthorID)
 	if err != nil {
 		if errors.Is(err, customerrors.ErrNotFound) {
 			return customerrors.ErrNotFound
@@ -122,50 +333,211 @@ func (s *service) GetPostByID(ctx context.Context, id string) (*models.Post, err
 	return currPost, nil
 }
 
-func (s *service) ListPosts(ctx context.Context, offset, limit int) ([]*models.Post, error) {
+// пакетное получение постов, используется загрузчиками graphql
+func (s *service) GetPostsByIDs(ctx context.Context, ids []string) ([]*models.Post, error) {
+	trIDs, err := trimIDs(ids)
+	if err != nil {
+		return nil, err
+	}
+	return s.repository.GetPostsByIDs(ctx, trIDs)
+}
+
+func (s *service) ListPosts(ctx context.Context, filter models.PostFilter, sort models.PostSort, offset, limit int) ([]*models.Post, error) {
 	if offset < 0 || limit <= 0 || limit > s.cfg.MaxListLimit {
 		return nil, fmt.Errorf("%w: неправильные параметры пагинации", customerrors.ErrParamOutOfRange)
 	}
 
-	return s.repository.ListPosts(ctx, offset, limit)
+	switch sort {
+	case "":
+		sort = models.PostSortNewest
+	case models.PostSortNewest, models.PostSortOldest, models.PostSortMostCommented, models.PostSortRecentlyActive:
+	default:
+		return nil, fmt.Errorf("%w: неизвестная сортировка постов %s", customerrors.ErrValidation, sort)
+	}
+
+	if filter.AuthorID != nil {
+		authorID := strings.TrimSpace(*filter.AuthorID)
+		if authorID == "" {
+			return nil, fmt.Errorf("%w: id автора в фильтре не может быть пустым", customerrors.ErrValidation)
+		}
+		filter.AuthorID = &authorID
+	}
+	if filter.CreatedAfter != nil && filter.CreatedBefore != nil && !filter.CreatedAfter.Before(*filter.CreatedBefore) {
+		return nil, fmt.Errorf("%w: createdAfter должен быть раньше createdBefore", customerrors.ErrValidation)
+	}
+
+	return s.repository.ListPosts(ctx, filter, sort, offset, limit)
 }
 
-func (s *service) UpdatePost(ctx context.Context, post *models.Post, userID string) error {
-	if post == nil {
-		return fmt.Errorf("%w: пост при обновлении не может быть nil", customerrors.ErrValidation)
+// курсорная пагинация постов, берем на один пост больше чтобы узнать о следующей странице
+func (s *service) ListPostsConnection(ctx context.Context, first int, after *string) (*models.PostConnection, error) {
+	if first <= 0 || first > s.cfg.MaxListLimit {
+		return nil, fmt.Errorf("%w: неправильные параметры пагинации", customerrors.ErrParamOutOfRange)
 	}
 
-	trPostID := strings.TrimSpace(post.ID)
-	trUserID := strings.TrimSpace(userID)
+	afterKey, err := s.decodeCursor(cursor.KindPost, after)
+	if err != nil {
+		return nil, err
+	}
 
-	if trPostID == "" || trUserID == "" {
-		return fmt.Errorf("%w: id поста и пользователя обязательны", customerrors.ErrValidation)
+	posts, err := s.repository.ListPostsAfter(ctx, afterKey, first+1)
+	if err != nil {
+		return nil, err
 	}
 
-	trPostTitle := strings.TrimSpace(post.Title)
-	trPostContent := strings.TrimSpace(post.Content)
+	hasNext := len(posts) > first
+	if hasNext {
+		posts = posts[:first]
+	}
 
-	if trPostTitle == "" || trPostContent == "" {
-		return fmt.Errorf("%w: title и content обязательны", customerrors.ErrValidation)
+	conn := &models.PostConnection{Edges: make([]*models.PostEdge, len(posts))}
+	for i, post := range posts {
+		conn.Edges[i] = &models.PostEdge{
+			Cursor: s.cursors.Encode(cursor.KindPost, models.PageKey{CreatedAt: post.CreatedAt, ID: post.ID}),
+			Node:   post,
+		}
 	}
+	conn.PageInfo = pageInfo(len(conn.Edges), func(i int) string { return conn.Edges[i].Cursor }, hasNext, after != nil)
+	return conn, nil
+}
 
-	currentPost, err := s.repository.GetPostByID(ctx, post.ID)
+// частично обновляет пост, редактировать могут автор и модераторы
+func (s *service) UpdatePost(ctx context.Context, id string, patch models.PostPatch) (*models.Post, error) {
+	if patch.Title == nil && patch.Content == nil && patch.CommentsEnabled == nil {
+		return nil, fmt.Errorf("%w: нет полей для обновления", customerrors.ErrValidation)
+	}
+
+	action := policy.ActionUpdatePost
+	if patch.Title == nil && patch.Content == nil {
+		action = policy.ActionSetCommentsEnabled
+	}
+	return s.patchPost(ctx, id, patch, action)
+}
+
+// включает или выключает комментарии к посту, доступно автору и модераторам
+func (s *service) SetCommentsEnabled(ctx context.Context, postID string, enabled bool) (*models.Post, error) {
+	return s.patchPost(ctx, postID, models.PostPatch{CommentsEnabled: &enabled}, policy.ActionSetCommentsEnabled)
+}
+
+// хранилище обновляет пост целиком, поэтому незаданные поля берутся из текущей версии;
+// при изменении заголовка или текста предыдущая версия сохраняется ревизией
+func (s *service) patchPost(ctx context.Context, id string, patch models.PostPatch, action policy.Action) (*models.Post, error) {
+	actor, err := auth.Require(ctx)
 	if err != nil {
-		return fmt.Errorf("%w: пост не найден %s: %v", customerrors.ErrNotFound, post.ID, err)
+		return nil, err
 	}
 
-	if currentPost.AuthorID != userID {
-		return fmt.Errorf("%w: запрещено редактировать чужой пост", customerrors.ErrForbidden)
+	trPostID := strings.TrimSpace(id)
+	if trPostID == "" {
+		return nil, fmt.Errorf("%w: id поста обязателен", customerrors.ErrValidation)
 	}
 
-	return s.repository.UpdatePost(ctx, post)
+	// чтение и запись в одной транзакции, чтобы параллельная правка не потерялась
+	var currentPost *models.Post
+	var updated models.Post
+	err = s.repository.WithTx(ctx, func(tx repository.Storage) error {
+		var err error
+		currentPost, err = tx.GetPostByID(ctx, trPostID)
+		if err != nil {
+			return fmt.Errorf("%w: пост не найден %s: %v", customerrors.ErrNotFound, trPostID, err)
+		}
+
+		// автор или модератор
+		if err := authorize(ctx, tx, actor, action, currentPost.AuthorID); err != nil {
+			return err
+		}
+
+		updated = *currentPost
+		if patch.Title != nil {
+			updated.Title = strings.TrimSpace(*patch.Title)
+			if updated.Title == "" {
+				return fmt.Errorf("%w: title не может быть пустым", customerrors.ErrValidation)
+			}
+		}
+		if patch.Content != nil {
+			updated.Content = strings.TrimSpace(*patch.Content)
+			if updated.Content == "" {
+				return fmt.Errorf("%w: content не может быть пустым", customerrors.ErrValidation)
+			}
+		}
+		if patch.CommentsEnabled != nil {
+			updated.CommentsEnabled = *patch.CommentsEnabled
+		}
+
+		var revision *models.PostRevision
+		if updated.Title != currentPost.Title || updated.Content != currentPost.Content {
+			now := time.Now().UTC()
+			updated.EditedAt = &now
+			revision = &models.PostRevision{
+				ID:        uuid.NewString(),
+				PostID:    currentPost.ID,
+				Title:     currentPost.Title,
+				Content:   currentPost.Content,
+				EditorID:  actor.UserID,
+				CreatedAt: now,
+			}
+		}
+		return tx.UpdatePost(ctx, &updated, revision)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &updated, nil
 }
 
-// создает комментарий и валидирует его
+// история правок поста от старых версий к новым
+func (s *service) ListPostRevisions(ctx context.Context, postID string) ([]*models.PostRevision, error) {
+	trPostID := strings.TrimSpace(postID)
+	if trPostID == "" {
+		return nil, fmt.Errorf("%w: id поста обязателен", customerrors.ErrValidation)
+	}
+	return s.repository.ListPostRevisions(ctx, trPostID)
+}
+
+// мягкое удаление доступно автору и модераторам, hard удаляет пост вместе с комментариями и доступно только администраторам
+func (s *service) DeletePost(ctx context.Context, id string, hard bool) error {
+	actor, err := auth.Require(ctx)
+	if err != nil {
+		return err
+	}
+
+	trID := strings.TrimSpace(id)
+	if trID == "" {
+		return fmt.Errorf("%w: id поста обязателен", customerrors.ErrValidation)
+	}
+
+	if hard {
+		if err := authorize(ctx, s.repository, actor, policy.ActionPurge, ""); err != nil {
+			return err
+		}
+		return s.repository.PurgePost(ctx, trID)
+	}
+
+	return s.repository.WithTx(ctx, func(tx repository.Storage) error {
+		post, err := tx.GetPostByID(ctx, trID)
+		if err != nil {
+			return err
+		}
+		if err := authorize(ctx, tx, actor, policy.ActionDeletePost, post.AuthorID); err != nil {
+			return err
+		}
+		return tx.SoftDeletePost(ctx, trID, time.Now().UTC())
+	})
+}
+
+// создает комментарий от имени пользователя из контекста и валидирует его
 func (s *service) CreateComment(ctx context.Context, comment *models.Comment) error {
 	if comment == nil {
 		return fmt.Errorf("%w: комментарий при создании не может быть nil", customerrors.ErrValidation)
 	}
+	actor, err := auth.Require(ctx)
+	if err != nil {
+		return err
+	}
+	if err := policy.Authorize(actor, policy.ActionCreateComment, ""); err != nil {
+		return err
+	}
+	comment.AuthorID = actor.UserID
 	if comment.ID == "" {
 		comment.ID = uuid.NewString()
 	}
@@ -188,42 +560,193 @@ func (s *service) CreateComment(ctx context.Context, comment *models.Comment) er
 		if trParentID == "" {
 			return fmt.Errorf("%w: parentID пустой", customerrors.ErrValidation)
 		}
-		parentComment, err := s.repository.GetCommentByID(ctx, trParentID)
+		comment.ParentID = &trParentID
+	}
+	if comment.CreatedAt.IsZero() {
+		comment.CreatedAt = time.Now().UTC()
+	}
+
+	// проверки родителя и поста и вставка в одной транзакции: между ними нельзя удалить родителя или выключить комментарии
+	return s.repository.WithTx(ctx, func(tx repository.Storage) error {
+		if comment.ParentID != nil {
+			parentComment, err := tx.GetCommentByID(ctx, *comment.ParentID)
+			if err != nil {
+				return fmt.Errorf("%w: parent comment %s: %v", customerrors.ErrNotFound, *comment.ParentID, err)
+			}
+			if parentComment.PostID != comment.PostID {
+				return fmt.Errorf("%w: parent comment %s принадлежит другому посту", customerrors.ErrValidation, *comment.ParentID)
+			}
+			if parentComment.DeletedAt != nil {
+				return fmt.Errorf("%w: нельзя ответить на удаленный комментарий %s", customerrors.ErrValidation, *comment.ParentID)
+			}
+		}
+
+		post, err := tx.GetPostByID(ctx, comment.PostID)
 		if err != nil {
-			return fmt.Errorf("%w: parent comment %s: %v", customerrors.ErrNotFound, trParentID, err)
+			return fmt.Errorf("%w: пост не найден %s: %v", customerrors.ErrNotFound, comment.PostID, err)
 		}
-		if parentComment.PostID != comment.PostID {
-			return fmt.Errorf("%w: parent comment %s принадлежит другому посту", customerrors.ErrValidation, trParentID)
+		if !post.CommentsEnabled {
+			return fmt.Errorf("%w: комментарии к посту запрещены ", customerrors.ErrCommForbidden)
 		}
-		comment.ParentID = &trParentID
+		return tx.CreateComment(ctx, comment)
+	})
+}
+
+func (s *service) GetCommentByID(ctx context.Context, id string) (*models.Comment, error) {
+	trId := strings.TrimSpace(id)
+
+	if trId == "" {
+		return nil, fmt.Errorf("%w: Id для получения не может быть пустым", customerrors.ErrValidation)
 	}
 
-	post, err := s.repository.GetPostByID(ctx, comment.PostID)
+	comment, err := s.repository.GetCommentByID(ctx, id)
 	if err != nil {
-		return fmt.Errorf("%w: пост не найден %s: %v", customerrors.ErrNotFound, comment.PostID, err)
+		return nil, err
 	}
-	if !post.CommentsEnabled {
-		return fmt.Errorf("%w: комментарии к посту запрещены ", customerrors.ErrCommForbidden)
+	return maskDeleted(comment), nil
+}
+
+// меняет текст комментария: автору в течение CommentEditWindowMin после создания, модератору в любое время
+func (s *service) UpdateComment(ctx context.Context, id, text string) (*models.Comment, error) {
+	actor, err := auth.Require(ctx)
+	if err != nil {
+		return nil, err
 	}
 
-	if comment.CreatedAt.IsZero() {
-		comment.CreatedAt = time.Now().UTC()
+	trID := strings.TrimSpace(id)
+	if trID == "" {
+		return nil, fmt.Errorf("%w: id комментария обязателен", customerrors.ErrValidation)
+	}
+	if strings.TrimSpace(text) == "" {
+		return nil, fmt.Errorf("%w: текст комментария не может быть пустым", customerrors.ErrValidation)
 	}
+	if len(text) > MAX_COMMENT_LENGTH {
+		return nil, fmt.Errorf("%w: длина комментария больше %d символов", customerrors.ErrParamOutOfRange, MAX_COMMENT_LENGTH)
+	}
+
+	// чтение и запись в одной транзакции: комментарий не удалят и не изменят между проверками и правкой
+	var current *models.Comment
+	var updated models.Comment
+	err = s.repository.WithTx(ctx, func(tx repository.Storage) error {
+		var err error
+		current, err = tx.GetCommentByID(ctx, trID)
+		if err != nil {
+			return err
+		}
+		if current.DeletedAt != nil {
+			return fmt.Errorf("%w: комментарий с id %s удален", customerrors.ErrNotFound, trID)
+		}
+		if err := authorize(ctx, tx, actor, policy.ActionUpdateComment, current.AuthorID); err != nil {
+			return err
+		}
 
-	return s.repository.CreateComment(ctx, comment)
+		// окно правки ограничивает автора, модерация возможна в любое время
+		now := time.Now().UTC()
+		if window := time.Duration(s.cfg.CommentEditWindowMin) * time.Minute; window > 0 && now.Sub(current.CreatedAt) > window {
+			moderator, err := hasRole(ctx, tx, actor, models.RoleModerator)
+			if err != nil {
+				return err
+			}
+			if !moderator {
+				return fmt.Errorf("%w: комментарий можно редактировать только %d минут после создания", customerrors.ErrForbidden, s.cfg.CommentEditWindowMin)
+			}
+		}
+		updated = *current
+		if text == current.Text {
+			return nil
+		}
+
+		updated.Text = text
+		updated.EditedAt = &now
+		revision := &models.CommentRevision{
+			ID:        uuid.NewString(),
+			CommentID: current.ID,
+			Text:      current.Text,
+			EditorID:  actor.UserID,
+			CreatedAt: now,
+		}
+		return tx.UpdateComment(ctx, &updated, revision)
+	})
+	if err != nil {
+		return nil, err
+	}
+	// текст не изменился, правка не записывалась
+	if text == current.Text {
+		return current, nil
+	}
+	return &updated, nil
 }
 
-func (s *service) GetCommentByID(ctx context.Context, id string) (*models.Comment, error) {
-	trId := strings.TrimSpace(id)
+// история правок комментария, у удаленного комментария история скрыта вместе с текстом
+func (s *service) ListCommentRevisions(ctx context.Context, commentID string) ([]*models.CommentRevision, error) {
+	trID := strings.TrimSpace(commentID)
+	if trID == "" {
+		return nil, fmt.Errorf("%w: id комментария обязателен", customerrors.ErrValidation)
+	}
 
-	if trId == "" {
-		return nil, fmt.Errorf("%w: Id для получения не может быть пустым", customerrors.ErrValidation)
+	comment, err := s.repository.GetCommentByID(ctx, trID)
+	if err != nil {
+		return nil, err
+	}
+	if comment.DeletedAt != nil {
+		return []*models.CommentRevision{}, nil
+	}
+	return s.repository.ListCommentRevisions(ctx, trID)
+}
+
+// мягкое удаление комментария: ответы остаются, а сам комментарий отдается заглушкой;
+// hard удаляет комментарий вместе со всеми ответами и доступно только администраторам
+func (s *service) DeleteComment(ctx context.Context, id string, hard bool) error {
+	actor, err := auth.Require(ctx)
+	if err != nil {
+		return err
+	}
+
+	trID := strings.TrimSpace(id)
+	if trID == "" {
+		return fmt.Errorf("%w: id комментария обязателен", customerrors.ErrValidation)
+	}
+
+	if hard {
+		if err := authorize(ctx, s.repository, actor, policy.ActionPurge, ""); err != nil {
+			return err
+		}
+		return s.repository.PurgeComment(ctx, trID)
 	}
 
-	return s.repository.GetCommentByID(ctx, id)
+	return s.repository.WithTx(ctx, func(tx repository.Storage) error {
+		comment, err := tx.GetCommentByID(ctx, trID)
+		if err != nil {
+			return err
+		}
+		if comment.DeletedAt != nil {
+			return fmt.Errorf("%w: комментарий с id %s уже удален", customerrors.ErrNotFound, trID)
+		}
+		if err := authorize(ctx, tx, actor, policy.ActionDeleteComment, comment.AuthorID); err != nil {
+			return err
+		}
+		return tx.SoftDeleteComment(ctx, trID, time.Now().UTC())
+	})
+}
+
+// хранилище отдает удаленные комментарии как есть, текст скрывается здесь, на всех путях чтения
+func maskDeleted(comment *models.Comment) *models.Comment {
+	if comment == nil || comment.DeletedAt == nil {
+		return comment
+	}
+	masked := *comment
+	masked.Text = DeletedCommentText
+	return &masked
 }
 
-func (s *service) ListCommentsByPost(ctx context.Context, postID string, parentID *string, offset, limit int) ([]*models.Comment, error) {
+func maskDeletedList(comments []*models.Comment) []*models.Comment {
+	for i, comment := range comments {
+		comments[i] = maskDeleted(comment)
+	}
+	return comments
+}
+
+func (s *service) ListCommentsByPost(ctx context.Context, postID string, parentID *string, sort models.CommentSort, offset, limit int) ([]*models.Comment, error) {
 	postID = strings.TrimSpace(postID)
 	if postID == "" {
 		return nil, fmt.Errorf("%w: Id для получения не может быть пустым", customerrors.ErrValidation)
@@ -233,27 +756,220 @@ func (s *service) ListCommentsByPost(ctx context.Context, postID string, parentI
 		return nil, fmt.Errorf("%w: неправильные параметры пагинации", customerrors.ErrParamOutOfRange)
 	}
 
+	sort, err := commentSort(sort)
+	if err != nil {
+		return nil, err
+	}
+
+	parentID, err = s.checkCommentsScope(ctx, postID, parentID)
+	if err != nil {
+		return nil, err
+	}
+
+	comments, err := s.repository.ListCommentsByPost(ctx, postID, parentID, sort, offset, limit)
+	if err != nil {
+		return nil, err
+	}
+	return maskDeletedList(comments), nil
+}
+
+// комментарии пользователя для профиля, от новых к старым
+func (s *service) ListCommentsByAuthor(ctx context.Context, authorID string, offset, limit int) ([]*models.Comment, error) {
+	authorID = strings.TrimSpace(authorID)
+	if authorID == "" {
+		return nil, fmt.Errorf("%w: Id автора не может быть пустым", customerrors.ErrValidation)
+	}
+
+	if offset < 0 || limit <= 0 || limit > s.cfg.MaxListLimit {
+		return nil, fmt.Errorf("%w: неправильные параметры пагинации", customerrors.ErrParamOutOfRange)
+	}
+
+	return s.repository.ListCommentsByAuthor(ctx, authorID, offset, limit)
+}
+
+// курсорная пагинация комментариев одного уровня
+func (s *service) ListCommentsConnection(ctx context.Context, postID string, parentID *string, first int, after *string) (*models.CommentConnection, error) {
+	postID = strings.TrimSpace(postID)
+	if postID == "" {
+		return nil, fmt.Errorf("%w: Id для получения не может быть пустым", customerrors.ErrValidation)
+	}
+
+	if first <= 0 || first > s.cfg.MaxListLimit {
+		return nil, fmt.Errorf("%w: неправильные параметры пагинации", customerrors.ErrParamOutOfRange)
+	}
+
+	afterKey, err := s.decodeCursor(cursor.KindComment, after)
+	if err != nil {
+		return nil, err
+	}
+
+	parentID, err = s.checkCommentsScope(ctx, postID, parentID)
+	if err != nil {
+		return nil, err
+	}
+
+	comments, err := s.repository.ListCommentsAfter(ctx, postID, parentID, afterKey, first+1)
+	if err != nil {
+		return nil, err
+	}
+	comments = maskDeletedList(comments)
+
+	hasNext := len(comments) > first
+	if hasNext {
+		comments = comments[:first]
+	}
+
+	conn := &models.CommentConnection{Edges: make([]*models.CommentEdge, len(comments))}
+	for i, comment := range comments {
+		conn.Edges[i] = &models.CommentEdge{
+			Cursor: s.cursors.Encode(cursor.KindComment, models.PageKey{CreatedAt: comment.CreatedAt, ID: comment.ID}),
+			Node:   comment,
+		}
+	}
+	conn.PageInfo = pageInfo(len(conn.Edges), func(i int) string { return conn.Edges[i].Cursor }, hasNext, after != nil)
+	return conn, nil
+}
+
+// возвращает дерево комментариев плоским списком, размер ограничен конфигом
+func (s *service) GetCommentThread(ctx context.Context, postID string, rootID *string, maxDepth, maxNodes int) (*models.CommentThread, error) {
+	postID = strings.TrimSpace(postID)
+	if postID == "" {
+		return nil, fmt.Errorf("%w: Id для получения не может быть пустым", customerrors.ErrValidation)
+	}
+
+	if maxDepth < 0 || maxDepth > s.cfg.MaxThreadDepth {
+		return nil, fmt.Errorf("%w: глубина дерева должна быть от 0 до %d", customerrors.ErrParamOutOfRange, s.cfg.MaxThreadDepth)
+	}
+	if maxNodes <= 0 || maxNodes > s.cfg.MaxThreadNodes {
+		return nil, fmt.Errorf("%w: количество комментариев должно быть от 1 до %d", customerrors.ErrParamOutOfRange, s.cfg.MaxThreadNodes)
+	}
+
+	rootID, err := s.checkCommentsScope(ctx, postID, rootID)
+	if err != nil {
+		return nil, err
+	}
+
+	// лишний узел показывает, что дерево обрезано
+	items, err := s.repository.GetCommentThread(ctx, postID, rootID, maxDepth, maxNodes+1)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, item := range items {
+		item.Comment = maskDeleted(item.Comment)
+	}
+
+	thread := &models.CommentThread{Items: items}
+	if len(items) > maxNodes {
+		thread.Items = items[:maxNodes]
+		thread.Truncated = true
+	}
+	return thread, nil
+}
+
+// проверяет что пост существует, а parentID (если есть) относится к этому посту
+func (s *service) checkCommentsScope(ctx context.Context, postID string, parentID *string) (*string, error) {
 	// проверка на существование поста
 	if _, err := s.repository.GetPostByID(ctx, postID); err != nil {
 		return nil, fmt.Errorf("%w: пост %s: %v", customerrors.ErrNotFound, postID, err)
 	}
 
 	// проверка parentID
-	if parentID != nil {
-		trParentID := strings.TrimSpace(*parentID)
-		if trParentID == "" {
-			return nil, fmt.Errorf("%w: parentID пустой", customerrors.ErrValidation)
-		}
-		parentComment, err := s.repository.GetCommentByID(ctx, trParentID)
-		if err != nil {
-			return nil, fmt.Errorf("%w: parent comment %s: %v", customerrors.ErrNotFound, trParentID, err)
-		}
-		if parentComment.PostID != postID {
-			return nil, fmt.Errorf("%w: parent comment %s не соответствует посту", customerrors.ErrValidation, trParentID)
-		}
-		p := trParentID
-		parentID = &p
+	if parentID == nil {
+		return nil, nil
+	}
+	trParentID := strings.TrimSpace(*parentID)
+	if trParentID == "" {
+		return nil, fmt.Errorf("%w: parentID пустой", customerrors.ErrValidation)
 	}
+	parentComment, err := s.repository.GetCommentByID(ctx, trParentID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: parent comment %s: %v", customerrors.ErrNotFound, trParentID, err)
+	}
+	if parentComment.PostID != postID {
+		return nil, fmt.Errorf("%w: parent comment %s не соответствует посту", customerrors.ErrValidation, trParentID)
+	}
+	return &trParentID, nil
+}
 
-	return s.repository.ListCommentsByPost(ctx, postID, parentID, offset, limit)
+func (s *service) decodeCursor(kind string, after *string) (*models.PageKey, error) {
+	if after == nil {
+		return nil, nil
+	}
+	return s.cursors.Decode(kind, *after)
+}
+
+func pageInfo(n int, cursorAt func(i int) string, hasNext, hasPrev bool) models.PageInfo {
+	info := models.PageInfo{HasNextPage: hasNext, HasPreviousPage: hasPrev}
+	if n > 0 {
+		start, end := cursorAt(0), cursorAt(n-1)
+		info.StartCursor = &start
+		info.EndCursor = &end
+	}
+	return info
+}
+
+// ответы для нескольких комментариев сразу, пагинация проверяется как в ListCommentsByPost
+func (s *service) ListRepliesByParentIDs(ctx context.Context, parentIDs []string, sort models.CommentSort, offset, limit int) (map[string][]*models.Comment, error) {
+	if offset < 0 || limit <= 0 || limit > s.cfg.MaxListLimit {
+		return nil, fmt.Errorf("%w: неправильные параметры пагинации", customerrors.ErrParamOutOfRange)
+	}
+
+	sort, err := commentSort(sort)
+	if err != nil {
+		return nil, err
+	}
+	trIDs, err := trimIDs(parentIDs)
+	if err != nil {
+		return nil, err
+	}
+	replies, err := s.repository.ListRepliesByParentIDs(ctx, trIDs, sort, offset, limit)
+	if err != nil {
+		return nil, err
+	}
+	for parentID, comments := range replies {
+		replies[parentID] = maskDeletedList(comments)
+	}
+	return replies, nil
+}
+
+// подписка на новые комментарии поста, канал закрывается при отмене ctx
+func (s *service) SubscribeCommentAdded(ctx context.Context, postID string) (<-chan *models.Comment, error) {
+	postID = strings.TrimSpace(postID)
+	if postID == "" {
+		return nil, fmt.Errorf("%w: Id поста для подписки не может быть пустым", customerrors.ErrValidation)
+	}
+
+	if _, err := s.repository.GetPostByID(ctx, postID); err != nil {
+		return nil, fmt.Errorf("%w: пост %s: %v", customerrors.ErrNotFound, postID, err)
+	}
+
+	return s.commentsAdded.Subscribe(ctx, postID)
+}
+
+// пустая сортировка означает порядок по времени создания
+func commentSort(sort models.CommentSort) (models.CommentSort, error) {
+	switch sort {
+	case "":
+		return models.CommentSortOld, nil
+	case models.CommentSortOld, models.CommentSortNew, models.CommentSortTop, models.CommentSortControversial:
+		return sort, nil
+	}
+	return "", fmt.Errorf("%w: неизвестная сортировка комментариев %s", customerrors.ErrValidation, sort)
+}
+
+// обрезает пробелы у списка id, пустые id считаются ошибкой
+func trimIDs(ids []string) ([]string, error) {
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("%w: список id не может быть пустым", customerrors.ErrValidation)
+	}
+
+	res := make([]string, len(ids))
+	for i, id := range ids {
+		res[i] = strings.TrimSpace(id)
+		if res[i] == "" {
+			return nil, fmt.Errorf("%w: Id для получения не может быть пустым", customerrors.ErrValidation)
+		}
+	}
+	return res, nil
 }
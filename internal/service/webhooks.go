@@ -0,0 +1,170 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/MAPiryazev/OzonTest/internal/auth"
+	"github.com/MAPiryazev/OzonTest/internal/customerrors"
+	"github.com/MAPiryazev/OzonTest/internal/models"
+	"github.com/MAPiryazev/OzonTest/internal/policy"
+)
+
+// минимальная длина ключа подписи, заданного вручную
+const minWebhookSecretLen = 16
+
+var webhookEvents = []models.WebhookEvent{
+	models.WebhookPostCreated,
+	models.WebhookPostUpdated,
+	models.WebhookCommentCreated,
+	models.WebhookCommentUpdated,
+}
+
+// все операции с вебхуками доступны только администратору
+func (s *service) authorizeWebhooks(ctx context.Context) (*auth.Identity, error) {
+	actor, err := auth.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := authorize(ctx, s.repository, actor, policy.ActionManageWebhooks, ""); err != nil {
+		return nil, err
+	}
+	return actor, nil
+}
+
+// пустой secret генерируется, ключ возвращается в Webhook.Secret и дальше наружу не отдается
+func (s *service) CreateWebhook(ctx context.Context, rawURL string, events []models.WebhookEvent, secret string) (*models.Webhook, error) {
+	actor, err := s.authorizeWebhooks(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	trURL := strings.TrimSpace(rawURL)
+	parsed, err := url.Parse(trURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return nil, fmt.Errorf("%w: url вебхука должен быть абсолютным http или https адресом", customerrors.ErrValidation)
+	}
+
+	if len(events) == 0 {
+		return nil, fmt.Errorf("%w: вебхук должен быть подписан хотя бы на одно событие", customerrors.ErrValidation)
+	}
+	unique := make([]models.WebhookEvent, 0, len(events))
+	for _, event := range events {
+		if !slices.Contains(webhookEvents, event) {
+			return nil, fmt.Errorf("%w: неизвестное событие %s", customerrors.ErrValidation, event)
+		}
+		if !slices.Contains(unique, event) {
+			unique = append(unique, event)
+		}
+	}
+
+	secret = strings.TrimSpace(secret)
+	if secret == "" {
+		key := make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, fmt.Errorf("не удалось сгенерировать ключ вебхука: %w", err)
+		}
+		secret = hex.EncodeToString(key)
+	}
+	if len(secret) < minWebhookSecretLen {
+		return nil, fmt.Errorf("%w: ключ вебхука должен быть не короче %d символов", customerrors.ErrValidation, minWebhookSecretLen)
+	}
+
+	webhook := &models.Webhook{
+		ID:        uuid.NewString(),
+		URL:       trURL,
+		Secret:    secret,
+		Events:    unique,
+		Active:    true,
+		CreatedBy: actor.UserID,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := s.repository.CreateWebhook(ctx, webhook); err != nil {
+		return nil, err
+	}
+	return webhook, nil
+}
+
+func (s *service) ListWebhooks(ctx context.Context) ([]*models.Webhook, error) {
+	if _, err := s.authorizeWebhooks(ctx); err != nil {
+		return nil, err
+	}
+	return s.repository.ListWebhooks(ctx)
+}
+
+// отключенный вебхук не получает новых событий, а накопленные доставки ждут повторного включения
+func (s *service) SetWebhookActive(ctx context.Context, id string, active bool) (*models.Webhook, error) {
+	if _, err := s.authorizeWebhooks(ctx); err != nil {
+		return nil, err
+	}
+	trID := strings.TrimSpace(id)
+	if trID == "" {
+		return nil, fmt.Errorf("%w: id вебхука обязателен", customerrors.ErrValidation)
+	}
+
+	if err := s.repository.SetWebhookActive(ctx, trID, active); err != nil {
+		return nil, err
+	}
+	return s.repository.GetWebhookByID(ctx, trID)
+}
+
+func (s *service) DeleteWebhook(ctx context.Context, id string) error {
+	if _, err := s.authorizeWebhooks(ctx); err != nil {
+		return err
+	}
+	trID := strings.TrimSpace(id)
+	if trID == "" {
+		return fmt.Errorf("%w: id вебхука обязателен", customerrors.ErrValidation)
+	}
+	return s.repository.DeleteWebhook(ctx, trID)
+}
+
+// история доставок вебхука от новых к старым, пустой status - все
+func (s *service) ListWebhookDeliveries(ctx context.Context, webhookID string, status models.WebhookDeliveryStatus, offset, limit int) ([]*models.WebhookDelivery, error) {
+	if _, err := s.authorizeWebhooks(ctx); err != nil {
+		return nil, err
+	}
+	trID := strings.TrimSpace(webhookID)
+	if trID == "" {
+		return nil, fmt.Errorf("%w: id вебхука обязателен", customerrors.ErrValidation)
+	}
+	if offset < 0 || limit <= 0 || limit > s.cfg.MaxListLimit {
+		return nil, fmt.Errorf("%w: неправильные параметры пагинации", customerrors.ErrParamOutOfRange)
+	}
+	return s.repository.ListWebhookDeliveries(ctx, trID, status, offset, limit)
+}
+
+// возвращает доставку из dead в очередь с обнуленным счетчиком попыток
+func (s *service) RetryWebhookDelivery(ctx context.Context, id string) (*models.WebhookDelivery, error) {
+	if _, err := s.authorizeWebhooks(ctx); err != nil {
+		return nil, err
+	}
+	trID := strings.TrimSpace(id)
+	if trID == "" {
+		return nil, fmt.Errorf("%w: id доставки обязателен", customerrors.ErrValidation)
+	}
+
+	delivery, err := s.repository.GetWebhookDeliveryByID(ctx, trID)
+	if err != nil {
+		return nil, err
+	}
+	if delivery.Status != models.WebhookDeliveryDead {
+		return nil, fmt.Errorf("%w: повторить можно только доставку в статусе dead", customerrors.ErrValidation)
+	}
+
+	delivery.Status = models.WebhookDeliveryPending
+	delivery.Attempts = 0
+	delivery.NextAttemptAt = time.Now().UTC()
+	if err := s.repository.UpdateWebhookDelivery(ctx, delivery); err != nil {
+		return nil, err
+	}
+	return delivery, nil
+}
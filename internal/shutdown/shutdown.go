@@ -6,17 +6,29 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/MAPiryazev/OzonTest/internal/outbox"
 	"github.com/MAPiryazev/OzonTest/internal/repository"
+	"github.com/MAPiryazev/OzonTest/internal/service"
+	"github.com/MAPiryazev/OzonTest/internal/webhooks"
 )
 
-func Shutdown(server *http.Server, storage repository.Storage) {
+func Shutdown(server *http.Server, storage repository.Storage, svc service.Service, relay *outbox.Relay, webhookWorker *webhooks.Worker) {
 	log.Println("Graceful shutdown")
 	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
+	// сначала закрываем подписки, чтобы websocket соединения завершились
+	svc.Close()
+
 	if err := server.Shutdown(shutdownCtx); err != nil {
 		log.Fatalf("Ошибка при завершении сервера: %v", err)
 	}
-	storage.Close()
+	// ретранслятор ставит доставки в очередь воркера, а воркер дописывает их результаты в хранилище,
+	// поэтому оба останавливаются до его закрытия
+	relay.Stop()
+	webhookWorker.Stop()
+	if err := storage.Close(); err != nil {
+		log.Printf("Ошибка при закрытии хранилища: %v", err)
+	}
 
 }
@@ -0,0 +1,174 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/MAPiryazev/OzonTest/internal/auth"
+	"github.com/MAPiryazev/OzonTest/internal/config"
+	"github.com/MAPiryazev/OzonTest/internal/customerrors"
+	"github.com/MAPiryazev/OzonTest/internal/models"
+	"github.com/MAPiryazev/OzonTest/internal/repository/mocks"
+	"github.com/MAPiryazev/OzonTest/internal/service"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/golang/mock/gomock"
+)
+
+func newTokenManager(t *testing.T, secret string, ttlMin int) *auth.TokenManager {
+	t.Helper()
+	tokens, err := auth.NewTokenManager(&config.AuthConfig{Algorithm: config.JWTAlgHS256, Secret: secret, Issuer: "test", TokenTTLMin: ttlMin})
+	if err != nil {
+		t.Fatalf("не удалось создать менеджер токенов: %v", err)
+	}
+	return tokens
+}
+
+func TestAuth_IssueVerify(t *testing.T) {
+	tokens := newTokenManager(t, "secret", 5)
+
+	token, _, err := tokens.Issue(&models.User{ID: "u1", Username: "vasya"})
+	if err != nil {
+		t.Fatalf("не удалось выпустить токен: %v", err)
+	}
+	id, err := tokens.Verify(token)
+	if err != nil || id.UserID != "u1" || id.Username != "vasya" {
+		t.Fatalf("неожиданный результат проверки: %v, %+v", err, id)
+	}
+
+	// токен, подписанный другим ключом
+	if _, err := newTokenManager(t, "other", 5).Verify(token); !errors.Is(err, customerrors.ErrInvalidToken) {
+		t.Fatalf("ожидалась ошибка подписи, получено %v", err)
+	}
+
+	// просроченный токен
+	expired, _, _ := newTokenManager(t, "secret", -1).Issue(&models.User{ID: "u1", Username: "vasya"})
+	if _, err := tokens.Verify(expired); !errors.Is(err, customerrors.ErrInvalidToken) {
+		t.Fatalf("ожидалась ошибка срока действия, получено %v", err)
+	}
+
+	// токен входа по одному имени, без amr, подписан верным ключом, но не принимается
+	legacy, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub": "u1", "name": "vasya", "iss": "test", "exp": time.Now().Add(time.Hour).Unix(),
+	}).SignedString([]byte("secret"))
+	if err != nil {
+		t.Fatalf("не удалось подписать токен: %v", err)
+	}
+	if _, err := tokens.Verify(legacy); !errors.Is(err, customerrors.ErrInvalidToken) {
+		t.Fatalf("токен без проверки пароля должен отклоняться, получено %v", err)
+	}
+}
+
+func TestAuth_Middleware(t *testing.T) {
+	tokens := newTokenManager(t, "secret", 5)
+	token, _, _ := tokens.Issue(&models.User{ID: "u1", Username: "vasya"})
+
+	var seen *auth.Identity
+	h := auth.Middleware(tokens)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen, _ = auth.FromContext(r.Context())
+	}))
+
+	cases := []struct {
+		name   string
+		header string
+		code   int
+		userID string
+	}{
+		{"аноним", "", http.StatusOK, ""},
+		{"валидный токен", "Bearer " + token, http.StatusOK, "u1"},
+		{"мусор", "Bearer abc", http.StatusUnauthorized, ""},
+		{"не bearer", "Basic abc", http.StatusUnauthorized, ""},
+	}
+	for _, tc := range cases {
+		seen = nil
+		req := httptest.NewRequest(http.MethodPost, "/query", nil)
+		if tc.header != "" {
+			req.Header.Set("Authorization", tc.header)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+
+		if rec.Code != tc.code {
+			t.Fatalf("%s: ожидался код %d, получен %d", tc.name, tc.code, rec.Code)
+		}
+		if tc.userID != "" && (seen == nil || seen.UserID != tc.userID) {
+			t.Fatalf("%s: в контексте нет пользователя %s", tc.name, tc.userID)
+		}
+		if tc.userID == "" && seen != nil {
+			t.Fatalf("%s: в контексте не должно быть пользователя", tc.name)
+		}
+	}
+}
+
+func TestAuth_Password(t *testing.T) {
+	hash, err := auth.HashPassword("correct horse")
+	if err != nil {
+		t.Fatalf("не удалось захешировать пароль: %v", err)
+	}
+	if !auth.CheckPassword("correct horse", hash) {
+		t.Fatal("верный пароль не прошел проверку")
+	}
+	if auth.CheckPassword("wrong horse", hash) {
+		t.Fatal("неверный пароль прошел проверку")
+	}
+	if auth.CheckPassword("", "") || auth.CheckPassword("x", "$argon2id$broken") {
+		t.Fatal("пустой или битый хеш не должен проходить проверку")
+	}
+}
+
+func TestService_Login(t *testing.T) {
+	controller := gomock.NewController(t)
+	defer controller.Finish()
+
+	mockForRepository := mocks.NewMockStorage(controller)
+	tokens := newTokenManager(t, "secret", 5)
+	svc := service.NewService(mockForRepository, &config.AppConfig{MaxLoginFailures: 3, LoginLockoutMin: 10}, tokens)
+	ctx := context.Background()
+
+	hash, _ := auth.HashPassword("password1")
+	user := &models.User{ID: "u1", Username: "vasya", PasswordHash: hash, FailedLogins: 1}
+	mockForRepository.EXPECT().GetUserByUsername(ctx, "vasya").Return(user, nil).Times(2)
+	mockForRepository.EXPECT().GetUserByUsername(ctx, "nobody").Return(nil, customerrors.ErrNotFound)
+	mockForRepository.EXPECT().RegisterLoginFailure(ctx, "u1", 3, gomock.Any()).Return(nil)
+	mockForRepository.EXPECT().ResetLoginFailures(ctx, "u1").Return(nil)
+
+	if _, err := svc.Login(ctx, "vasya", "wrong"); !errors.Is(err, customerrors.ErrUnauthorized) {
+		t.Fatalf("ожидалась ошибка авторизации, получено %v", err)
+	}
+
+	payload, err := svc.Login(ctx, " vasya ", "password1")
+	if err != nil {
+		t.Fatalf("не удалось войти: %v", err)
+	}
+	if id, err := tokens.Verify(payload.Token); err != nil || id.UserID != "u1" {
+		t.Fatalf("выданный токен не проходит проверку: %v", err)
+	}
+	if payload.User.PasswordHash != "" {
+		t.Fatal("хеш пароля не должен возвращаться наружу")
+	}
+
+	if _, err := svc.Login(ctx, "nobody", "password1"); !errors.Is(err, customerrors.ErrUnauthorized) {
+		t.Fatalf("ожидалась ошибка авторизации, получено %v", err)
+	}
+}
+
+func TestService_LoginLocked(t *testing.T) {
+	controller := gomock.NewController(t)
+	defer controller.Finish()
+
+	mockForRepository := mocks.NewMockStorage(controller)
+	svc := service.NewService(mockForRepository, &config.AppConfig{MaxLoginFailures: 3, LoginLockoutMin: 10}, newTokenManager(t, "secret", 5))
+	ctx := context.Background()
+
+	hash, _ := auth.HashPassword("password1")
+	until := time.Now().Add(time.Minute)
+	mockForRepository.EXPECT().GetUserByUsername(ctx, "vasya").Return(&models.User{ID: "u1", Username: "vasya", PasswordHash: hash, LockedUntil: &until}, nil)
+
+	// даже верный пароль не пускает, пока действует блокировка
+	if _, err := svc.Login(ctx, "vasya", "password1"); !errors.Is(err, customerrors.ErrAccountLocked) {
+		t.Fatalf("ожидалась блокировка, получено %v", err)
+	}
+}
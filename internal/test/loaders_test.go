@@ -0,0 +1,130 @@
+package test
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/MAPiryazev/OzonTest/internal/config"
+	"github.com/MAPiryazev/OzonTest/internal/handler"
+	"github.com/MAPiryazev/OzonTest/internal/loaders"
+	"github.com/MAPiryazev/OzonTest/internal/models"
+	"github.com/MAPiryazev/OzonTest/internal/repository/mocks"
+	"github.com/MAPiryazev/OzonTest/internal/service"
+	"github.com/golang/mock/gomock"
+)
+
+func TestLoaders_UsersBatched(t *testing.T) {
+	controller := gomock.NewController(t)
+	defer controller.Finish()
+
+	mockForRepository := mocks.NewMockStorage(controller)
+	svc := service.NewService(mockForRepository, &config.AppConfig{MaxListLimit: 10}, nil)
+	ctx := context.Background()
+	l := loaders.NewLoaders(ctx, handler.NewHandler(svc))
+
+	// несколько параллельных Load должны превратиться в один запрос к хранилищу
+	mockForRepository.EXPECT().GetUsersByIDs(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, ids []string) ([]*models.User, error) {
+			if len(ids) != 3 {
+				t.Errorf("ожидалось 3 id в пачке, получено %d", len(ids))
+			}
+			users := make([]*models.User, 0, len(ids))
+			for _, id := range ids {
+				if id != "missing" {
+					users = append(users, &models.User{ID: id, Username: "user_" + id})
+				}
+			}
+			return users, nil
+		}).Times(1)
+
+	var wg sync.WaitGroup
+	results := make(map[string]*models.User)
+	var mu sync.Mutex
+	for _, id := range []string{"u1", "u2", "missing", "u1"} {
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+			u, err := l.Users.Load(ctx, id)
+			if err != nil {
+				t.Errorf("ошибка загрузки %s: %v", id, err)
+				return
+			}
+			mu.Lock()
+			results[id] = u
+			mu.Unlock()
+		}(id)
+	}
+	wg.Wait()
+
+	if results["u1"] == nil || results["u2"] == nil {
+		t.Fatal("ожидались загруженные пользователи u1 и u2")
+	}
+	if results["missing"] != nil {
+		t.Fatal("для отсутствующего id ожидался nil")
+	}
+}
+
+func TestLoaders_RepliesGroupedByParent(t *testing.T) {
+	controller := gomock.NewController(t)
+	defer controller.Finish()
+
+	mockForRepository := mocks.NewMockStorage(controller)
+	svc := service.NewService(mockForRepository, &config.AppConfig{MaxListLimit: 10}, nil)
+	ctx := context.Background()
+	l := loaders.NewLoaders(ctx, handler.NewHandler(svc))
+
+	p1, p2 := "c1", "c2"
+	mockForRepository.EXPECT().ListRepliesByParentIDs(gomock.Any(), gomock.Any(), models.CommentSortOld, 0, 5).Return(map[string][]*models.Comment{
+		p1: {{ID: "r1", ParentID: &p1}},
+		p2: {{ID: "r2", ParentID: &p2}, {ID: "r3", ParentID: &p2}},
+	}, nil).Times(1)
+
+	var wg sync.WaitGroup
+	counts := make([]int, 2)
+	for i, parent := range []string{p1, p2} {
+		wg.Add(1)
+		go func(i int, parent string) {
+			defer wg.Done()
+			replies, err := l.Replies.Load(ctx, loaders.RepliesKey{ParentID: parent, Offset: 0, Limit: 5})
+			if err != nil {
+				t.Errorf("ошибка загрузки ответов: %v", err)
+				return
+			}
+			counts[i] = len(replies)
+		}(i, parent)
+	}
+	wg.Wait()
+
+	if counts[0] != 1 || counts[1] != 2 {
+		t.Fatalf("ожидалось 1 и 2 ответа, получено %v", counts)
+	}
+}
+
+func TestLoaders_ExtensionPerResponse(t *testing.T) {
+	controller := gomock.NewController(t)
+	defer controller.Finish()
+
+	svc := service.NewService(mocks.NewMockStorage(controller), &config.AppConfig{MaxListLimit: 10}, nil)
+	ext := loaders.NewExtension(handler.NewHandler(svc))
+	// в websocket соединении все ответы получают один и тот же контекст соединения
+	conn := context.Background()
+
+	var got []*loaders.Loaders
+	for i := 0; i < 2; i++ {
+		ext.InterceptResponse(conn, func(ctx context.Context) *graphql.Response {
+			got = append(got, loaders.For(ctx))
+			return nil
+		})
+	}
+	if got[0] == nil || got[1] == nil {
+		t.Fatal("расширение должно класть загрузчики в контекст ответа")
+	}
+	if got[0] == got[1] {
+		t.Fatal("каждый ответ должен получать свои загрузчики")
+	}
+	if loaders.For(conn) != nil {
+		t.Fatal("контекст соединения не должен получать загрузчики")
+	}
+}
@@ -0,0 +1,479 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/MAPiryazev/OzonTest/internal/config"
+	"github.com/MAPiryazev/OzonTest/internal/customerrors"
+	"github.com/MAPiryazev/OzonTest/internal/models"
+	"github.com/MAPiryazev/OzonTest/internal/repository/inmemory"
+)
+
+func TestMemoryStorage_ListPostsOrder(t *testing.T) {
+	strg := inmemory.NewMemoryStorage()
+	ctx := context.Background()
+
+	for i := 0; i < 10; i++ {
+		if err := strg.CreatePost(ctx, &models.Post{ID: fmt.Sprintf("p%d", i), Title: "t", Content: "c"}); err != nil {
+			t.Fatalf("не удалось создать пост: %v", err)
+		}
+	}
+
+	// страницы не должны пересекаться и идти от новых к старым
+	seen := make(map[string]bool)
+	var prev *models.Post
+	for offset := 0; offset < 10; offset += 3 {
+		page, err := strg.ListPosts(ctx, models.PostFilter{}, models.PostSortNewest, offset, 3)
+		if err != nil {
+			t.Fatalf("не удалось получить посты: %v", err)
+		}
+		for _, p := range page {
+			if seen[p.ID] {
+				t.Fatalf("пост %s встретился на двух страницах", p.ID)
+			}
+			seen[p.ID] = true
+			if prev != nil && p.CreatedAt.After(prev.CreatedAt) {
+				t.Fatalf("посты не отсортированы по убыванию даты")
+			}
+			prev = p
+		}
+	}
+	if len(seen) != 10 {
+		t.Fatalf("ожидалось 10 постов, получено %d", len(seen))
+	}
+}
+
+func TestMemoryStorage_ListCommentsByParent(t *testing.T) {
+	strg := inmemory.NewMemoryStorage()
+	ctx := context.Background()
+	if err := strg.CreatePost(ctx, &models.Post{ID: "p1", Title: "t", Content: "c"}); err != nil {
+		t.Fatalf("не удалось создать пост: %v", err)
+	}
+
+	parent := "c0"
+	if err := strg.CreateComment(ctx, &models.Comment{ID: parent, PostID: "p1", Text: "корень"}); err != nil {
+		t.Fatalf("не удалось создать комментарий: %v", err)
+	}
+	for i := 1; i <= 5; i++ {
+		if err := strg.CreateComment(ctx, &models.Comment{ID: fmt.Sprintf("c%d", i), PostID: "p1", ParentID: &parent, Text: "ответ"}); err != nil {
+			t.Fatalf("не удалось создать комментарий: %v", err)
+		}
+	}
+
+	top, err := strg.ListCommentsByPost(ctx, "p1", nil, models.CommentSortOld, 0, 10)
+	if err != nil {
+		t.Fatalf("не удалось получить комментарии: %v", err)
+	}
+	if len(top) != 1 || top[0].ID != parent {
+		t.Fatalf("ожидался один комментарий верхнего уровня, получено %d", len(top))
+	}
+
+	replies, err := strg.ListCommentsByPost(ctx, "p1", &parent, models.CommentSortOld, 1, 3)
+	if err != nil {
+		t.Fatalf("не удалось получить ответы: %v", err)
+	}
+	// ответы по возрастанию даты, как в postgres
+	for i, want := range []string{"c2", "c3", "c4"} {
+		if replies[i].ID != want {
+			t.Fatalf("на позиции %d ожидался %s, получен %s", i, want, replies[i].ID)
+		}
+	}
+}
+
+func TestMemoryStorage_WALReplay(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &config.MemoryConfig{
+		WALPath:      filepath.Join(dir, "memory.wal"),
+		SnapshotPath: filepath.Join(dir, "memory.snapshot"),
+		FsyncPolicy:  config.FsyncAlways,
+	}
+	ctx := context.Background()
+
+	strg, err := inmemory.OpenMemoryStorage(cfg)
+	if err != nil {
+		t.Fatalf("не удалось открыть хранилище: %v", err)
+	}
+	if err := strg.CreateUser(ctx, &models.User{ID: "u1", Username: "vasya", PasswordHash: "hash"}); err != nil {
+		t.Fatalf("не удалось создать пользователя: %v", err)
+	}
+	if err := strg.CreatePost(ctx, &models.Post{ID: "p1", Title: "t", Content: "c", AuthorID: "u1", CommentsEnabled: true}); err != nil {
+		t.Fatalf("не удалось создать пост: %v", err)
+	}
+	// снапшот посередине: дальше данные восстанавливаются из снапшота и журнала вместе
+	if err := strg.Snapshot(); err != nil {
+		t.Fatalf("не удалось сделать снапшот: %v", err)
+	}
+	revision := &models.PostRevision{ID: "r1", PostID: "p1", Title: "t", Content: "c", EditorID: "u1", CreatedAt: time.Now()}
+	if err := strg.UpdatePost(ctx, &models.Post{ID: "p1", Title: "новый", Content: "c", CommentsEnabled: true}, revision); err != nil {
+		t.Fatalf("не удалось обновить пост: %v", err)
+	}
+	if err := strg.CreateComment(ctx, &models.Comment{ID: "c1", PostID: "p1", AuthorID: "u1", Text: "привет"}); err != nil {
+		t.Fatalf("не удалось создать комментарий: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := strg.RegisterLoginFailure(ctx, "u1", 2, time.Now().Add(time.Hour)); err != nil {
+			t.Fatalf("не удалось учесть неудачный вход: %v", err)
+		}
+	}
+
+	// Close не вызываем - как при падении процесса
+	restored, err := inmemory.OpenMemoryStorage(cfg)
+	if err != nil {
+		t.Fatalf("не удалось восстановить хранилище: %v", err)
+	}
+	defer restored.Close()
+
+	user, err := restored.GetUserByUsername(ctx, "vasya")
+	if err != nil {
+		t.Fatalf("пользователь не восстановлен: %v", err)
+	}
+	if user.PasswordHash != "hash" || user.LockedUntil == nil || user.FailedLogins != 0 {
+		t.Fatalf("учетные данные не восстановлены: %+v", user)
+	}
+	post, err := restored.GetPostByID(ctx, "p1")
+	if err != nil {
+		t.Fatalf("пост не восстановлен: %v", err)
+	}
+	if post.Title != "новый" || post.AuthorID != "u1" {
+		t.Fatalf("обновление поста не восстановлено: %+v", post)
+	}
+	revisions, err := restored.ListPostRevisions(ctx, "p1")
+	if err != nil || len(revisions) != 1 || revisions[0].Title != "t" {
+		t.Fatalf("ревизия поста не восстановлена: %v, %+v", err, revisions)
+	}
+	comments, err := restored.ListCommentsByPost(ctx, "p1", nil, models.CommentSortOld, 0, 10)
+	if err != nil || len(comments) != 1 {
+		t.Fatalf("комментарий не восстановлен: %v, %d", err, len(comments))
+	}
+}
+
+func TestMemoryStorage_DeleteReplay(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &config.MemoryConfig{
+		WALPath:      filepath.Join(dir, "memory.wal"),
+		SnapshotPath: filepath.Join(dir, "memory.snapshot"),
+		FsyncPolicy:  config.FsyncAlways,
+	}
+	ctx := context.Background()
+
+	strg, err := inmemory.OpenMemoryStorage(cfg)
+	if err != nil {
+		t.Fatalf("не удалось открыть хранилище: %v", err)
+	}
+	for _, id := range []string{"p1", "p2"} {
+		if err := strg.CreatePost(ctx, &models.Post{ID: id, Title: "t", Content: "c"}); err != nil {
+			t.Fatalf("не удалось создать пост: %v", err)
+		}
+	}
+	root := "c1"
+	for _, c := range []*models.Comment{
+		{ID: "c1", PostID: "p1", Text: "корень"},
+		{ID: "c2", PostID: "p1", ParentID: &root, Text: "ответ"},
+		{ID: "c3", PostID: "p1", Text: "второй"},
+	} {
+		if err := strg.CreateComment(ctx, c); err != nil {
+			t.Fatalf("не удалось создать комментарий: %v", err)
+		}
+	}
+	if err := strg.SoftDeletePost(ctx, "p2", time.Now()); err != nil {
+		t.Fatalf("не удалось удалить пост: %v", err)
+	}
+	// мягко удаленный пост должен пережить снапшот
+	if err := strg.Snapshot(); err != nil {
+		t.Fatalf("не удалось сделать снапшот: %v", err)
+	}
+	if err := strg.SoftDeleteComment(ctx, "c3", time.Now()); err != nil {
+		t.Fatalf("не удалось удалить комментарий: %v", err)
+	}
+	if err := strg.PurgeComment(ctx, "c1"); err != nil {
+		t.Fatalf("не удалось удалить комментарий физически: %v", err)
+	}
+
+	restored, err := inmemory.OpenMemoryStorage(cfg)
+	if err != nil {
+		t.Fatalf("не удалось восстановить хранилище: %v", err)
+	}
+	defer restored.Close()
+
+	if _, err := restored.GetPostByID(ctx, "p2"); !errors.Is(err, customerrors.ErrNotFound) {
+		t.Fatalf("удаленный пост не должен отдаваться, получено %v", err)
+	}
+	if err := restored.PurgePost(ctx, "p2"); err != nil {
+		t.Fatalf("удаленный пост должен остаться в хранилище до физического удаления: %v", err)
+	}
+	if _, err := restored.GetCommentByID(ctx, "c2"); !errors.Is(err, customerrors.ErrNotFound) {
+		t.Fatalf("ответ должен удалиться вместе с родителем, получено %v", err)
+	}
+	top, err := restored.ListCommentsByPost(ctx, "p1", nil, models.CommentSortOld, 0, 10)
+	if err != nil || len(top) != 1 || top[0].ID != "c3" || top[0].DeletedAt == nil {
+		t.Fatalf("ожидался один мягко удаленный комментарий: %v, %+v", err, top)
+	}
+}
+
+func TestMemoryStorage_ReactionsReplay(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &config.MemoryConfig{
+		WALPath:      filepath.Join(dir, "memory.wal"),
+		SnapshotPath: filepath.Join(dir, "memory.snapshot"),
+		FsyncPolicy:  config.FsyncAlways,
+	}
+	ctx := context.Background()
+
+	strg, err := inmemory.OpenMemoryStorage(cfg)
+	if err != nil {
+		t.Fatalf("не удалось открыть хранилище: %v", err)
+	}
+	for _, u := range []*models.User{{ID: "u1", Username: "vasya"}, {ID: "u2", Username: "petya"}} {
+		if err := strg.CreateUser(ctx, u); err != nil {
+			t.Fatalf("не удалось создать пользователя: %v", err)
+		}
+	}
+	if err := strg.CreatePost(ctx, &models.Post{ID: "p1", Title: "t", Content: "c", AuthorID: "u1"}); err != nil {
+		t.Fatalf("не удалось создать пост: %v", err)
+	}
+	if err := strg.SetReaction(ctx, &models.Reaction{Target: models.ReactionTargetPost, TargetID: "p1", UserID: "u1", Kind: models.ReactionHeart}); err != nil {
+		t.Fatalf("не удалось поставить реакцию: %v", err)
+	}
+	// счетчики после снапшота пересчитываются из самих реакций
+	if err := strg.Snapshot(); err != nil {
+		t.Fatalf("не удалось сделать снапшот: %v", err)
+	}
+	if err := strg.SetReaction(ctx, &models.Reaction{Target: models.ReactionTargetPost, TargetID: "p1", UserID: "u2", Kind: models.ReactionHeart}); err != nil {
+		t.Fatalf("не удалось поставить реакцию: %v", err)
+	}
+	if err := strg.SetReaction(ctx, &models.Reaction{Target: models.ReactionTargetPost, TargetID: "p1", UserID: "u1", Kind: models.ReactionUp}); err != nil {
+		t.Fatalf("не удалось сменить реакцию: %v", err)
+	}
+
+	restored, err := inmemory.OpenMemoryStorage(cfg)
+	if err != nil {
+		t.Fatalf("не удалось восстановить хранилище: %v", err)
+	}
+	defer restored.Close()
+
+	counts, err := restored.GetReactionCounts(ctx, models.ReactionTargetPost, []string{"p1"})
+	if err != nil || len(counts["p1"]) != 2 {
+		t.Fatalf("ожидались счетчики heart и up: %v, %+v", err, counts)
+	}
+	for _, c := range counts["p1"] {
+		if c.Count != 1 {
+			t.Fatalf("каждый счетчик должен быть равен 1: %+v", counts["p1"])
+		}
+	}
+	mine, err := restored.GetUserReactions(ctx, models.ReactionTargetPost, "u1", []string{"p1"})
+	if err != nil || mine["p1"] != models.ReactionUp {
+		t.Fatalf("ожидалась реакция up после восстановления: %v, %+v", err, mine)
+	}
+}
+
+func TestMemoryStorage_CommentSort(t *testing.T) {
+	strg := inmemory.NewMemoryStorage()
+	ctx := context.Background()
+	base := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	for _, u := range []*models.User{{ID: "u1", Username: "vasya"}, {ID: "u2", Username: "petya"}} {
+		if err := strg.CreateUser(ctx, u); err != nil {
+			t.Fatalf("не удалось создать пользователя: %v", err)
+		}
+	}
+	if err := strg.CreatePost(ctx, &models.Post{ID: "p1", Title: "t", Content: "c", AuthorID: "u1"}); err != nil {
+		t.Fatalf("не удалось создать пост: %v", err)
+	}
+	root := "root"
+	if err := strg.CreateComment(ctx, &models.Comment{ID: root, PostID: "p1", Text: "корень", CreatedAt: base}); err != nil {
+		t.Fatalf("не удалось создать комментарий: %v", err)
+	}
+	for i, id := range []string{"r1", "r2", "r3"} {
+		reply := &models.Comment{ID: id, PostID: "p1", ParentID: &root, Text: id, CreatedAt: base.Add(time.Duration(i+1) * time.Second)}
+		if err := strg.CreateComment(ctx, reply); err != nil {
+			t.Fatalf("не удалось создать ответ: %v", err)
+		}
+	}
+	// r2 набирает голоса за, у r3 голоса делятся поровну
+	for _, r := range []*models.Reaction{
+		{Target: models.ReactionTargetComment, TargetID: "r2", UserID: "u1", Kind: models.ReactionUp},
+		{Target: models.ReactionTargetComment, TargetID: "r2", UserID: "u2", Kind: models.ReactionUp},
+		{Target: models.ReactionTargetComment, TargetID: "r3", UserID: "u1", Kind: models.ReactionUp},
+		{Target: models.ReactionTargetComment, TargetID: "r3", UserID: "u2", Kind: models.ReactionDown},
+	} {
+		if err := strg.SetReaction(ctx, r); err != nil {
+			t.Fatalf("не удалось поставить реакцию: %v", err)
+		}
+	}
+
+	replies, err := strg.ListRepliesByParentIDs(ctx, []string{root}, models.CommentSortTop, 0, 2)
+	if err != nil || len(replies[root]) != 2 || replies[root][0].ID != "r2" || replies[root][1].ID != "r3" {
+		t.Fatalf("ожидались ответы r2, r3: %v, %+v", err, replies[root])
+	}
+	// при равных рангах порядок по времени создания, страницы не пересекаются
+	page, err := strg.ListCommentsByPost(ctx, "p1", &root, models.CommentSortControversial, 1, 2)
+	if err != nil || len(page) != 2 || page[0].ID != "r1" || page[1].ID != "r2" {
+		t.Fatalf("ожидалась вторая страница r1, r2: %v, %+v", err, page)
+	}
+	page, err = strg.ListCommentsByPost(ctx, "p1", &root, models.CommentSortNew, 0, 1)
+	if err != nil || len(page) != 1 || page[0].ID != "r3" {
+		t.Fatalf("первым новым ожидался r3: %v, %+v", err, page)
+	}
+}
+
+func TestMemoryStorage_ListPostsFilterAndSort(t *testing.T) {
+	strg := inmemory.NewMemoryStorage()
+	ctx := context.Background()
+
+	for _, p := range []*models.Post{
+		{ID: "p1", Title: "t", Content: "c", AuthorID: "u1"},
+		{ID: "p2", Title: "t", Content: "c", AuthorID: "u2"},
+		{ID: "p3", Title: "t", Content: "c", AuthorID: "u1"},
+	} {
+		if err := strg.CreatePost(ctx, p); err != nil {
+			t.Fatalf("не удалось создать пост: %v", err)
+		}
+	}
+	for _, c := range []*models.Comment{
+		{ID: "c1", PostID: "p1", Text: "1"},
+		{ID: "c2", PostID: "p1", Text: "2"},
+		{ID: "c3", PostID: "p2", Text: "3"},
+	} {
+		if err := strg.CreateComment(ctx, c); err != nil {
+			t.Fatalf("не удалось создать комментарий: %v", err)
+		}
+	}
+
+	author, yes := "u1", true
+	posts, err := strg.ListPosts(ctx, models.PostFilter{AuthorID: &author, HasComments: &yes}, models.PostSortNewest, 0, 10)
+	if err != nil || len(posts) != 1 || posts[0].ID != "p1" {
+		t.Fatalf("ожидался только p1: %v, %+v", err, posts)
+	}
+	posts, err = strg.ListPosts(ctx, models.PostFilter{}, models.PostSortMostCommented, 0, 2)
+	if err != nil || len(posts) != 2 || posts[0].ID != "p1" || posts[1].ID != "p2" {
+		t.Fatalf("ожидались p1, p2: %v, %+v", err, posts)
+	}
+	// последний комментарий оставлен к p2
+	posts, err = strg.ListPosts(ctx, models.PostFilter{}, models.PostSortRecentlyActive, 0, 1)
+	if err != nil || len(posts) != 1 || posts[0].ID != "p2" {
+		t.Fatalf("самым активным ожидался p2: %v, %+v", err, posts)
+	}
+
+	// удаленный комментарий не считается: у p2 комментариев не остается, а активность p1 - по c2
+	if err := strg.SoftDeleteComment(ctx, "c3", time.Now().UTC()); err != nil {
+		t.Fatalf("не удалось удалить комментарий: %v", err)
+	}
+	posts, err = strg.ListPosts(ctx, models.PostFilter{HasComments: &yes}, models.PostSortNewest, 0, 10)
+	if err != nil || len(posts) != 1 || posts[0].ID != "p1" {
+		t.Fatalf("после удаления c3 комментарии ожидались только у p1: %v, %+v", err, posts)
+	}
+	posts, err = strg.ListPosts(ctx, models.PostFilter{}, models.PostSortRecentlyActive, 0, 1)
+	if err != nil || len(posts) != 1 || posts[0].ID != "p1" {
+		t.Fatalf("самым активным ожидался p1: %v, %+v", err, posts)
+	}
+}
+
+func TestMemoryStorage_ListCommentsByAuthor(t *testing.T) {
+	strg := inmemory.NewMemoryStorage()
+	ctx := context.Background()
+
+	for _, p := range []*models.Post{{ID: "p1", Title: "t", Content: "c"}, {ID: "p2", Title: "t", Content: "c"}} {
+		if err := strg.CreatePost(ctx, p); err != nil {
+			t.Fatalf("не удалось создать пост: %v", err)
+		}
+	}
+	for _, c := range []*models.Comment{
+		{ID: "c1", PostID: "p1", AuthorID: "u1", Text: "1"},
+		{ID: "c2", PostID: "p1", AuthorID: "u2", Text: "2"},
+		{ID: "c3", PostID: "p1", AuthorID: "u1", Text: "3"},
+		{ID: "c4", PostID: "p2", AuthorID: "u1", Text: "4"},
+	} {
+		if err := strg.CreateComment(ctx, c); err != nil {
+			t.Fatalf("не удалось создать комментарий: %v", err)
+		}
+	}
+	if err := strg.SoftDeletePost(ctx, "p2", time.Now()); err != nil {
+		t.Fatalf("не удалось удалить пост: %v", err)
+	}
+
+	comments, err := strg.ListCommentsByAuthor(ctx, "u1", 0, 10)
+	if err != nil || len(comments) != 2 || comments[0].ID != "c3" || comments[1].ID != "c1" {
+		t.Fatalf("ожидались c3, c1: %v, %+v", err, comments)
+	}
+	comments, err = strg.ListCommentsByAuthor(ctx, "u1", 1, 1)
+	if err != nil || len(comments) != 1 || comments[0].ID != "c1" {
+		t.Fatalf("второй страницей ожидался c1: %v, %+v", err, comments)
+	}
+
+	if err := strg.SoftDeleteComment(ctx, "c3", time.Now()); err != nil {
+		t.Fatalf("не удалось удалить комментарий: %v", err)
+	}
+	comments, err = strg.ListCommentsByAuthor(ctx, "u1", 0, 10)
+	if err != nil || len(comments) != 1 || comments[0].ID != "c1" {
+		t.Fatalf("после удаления c3 ожидался только c1: %v, %+v", err, comments)
+	}
+	comments, err = strg.ListCommentsByAuthor(ctx, "u1", 5, 10)
+	if err != nil || len(comments) != 0 {
+		t.Fatalf("за концом списка ожидалась пустая страница: %v, %+v", err, comments)
+	}
+}
+
+func TestMemoryStorage_NotificationsReplay(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &config.MemoryConfig{
+		WALPath:      filepath.Join(dir, "memory.wal"),
+		SnapshotPath: filepath.Join(dir, "memory.snapshot"),
+		FsyncPolicy:  config.FsyncAlways,
+	}
+	ctx := context.Background()
+
+	strg, err := inmemory.OpenMemoryStorage(cfg)
+	if err != nil {
+		t.Fatalf("не удалось открыть хранилище: %v", err)
+	}
+	for _, u := range []*models.User{{ID: "u1", Username: "vasya"}, {ID: "u2", Username: "petya"}} {
+		if err := strg.CreateUser(ctx, u); err != nil {
+			t.Fatalf("не удалось создать пользователя: %v", err)
+		}
+	}
+	if err := strg.CreatePost(ctx, &models.Post{ID: "p1", Title: "t", Content: "c", AuthorID: "u1"}); err != nil {
+		t.Fatalf("не удалось создать пост: %v", err)
+	}
+	for _, id := range []string{"c1", "c2"} {
+		if err := strg.CreateComment(ctx, &models.Comment{ID: id, PostID: "p1", AuthorID: "u2", Text: id}); err != nil {
+			t.Fatalf("не удалось создать комментарий: %v", err)
+		}
+	}
+	base := time.Now().UTC()
+	c1, c2 := "c1", "c2"
+	if err := strg.CreateNotifications(ctx, []*models.Notification{
+		{ID: "n1", UserID: "u1", Kind: models.NotificationPostComment, ActorID: "u2", PostID: "p1", CommentID: &c1, CreatedAt: base},
+	}); err != nil {
+		t.Fatalf("не удалось создать уведомление: %v", err)
+	}
+	if err := strg.Snapshot(); err != nil {
+		t.Fatalf("не удалось сделать снапшот: %v", err)
+	}
+	if err := strg.CreateNotifications(ctx, []*models.Notification{
+		{ID: "n2", UserID: "u1", Kind: models.NotificationPostComment, ActorID: "u2", PostID: "p1", CommentID: &c2, CreatedAt: base.Add(time.Second)},
+	}); err != nil {
+		t.Fatalf("не удалось создать уведомление: %v", err)
+	}
+	if marked, err := strg.MarkNotificationsRead(ctx, "u1", []string{"n1"}, base); err != nil || marked != 1 {
+		t.Fatalf("ожидалось одно отмеченное уведомление: %v, %d", err, marked)
+	}
+	if err := strg.PurgeComment(ctx, "c2"); err != nil {
+		t.Fatalf("не удалось удалить комментарий: %v", err)
+	}
+
+	restored, err := inmemory.OpenMemoryStorage(cfg)
+	if err != nil {
+		t.Fatalf("не удалось восстановить хранилище: %v", err)
+	}
+	defer restored.Close()
+
+	list, err := restored.ListNotifications(ctx, "u1", false, nil, 10)
+	if err != nil || len(list) != 1 || list[0].ID != "n1" || list[0].ReadAt == nil {
+		t.Fatalf("ожидалось прочитанное n1: %v, %+v", err, list)
+	}
+}
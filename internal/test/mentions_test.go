@@ -0,0 +1,177 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MAPiryazev/OzonTest/internal/config"
+	"github.com/MAPiryazev/OzonTest/internal/customerrors"
+	"github.com/MAPiryazev/OzonTest/internal/mentions"
+	"github.com/MAPiryazev/OzonTest/internal/models"
+	"github.com/MAPiryazev/OzonTest/internal/repository"
+	"github.com/MAPiryazev/OzonTest/internal/repository/inmemory"
+	"github.com/MAPiryazev/OzonTest/internal/repository/mocks"
+	"github.com/MAPiryazev/OzonTest/internal/service"
+	"github.com/golang/mock/gomock"
+)
+
+func TestMentions_Parse(t *testing.T) {
+	cases := []struct {
+		text string
+		want string
+	}{
+		{"привет, @vasya и @petya_1!", "vasya petya_1"},
+		{"@vasya. Спасибо, @vasya", "vasya"},
+		{"пиши на mail@example.com", ""},
+		{"@@vasya и @ просто", ""},
+		{"(@маша-)", "маша"},
+	}
+	for _, tc := range cases {
+		if got := strings.Join(mentions.Parse(tc.text), " "); got != tc.want {
+			t.Fatalf("%q: ожидалось %q, получено %q", tc.text, tc.want, got)
+		}
+	}
+
+	many := strings.Repeat("@u ", 5) + strings.Repeat("@a@b ", 1)
+	for i := 0; i < mentions.MaxMentions+5; i++ {
+		many += " @user" + strings.Repeat("x", i)
+	}
+	if got := mentions.Parse(many); len(got) != mentions.MaxMentions {
+		t.Fatalf("ожидалось не больше %d упоминаний, получено %d", mentions.MaxMentions, len(got))
+	}
+}
+
+// любое имя, принятое при регистрации, читается из упоминания целиком и находит этого пользователя
+func TestService_RegisterNameRoundTrip(t *testing.T) {
+	strg := inmemory.NewMemoryStorage()
+	svc := service.NewService(strg, &config.AppConfig{MinUsernameLen: 3, MinPasswordLen: 8}, newTokenManager(t, "secret", 5))
+	ctx := context.Background()
+
+	for _, name := range []string{"vasya petya", "a@b.ru", "vasya.", "маша-", "@vasya", "vasya,petya"} {
+		if _, err := svc.Register(ctx, name, "password1"); !errors.Is(err, customerrors.ErrValidation) {
+			t.Fatalf("%q: ожидалась ErrValidation, получено %v", name, err)
+		}
+	}
+
+	for _, name := range []string{"vasya", "petya.ivanov", "маша-1", "_kolya_", "a-b.c"} {
+		payload, err := svc.Register(ctx, name, "password1")
+		if err != nil {
+			t.Fatalf("%q: не удалось зарегистрироваться: %v", name, err)
+		}
+		parsed := mentions.Parse("спасибо, @" + name + ".")
+		if len(parsed) != 1 || parsed[0] != name {
+			t.Fatalf("%q: упоминание прочитано как %v", name, parsed)
+		}
+		users, err := strg.GetUsersByUsernames(ctx, parsed)
+		if err != nil || len(users) != 1 || users[0].ID != payload.User.ID {
+			t.Fatalf("%q: упоминание должно найти зарегистрированного пользователя: %v, %+v", name, err, users)
+		}
+	}
+}
+
+func TestSQLiteStorage_Mentions(t *testing.T) {
+	strg := newSQLiteStorage(t)
+	ctx := context.Background()
+
+	for _, u := range []*models.User{{ID: "u1", Username: "vasya"}, {ID: "u2", Username: "petya"}, {ID: "u3", Username: "masha"}} {
+		if err := strg.CreateUser(ctx, u); err != nil {
+			t.Fatalf("не удалось создать пользователя: %v", err)
+		}
+	}
+	users, err := strg.GetUsersByUsernames(ctx, []string{"petya", "nobody", "masha"})
+	if err != nil || len(users) != 2 {
+		t.Fatalf("ожидалось два пользователя: %v, %+v", err, users)
+	}
+
+	if err := strg.CreatePost(ctx, &models.Post{ID: "p1", Title: "t", Content: "@petya @masha", AuthorID: "u1", CommentsEnabled: true, CreatedAt: time.Now()}); err != nil {
+		t.Fatalf("не удалось создать пост: %v", err)
+	}
+	added, err := strg.ReplaceMentions(ctx, models.ReactionTargetPost, "p1", []string{"u2", "u3"})
+	if err != nil || strings.Join(added, " ") != "u2 u3" {
+		t.Fatalf("оба упоминания должны быть новыми: %v, %v", err, added)
+	}
+	// после правки новым считается только u1, u3 пропадает
+	added, err = strg.ReplaceMentions(ctx, models.ReactionTargetPost, "p1", []string{"u1", "u2"})
+	if err != nil || strings.Join(added, " ") != "u1" {
+		t.Fatalf("новым должен быть только u1: %v, %v", err, added)
+	}
+
+	mentioned, err := strg.GetMentions(ctx, models.ReactionTargetPost, []string{"p1", "p2"})
+	if err != nil {
+		t.Fatalf("не удалось получить упоминания: %v", err)
+	}
+	if len(mentioned) != 1 || len(mentioned["p1"]) != 2 || mentioned["p1"][0].Username != "vasya" || mentioned["p1"][1].UserID != "u2" {
+		t.Fatalf("неожиданные упоминания %+v", mentioned)
+	}
+
+	// уведомление об упоминании в посте хранится без комментария, повтор с тем же id пропускается
+	mention := &models.Notification{ID: "n1", UserID: "u2", Kind: models.NotificationMention, ActorID: "u1", PostID: "p1", CreatedAt: time.Now()}
+	for i := 0; i < 2; i++ {
+		if err := strg.CreateNotifications(ctx, []*models.Notification{mention}); err != nil {
+			t.Fatalf("не удалось создать уведомление: %v", err)
+		}
+	}
+	list, err := strg.ListNotifications(ctx, "u2", false, nil, 10)
+	if err != nil || len(list) != 1 || list[0].Kind != models.NotificationMention || list[0].CommentID != nil {
+		t.Fatalf("ожидалось уведомление об упоминании без комментария: %v, %+v", err, list)
+	}
+}
+
+func TestService_CommentMentionsNotify(t *testing.T) {
+	controller := gomock.NewController(t)
+	defer controller.Finish()
+
+	mockForRepository := mocks.NewMockStorage(controller)
+	expectTx(mockForRepository)
+	svc := service.NewService(mockForRepository, &config.AppConfig{}, nil)
+	defer svc.Close()
+	ctx := context.Background()
+
+	comment := &models.Comment{ID: "c1", PostID: "p1", AuthorID: "u1", Text: "@petya, @masha и @nobody, это я @vasya"}
+	event, err := repository.NewOutboxEvent(models.EventCommentCreated, comment.ID, comment, time.Now())
+	if err != nil {
+		t.Fatalf("не удалось собрать событие: %v", err)
+	}
+	mockForRepository.EXPECT().GetCommentByID(ctx, "c1").Return(comment, nil)
+	mockForRepository.EXPECT().GetPostByID(ctx, "p1").Return(&models.Post{ID: "p1", AuthorID: "u2", CommentsEnabled: true}, nil)
+	mockForRepository.EXPECT().GetUsersByUsernames(ctx, []string{"petya", "masha", "nobody", "vasya"}).Return([]*models.User{
+		{ID: "u1", Username: "vasya"}, {ID: "u2", Username: "petya"}, {ID: "u3", Username: "masha"},
+	}, nil)
+	// порядок как в тексте, неизвестное имя пропускается
+	mockForRepository.EXPECT().ReplaceMentions(ctx, models.ReactionTargetComment, "c1", []string{"u2", "u3", "u1"}).Return([]string{"u2", "u3", "u1"}, nil)
+	mockForRepository.EXPECT().CreateNotifications(ctx, gomock.Any()).DoAndReturn(
+		func(_ context.Context, notifications []*models.Notification) error {
+			kinds := make(map[string]models.NotificationKind)
+			for _, n := range notifications {
+				if n.CommentID == nil {
+					t.Fatalf("у уведомления о комментарии должен быть commentId: %+v", n)
+				}
+				kinds[n.UserID] = n.Kind
+			}
+			// автор поста получает одно уведомление о комментарии, себе уведомление не приходит
+			if len(kinds) != 2 || kinds["u2"] != models.NotificationPostComment || kinds["u3"] != models.NotificationMention {
+				t.Fatalf("неожиданные уведомления %+v", kinds)
+			}
+			return nil
+		})
+	if err := svc.NotifyEvent(ctx, event); err != nil {
+		t.Fatalf("не удалось обработать событие: %v", err)
+	}
+
+	// после правки без упоминаний список очищается, уведомлять некого
+	edited := *comment
+	edited.Text = "без упоминаний"
+	event, err = repository.NewOutboxEvent(models.EventCommentUpdated, edited.ID, &edited, time.Now())
+	if err != nil {
+		t.Fatalf("не удалось собрать событие: %v", err)
+	}
+	mockForRepository.EXPECT().GetCommentByID(ctx, "c1").Return(&edited, nil)
+	mockForRepository.EXPECT().GetPostByID(ctx, "p1").Return(&models.Post{ID: "p1", AuthorID: "u2", CommentsEnabled: true}, nil)
+	mockForRepository.EXPECT().ReplaceMentions(ctx, models.ReactionTargetComment, "c1", nil).Return(nil, nil)
+	if err := svc.NotifyEvent(ctx, event); err != nil {
+		t.Fatalf("не удалось обработать событие: %v", err)
+	}
+}
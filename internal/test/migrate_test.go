@@ -0,0 +1,88 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/MAPiryazev/OzonTest/internal/config"
+	"github.com/MAPiryazev/OzonTest/internal/customerrors"
+	"github.com/MAPiryazev/OzonTest/internal/migrate"
+	"github.com/MAPiryazev/OzonTest/internal/repository/sqlite"
+)
+
+func TestMigrate_Load(t *testing.T) {
+	fsys := fstest.MapFS{
+		"m/0002_second.up.sql":    {Data: []byte("create table b(id text);")},
+		"m/0001_first.up.sql":     {Data: []byte("create table a(id text);")},
+		"m/0001_first.down.sql":   {Data: []byte("drop table a;")},
+		"bad/0001_first.down.sql": {Data: []byte("drop table a;")},
+	}
+
+	list, err := migrate.Load(fsys, "m")
+	if err != nil {
+		t.Fatalf("не удалось загрузить миграции: %v", err)
+	}
+	if len(list) != 2 || list[0].Version != 1 || list[1].Version != 2 || list[0].Down == "" {
+		t.Fatalf("неожиданный список миграций: %+v", list)
+	}
+
+	if _, err := migrate.Load(fsys, "bad"); !errors.Is(err, customerrors.ErrMigration) {
+		t.Fatalf("ожидалась ошибка миграции без up файла, получено %v", err)
+	}
+}
+
+func TestMigrate_UpDownStatus(t *testing.T) {
+	conn, err := sqlite.OpenDB(&config.SQLiteConfig{Path: filepath.Join(t.TempDir(), "migrate.db"), BusyTimeoutMs: 1000})
+	if err != nil {
+		t.Fatalf("не удалось открыть sqlite: %v", err)
+	}
+	defer conn.Close()
+
+	migrator, err := sqlite.NewMigrator(conn)
+	if err != nil {
+		t.Fatalf("не удалось создать мигратор: %v", err)
+	}
+	ctx := context.Background()
+
+	pending, err := migrator.Pending(ctx)
+	if err != nil || len(pending) == 0 {
+		t.Fatalf("ожидались неприменённые миграции: %v, %d", err, len(pending))
+	}
+
+	applied, err := migrator.Up(ctx)
+	if err != nil || applied != len(pending) {
+		t.Fatalf("up: применено %d из %d: %v", applied, len(pending), err)
+	}
+	if again, err := migrator.Up(ctx); err != nil || again != 0 {
+		t.Fatalf("повторный up должен ничего не делать: %d, %v", again, err)
+	}
+
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	for _, st := range statuses {
+		if !st.Applied || st.AppliedAt == nil {
+			t.Fatalf("миграция %d должна быть применена", st.Version)
+		}
+	}
+
+	reverted, err := migrator.Down(ctx, 1)
+	if err != nil || reverted != 1 {
+		t.Fatalf("down: откачено %d: %v", reverted, err)
+	}
+	if pending, _ := migrator.Pending(ctx); len(pending) != 1 || pending[0].Version != statuses[len(statuses)-1].Version {
+		t.Fatalf("после отката должна остаться неприменённой только последняя миграция, получено %+v", pending)
+	}
+
+	// полный откат удаляет схему
+	if _, err := migrator.Down(ctx, len(statuses)); err != nil {
+		t.Fatalf("полный откат: %v", err)
+	}
+	if _, err := conn.Exec(`select 1 from users`); err == nil {
+		t.Fatal("после полного отката таблица users должна быть удалена")
+	}
+}
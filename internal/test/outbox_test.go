@@ -0,0 +1,245 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/MAPiryazev/OzonTest/internal/config"
+	"github.com/MAPiryazev/OzonTest/internal/models"
+	"github.com/MAPiryazev/OzonTest/internal/outbox"
+	"github.com/MAPiryazev/OzonTest/internal/repository"
+	"github.com/MAPiryazev/OzonTest/internal/repository/inmemory"
+)
+
+// пользователь, пост с правкой и комментарий с правкой - пять событий
+func writeOutboxEvents(t *testing.T, strg repository.Storage) {
+	t.Helper()
+	ctx := context.Background()
+
+	if err := strg.CreateUser(ctx, &models.User{ID: "11111111-1111-1111-1111-111111111111", Username: "vasya", PasswordHash: "secret-hash"}); err != nil {
+		t.Fatalf("не удалось создать пользователя: %v", err)
+	}
+	post := &models.Post{ID: "22222222-2222-2222-2222-222222222222", Title: "t", Content: "c", AuthorID: "11111111-1111-1111-1111-111111111111", CommentsEnabled: true}
+	if err := strg.CreatePost(ctx, post); err != nil {
+		t.Fatalf("не удалось создать пост: %v", err)
+	}
+	post.Title = "новый"
+	if err := strg.UpdatePost(ctx, post, nil); err != nil {
+		t.Fatalf("не удалось обновить пост: %v", err)
+	}
+	comment := &models.Comment{ID: "33333333-3333-3333-3333-333333333333", PostID: post.ID, AuthorID: post.AuthorID, Text: "привет"}
+	if err := strg.CreateComment(ctx, comment); err != nil {
+		t.Fatalf("не удалось создать комментарий: %v", err)
+	}
+	comment.Text = "пока"
+	if err := strg.UpdateComment(ctx, comment, nil); err != nil {
+		t.Fatalf("не удалось обновить комментарий: %v", err)
+	}
+}
+
+func checkOutboxEvents(t *testing.T, strg repository.Storage) {
+	t.Helper()
+	ctx := context.Background()
+
+	events, err := strg.ListOutboxEvents(ctx, 0, 10)
+	if err != nil {
+		t.Fatalf("не удалось получить события: %v", err)
+	}
+	want := []models.EventType{models.EventUserCreated, models.EventPostCreated, models.EventPostUpdated, models.EventCommentCreated, models.EventCommentUpdated}
+	if len(events) != len(want) {
+		t.Fatalf("ожидалось %d событий, получено %+v", len(want), events)
+	}
+	for i, event := range events {
+		if event.Type != want[i] || (i > 0 && event.Seq <= events[i-1].Seq) {
+			t.Fatalf("событие %d: неожиданный тип или порядок %+v", i, event)
+		}
+	}
+	// потребителям уходят только id, имя и роль, без хеша пароля
+	if want := `{"id":"11111111-1111-1111-1111-111111111111","username":"vasya","role":"user"}`; events[0].Payload != want {
+		t.Fatalf("неожиданное тело события пользователя %s", events[0].Payload)
+	}
+	if !strings.Contains(events[2].Payload, `"title":"новый"`) || events[4].AggregateID != "33333333-3333-3333-3333-333333333333" {
+		t.Fatalf("неожиданные события правок %+v, %+v", events[2], events[4])
+	}
+
+	after, err := strg.ListOutboxEvents(ctx, events[1].Seq, 2)
+	if err != nil || len(after) != 2 || after[0].Seq != events[2].Seq {
+		t.Fatalf("ожидались два события после второго: %v, %+v", err, after)
+	}
+
+	if offset, err := strg.GetConsumerOffset(ctx, "c"); err != nil || offset != 0 {
+		t.Fatalf("смещение нового потребителя должно быть 0: %v, %d", err, offset)
+	}
+	if err := strg.SetConsumerOffset(ctx, "c", events[3].Seq); err != nil {
+		t.Fatalf("не удалось сохранить смещение: %v", err)
+	}
+	// смещение назад не двигается
+	if err := strg.SetConsumerOffset(ctx, "c", events[1].Seq); err != nil {
+		t.Fatalf("не удалось сохранить смещение: %v", err)
+	}
+	if offset, _ := strg.GetConsumerOffset(ctx, "c"); offset != events[3].Seq {
+		t.Fatalf("ожидалось смещение %d, получено %d", events[3].Seq, offset)
+	}
+
+	if err := strg.PruneOutbox(ctx, events[3].Seq); err != nil {
+		t.Fatalf("не удалось очистить outbox: %v", err)
+	}
+	left, err := strg.ListOutboxEvents(ctx, 0, 10)
+	if err != nil || len(left) != 1 || left[0].Seq != events[4].Seq {
+		t.Fatalf("должно остаться только последнее событие: %v, %+v", err, left)
+	}
+}
+
+func TestMemoryStorage_Outbox(t *testing.T) {
+	strg := inmemory.NewMemoryStorage()
+	writeOutboxEvents(t, strg)
+	checkOutboxEvents(t, strg)
+}
+
+func TestSQLiteStorage_Outbox(t *testing.T) {
+	strg := newSQLiteStorage(t)
+	writeOutboxEvents(t, strg)
+	checkOutboxEvents(t, strg)
+}
+
+func TestMemoryStorage_OutboxRestored(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &config.MemoryConfig{
+		WALPath:      filepath.Join(dir, "memory.wal"),
+		SnapshotPath: filepath.Join(dir, "memory.snapshot"),
+		FsyncPolicy:  config.FsyncAlways,
+	}
+	ctx := context.Background()
+
+	strg, err := inmemory.OpenMemoryStorage(cfg)
+	if err != nil {
+		t.Fatalf("не удалось открыть хранилище: %v", err)
+	}
+	writeOutboxEvents(t, strg)
+	if err := strg.SetConsumerOffset(ctx, "c", 2); err != nil {
+		t.Fatalf("не удалось сохранить смещение: %v", err)
+	}
+	if err := strg.PruneOutbox(ctx, 2); err != nil {
+		t.Fatalf("не удалось очистить outbox: %v", err)
+	}
+	// после снапшота очищенные события пропадают и из журнала, seq должен продолжиться
+	if err := strg.Snapshot(); err != nil {
+		t.Fatalf("не удалось сделать снапшот: %v", err)
+	}
+	if err := strg.CreatePost(ctx, &models.Post{ID: "p2", Title: "t", Content: "c", AuthorID: "11111111-1111-1111-1111-111111111111"}); err != nil {
+		t.Fatalf("не удалось создать пост: %v", err)
+	}
+
+	// Close не вызываем - как при падении процесса
+	restored, err := inmemory.OpenMemoryStorage(cfg)
+	if err != nil {
+		t.Fatalf("не удалось восстановить хранилище: %v", err)
+	}
+	defer restored.Close()
+
+	events, err := restored.ListOutboxEvents(ctx, 0, 10)
+	if err != nil || len(events) != 4 || events[0].Seq != 3 || events[3].Seq != 6 || events[3].AggregateID != "p2" {
+		t.Fatalf("события outbox не восстановлены: %v, %+v", err, events)
+	}
+	if offset, _ := restored.GetConsumerOffset(ctx, "c"); offset != 2 {
+		t.Fatalf("смещение потребителя не восстановлено: %d", offset)
+	}
+	if err := restored.CreatePost(ctx, &models.Post{ID: "p3", Title: "t", Content: "c", AuthorID: "11111111-1111-1111-1111-111111111111"}); err != nil {
+		t.Fatalf("не удалось создать пост: %v", err)
+	}
+	if events, _ := restored.ListOutboxEvents(ctx, 6, 10); len(events) != 1 || events[0].Seq != 7 {
+		t.Fatalf("seq после восстановления должен продолжиться: %+v", events)
+	}
+}
+
+func TestRelay_OrderedAtLeastOnce(t *testing.T) {
+	strg := inmemory.NewMemoryStorage()
+	writeOutboxEvents(t, strg)
+	ctx := context.Background()
+	relay := outbox.NewRelay(strg, &config.OutboxConfig{BatchSize: 10})
+
+	var fast []int64
+	relay.Register("fast", func(_ context.Context, event *models.OutboxEvent) error {
+		fast = append(fast, event.Seq)
+		return nil
+	})
+	// третье событие первый раз обрабатывается с ошибкой
+	var slow []int64
+	failed := false
+	relay.Register("slow", func(_ context.Context, event *models.OutboxEvent) error {
+		if event.Seq == 3 && !failed {
+			failed = true
+			return errors.New("временная ошибка")
+		}
+		slow = append(slow, event.Seq)
+		return nil
+	})
+
+	if _, err := relay.RunOnce(ctx); err == nil {
+		t.Fatal("ожидалась ошибка потребителя")
+	}
+	if len(fast) != 5 || len(slow) != 2 {
+		t.Fatalf("первый потребитель получает все, второй останавливается на ошибке: %v, %v", fast, slow)
+	}
+	// события, которые не обработал второй потребитель, остаются в outbox
+	if events, _ := strg.ListOutboxEvents(ctx, 0, 10); len(events) != 3 || events[0].Seq != 3 {
+		t.Fatalf("очищены только обработанные всеми события: %+v", events)
+	}
+
+	processed, err := relay.RunOnce(ctx)
+	if err != nil || processed != 3 {
+		t.Fatalf("ожидалась повторная доставка трех событий: %v, %d", err, processed)
+	}
+	if want := []int64{1, 2, 3, 4, 5}; len(slow) != len(want) || slow[2] != 3 || slow[4] != 5 {
+		t.Fatalf("события должны прийти по порядку: %v", slow)
+	}
+	if len(fast) != 5 {
+		t.Fatalf("первый потребитель не должен получать события повторно: %v", fast)
+	}
+	if events, _ := strg.ListOutboxEvents(ctx, 0, 10); len(events) != 0 {
+		t.Fatalf("outbox должен быть очищен: %+v", events)
+	}
+}
+
+func TestRelay_SkipsAfterMaxAttempts(t *testing.T) {
+	strg := inmemory.NewMemoryStorage()
+	writeOutboxEvents(t, strg)
+	ctx := context.Background()
+	relay := outbox.NewRelay(strg, &config.OutboxConfig{BatchSize: 10, MaxAttempts: 3})
+
+	// третье событие не обрабатывается никогда
+	attempts := 0
+	var got []int64
+	relay.Register("broken", func(_ context.Context, event *models.OutboxEvent) error {
+		if event.Seq == 3 {
+			attempts++
+			return errors.New("постоянная ошибка")
+		}
+		got = append(got, event.Seq)
+		return nil
+	})
+
+	for i := 0; i < 2; i++ {
+		if _, err := relay.RunOnce(ctx); err == nil {
+			t.Fatalf("попытка %d: ожидалась ошибка потребителя", i+1)
+		}
+	}
+	if offset, _ := strg.GetConsumerOffset(ctx, "broken"); offset != 2 || len(got) != 2 {
+		t.Fatalf("до последней попытки потребитель стоит на неудачном событии: %d, %v", offset, got)
+	}
+
+	// третья попытка пропускает событие, следующие доставляются, outbox очищается
+	processed, err := relay.RunOnce(ctx)
+	if err != nil || processed != 3 || attempts != 3 {
+		t.Fatalf("событие должно пропуститься после трех попыток: %v, %d, %d", err, processed, attempts)
+	}
+	if want := []int64{1, 2, 4, 5}; len(got) != len(want) || got[2] != 4 || got[3] != 5 {
+		t.Fatalf("события после пропущенного должны прийти по порядку: %v", got)
+	}
+	if events, _ := strg.ListOutboxEvents(ctx, 0, 10); len(events) != 0 {
+		t.Fatalf("outbox должен быть очищен: %+v", events)
+	}
+}
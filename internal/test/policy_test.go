@@ -0,0 +1,115 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/MAPiryazev/OzonTest/graph"
+	"github.com/MAPiryazev/OzonTest/graph/model"
+	"github.com/MAPiryazev/OzonTest/internal/auth"
+	"github.com/MAPiryazev/OzonTest/internal/config"
+	"github.com/MAPiryazev/OzonTest/internal/customerrors"
+	"github.com/MAPiryazev/OzonTest/internal/models"
+	"github.com/MAPiryazev/OzonTest/internal/policy"
+	"github.com/MAPiryazev/OzonTest/internal/repository/mocks"
+	"github.com/MAPiryazev/OzonTest/internal/service"
+	"github.com/golang/mock/gomock"
+)
+
+func TestPolicy_Authorize(t *testing.T) {
+	user := &auth.Identity{UserID: "u1", Role: models.RoleUser}
+	moderator := &auth.Identity{UserID: "m1", Role: models.RoleModerator}
+	admin := &auth.Identity{UserID: "a1", Role: models.RoleAdmin}
+
+	cases := []struct {
+		name    string
+		actor   *auth.Identity
+		action  policy.Action
+		ownerID string
+		want    error
+	}{
+		{"аноним не создает посты", nil, policy.ActionCreatePost, "", customerrors.ErrUnauthorized},
+		{"пользователь создает пост", user, policy.ActionCreatePost, "", nil},
+		{"автор правит свой пост", user, policy.ActionUpdatePost, "u1", nil},
+		{"пользователь не правит чужой пост", user, policy.ActionUpdatePost, "other", customerrors.ErrForbidden},
+		{"модератор правит чужой пост", moderator, policy.ActionUpdatePost, "other", nil},
+		{"модератор выключает комментарии", moderator, policy.ActionSetCommentsEnabled, "other", nil},
+		{"модератор не меняет роли", moderator, policy.ActionManageRoles, "", customerrors.ErrForbidden},
+		{"админ меняет роли", admin, policy.ActionManageRoles, "", nil},
+		{"админ правит чужой пост", admin, policy.ActionUpdatePost, "other", nil},
+		{"автор правит свой комментарий", user, policy.ActionUpdateComment, "u1", nil},
+		{"пользователь не правит чужой комментарий", user, policy.ActionUpdateComment, "other", customerrors.ErrForbidden},
+		{"модератор правит чужой комментарий", moderator, policy.ActionUpdateComment, "other", nil},
+	}
+	for _, tc := range cases {
+		err := policy.Authorize(tc.actor, tc.action, tc.ownerID)
+		if tc.want == nil && err != nil {
+			t.Fatalf("%s: ожидался доступ, получено %v", tc.name, err)
+		}
+		if tc.want != nil && !errors.Is(err, tc.want) {
+			t.Fatalf("%s: ожидалась ошибка %v, получено %v", tc.name, tc.want, err)
+		}
+	}
+}
+
+func TestGraph_HasRoleDirective(t *testing.T) {
+	next := func(ctx context.Context) (any, error) { return "ok", nil }
+
+	ctx := auth.WithIdentity(context.Background(), &auth.Identity{UserID: "m1", Role: models.RoleModerator})
+	if _, err := graph.HasRole(ctx, nil, next, model.RoleAdmin); !errors.Is(err, customerrors.ErrForbidden) {
+		t.Fatalf("модератор не должен проходить @hasRole(ADMIN), получено %v", err)
+	}
+	if res, err := graph.HasRole(ctx, nil, next, model.RoleModerator); err != nil || res != "ok" {
+		t.Fatalf("модератор должен проходить @hasRole(MODERATOR): %v", err)
+	}
+	if _, err := graph.HasRole(context.Background(), nil, next, model.RoleUser); !errors.Is(err, customerrors.ErrUnauthorized) {
+		t.Fatalf("аноним не должен проходить @hasRole, получено %v", err)
+	}
+}
+
+func TestService_SetUserRole(t *testing.T) {
+	controller := gomock.NewController(t)
+	defer controller.Finish()
+
+	mockForRepository := mocks.NewMockStorage(controller)
+	svc := service.NewService(mockForRepository, &config.AppConfig{}, nil)
+	admin := auth.WithIdentity(context.Background(), &auth.Identity{UserID: "a1", Role: models.RoleAdmin})
+	moderator := auth.WithIdentity(context.Background(), &auth.Identity{UserID: "m1", Role: models.RoleModerator})
+
+	// роль из токена перепроверяется по хранилищу
+	mockForRepository.EXPECT().GetUserByID(admin, "a1").Return(&models.User{ID: "a1", Username: "root", Role: models.RoleAdmin}, nil).AnyTimes()
+	mockForRepository.EXPECT().SetUserRole(admin, "u1", models.RoleModerator).Return(nil)
+	mockForRepository.EXPECT().GetUserByID(admin, "u1").Return(&models.User{ID: "u1", Username: "vasya", Role: models.RoleModerator}, nil)
+
+	user, err := svc.SetUserRole(admin, "u1", models.RoleModerator)
+	if err != nil || user.Role != models.RoleModerator {
+		t.Fatalf("админ должен менять роли: %v, %+v", err, user)
+	}
+	if _, err := svc.SetUserRole(moderator, "u1", models.RoleAdmin); !errors.Is(err, customerrors.ErrForbidden) {
+		t.Fatalf("модератор не должен менять роли, получено %v", err)
+	}
+	if _, err := svc.SetUserRole(admin, "a1", models.RoleUser); !errors.Is(err, customerrors.ErrForbidden) {
+		t.Fatalf("админ не должен менять свою роль, получено %v", err)
+	}
+	if _, err := svc.SetUserRole(admin, "u1", "root"); !errors.Is(err, customerrors.ErrValidation) {
+		t.Fatalf("ожидалась ошибка неизвестной роли, получено %v", err)
+	}
+
+	// пониженный администратор теряет права сразу, хотя в его токене роль прежняя
+	demoted := auth.WithIdentity(context.Background(), &auth.Identity{UserID: "a2", Role: models.RoleAdmin})
+	mockForRepository.EXPECT().GetUserByID(demoted, "a2").Return(&models.User{ID: "a2", Username: "former", Role: models.RoleUser}, nil).Times(2)
+	if _, err := svc.SetUserRole(demoted, "u1", models.RoleAdmin); !errors.Is(err, customerrors.ErrForbidden) {
+		t.Fatalf("пониженный администратор не должен менять роли, получено %v", err)
+	}
+	if err := svc.DeletePost(demoted, "p1", true); !errors.Is(err, customerrors.ErrForbidden) {
+		t.Fatalf("пониженный администратор не должен удалять физически, получено %v", err)
+	}
+
+	// удаленный пользователь со старым токеном
+	gone := auth.WithIdentity(context.Background(), &auth.Identity{UserID: "a3", Role: models.RoleAdmin})
+	mockForRepository.EXPECT().GetUserByID(gone, "a3").Return(nil, customerrors.ErrNotFound)
+	if _, err := svc.SetUserRole(gone, "u1", models.RoleAdmin); !errors.Is(err, customerrors.ErrUnauthorized) {
+		t.Fatalf("удаленный пользователь не должен менять роли, получено %v", err)
+	}
+}
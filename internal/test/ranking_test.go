@@ -0,0 +1,32 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/MAPiryazev/OzonTest/internal/ranking"
+)
+
+func TestRanking_WilsonLowerBound(t *testing.T) {
+	if got := ranking.WilsonLowerBound(0, 0); got != 0 {
+		t.Fatalf("без голосов ранг должен быть 0, получено %v", got)
+	}
+	// один голос из одного надежнее не делает, чем 90 из 100
+	if ranking.WilsonLowerBound(1, 0) >= ranking.WilsonLowerBound(90, 10) {
+		t.Fatalf("ожидалось wilson(1, 0) < wilson(90, 10)")
+	}
+	if ranking.WilsonLowerBound(10, 0) <= ranking.WilsonLowerBound(10, 5) {
+		t.Fatalf("голоса против должны понижать ранг")
+	}
+}
+
+func TestRanking_Controversy(t *testing.T) {
+	if got := ranking.Controversy(5, 0); got != 0 {
+		t.Fatalf("без голосов против спорность должна быть 0, получено %v", got)
+	}
+	if ranking.Controversy(5, 5) <= ranking.Controversy(9, 1) {
+		t.Fatalf("равное соотношение голосов должно быть спорнее")
+	}
+	if ranking.Controversy(50, 50) <= ranking.Controversy(5, 5) {
+		t.Fatalf("больше голосов при том же соотношении должно быть спорнее")
+	}
+}
@@ -0,0 +1,108 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/MAPiryazev/OzonTest/graph"
+	"github.com/MAPiryazev/OzonTest/graph/model"
+	"github.com/MAPiryazev/OzonTest/internal/config"
+	"github.com/MAPiryazev/OzonTest/internal/customerrors"
+	"github.com/MAPiryazev/OzonTest/internal/handler"
+	"github.com/MAPiryazev/OzonTest/internal/loaders"
+	"github.com/MAPiryazev/OzonTest/internal/models"
+	"github.com/MAPiryazev/OzonTest/internal/repository/inmemory"
+	"github.com/MAPiryazev/OzonTest/internal/service"
+)
+
+// пост с корневым комментарием c1 и двумя ответами на него
+func newCommentsResolver(t *testing.T) (*graph.Resolver, *handler.Handler) {
+	t.Helper()
+	strg := inmemory.NewMemoryStorage()
+	ctx := context.Background()
+
+	if err := strg.CreateUser(ctx, &models.User{ID: "u1", Username: "vasya"}); err != nil {
+		t.Fatalf("не удалось создать пользователя: %v", err)
+	}
+	if err := strg.CreatePost(ctx, &models.Post{ID: "p1", Title: "t", Content: "c", AuthorID: "u1", CommentsEnabled: true}); err != nil {
+		t.Fatalf("не удалось создать пост: %v", err)
+	}
+	parentID := "c1"
+	for _, c := range []*models.Comment{
+		{ID: "c1", PostID: "p1", AuthorID: "u1", Text: "корень"},
+		{ID: "r1", PostID: "p1", ParentID: &parentID, AuthorID: "u1", Text: "первый ответ"},
+		{ID: "r2", PostID: "p1", ParentID: &parentID, AuthorID: "u1", Text: "второй ответ"},
+	} {
+		if err := strg.CreateComment(ctx, c); err != nil {
+			t.Fatalf("не удалось создать комментарий: %v", err)
+		}
+	}
+
+	h := handler.NewHandler(service.NewService(strg, &config.AppConfig{MaxListLimit: 10}, nil))
+	return &graph.Resolver{Handler: h}, h
+}
+
+func commentIDs(comments []*model.Comment) []string {
+	ids := make([]string, len(comments))
+	for i, c := range comments {
+		ids[i] = c.ID
+	}
+	return ids
+}
+
+func TestGraph_PostComments(t *testing.T) {
+	resolver, _ := newCommentsResolver(t)
+	ctx := context.Background()
+	post := &model.Post{ID: "p1"}
+
+	for _, limit := range []int32{0, 11} {
+		if _, err := resolver.Post().Comments(ctx, post, nil, 0, limit, nil); !errors.Is(err, customerrors.ErrParamOutOfRange) {
+			t.Fatalf("limit %d: ожидалась ErrParamOutOfRange, получено %v", limit, err)
+		}
+	}
+
+	roots, err := resolver.Post().Comments(ctx, post, nil, 0, 10, nil)
+	if err != nil || len(roots) != 1 || roots[0].ID != "c1" || roots[0].Text != "корень" {
+		t.Fatalf("ожидался корневой комментарий c1: %v, %+v", err, commentIDs(roots))
+	}
+	parentID := "c1"
+	replies, err := resolver.Post().Comments(ctx, post, &parentID, 0, 10, nil)
+	if ids := commentIDs(replies); err != nil || len(ids) != 2 || ids[0] != "r1" || ids[1] != "r2" {
+		t.Fatalf("ожидались ответы r1, r2: %v, %v", err, ids)
+	}
+}
+
+func TestGraph_CommentReplies(t *testing.T) {
+	resolver, h := newCommentsResolver(t)
+	root := &model.Comment{ID: "c1", PostID: "p1"}
+	newest := model.CommentSortNew
+
+	check := func(t *testing.T, ctx context.Context) {
+		t.Helper()
+		if _, err := resolver.Comment().Replies(ctx, root, 0, 11, nil); !errors.Is(err, customerrors.ErrParamOutOfRange) {
+			t.Fatalf("ожидалась ErrParamOutOfRange, получено %v", err)
+		}
+		replies, err := resolver.Comment().Replies(ctx, root, 0, 10, nil)
+		if ids := commentIDs(replies); err != nil || len(ids) != 2 || ids[0] != "r1" || ids[1] != "r2" {
+			t.Fatalf("ожидались ответы r1, r2: %v, %v", err, ids)
+		}
+		replies, err = resolver.Comment().Replies(ctx, root, 1, 10, &newest)
+		if ids := commentIDs(replies); err != nil || len(ids) != 1 || ids[0] != "r1" {
+			t.Fatalf("со смещением 1 от новых к старым ожидался r1: %v, %v", err, ids)
+		}
+	}
+
+	// без загрузчиков ответы читаются через ListComments, с ними - пачкой через ListRepliesByParentIDs
+	t.Run("без загрузчиков", func(t *testing.T) { check(t, context.Background()) })
+	t.Run("с загрузчиками", func(t *testing.T) {
+		loaders.NewExtension(h).InterceptResponse(context.Background(), func(ctx context.Context) *graphql.Response {
+			if loaders.For(ctx) == nil {
+				t.Fatal("расширение должно положить загрузчики в контекст")
+			}
+			check(t, ctx)
+			return nil
+		})
+	})
+}
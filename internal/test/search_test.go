@@ -0,0 +1,188 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MAPiryazev/OzonTest/internal/config"
+	"github.com/MAPiryazev/OzonTest/internal/customerrors"
+	"github.com/MAPiryazev/OzonTest/internal/models"
+	"github.com/MAPiryazev/OzonTest/internal/repository/inmemory"
+	"github.com/MAPiryazev/OzonTest/internal/repository/mocks"
+	"github.com/MAPiryazev/OzonTest/internal/search"
+	"github.com/MAPiryazev/OzonTest/internal/service"
+	"github.com/golang/mock/gomock"
+)
+
+func TestSearch_Terms(t *testing.T) {
+	for _, forms := range [][]string{
+		{"пост", "поста", "постами", "постов"},
+		{"комментарий", "комментарии", "комментариев", "комментарием"},
+	} {
+		for _, form := range forms[1:] {
+			if search.Stem(form) != search.Stem(forms[0]) {
+				t.Fatalf("формы %s и %s должны давать один стем: %s, %s", forms[0], form, search.Stem(forms[0]), search.Stem(form))
+			}
+		}
+	}
+	if terms := search.QueryTerms("Кот и кот, на КОТЕ"); len(terms) != 1 {
+		t.Fatalf("ожидался один терм без стоп-слов, получено %v", terms)
+	}
+
+	snippet := search.Snippet("Длинный рассказ про котов и собак", search.QueryTerms("кот"), 3)
+	if snippet != "… про <b>котов</b> и …" {
+		t.Fatalf("неожиданный фрагмент %q", snippet)
+	}
+
+	// текст пользователя экранируется, разметкой остается только подсветка
+	snippet = search.Snippet(`<img src=x onerror="alert(1)"> кот<b>`, search.QueryTerms("кот"), 10)
+	if snippet != `&lt;img src=x onerror=&#34;alert(1)&#34;&gt; <b>кот&lt;b&gt;</b>` {
+		t.Fatalf("неожиданный фрагмент %q", snippet)
+	}
+	highlighted := search.EscapeHighlighted("<i>" + search.RawStart + "кот" + search.RawStop + " & пес")
+	if highlighted != "&lt;i&gt;<b>кот</b> &amp; пес" {
+		t.Fatalf("неожиданный фрагмент %q", highlighted)
+	}
+}
+
+func TestMemoryStorage_Search(t *testing.T) {
+	strg := inmemory.NewMemoryStorage()
+	ctx := context.Background()
+
+	for _, p := range []*models.Post{
+		{ID: "p1", Title: "Рецепт борща", Content: "Свекла, капуста и немного терпения"},
+		{ID: "p2", Title: "Заметки", Content: "Сегодня варил борщ по рецепту бабушки, вышло вкусно"},
+		{ID: "p3", Title: "Про котов", Content: "Коты спят весь день"},
+	} {
+		if err := strg.CreatePost(ctx, p); err != nil {
+			t.Fatalf("не удалось создать пост: %v", err)
+		}
+	}
+	if err := strg.CreateComment(ctx, &models.Comment{ID: "c1", PostID: "p3", Text: "А мой кот любит борщ"}); err != nil {
+		t.Fatalf("не удалось создать комментарий: %v", err)
+	}
+	if err := strg.CreateComment(ctx, &models.Comment{ID: "c2", PostID: "p1", Text: "<script>alert(1)</script> свекла"}); err != nil {
+		t.Fatalf("не удалось создать комментарий: %v", err)
+	}
+	hits, err := strg.Search(ctx, "свекла", models.SearchComments, 0, 10)
+	if err != nil || len(hits) != 1 || hits[0].Snippet != "&lt;script&gt;alert(1)&lt;/script&gt; <b>свекла</b>" {
+		t.Fatalf("ожидался экранированный фрагмент c2: %v, %+v", err, hits)
+	}
+
+	hits, err = strg.Search(ctx, "борщи", models.SearchAll, 0, 10)
+	if err != nil || len(hits) != 3 {
+		t.Fatalf("ожидались три результата: %v, %d", err, len(hits))
+	}
+	// совпадение в заголовке весит больше
+	if hits[0].Post == nil || hits[0].Post.ID != "p1" || !strings.Contains(hits[0].Snippet, "<b>борща</b>") {
+		t.Fatalf("первым ожидался p1 с подсветкой: %+v", hits[0])
+	}
+
+	hits, err = strg.Search(ctx, "борщ рецепт", models.SearchPosts, 0, 10)
+	if err != nil || len(hits) != 2 {
+		t.Fatalf("ожидались посты с обоими словами: %v, %d", err, len(hits))
+	}
+
+	// правка текста переиндексирует пост, удаленный пост и его комментарии не находятся
+	if err := strg.UpdatePost(ctx, &models.Post{ID: "p2", Title: "Заметки", Content: "Ничего интересного"}, nil); err != nil {
+		t.Fatalf("не удалось обновить пост: %v", err)
+	}
+	if err := strg.SoftDeletePost(ctx, "p3", time.Now()); err != nil {
+		t.Fatalf("не удалось удалить пост: %v", err)
+	}
+	hits, err = strg.Search(ctx, "борщ", models.SearchAll, 0, 10)
+	if err != nil || len(hits) != 1 || hits[0].Post.ID != "p1" {
+		t.Fatalf("ожидался только p1: %v, %+v", err, hits)
+	}
+}
+
+func TestSQLiteStorage_Search(t *testing.T) {
+	strg := newSQLiteStorage(t)
+	ctx := context.Background()
+
+	if err := strg.CreateUser(ctx, &models.User{ID: "u1", Username: "vasya"}); err != nil {
+		t.Fatalf("не удалось создать пользователя: %v", err)
+	}
+	for _, p := range []*models.Post{
+		{ID: "p1", Title: "Рецепт борща", Content: "Свекла, капуста и немного терпения", AuthorID: "u1", CommentsEnabled: true},
+		{ID: "p2", Title: "Заметки", Content: "Сегодня варил борщ по рецепту бабушки", AuthorID: "u1", CommentsEnabled: true},
+	} {
+		if err := strg.CreatePost(ctx, p); err != nil {
+			t.Fatalf("не удалось создать пост: %v", err)
+		}
+	}
+	if err := strg.CreateComment(ctx, &models.Comment{ID: "c1", PostID: "p2", AuthorID: "u1", Text: "Борщи бывают разные"}); err != nil {
+		t.Fatalf("не удалось создать комментарий: %v", err)
+	}
+	if err := strg.CreateComment(ctx, &models.Comment{ID: "c2", PostID: "p1", AuthorID: "u1", Text: "<img src=x onerror=alert(1)> капуста"}); err != nil {
+		t.Fatalf("не удалось создать комментарий: %v", err)
+	}
+	hits, err := strg.Search(ctx, "капуста", models.SearchComments, 0, 10)
+	if err != nil || len(hits) != 1 || hits[0].Snippet != "&lt;img src=x onerror=alert(1)&gt; <b>капуста</b>" {
+		t.Fatalf("ожидался экранированный фрагмент c2: %v, %+v", err, hits)
+	}
+
+	hits, err = strg.Search(ctx, "борщами", models.SearchAll, 0, 10)
+	if err != nil || len(hits) != 3 {
+		t.Fatalf("ожидались три результата: %v, %d", err, len(hits))
+	}
+	hits, err = strg.Search(ctx, "борщ", models.SearchComments, 0, 10)
+	if err != nil || len(hits) != 1 || hits[0].Comment == nil || hits[0].Comment.ID != "c1" || !strings.Contains(hits[0].Snippet, "<b>Борщи</b>") {
+		t.Fatalf("ожидался комментарий c1 с подсветкой: %v, %+v", err, hits)
+	}
+	// объект результата приходит полностью из того же запроса
+	if c := hits[0].Comment; c.PostID != "p2" || c.AuthorID != "u1" || c.Text != "Борщи бывают разные" || c.CreatedAt.IsZero() {
+		t.Fatalf("неполный комментарий в результате: %+v", c)
+	}
+
+	if err := strg.UpdateComment(ctx, &models.Comment{ID: "c1", Text: "Щи тоже хороши"}, nil); err != nil {
+		t.Fatalf("не удалось изменить комментарий: %v", err)
+	}
+	if err := strg.SoftDeletePost(ctx, "p2", time.Now()); err != nil {
+		t.Fatalf("не удалось удалить пост: %v", err)
+	}
+	hits, err = strg.Search(ctx, "борщ", models.SearchAll, 0, 10)
+	if err != nil || len(hits) != 1 || hits[0].Post == nil || hits[0].Post.ID != "p1" {
+		t.Fatalf("ожидался только p1: %v, %+v", err, hits)
+	}
+	if hits, err := strg.Search(ctx, "и на", models.SearchAll, 0, 10); err != nil || len(hits) != 0 {
+		t.Fatalf("запрос из стоп-слов не должен ничего находить: %v, %d", err, len(hits))
+	}
+}
+
+func TestService_SearchPagination(t *testing.T) {
+	controller := gomock.NewController(t)
+	defer controller.Finish()
+
+	mockForRepository := mocks.NewMockStorage(controller)
+	svc := service.NewService(mockForRepository, &config.AppConfig{MaxListLimit: 10, CursorSecret: "secret"}, nil)
+	ctx := context.Background()
+
+	if _, err := svc.Search(ctx, "   ", models.SearchAll, 1, nil); !errors.Is(err, customerrors.ErrValidation) {
+		t.Fatalf("ожидалась ошибка пустого запроса, получено %v", err)
+	}
+
+	hits := []*models.SearchHit{
+		{Type: models.SearchPosts, Post: &models.Post{ID: "p1"}},
+		{Type: models.SearchComments, Comment: &models.Comment{ID: "c1"}},
+	}
+	mockForRepository.EXPECT().Search(ctx, "борщ", models.SearchAll, 0, 2).Return(hits, nil)
+	conn, err := svc.Search(ctx, " борщ ", "", 1, nil)
+	if err != nil || len(conn.Edges) != 1 || !conn.PageInfo.HasNextPage {
+		t.Fatalf("ожидалась одна запись и следующая страница: %v, %+v", err, conn)
+	}
+
+	mockForRepository.EXPECT().Search(ctx, "борщ", models.SearchAll, 1, 2).Return(hits[1:], nil)
+	conn, err = svc.Search(ctx, "борщ", models.SearchAll, 1, conn.PageInfo.EndCursor)
+	if err != nil || len(conn.Edges) != 1 || conn.PageInfo.HasNextPage || conn.Edges[0].Node.Comment.ID != "c1" {
+		t.Fatalf("ожидалась последняя страница с c1: %v, %+v", err, conn)
+	}
+
+	// курсор другого запроса не подходит
+	if _, err := svc.Search(ctx, "щи", models.SearchAll, 1, conn.PageInfo.EndCursor); !errors.Is(err, customerrors.ErrInvalidCursor) {
+		t.Fatalf("ожидалась ошибка курсора, получено %v", err)
+	}
+}
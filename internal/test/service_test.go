@@ -2,33 +2,55 @@ package test
 
 import (
 	"context"
+	"errors"
 	"testing"
+	"time"
 
+	"github.com/MAPiryazev/OzonTest/internal/auth"
 	"github.com/MAPiryazev/OzonTest/internal/config"
+	"github.com/MAPiryazev/OzonTest/internal/customerrors"
 	"github.com/MAPiryazev/OzonTest/internal/models"
+	"github.com/MAPiryazev/OzonTest/internal/repository"
 	"github.com/MAPiryazev/OzonTest/internal/repository/mocks"
 	"github.com/MAPiryazev/OzonTest/internal/service"
 	"github.com/golang/mock/gomock"
 	"github.com/google/uuid"
 )
 
-func TestService_CreateUser(t *testing.T) {
+// транзакция в моке выполняет функцию на том же моке
+func expectTx(mockForRepository *mocks.MockStorage) {
+	mockForRepository.EXPECT().WithTx(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, fn func(tx repository.Storage) error) error {
+			return fn(mockForRepository)
+		}).AnyTimes()
+}
+
+func TestService_Register(t *testing.T) {
 	controller := gomock.NewController(t)
 	defer controller.Finish()
 
 	mockForRepository := mocks.NewMockStorage(controller)
-	cfg := &config.AppConfig{MinUsernameLen: 3}
-	svc := service.NewService(mockForRepository, cfg)
-
+	cfg := &config.AppConfig{MinUsernameLen: 3, MinPasswordLen: 8}
+	svc := service.NewService(mockForRepository, cfg, newTokenManager(t, "secret", 5))
 	ctx := context.Background()
-	user := &models.User{Username: "Ivan"}
-	mockForRepository.EXPECT().CreateUser(ctx, gomock.Any()).Return(nil)
 
-	if err := svc.CreateUser(ctx, user); err != nil {
-		t.Fatalf("не удалось создать пользователя: %v", err)
+	if _, err := svc.Register(ctx, "Ivan", "short"); !errors.Is(err, customerrors.ErrValidation) {
+		t.Fatalf("ожидалась ошибка короткого пароля, получено %v", err)
+	}
+
+	// аккаунт всегда создается с паролем и обычной ролью
+	mockForRepository.EXPECT().CreateUser(ctx, gomock.Any()).DoAndReturn(func(_ context.Context, u *models.User) error {
+		if u.ID == "" || u.PasswordHash == "" || u.Role != models.RoleUser {
+			t.Errorf("неожиданный пользователь: %+v", u)
+		}
+		return nil
+	})
+	payload, err := svc.Register(ctx, " Ivan ", "password1")
+	if err != nil {
+		t.Fatalf("не удалось зарегистрироваться: %v", err)
 	}
-	if user.ID == "" {
-		t.Fatal("у пользователя должен быть ID")
+	if payload.Token == "" || payload.User.Username != "Ivan" {
+		t.Fatalf("неожиданный ответ регистрации: %+v", payload)
 	}
 }
 
@@ -37,7 +59,7 @@ func TestService_GetUserByID(t *testing.T) {
 	defer controller.Finish()
 
 	mockForRepository := mocks.NewMockStorage(controller)
-	svc := service.NewService(mockForRepository, &config.AppConfig{})
+	svc := service.NewService(mockForRepository, &config.AppConfig{}, nil)
 	ctx := context.Background()
 	expectedUser := &models.User{ID: "123", Username: "Vanya"}
 	mockForRepository.EXPECT().GetUserByID(ctx, "123").Return(expectedUser, nil)
@@ -50,19 +72,45 @@ func TestService_GetUserByID(t *testing.T) {
 	}
 }
 
+func TestService_GetUserByUsername(t *testing.T) {
+	controller := gomock.NewController(t)
+	defer controller.Finish()
+
+	mockForRepository := mocks.NewMockStorage(controller)
+	svc := service.NewService(mockForRepository, &config.AppConfig{}, nil)
+	ctx := context.Background()
+	locked := time.Now().Add(time.Hour)
+	stored := &models.User{ID: "123", Username: "Vanya", Role: models.RoleModerator, PasswordHash: "hash", FailedLogins: 3, LockedUntil: &locked}
+	mockForRepository.EXPECT().GetUserByUsername(ctx, "Vanya").Return(stored, nil)
+
+	u, err := svc.GetUserByUsername(ctx, " Vanya ")
+	if err != nil {
+		t.Fatalf("не удалось получить пользователя: %v", err)
+	}
+	if u.ID != "123" || u.Role != models.RoleModerator {
+		t.Fatalf("неожиданный пользователь %+v", u)
+	}
+	if u.PasswordHash != "" || u.FailedLogins != 0 || u.LockedUntil != nil {
+		t.Fatalf("учетные данные не должны возвращаться: %+v", u)
+	}
+
+	if _, err := svc.GetUserByUsername(ctx, "  "); !errors.Is(err, customerrors.ErrValidation) {
+		t.Fatalf("ожидалась ошибка валидации, получено %v", err)
+	}
+}
+
 func TestService_CreatePost(t *testing.T) {
 	controller := gomock.NewController(t)
 	defer controller.Finish()
 
 	mockForRepository := mocks.NewMockStorage(controller)
 	cfg := &config.AppConfig{MinUsernameLen: 3}
-	svc := service.NewService(mockForRepository, cfg)
-	ctx := context.Background()
+	svc := service.NewService(mockForRepository, cfg, nil)
 	authorID := uuid.NewString()
+	ctx := auth.WithIdentity(context.Background(), &auth.Identity{UserID: authorID, Username: "Ivan"})
 	post := &models.Post{
-		Title:    "Тестовый пост",
-		Content:  " контент",
-		AuthorID: authorID,
+		Title:   "Тестовый пост",
+		Content: " контент",
 	}
 	// смотрим что автор есть
 	mockForRepository.EXPECT().GetUserByID(ctx, authorID).Return(&models.User{ID: authorID, Username: "Ivan"}, nil)
@@ -76,6 +124,84 @@ func TestService_CreatePost(t *testing.T) {
 	if post.CreatedAt.IsZero() {
 		t.Fatal("у поста должно быть время создания")
 	}
+	if post.AuthorID != authorID {
+		t.Fatalf("автором должен стать пользователь из токена, получено %s", post.AuthorID)
+	}
+
+	// без пользователя в контексте создавать посты нельзя
+	if err := svc.CreatePost(context.Background(), &models.Post{Title: "t", Content: "c"}); !errors.Is(err, customerrors.ErrUnauthorized) {
+		t.Fatalf("ожидалась ошибка авторизации, получено %v", err)
+	}
+}
+
+func TestService_UpdatePostForeign(t *testing.T) {
+	controller := gomock.NewController(t)
+	defer controller.Finish()
+
+	mockForRepository := mocks.NewMockStorage(controller)
+	expectTx(mockForRepository)
+	svc := service.NewService(mockForRepository, &config.AppConfig{}, nil)
+	ctx := auth.WithIdentity(context.Background(), &auth.Identity{UserID: "intruder"})
+	mockForRepository.EXPECT().GetPostByID(ctx, "p1").Return(&models.Post{ID: "p1", AuthorID: "owner"}, nil)
+
+	title := "чужой"
+	_, err := svc.UpdatePost(ctx, "p1", models.PostPatch{Title: &title})
+	if !errors.Is(err, customerrors.ErrForbidden) {
+		t.Fatalf("ожидался запрет редактирования чужого поста, получено %v", err)
+	}
+
+	// модератор может править любой пост
+	modCtx := auth.WithIdentity(context.Background(), &auth.Identity{UserID: "mod", Role: models.RoleModerator})
+	title = "модерация"
+	mockForRepository.EXPECT().GetPostByID(modCtx, "p1").Return(&models.Post{ID: "p1", AuthorID: "owner"}, nil)
+	mockForRepository.EXPECT().GetUserByID(modCtx, "mod").Return(&models.User{ID: "mod", Role: models.RoleModerator}, nil)
+	mockForRepository.EXPECT().UpdatePost(modCtx, gomock.Any(), gomock.Any()).Return(nil)
+	if _, err := svc.UpdatePost(modCtx, "p1", models.PostPatch{Title: &title}); err != nil {
+		t.Fatalf("модератор должен редактировать чужой пост: %v", err)
+	}
+}
+
+func TestService_UpdatePostPartial(t *testing.T) {
+	controller := gomock.NewController(t)
+	defer controller.Finish()
+
+	mockForRepository := mocks.NewMockStorage(controller)
+	expectTx(mockForRepository)
+	svc := service.NewService(mockForRepository, &config.AppConfig{}, nil)
+	ctx := auth.WithIdentity(context.Background(), &auth.Identity{UserID: "owner"})
+	current := &models.Post{ID: "p1", Title: "старый", Content: "текст", AuthorID: "owner", CommentsEnabled: true}
+
+	// незаданные поля, в том числе commentsEnabled, не должны сбрасываться, а старый заголовок уходит в ревизию
+	title := "новый"
+	mockForRepository.EXPECT().GetPostByID(ctx, "p1").Return(current, nil)
+	mockForRepository.EXPECT().UpdatePost(ctx, gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, post *models.Post, revision *models.PostRevision) error {
+			if post.Title != "новый" || post.Content != "текст" || !post.CommentsEnabled || post.EditedAt == nil {
+				t.Fatalf("неожиданное состояние поста: %+v", post)
+			}
+			if revision == nil || revision.Title != "старый" || revision.EditorID != "owner" {
+				t.Fatalf("ожидалась ревизия со старым заголовком, получено %+v", revision)
+			}
+			return nil
+		})
+	if _, err := svc.UpdatePost(ctx, "p1", models.PostPatch{Title: &title}); err != nil {
+		t.Fatalf("не удалось обновить пост: %v", err)
+	}
+
+	// смена commentsEnabled не создает ревизию
+	mockForRepository.EXPECT().GetPostByID(ctx, "p1").Return(current, nil)
+	mockForRepository.EXPECT().UpdatePost(ctx, &models.Post{ID: "p1", Title: "старый", Content: "текст", AuthorID: "owner", CommentsEnabled: false}, nil).Return(nil)
+	post, err := svc.SetCommentsEnabled(ctx, "p1", false)
+	if err != nil {
+		t.Fatalf("не удалось выключить комментарии: %v", err)
+	}
+	if post.CommentsEnabled || !current.CommentsEnabled {
+		t.Fatalf("комментарии должны выключиться только в новой версии поста")
+	}
+
+	if _, err := svc.UpdatePost(ctx, "p1", models.PostPatch{}); !errors.Is(err, customerrors.ErrValidation) {
+		t.Fatalf("ожидалась ошибка пустого обновления, получено %v", err)
+	}
 }
 
 func TestService_GetPostByID(t *testing.T) {
@@ -83,7 +209,7 @@ func TestService_GetPostByID(t *testing.T) {
 	defer controller.Finish()
 
 	mockForRepository := mocks.NewMockStorage(controller)
-	svc := service.NewService(mockForRepository, &config.AppConfig{})
+	svc := service.NewService(mockForRepository, &config.AppConfig{}, nil)
 	ctx := context.Background()
 	post := &models.Post{ID: "p1", Title: "Заголовок", Content: "текст"}
 	mockForRepository.EXPECT().GetPostByID(ctx, "p1").Return(post, nil)
@@ -103,14 +229,14 @@ func TestService_ListPosts(t *testing.T) {
 
 	mockForRepository := mocks.NewMockStorage(controller)
 	cfg := &config.AppConfig{MaxListLimit: 10}
-	svc := service.NewService(mockForRepository, cfg)
+	svc := service.NewService(mockForRepository, cfg, nil)
 	ctx := context.Background()
 	posts := []*models.Post{
 		{ID: "p1", Title: "T1", Content: "C1"},
 		{ID: "p2", Title: "T2", Content: "C2"}}
 
-	mockForRepository.EXPECT().ListPosts(ctx, 0, 2).Return(posts, nil)
-	list, err := svc.ListPosts(ctx, 0, 2)
+	mockForRepository.EXPECT().ListPosts(ctx, models.PostFilter{}, models.PostSortNewest, 0, 2).Return(posts, nil)
+	list, err := svc.ListPosts(ctx, models.PostFilter{}, "", 0, 2)
 	if err != nil {
 		t.Fatalf("не удалось получить список постов: %v", err)
 	}
@@ -119,20 +245,54 @@ func TestService_ListPosts(t *testing.T) {
 	}
 }
 
-func TestService_CreateComment(t *testing.T) {
+func TestService_ListPostsValidation(t *testing.T) {
 	controller := gomock.NewController(t)
 	defer controller.Finish()
 
 	mockForRepository := mocks.NewMockStorage(controller)
-	svc := service.NewService(mockForRepository, &config.AppConfig{})
+	svc := service.NewService(mockForRepository, &config.AppConfig{MaxListLimit: 10}, nil)
 	ctx := context.Background()
+
+	if _, err := svc.ListPosts(ctx, models.PostFilter{}, models.PostSortNewest, 0, 11); !errors.Is(err, customerrors.ErrParamOutOfRange) {
+		t.Fatalf("ожидалась ошибка лимита, получено %v", err)
+	}
+	if _, err := svc.ListPosts(ctx, models.PostFilter{}, "popular", 0, 5); !errors.Is(err, customerrors.ErrValidation) {
+		t.Fatalf("ожидалась ошибка неизвестной сортировки, получено %v", err)
+	}
+	after := time.Now()
+	before := after.Add(-time.Hour)
+	if _, err := svc.ListPosts(ctx, models.PostFilter{CreatedAfter: &after, CreatedBefore: &before}, "", 0, 5); !errors.Is(err, customerrors.ErrValidation) {
+		t.Fatalf("ожидалась ошибка пустого периода, получено %v", err)
+	}
+
+	author := " u1 "
+	mockForRepository.EXPECT().ListPosts(ctx, gomock.Any(), models.PostSortMostCommented, 0, 5).DoAndReturn(
+		func(_ context.Context, filter models.PostFilter, _ models.PostSort, _, _ int) ([]*models.Post, error) {
+			if *filter.AuthorID != "u1" {
+				t.Fatalf("id автора должен быть обрезан, получено %q", *filter.AuthorID)
+			}
+			return []*models.Post{}, nil
+		})
+	if _, err := svc.ListPosts(ctx, models.PostFilter{AuthorID: &author}, models.PostSortMostCommented, 0, 5); err != nil {
+		t.Fatalf("не удалось получить посты: %v", err)
+	}
+}
+
+func TestService_CreateComment(t *testing.T) {
+	controller := gomock.NewController(t)
+	defer controller.Finish()
+
+	mockForRepository := mocks.NewMockStorage(controller)
+	expectTx(mockForRepository)
+	svc := service.NewService(mockForRepository, &config.AppConfig{}, nil)
+	ctx := auth.WithIdentity(context.Background(), &auth.Identity{UserID: "user1"})
 	postID := uuid.NewString()
 	comment := &models.Comment{
 		PostID:   postID,
 		Text:     "Привет",
-		AuthorID: "user1",
+		AuthorID: "подмененный",
 	}
-	mockForRepository.EXPECT().GetPostByID(ctx, postID).Return(&models.Post{ID: postID, CommentsEnabled: true}, nil)
+	mockForRepository.EXPECT().GetPostByID(ctx, postID).Return(&models.Post{ID: postID, AuthorID: "user1", CommentsEnabled: true}, nil)
 	mockForRepository.EXPECT().CreateComment(ctx, gomock.Any()).Return(nil)
 	if err := svc.CreateComment(ctx, comment); err != nil {
 		t.Fatalf("не удалось создать комментарий: %v", err)
@@ -143,6 +303,9 @@ func TestService_CreateComment(t *testing.T) {
 	if comment.CreatedAt.IsZero() {
 		t.Fatal("у комментария должно быть  время создания")
 	}
+	if comment.AuthorID != "user1" {
+		t.Fatalf("автор комментария должен браться из контекста, получено %s", comment.AuthorID)
+	}
 }
 
 func TestService_GetCommentByID(t *testing.T) {
@@ -150,7 +313,7 @@ func TestService_GetCommentByID(t *testing.T) {
 	defer controller.Finish()
 
 	mockForRepository := mocks.NewMockStorage(controller)
-	svc := service.NewService(mockForRepository, &config.AppConfig{})
+	svc := service.NewService(mockForRepository, &config.AppConfig{}, nil)
 	ctx := context.Background()
 	comment := &models.Comment{ID: "c1", Text: "Привет"}
 	mockForRepository.EXPECT().GetCommentByID(ctx, "c1").Return(comment, nil)
@@ -163,3 +326,396 @@ func TestService_GetCommentByID(t *testing.T) {
 		t.Fatalf("ожидался текст 'Привет', получено '%s'", got.Text)
 	}
 }
+
+func TestService_DeleteComment(t *testing.T) {
+	controller := gomock.NewController(t)
+	defer controller.Finish()
+
+	mockForRepository := mocks.NewMockStorage(controller)
+	expectTx(mockForRepository)
+	svc := service.NewService(mockForRepository, &config.AppConfig{}, nil)
+
+	// чужой комментарий удалить нельзя, а физическое удаление только у администратора
+	ctx := auth.WithIdentity(context.Background(), &auth.Identity{UserID: "intruder"})
+	mockForRepository.EXPECT().GetCommentByID(ctx, "c1").Return(&models.Comment{ID: "c1", AuthorID: "owner"}, nil)
+	if err := svc.DeleteComment(ctx, "c1", false); !errors.Is(err, customerrors.ErrForbidden) {
+		t.Fatalf("ожидался запрет удаления чужого комментария, получено %v", err)
+	}
+	ownerCtx := auth.WithIdentity(context.Background(), &auth.Identity{UserID: "owner"})
+	if err := svc.DeleteComment(ownerCtx, "c1", true); !errors.Is(err, customerrors.ErrForbidden) {
+		t.Fatalf("автор не должен удалять физически, получено %v", err)
+	}
+
+	mockForRepository.EXPECT().GetCommentByID(ownerCtx, "c1").Return(&models.Comment{ID: "c1", AuthorID: "owner"}, nil)
+	mockForRepository.EXPECT().SoftDeleteComment(ownerCtx, "c1", gomock.Any()).Return(nil)
+	if err := svc.DeleteComment(ownerCtx, "c1", false); err != nil {
+		t.Fatalf("автор должен удалять свой комментарий: %v", err)
+	}
+
+	adminCtx := auth.WithIdentity(context.Background(), &auth.Identity{UserID: "admin", Role: models.RoleAdmin})
+	mockForRepository.EXPECT().GetUserByID(adminCtx, "admin").Return(&models.User{ID: "admin", Role: models.RoleAdmin}, nil)
+	mockForRepository.EXPECT().PurgeComment(adminCtx, "c1").Return(nil)
+	if err := svc.DeleteComment(adminCtx, "c1", true); err != nil {
+		t.Fatalf("администратор должен удалять физически: %v", err)
+	}
+}
+
+func TestService_UpdateComment(t *testing.T) {
+	controller := gomock.NewController(t)
+	defer controller.Finish()
+
+	mockForRepository := mocks.NewMockStorage(controller)
+	expectTx(mockForRepository)
+	svc := service.NewService(mockForRepository, &config.AppConfig{CommentEditWindowMin: 15}, nil)
+	ownerCtx := auth.WithIdentity(context.Background(), &auth.Identity{UserID: "owner"})
+	fresh := &models.Comment{ID: "c1", AuthorID: "owner", Text: "опечатка", CreatedAt: time.Now().UTC()}
+
+	mockForRepository.EXPECT().GetCommentByID(ownerCtx, "c1").Return(fresh, nil)
+	mockForRepository.EXPECT().UpdateComment(ownerCtx, gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, comment *models.Comment, revision *models.CommentRevision) error {
+			if comment.Text != "исправлено" || comment.EditedAt == nil || revision.Text != "опечатка" {
+				t.Fatalf("неожиданная правка: %+v, %+v", comment, revision)
+			}
+			return nil
+		})
+	if _, err := svc.UpdateComment(ownerCtx, "c1", "исправлено"); err != nil {
+		t.Fatalf("автор должен править свежий комментарий: %v", err)
+	}
+
+	// чужой комментарий пользователь не правит
+	otherCtx := auth.WithIdentity(context.Background(), &auth.Identity{UserID: "other"})
+	mockForRepository.EXPECT().GetCommentByID(otherCtx, "c1").Return(fresh, nil)
+	if _, err := svc.UpdateComment(otherCtx, "c1", "чужое"); !errors.Is(err, customerrors.ErrForbidden) {
+		t.Fatalf("ожидался запрет правки чужого комментария, получено %v", err)
+	}
+
+	old := &models.Comment{ID: "c2", AuthorID: "owner", Text: "давно", CreatedAt: time.Now().Add(-time.Hour)}
+	mockForRepository.EXPECT().GetCommentByID(ownerCtx, "c2").Return(old, nil)
+	if _, err := svc.UpdateComment(ownerCtx, "c2", "поздно"); !errors.Is(err, customerrors.ErrForbidden) {
+		t.Fatalf("ожидался запрет правки после окна редактирования, получено %v", err)
+	}
+
+	// модератор правит чужой комментарий и после окна редактирования, правка записывается от его имени
+	modCtx := auth.WithIdentity(context.Background(), &auth.Identity{UserID: "mod", Role: models.RoleModerator})
+	mockForRepository.EXPECT().GetCommentByID(modCtx, "c2").Return(old, nil)
+	mockForRepository.EXPECT().GetUserByID(modCtx, "mod").Return(&models.User{ID: "mod", Role: models.RoleModerator}, nil).AnyTimes()
+	mockForRepository.EXPECT().UpdateComment(modCtx, gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, comment *models.Comment, revision *models.CommentRevision) error {
+			if comment.Text != "модерация" || revision.EditorID != "mod" {
+				t.Fatalf("неожиданная правка модератора: %+v, %+v", comment, revision)
+			}
+			return nil
+		})
+	if _, err := svc.UpdateComment(modCtx, "c2", "модерация"); err != nil {
+		t.Fatalf("модератор должен править чужой комментарий: %v", err)
+	}
+}
+
+func TestService_DeletedCommentMasked(t *testing.T) {
+	controller := gomock.NewController(t)
+	defer controller.Finish()
+
+	mockForRepository := mocks.NewMockStorage(controller)
+	expectTx(mockForRepository)
+	svc := service.NewService(mockForRepository, &config.AppConfig{}, nil)
+	ctx := auth.WithIdentity(context.Background(), &auth.Identity{UserID: "user1"})
+	deletedAt := time.Now()
+	deleted := &models.Comment{ID: "c1", PostID: "p1", Text: "секрет", DeletedAt: &deletedAt}
+
+	mockForRepository.EXPECT().GetCommentByID(ctx, "c1").Return(deleted, nil).Times(2)
+	got, err := svc.GetCommentByID(ctx, "c1")
+	if err != nil {
+		t.Fatalf("не удалось получить комментарий: %v", err)
+	}
+	if got.Text != service.DeletedCommentText || deleted.Text != "секрет" {
+		t.Fatalf("текст удаленного комментария должен скрываться в копии, получено %q", got.Text)
+	}
+
+	// на удаленный комментарий нельзя ответить
+	parentID := "c1"
+	err = svc.CreateComment(ctx, &models.Comment{PostID: "p1", ParentID: &parentID, Text: "ответ"})
+	if !errors.Is(err, customerrors.ErrValidation) {
+		t.Fatalf("ожидалась ошибка ответа на удаленный комментарий, получено %v", err)
+	}
+}
+
+func TestService_SubscribeCommentAdded(t *testing.T) {
+	controller := gomock.NewController(t)
+	defer controller.Finish()
+
+	mockForRepository := mocks.NewMockStorage(controller)
+	svc := service.NewService(mockForRepository, &config.AppConfig{}, nil)
+	defer svc.Close()
+
+	ctx, cancel := context.WithCancel(auth.WithIdentity(context.Background(), &auth.Identity{UserID: "user1"}))
+	defer cancel()
+	postID := uuid.NewString()
+	otherPostID := uuid.NewString()
+	mockForRepository.EXPECT().GetPostByID(ctx, postID).Return(&models.Post{ID: postID, AuthorID: "user1", CommentsEnabled: true}, nil)
+
+	comments, err := svc.SubscribeCommentAdded(ctx, postID)
+	if err != nil {
+		t.Fatalf("не удалось подписаться: %v", err)
+	}
+
+	// комментарий к другому посту и правка не должны прийти подписчику
+	for i, ev := range []struct {
+		eventType models.EventType
+		comment   *models.Comment
+	}{
+		{models.EventCommentCreated, &models.Comment{ID: "c1", PostID: otherPostID, Text: "мимо"}},
+		{models.EventCommentUpdated, &models.Comment{ID: "c2", PostID: postID, Text: "правка"}},
+		{models.EventCommentCreated, &models.Comment{ID: "c3", PostID: postID, Text: "Привет"}},
+	} {
+		event, err := repository.NewOutboxEvent(ev.eventType, ev.comment.ID, ev.comment, time.Now())
+		if err != nil {
+			t.Fatalf("не удалось собрать событие: %v", err)
+		}
+		event.Seq = int64(i + 1)
+		if err := svc.PublishCommentEvent(ctx, event); err != nil {
+			t.Fatalf("не удалось обработать событие: %v", err)
+		}
+	}
+
+	select {
+	case got := <-comments:
+		if got.PostID != postID || got.ID != "c3" {
+			t.Fatalf("получен неожиданный комментарий %+v", got)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("комментарий не пришёл подписчику")
+	}
+
+	// после отмены контекста канал должен закрыться
+	cancel()
+	select {
+	case _, ok := <-comments:
+		if ok {
+			t.Fatal("ожидалось закрытие канала")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("канал не закрылся после отмены контекста")
+	}
+}
+
+func TestService_ListPostsConnection(t *testing.T) {
+	controller := gomock.NewController(t)
+	defer controller.Finish()
+
+	mockForRepository := mocks.NewMockStorage(controller)
+	svc := service.NewService(mockForRepository, &config.AppConfig{MaxListLimit: 10, CursorSecret: "secret"}, nil)
+	ctx := context.Background()
+	now := time.Now().UTC()
+	posts := []*models.Post{
+		{ID: "p3", CreatedAt: now},
+		{ID: "p2", CreatedAt: now.Add(-time.Minute)},
+		{ID: "p1", CreatedAt: now.Add(-2 * time.Minute)},
+	}
+
+	// просим 2, хранилище отдает 3 - значит есть следующая страница
+	mockForRepository.EXPECT().ListPostsAfter(ctx, nil, 3).Return(posts, nil)
+	conn, err := svc.ListPostsConnection(ctx, 2, nil)
+	if err != nil {
+		t.Fatalf("не удалось получить страницу постов: %v", err)
+	}
+	if len(conn.Edges) != 2 || !conn.PageInfo.HasNextPage {
+		t.Fatalf("ожидалось 2 поста и следующая страница, получено %d, %v", len(conn.Edges), conn.PageInfo.HasNextPage)
+	}
+
+	// курсор раскодируется в позицию последнего поста
+	mockForRepository.EXPECT().ListPostsAfter(ctx, &models.PageKey{CreatedAt: posts[1].CreatedAt, ID: "p2"}, 3).Return(posts[2:], nil)
+	conn, err = svc.ListPostsConnection(ctx, 2, conn.PageInfo.EndCursor)
+	if err != nil {
+		t.Fatalf("не удалось получить вторую страницу: %v", err)
+	}
+	if len(conn.Edges) != 1 || conn.PageInfo.HasNextPage || !conn.PageInfo.HasPreviousPage {
+		t.Fatalf("неожиданная вторая страница: %d постов, %+v", len(conn.Edges), conn.PageInfo)
+	}
+
+	// подделанный курсор отклоняется
+	forged := *conn.PageInfo.EndCursor + "x"
+	if _, err := svc.ListPostsConnection(ctx, 2, &forged); !errors.Is(err, customerrors.ErrInvalidCursor) {
+		t.Fatalf("ожидалась ошибка курсора, получено %v", err)
+	}
+}
+
+func TestService_GetCommentThread(t *testing.T) {
+	controller := gomock.NewController(t)
+	defer controller.Finish()
+
+	mockForRepository := mocks.NewMockStorage(controller)
+	svc := service.NewService(mockForRepository, &config.AppConfig{MaxThreadDepth: 5, MaxThreadNodes: 2}, nil)
+	ctx := context.Background()
+	postID := uuid.NewString()
+
+	// глубина больше лимита из конфига отклоняется без похода в хранилище
+	if _, err := svc.GetCommentThread(ctx, postID, nil, 6, 2); !errors.Is(err, customerrors.ErrParamOutOfRange) {
+		t.Fatalf("ожидалась ошибка ограничения глубины, получено %v", err)
+	}
+
+	items := []*models.ThreadComment{
+		{Comment: &models.Comment{ID: "c1", PostID: postID}, Depth: 0},
+		{Comment: &models.Comment{ID: "c2", PostID: postID}, Depth: 1},
+		{Comment: &models.Comment{ID: "c3", PostID: postID}, Depth: 2},
+	}
+	mockForRepository.EXPECT().GetPostByID(ctx, postID).Return(&models.Post{ID: postID}, nil)
+	mockForRepository.EXPECT().GetCommentThread(ctx, postID, nil, 5, 3).Return(items, nil)
+
+	thread, err := svc.GetCommentThread(ctx, postID, nil, 5, 2)
+	if err != nil {
+		t.Fatalf("не удалось получить дерево: %v", err)
+	}
+	if len(thread.Items) != 2 || !thread.Truncated {
+		t.Fatalf("ожидалось 2 узла и признак обрезки, получено %d, %v", len(thread.Items), thread.Truncated)
+	}
+}
+
+func TestService_SetReaction(t *testing.T) {
+	controller := gomock.NewController(t)
+	defer controller.Finish()
+
+	mockForRepository := mocks.NewMockStorage(controller)
+	expectTx(mockForRepository)
+	svc := service.NewService(mockForRepository, &config.AppConfig{}, nil)
+	ctx := auth.WithIdentity(context.Background(), &auth.Identity{UserID: "user1"})
+
+	if _, err := svc.SetReaction(ctx, models.ReactionTargetPost, "p1", "clown"); !errors.Is(err, customerrors.ErrValidation) {
+		t.Fatalf("ожидалась ошибка неизвестной реакции, получено %v", err)
+	}
+	if _, err := svc.SetReaction(context.Background(), models.ReactionTargetPost, "p1", models.ReactionUp); !errors.Is(err, customerrors.ErrUnauthorized) {
+		t.Fatalf("аноним не должен ставить реакции, получено %v", err)
+	}
+
+	deletedAt := time.Now()
+	mockForRepository.EXPECT().GetCommentByID(ctx, "c1").Return(&models.Comment{ID: "c1", PostID: "p1", DeletedAt: &deletedAt}, nil)
+	if _, err := svc.SetReaction(ctx, models.ReactionTargetComment, "c1", models.ReactionLike); !errors.Is(err, customerrors.ErrNotFound) {
+		t.Fatalf("на удаленный комментарий нельзя реагировать, получено %v", err)
+	}
+
+	mockForRepository.EXPECT().GetPostByID(ctx, "p1").Return(&models.Post{ID: "p1"}, nil)
+	mockForRepository.EXPECT().SetReaction(ctx, gomock.Any()).DoAndReturn(func(_ context.Context, r *models.Reaction) error {
+		if r.UserID != "user1" || r.Kind != models.ReactionDown {
+			t.Fatalf("неожиданная реакция: %+v", r)
+		}
+		return nil
+	})
+	mockForRepository.EXPECT().GetReactionCounts(ctx, models.ReactionTargetPost, []string{"p1"}).Return(map[string][]models.ReactionCount{
+		"p1": {{Kind: models.ReactionHeart, Count: 2}, {Kind: models.ReactionDown, Count: 1}, {Kind: models.ReactionUp, Count: 4}},
+	}, nil)
+	summary, err := svc.SetReaction(ctx, models.ReactionTargetPost, " p1 ", models.ReactionDown)
+	if err != nil {
+		t.Fatalf("не удалось поставить реакцию: %v", err)
+	}
+	if summary.Score != 3 || len(summary.Counts) != 3 || summary.Counts[0].Kind != models.ReactionUp || summary.Counts[2].Kind != models.ReactionHeart {
+		t.Fatalf("неожиданный агрегат: %+v", summary)
+	}
+}
+
+func TestService_NotifyCommentEvent(t *testing.T) {
+	controller := gomock.NewController(t)
+	defer controller.Finish()
+
+	mockForRepository := mocks.NewMockStorage(controller)
+	expectTx(mockForRepository)
+	svc := service.NewService(mockForRepository, &config.AppConfig{}, nil)
+	defer svc.Close()
+
+	postAuthor, cancel := context.WithCancel(auth.WithIdentity(context.Background(), &auth.Identity{UserID: "owner"}))
+	defer cancel()
+	inbox, err := svc.SubscribeNotifications(postAuthor)
+	if err != nil {
+		t.Fatalf("не удалось подписаться на уведомления: %v", err)
+	}
+
+	ctx := context.Background()
+	parentID := "c1"
+	reply := &models.Comment{ID: "c2", PostID: "p1", ParentID: &parentID, AuthorID: "replier", Text: "ответ"}
+	event, err := repository.NewOutboxEvent(models.EventCommentCreated, reply.ID, reply, time.Now())
+	if err != nil {
+		t.Fatalf("не удалось собрать событие: %v", err)
+	}
+	event.Seq = 7
+	mockForRepository.EXPECT().GetCommentByID(ctx, reply.ID).Return(reply, nil)
+	mockForRepository.EXPECT().GetCommentByID(ctx, parentID).Return(&models.Comment{ID: parentID, PostID: "p1", AuthorID: "commenter"}, nil)
+	mockForRepository.EXPECT().GetPostByID(ctx, "p1").Return(&models.Post{ID: "p1", AuthorID: "owner", CommentsEnabled: true}, nil)
+	mockForRepository.EXPECT().CreateNotifications(ctx, gomock.Any()).DoAndReturn(
+		func(_ context.Context, notifications []*models.Notification) error {
+			kinds := make(map[string]models.NotificationKind)
+			for _, n := range notifications {
+				if n.ActorID != "replier" || n.PostID != "p1" || !n.CreatedAt.Equal(event.CreatedAt) {
+					t.Fatalf("неожиданное уведомление %+v", n)
+				}
+				// повторная обработка события должна давать те же id
+				if n.ID != service.NotificationID(event.Seq, n.UserID) {
+					t.Fatalf("id уведомления %s не выведен из события", n.ID)
+				}
+				kinds[n.UserID] = n.Kind
+			}
+			if len(kinds) != 2 || kinds["owner"] != models.NotificationPostComment || kinds["commenter"] != models.NotificationCommentReply {
+				t.Fatalf("ожидались уведомления автору поста и автору комментария, получено %+v", kinds)
+			}
+			return nil
+		})
+
+	if err := svc.NotifyEvent(ctx, event); err != nil {
+		t.Fatalf("не удалось обработать событие: %v", err)
+	}
+
+	select {
+	case n := <-inbox:
+		if n.UserID != "owner" || n.Kind != models.NotificationPostComment {
+			t.Fatalf("пришло чужое уведомление %+v", n)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("уведомление не пришло подписчику")
+	}
+
+	// ответ на свой комментарий в своем посте никого не уведомляет
+	ownParent := "c3"
+	own := &models.Comment{ID: "c4", PostID: "p1", ParentID: &ownParent, AuthorID: "owner", Text: "дополнение"}
+	event, err = repository.NewOutboxEvent(models.EventCommentCreated, own.ID, own, time.Now())
+	if err != nil {
+		t.Fatalf("не удалось собрать событие: %v", err)
+	}
+	mockForRepository.EXPECT().GetCommentByID(ctx, own.ID).Return(own, nil)
+	mockForRepository.EXPECT().GetCommentByID(ctx, ownParent).Return(&models.Comment{ID: ownParent, PostID: "p1", AuthorID: "owner"}, nil)
+	mockForRepository.EXPECT().GetPostByID(ctx, "p1").Return(&models.Post{ID: "p1", AuthorID: "owner", CommentsEnabled: true}, nil)
+	if err := svc.NotifyEvent(ctx, event); err != nil {
+		t.Fatalf("не удалось обработать событие: %v", err)
+	}
+
+	// комментарий удалили до обработки события
+	gone := &models.Comment{ID: "c5", PostID: "p1", AuthorID: "replier", Text: "удален"}
+	event, err = repository.NewOutboxEvent(models.EventCommentCreated, gone.ID, gone, time.Now())
+	if err != nil {
+		t.Fatalf("не удалось собрать событие: %v", err)
+	}
+	mockForRepository.EXPECT().GetCommentByID(ctx, gone.ID).Return(nil, customerrors.ErrNotFound)
+	if err := svc.NotifyEvent(ctx, event); err != nil {
+		t.Fatalf("событие об удаленном комментарии должно пропускаться: %v", err)
+	}
+}
+
+func TestService_NotificationsRequireAuth(t *testing.T) {
+	controller := gomock.NewController(t)
+	defer controller.Finish()
+
+	mockForRepository := mocks.NewMockStorage(controller)
+	svc := service.NewService(mockForRepository, &config.AppConfig{MaxListLimit: 10}, nil)
+	defer svc.Close()
+	ctx := context.Background()
+
+	if _, err := svc.ListNotifications(ctx, 5, nil, false); !errors.Is(err, customerrors.ErrUnauthorized) {
+		t.Fatalf("ожидалась ошибка авторизации, получено %v", err)
+	}
+	if _, err := svc.MarkAllNotificationsRead(ctx); !errors.Is(err, customerrors.ErrUnauthorized) {
+		t.Fatalf("ожидалась ошибка авторизации, получено %v", err)
+	}
+
+	ctx = auth.WithIdentity(ctx, &auth.Identity{UserID: "u1"})
+	mockForRepository.EXPECT().MarkNotificationsRead(ctx, "u1", []string{"n1", "n2"}, gomock.Any()).Return(1, nil)
+	marked, err := svc.MarkNotificationsRead(ctx, []string{" n1", "n2 "})
+	if err != nil || marked != 1 {
+		t.Fatalf("ожидалось одно отмеченное уведомление: %v, %d", err, marked)
+	}
+}
@@ -0,0 +1,594 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MAPiryazev/OzonTest/internal/config"
+	"github.com/MAPiryazev/OzonTest/internal/customerrors"
+	"github.com/MAPiryazev/OzonTest/internal/models"
+	"github.com/MAPiryazev/OzonTest/internal/repository/sqlite"
+)
+
+func newSQLiteStorage(t *testing.T) *sqlite.SQLiteStorage {
+	t.Helper()
+	strg, err := sqlite.NewSQLiteStorage(&config.SQLiteConfig{Path: filepath.Join(t.TempDir(), "test.db"), BusyTimeoutMs: 1000})
+	if err != nil {
+		t.Fatalf("не удалось открыть sqlite: %v", err)
+	}
+	t.Cleanup(func() { strg.Close() })
+	return strg
+}
+
+func TestSQLiteStorage_ErrorMapping(t *testing.T) {
+	strg := newSQLiteStorage(t)
+	ctx := context.Background()
+
+	if err := strg.CreateUser(ctx, &models.User{ID: "u1", Username: "vasya"}); err != nil {
+		t.Fatalf("не удалось создать пользователя: %v", err)
+	}
+	if err := strg.CreateUser(ctx, &models.User{ID: "u2", Username: "vasya"}); !errors.Is(err, customerrors.ErrAlreadyExists) {
+		t.Fatalf("ожидалась ошибка уникальности имени, получено %v", err)
+	}
+	if _, err := strg.GetUserByID(ctx, "nope"); !errors.Is(err, customerrors.ErrNotFound) {
+		t.Fatalf("ожидалась ошибка not found, получено %v", err)
+	}
+	if err := strg.CreatePost(ctx, &models.Post{ID: "p1", Title: "t", Content: "c", AuthorID: "nope"}); !errors.Is(err, customerrors.ErrNotFound) {
+		t.Fatalf("ожидалась ошибка несуществующего автора, получено %v", err)
+	}
+	if err := strg.UpdatePost(ctx, &models.Post{ID: "nope", Title: "t", Content: "c"}, nil); !errors.Is(err, customerrors.ErrNotFound) {
+		t.Fatalf("ожидалась ошибка not found при обновлении, получено %v", err)
+	}
+}
+
+func TestSQLiteStorage_PaginationAndThread(t *testing.T) {
+	strg := newSQLiteStorage(t)
+	ctx := context.Background()
+	base := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	if err := strg.CreateUser(ctx, &models.User{ID: "u1", Username: "vasya"}); err != nil {
+		t.Fatalf("не удалось создать пользователя: %v", err)
+	}
+	for i, id := range []string{"p1", "p2", "p3"} {
+		post := &models.Post{ID: id, Title: "t", Content: "c", AuthorID: "u1", CommentsEnabled: true, CreatedAt: base.Add(time.Duration(i) * time.Second)}
+		if err := strg.CreatePost(ctx, post); err != nil {
+			t.Fatalf("не удалось создать пост: %v", err)
+		}
+	}
+
+	page, err := strg.ListPostsAfter(ctx, nil, 2)
+	if err != nil || len(page) != 2 || page[0].ID != "p3" {
+		t.Fatalf("неожиданная первая страница: %v, %v", err, page)
+	}
+	next, err := strg.ListPostsAfter(ctx, &models.PageKey{CreatedAt: page[1].CreatedAt, ID: page[1].ID}, 2)
+	if err != nil || len(next) != 1 || next[0].ID != "p1" {
+		t.Fatalf("неожиданная вторая страница: %v, %v", err, next)
+	}
+
+	// c1 -> c2 -> c3 и отдельный c4 верхнего уровня
+	parent := func(id string) *string { return &id }
+	comments := []*models.Comment{
+		{ID: "c1", PostID: "p1", AuthorID: "u1", Text: "1", CreatedAt: base},
+		{ID: "c4", PostID: "p1", AuthorID: "u1", Text: "4", CreatedAt: base.Add(time.Second)},
+		{ID: "c2", PostID: "p1", ParentID: parent("c1"), AuthorID: "u1", Text: "2", CreatedAt: base.Add(2 * time.Second)},
+		{ID: "c3", PostID: "p1", ParentID: parent("c2"), AuthorID: "u1", Text: "3", CreatedAt: base.Add(3 * time.Second)},
+	}
+	for _, c := range comments {
+		if err := strg.CreateComment(ctx, c); err != nil {
+			t.Fatalf("не удалось создать комментарий: %v", err)
+		}
+	}
+
+	thread, err := strg.GetCommentThread(ctx, "p1", nil, 5, 10)
+	if err != nil {
+		t.Fatalf("не удалось получить дерево: %v", err)
+	}
+	want := []struct {
+		id    string
+		depth int
+	}{{"c1", 0}, {"c2", 1}, {"c3", 2}, {"c4", 0}}
+	if len(thread) != len(want) {
+		t.Fatalf("ожидалось %d узлов, получено %d", len(want), len(thread))
+	}
+	for i, w := range want {
+		if thread[i].Comment.ID != w.id || thread[i].Depth != w.depth {
+			t.Fatalf("позиция %d: ожидался %s/%d, получен %s/%d", i, w.id, w.depth, thread[i].Comment.ID, thread[i].Depth)
+		}
+	}
+
+	// рекурсия останавливается на первых узлах обхода в глубину и на maxDepth
+	for _, tc := range []struct {
+		maxDepth, maxNodes int
+		want               string
+	}{{5, 3, "c1 c2 c3"}, {1, 10, "c1 c2 c4"}, {5, 1, "c1"}} {
+		thread, err := strg.GetCommentThread(ctx, "p1", nil, tc.maxDepth, tc.maxNodes)
+		if err != nil {
+			t.Fatalf("не удалось получить дерево: %v", err)
+		}
+		ids := make([]string, len(thread))
+		for i, c := range thread {
+			ids[i] = c.Comment.ID
+		}
+		if got := strings.Join(ids, " "); got != tc.want {
+			t.Fatalf("глубина %d, узлов %d: ожидалось %s, получено %s", tc.maxDepth, tc.maxNodes, tc.want, got)
+		}
+	}
+
+	replies, err := strg.ListRepliesByParentIDs(ctx, []string{"c1", "c2"}, models.CommentSortOld, 0, 10)
+	if err != nil || len(replies["c1"]) != 1 || len(replies["c2"]) != 1 {
+		t.Fatalf("неожиданные ответы: %v, %v", err, replies)
+	}
+}
+
+func TestSQLiteStorage_LoginFailures(t *testing.T) {
+	strg := newSQLiteStorage(t)
+	ctx := context.Background()
+
+	if err := strg.CreateUser(ctx, &models.User{ID: "u1", Username: "vasya", PasswordHash: "hash"}); err != nil {
+		t.Fatalf("не удалось создать пользователя: %v", err)
+	}
+	lockUntil := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	if err := strg.RegisterLoginFailure(ctx, "u1", 2, lockUntil); err != nil {
+		t.Fatalf("не удалось учесть неудачный вход: %v", err)
+	}
+	user, err := strg.GetUserByUsername(ctx, "vasya")
+	if err != nil || user.PasswordHash != "hash" || user.FailedLogins != 1 || user.LockedUntil != nil {
+		t.Fatalf("после первой неудачи: %v, %+v", err, user)
+	}
+
+	// вторая неудача достигает порога: блокировка и обнуление счетчика
+	if err := strg.RegisterLoginFailure(ctx, "u1", 2, lockUntil); err != nil {
+		t.Fatalf("не удалось учесть неудачный вход: %v", err)
+	}
+	user, _ = strg.GetUserByUsername(ctx, "vasya")
+	if user.FailedLogins != 0 || user.LockedUntil == nil || !user.LockedUntil.Equal(lockUntil) {
+		t.Fatalf("ожидалась блокировка до %v: %+v", lockUntil, user)
+	}
+
+	if err := strg.ResetLoginFailures(ctx, "u1"); err != nil {
+		t.Fatalf("не удалось сбросить блокировку: %v", err)
+	}
+	user, _ = strg.GetUserByUsername(ctx, "vasya")
+	if user.LockedUntil != nil {
+		t.Fatalf("блокировка не снята: %+v", user)
+	}
+	if err := strg.ResetLoginFailures(ctx, "nope"); !errors.Is(err, customerrors.ErrNotFound) {
+		t.Fatalf("ожидалась ошибка not found, получено %v", err)
+	}
+}
+
+func TestSQLiteStorage_SoftDeleteAndPurge(t *testing.T) {
+	strg := newSQLiteStorage(t)
+	ctx := context.Background()
+
+	if err := strg.CreateUser(ctx, &models.User{ID: "u1", Username: "vasya"}); err != nil {
+		t.Fatalf("не удалось создать пользователя: %v", err)
+	}
+	for _, id := range []string{"p1", "p2"} {
+		if err := strg.CreatePost(ctx, &models.Post{ID: id, Title: "t", Content: "c", AuthorID: "u1", CommentsEnabled: true}); err != nil {
+			t.Fatalf("не удалось создать пост: %v", err)
+		}
+	}
+	root := "c1"
+	for _, c := range []*models.Comment{
+		{ID: "c1", PostID: "p1", AuthorID: "u1", Text: "корень"},
+		{ID: "c2", PostID: "p1", ParentID: &root, AuthorID: "u1", Text: "ответ"},
+		{ID: "c3", PostID: "p2", AuthorID: "u1", Text: "другой пост"},
+	} {
+		if err := strg.CreateComment(ctx, c); err != nil {
+			t.Fatalf("не удалось создать комментарий: %v", err)
+		}
+	}
+
+	// мягко удаленный комментарий остается в выдаче с отметкой времени
+	if err := strg.SoftDeleteComment(ctx, "c1", time.Now()); err != nil {
+		t.Fatalf("не удалось удалить комментарий: %v", err)
+	}
+	if err := strg.SoftDeleteComment(ctx, "c1", time.Now()); !errors.Is(err, customerrors.ErrNotFound) {
+		t.Fatalf("повторное удаление должно давать not found, получено %v", err)
+	}
+	top, err := strg.ListCommentsByPost(ctx, "p1", nil, models.CommentSortOld, 0, 10)
+	if err != nil || len(top) != 1 || top[0].DeletedAt == nil {
+		t.Fatalf("ожидался удаленный комментарий в выдаче: %v, %+v", err, top)
+	}
+
+	if err := strg.SoftDeletePost(ctx, "p2", time.Now()); err != nil {
+		t.Fatalf("не удалось удалить пост: %v", err)
+	}
+	if _, err := strg.GetPostByID(ctx, "p2"); !errors.Is(err, customerrors.ErrNotFound) {
+		t.Fatalf("удаленный пост не должен отдаваться, получено %v", err)
+	}
+	posts, err := strg.ListPosts(ctx, models.PostFilter{}, models.PostSortNewest, 0, 10)
+	if err != nil || len(posts) != 1 {
+		t.Fatalf("ожидался один пост в списке: %v, %d", err, len(posts))
+	}
+
+	// физическое удаление каскадом забирает ответы и комментарии поста
+	if err := strg.PurgeComment(ctx, "c1"); err != nil {
+		t.Fatalf("не удалось удалить комментарий физически: %v", err)
+	}
+	if _, err := strg.GetCommentByID(ctx, "c2"); !errors.Is(err, customerrors.ErrNotFound) {
+		t.Fatalf("ответ должен удалиться вместе с родителем, получено %v", err)
+	}
+	if err := strg.PurgePost(ctx, "p2"); err != nil {
+		t.Fatalf("не удалось удалить пост физически: %v", err)
+	}
+	if _, err := strg.GetCommentByID(ctx, "c3"); !errors.Is(err, customerrors.ErrNotFound) {
+		t.Fatalf("комментарий должен удалиться вместе с постом, получено %v", err)
+	}
+}
+
+func TestSQLiteStorage_Revisions(t *testing.T) {
+	strg := newSQLiteStorage(t)
+	ctx := context.Background()
+
+	if err := strg.CreateUser(ctx, &models.User{ID: "u1", Username: "vasya"}); err != nil {
+		t.Fatalf("не удалось создать пользователя: %v", err)
+	}
+	if err := strg.CreatePost(ctx, &models.Post{ID: "p1", Title: "t", Content: "c", AuthorID: "u1", CommentsEnabled: true}); err != nil {
+		t.Fatalf("не удалось создать пост: %v", err)
+	}
+	if err := strg.CreateComment(ctx, &models.Comment{ID: "c1", PostID: "p1", AuthorID: "u1", Text: "v1"}); err != nil {
+		t.Fatalf("не удалось создать комментарий: %v", err)
+	}
+
+	base := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	for i, text := range []string{"v2", "v3"} {
+		editedAt := base.Add(time.Duration(i) * time.Minute)
+		prev := "v1"
+		if i > 0 {
+			prev = "v2"
+		}
+		comment := &models.Comment{ID: "c1", Text: text, EditedAt: &editedAt}
+		revision := &models.CommentRevision{ID: prev, CommentID: "c1", Text: prev, EditorID: "u1", CreatedAt: editedAt}
+		if err := strg.UpdateComment(ctx, comment, revision); err != nil {
+			t.Fatalf("не удалось отредактировать комментарий: %v", err)
+		}
+	}
+
+	comment, err := strg.GetCommentByID(ctx, "c1")
+	if err != nil || comment.Text != "v3" || comment.EditedAt == nil {
+		t.Fatalf("правка не сохранилась: %v, %+v", err, comment)
+	}
+	revisions, err := strg.ListCommentRevisions(ctx, "c1")
+	if err != nil || len(revisions) != 2 || revisions[0].Text != "v1" || revisions[1].Text != "v2" {
+		t.Fatalf("ожидались ревизии v1, v2: %v, %+v", err, revisions)
+	}
+
+	// ревизия с несуществующим редактором откатывает и само обновление
+	badRevision := &models.CommentRevision{ID: "bad", CommentID: "c1", Text: "v3", EditorID: "nope", CreatedAt: base}
+	if err := strg.UpdateComment(ctx, &models.Comment{ID: "c1", Text: "v4"}, badRevision); err == nil {
+		t.Fatalf("ожидалась ошибка внешнего ключа")
+	}
+	if comment, _ := strg.GetCommentByID(ctx, "c1"); comment.Text != "v3" {
+		t.Fatalf("обновление должно откатиться вместе с ревизией, текст %q", comment.Text)
+	}
+
+	if err := strg.PurgeComment(ctx, "c1"); err != nil {
+		t.Fatalf("не удалось удалить комментарий: %v", err)
+	}
+	if revisions, err := strg.ListCommentRevisions(ctx, "c1"); err != nil || len(revisions) != 0 {
+		t.Fatalf("ревизии должны удалиться вместе с комментарием: %v, %d", err, len(revisions))
+	}
+}
+
+func TestSQLiteStorage_Reactions(t *testing.T) {
+	strg := newSQLiteStorage(t)
+	ctx := context.Background()
+
+	for _, u := range []*models.User{{ID: "u1", Username: "vasya"}, {ID: "u2", Username: "petya"}} {
+		if err := strg.CreateUser(ctx, u); err != nil {
+			t.Fatalf("не удалось создать пользователя: %v", err)
+		}
+	}
+	if err := strg.CreatePost(ctx, &models.Post{ID: "p1", Title: "t", Content: "c", AuthorID: "u1", CommentsEnabled: true}); err != nil {
+		t.Fatalf("не удалось создать пост: %v", err)
+	}
+
+	for _, r := range []*models.Reaction{
+		{Target: models.ReactionTargetPost, TargetID: "p1", UserID: "u1", Kind: models.ReactionUp},
+		{Target: models.ReactionTargetPost, TargetID: "p1", UserID: "u2", Kind: models.ReactionUp},
+		// повторная реакция того же пользователя заменяет предыдущую
+		{Target: models.ReactionTargetPost, TargetID: "p1", UserID: "u2", Kind: models.ReactionDown},
+		{Target: models.ReactionTargetPost, TargetID: "p1", UserID: "u2", Kind: models.ReactionDown},
+	} {
+		if err := strg.SetReaction(ctx, r); err != nil {
+			t.Fatalf("не удалось поставить реакцию: %v", err)
+		}
+	}
+	counts, err := strg.GetReactionCounts(ctx, models.ReactionTargetPost, []string{"p1"})
+	if err != nil || len(counts["p1"]) != 2 {
+		t.Fatalf("ожидались два ненулевых счетчика: %v, %+v", err, counts)
+	}
+	for _, c := range counts["p1"] {
+		if c.Count != 1 {
+			t.Fatalf("каждый счетчик должен быть равен 1: %+v", counts["p1"])
+		}
+	}
+	mine, err := strg.GetUserReactions(ctx, models.ReactionTargetPost, "u2", []string{"p1"})
+	if err != nil || mine["p1"] != models.ReactionDown {
+		t.Fatalf("ожидалась реакция down: %v, %+v", err, mine)
+	}
+
+	if err := strg.RemoveReaction(ctx, models.ReactionTargetPost, "p1", "u2"); err != nil {
+		t.Fatalf("не удалось снять реакцию: %v", err)
+	}
+	if err := strg.RemoveReaction(ctx, models.ReactionTargetPost, "p1", "u2"); err != nil {
+		t.Fatalf("повторное снятие не должно быть ошибкой: %v", err)
+	}
+	counts, err = strg.GetReactionCounts(ctx, models.ReactionTargetPost, []string{"p1"})
+	if err != nil || len(counts["p1"]) != 1 || counts["p1"][0].Kind != models.ReactionUp {
+		t.Fatalf("ожидался только счетчик up: %v, %+v", err, counts)
+	}
+
+	if err := strg.SetReaction(ctx, &models.Reaction{Target: models.ReactionTargetComment, TargetID: "nope", UserID: "u1", Kind: models.ReactionLike}); !errors.Is(err, customerrors.ErrNotFound) {
+		t.Fatalf("ожидалась ошибка несуществующего комментария, получено %v", err)
+	}
+
+	// реакции и счетчики удаляются вместе с постом
+	if err := strg.PurgePost(ctx, "p1"); err != nil {
+		t.Fatalf("не удалось удалить пост: %v", err)
+	}
+	if counts, err := strg.GetReactionCounts(ctx, models.ReactionTargetPost, []string{"p1"}); err != nil || len(counts["p1"]) != 0 {
+		t.Fatalf("счетчики должны удалиться вместе с постом: %v, %+v", err, counts)
+	}
+}
+
+func TestSQLiteStorage_CommentSort(t *testing.T) {
+	strg := newSQLiteStorage(t)
+	ctx := context.Background()
+	base := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	for _, u := range []*models.User{{ID: "u1", Username: "vasya"}, {ID: "u2", Username: "petya"}} {
+		if err := strg.CreateUser(ctx, u); err != nil {
+			t.Fatalf("не удалось создать пользователя: %v", err)
+		}
+	}
+	if err := strg.CreatePost(ctx, &models.Post{ID: "p1", Title: "t", Content: "c", AuthorID: "u1", CommentsEnabled: true}); err != nil {
+		t.Fatalf("не удалось создать пост: %v", err)
+	}
+	for i, id := range []string{"c1", "c2", "c3"} {
+		comment := &models.Comment{ID: id, PostID: "p1", AuthorID: "u1", Text: id, CreatedAt: base.Add(time.Duration(i) * time.Second)}
+		if err := strg.CreateComment(ctx, comment); err != nil {
+			t.Fatalf("не удалось создать комментарий: %v", err)
+		}
+	}
+	// c1: против, c2: два за, c3: один за и один против
+	for _, r := range []struct {
+		id, user string
+		kind     models.ReactionKind
+	}{
+		{"c1", "u1", models.ReactionDown},
+		{"c2", "u1", models.ReactionUp},
+		{"c2", "u2", models.ReactionUp},
+		{"c3", "u1", models.ReactionUp},
+		{"c3", "u2", models.ReactionDown},
+	} {
+		reaction := &models.Reaction{Target: models.ReactionTargetComment, TargetID: r.id, UserID: r.user, Kind: r.kind}
+		if err := strg.SetReaction(ctx, reaction); err != nil {
+			t.Fatalf("не удалось поставить реакцию: %v", err)
+		}
+	}
+
+	ids := func(comments []*models.Comment) []string {
+		res := make([]string, len(comments))
+		for i, c := range comments {
+			res[i] = c.ID
+		}
+		return res
+	}
+	for sort, want := range map[models.CommentSort]string{
+		models.CommentSortOld:           "c1 c2 c3",
+		models.CommentSortNew:           "c3 c2 c1",
+		models.CommentSortTop:           "c2 c3 c1",
+		models.CommentSortControversial: "c3 c1 c2",
+	} {
+		comments, err := strg.ListCommentsByPost(ctx, "p1", nil, sort, 0, 10)
+		if err != nil {
+			t.Fatalf("не удалось получить комментарии %s: %v", sort, err)
+		}
+		if got := strings.Join(ids(comments), " "); got != want {
+			t.Fatalf("сортировка %s: ожидалось %s, получено %s", sort, want, got)
+		}
+	}
+
+	// снятая реакция сразу меняет ранг
+	if err := strg.RemoveReaction(ctx, models.ReactionTargetComment, "c3", "u2"); err != nil {
+		t.Fatalf("не удалось снять реакцию: %v", err)
+	}
+	page, err := strg.ListCommentsByPost(ctx, "p1", nil, models.CommentSortControversial, 0, 1)
+	if err != nil || len(page) != 1 || page[0].ID != "c1" {
+		t.Fatalf("после снятия голоса спорных комментариев нет, первым ожидался c1: %v, %v", err, ids(page))
+	}
+	if _, err := strg.ListCommentsByPost(ctx, "p1", nil, "random", 0, 10); !errors.Is(err, customerrors.ErrValidation) {
+		t.Fatalf("ожидалась ошибка неизвестной сортировки, получено %v", err)
+	}
+}
+
+func TestSQLiteStorage_ListPostsFilterAndSort(t *testing.T) {
+	strg := newSQLiteStorage(t)
+	ctx := context.Background()
+	base := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	for _, u := range []*models.User{{ID: "u1", Username: "vasya"}, {ID: "u2", Username: "petya"}} {
+		if err := strg.CreateUser(ctx, u); err != nil {
+			t.Fatalf("не удалось создать пользователя: %v", err)
+		}
+	}
+	for _, p := range []*models.Post{
+		{ID: "p1", Title: "t", Content: "c", AuthorID: "u1", CommentsEnabled: true, CreatedAt: base},
+		{ID: "p2", Title: "t", Content: "c", AuthorID: "u2", CommentsEnabled: false, CreatedAt: base.Add(time.Hour)},
+		{ID: "p3", Title: "t", Content: "c", AuthorID: "u1", CommentsEnabled: true, CreatedAt: base.Add(2 * time.Hour)},
+	} {
+		if err := strg.CreatePost(ctx, p); err != nil {
+			t.Fatalf("не удалось создать пост: %v", err)
+		}
+	}
+	for i, c := range []*models.Comment{
+		{ID: "c1", PostID: "p1", AuthorID: "u2", Text: "1"},
+		{ID: "c2", PostID: "p1", AuthorID: "u2", Text: "2"},
+		{ID: "c3", PostID: "p2", AuthorID: "u1", Text: "3"},
+		{ID: "c4", PostID: "p3", AuthorID: "u2", Text: "4"},
+	} {
+		c.CreatedAt = base.Add(time.Duration(3+i) * time.Hour)
+		if err := strg.CreateComment(ctx, c); err != nil {
+			t.Fatalf("не удалось создать комментарий: %v", err)
+		}
+	}
+	// удаленный комментарий не считается активностью
+	if err := strg.SoftDeleteComment(ctx, "c4", time.Now()); err != nil {
+		t.Fatalf("не удалось удалить комментарий: %v", err)
+	}
+
+	author, yes, no := "u1", true, false
+	after, before := base.Add(time.Hour), base.Add(2*time.Hour)
+	cases := []struct {
+		name   string
+		filter models.PostFilter
+		sort   models.PostSort
+		want   string
+	}{
+		{"автор", models.PostFilter{AuthorID: &author}, models.PostSortNewest, "p3 p1"},
+		{"период", models.PostFilter{CreatedAfter: &after, CreatedBefore: &before}, models.PostSortNewest, "p2"},
+		{"с комментариями", models.PostFilter{HasComments: &yes}, models.PostSortNewest, "p2 p1"},
+		{"без комментариев", models.PostFilter{HasComments: &no}, models.PostSortNewest, "p3"},
+		{"комментарии закрыты", models.PostFilter{CommentsEnabled: &no}, models.PostSortNewest, "p2"},
+		{"старые", models.PostFilter{}, models.PostSortOldest, "p1 p2 p3"},
+		{"обсуждаемые", models.PostFilter{}, models.PostSortMostCommented, "p1 p2 p3"},
+		{"активные", models.PostFilter{}, models.PostSortRecentlyActive, "p2 p1 p3"},
+	}
+	for _, tc := range cases {
+		posts, err := strg.ListPosts(ctx, tc.filter, tc.sort, 0, 10)
+		if err != nil {
+			t.Fatalf("%s: не удалось получить посты: %v", tc.name, err)
+		}
+		ids := make([]string, len(posts))
+		for i, p := range posts {
+			ids[i] = p.ID
+		}
+		if got := strings.Join(ids, " "); got != tc.want {
+			t.Fatalf("%s: ожидалось %s, получено %s", tc.name, tc.want, got)
+		}
+	}
+}
+
+func TestSQLiteStorage_ListCommentsByAuthor(t *testing.T) {
+	strg := newSQLiteStorage(t)
+	ctx := context.Background()
+	base := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	for _, u := range []*models.User{{ID: "u1", Username: "vasya"}, {ID: "u2", Username: "petya"}} {
+		if err := strg.CreateUser(ctx, u); err != nil {
+			t.Fatalf("не удалось создать пользователя: %v", err)
+		}
+	}
+	for _, p := range []*models.Post{
+		{ID: "p1", Title: "t", Content: "c", AuthorID: "u2", CommentsEnabled: true, CreatedAt: base},
+		{ID: "p2", Title: "t", Content: "c", AuthorID: "u2", CommentsEnabled: true, CreatedAt: base},
+	} {
+		if err := strg.CreatePost(ctx, p); err != nil {
+			t.Fatalf("не удалось создать пост: %v", err)
+		}
+	}
+	for i, c := range []*models.Comment{
+		{ID: "c1", PostID: "p1", AuthorID: "u1", Text: "1"},
+		{ID: "c2", PostID: "p1", AuthorID: "u2", Text: "2"},
+		{ID: "c3", PostID: "p1", AuthorID: "u1", Text: "3"},
+		{ID: "c4", PostID: "p1", AuthorID: "u1", Text: "4"},
+		{ID: "c5", PostID: "p2", AuthorID: "u1", Text: "5"},
+	} {
+		c.CreatedAt = base.Add(time.Duration(i+1) * time.Minute)
+		if err := strg.CreateComment(ctx, c); err != nil {
+			t.Fatalf("не удалось создать комментарий: %v", err)
+		}
+	}
+	if err := strg.SoftDeleteComment(ctx, "c4", time.Now()); err != nil {
+		t.Fatalf("не удалось удалить комментарий: %v", err)
+	}
+	if err := strg.SoftDeletePost(ctx, "p2", time.Now()); err != nil {
+		t.Fatalf("не удалось удалить пост: %v", err)
+	}
+
+	comments, err := strg.ListCommentsByAuthor(ctx, "u1", 0, 10)
+	if err != nil {
+		t.Fatalf("не удалось получить комментарии автора: %v", err)
+	}
+	if len(comments) != 2 || comments[0].ID != "c3" || comments[1].ID != "c1" {
+		t.Fatalf("ожидались c3, c1, получено %+v", comments)
+	}
+
+	comments, err = strg.ListCommentsByAuthor(ctx, "u1", 1, 10)
+	if err != nil || len(comments) != 1 || comments[0].ID != "c1" {
+		t.Fatalf("ожидалась вторая страница с c1: %v, %+v", err, comments)
+	}
+}
+
+func TestSQLiteStorage_Notifications(t *testing.T) {
+	strg := newSQLiteStorage(t)
+	ctx := context.Background()
+	base := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	for _, u := range []*models.User{{ID: "u1", Username: "vasya"}, {ID: "u2", Username: "petya"}} {
+		if err := strg.CreateUser(ctx, u); err != nil {
+			t.Fatalf("не удалось создать пользователя: %v", err)
+		}
+	}
+	if err := strg.CreatePost(ctx, &models.Post{ID: "p1", Title: "t", Content: "c", AuthorID: "u1", CommentsEnabled: true, CreatedAt: base}); err != nil {
+		t.Fatalf("не удалось создать пост: %v", err)
+	}
+	var notifications []*models.Notification
+	for i, id := range []string{"c1", "c2", "c3"} {
+		at := base.Add(time.Duration(i+1) * time.Minute)
+		if err := strg.CreateComment(ctx, &models.Comment{ID: id, PostID: "p1", AuthorID: "u2", Text: id, CreatedAt: at}); err != nil {
+			t.Fatalf("не удалось создать комментарий: %v", err)
+		}
+		commentID := id
+		notifications = append(notifications, &models.Notification{
+			ID: "n" + id[1:], UserID: "u1", Kind: models.NotificationPostComment, ActorID: "u2", PostID: "p1", CommentID: &commentID, CreatedAt: at,
+		})
+	}
+	if err := strg.CreateNotifications(ctx, notifications); err != nil {
+		t.Fatalf("не удалось создать уведомления: %v", err)
+	}
+
+	page, err := strg.ListNotifications(ctx, "u1", false, nil, 2)
+	if err != nil || len(page) != 2 || page[0].ID != "n3" || page[1].ID != "n2" {
+		t.Fatalf("ожидались n3, n2: %v, %+v", err, page)
+	}
+	page, err = strg.ListNotifications(ctx, "u1", false, &models.PageKey{CreatedAt: page[1].CreatedAt, ID: page[1].ID}, 2)
+	if err != nil || len(page) != 1 || page[0].ID != "n1" {
+		t.Fatalf("ожидалась вторая страница с n1: %v, %+v", err, page)
+	}
+
+	// чужие уведомления не отмечаются
+	if marked, err := strg.MarkNotificationsRead(ctx, "u2", []string{"n1"}, time.Now()); err != nil || marked != 0 {
+		t.Fatalf("чужое уведомление не должно отмечаться: %v, %d", err, marked)
+	}
+	if marked, err := strg.MarkNotificationsRead(ctx, "u1", []string{"n1", "n3"}, time.Now()); err != nil || marked != 2 {
+		t.Fatalf("ожидалось два отмеченных уведомления: %v, %d", err, marked)
+	}
+	unread, err := strg.ListNotifications(ctx, "u1", true, nil, 10)
+	if err != nil || len(unread) != 1 || unread[0].ID != "n2" || unread[0].ReadAt != nil {
+		t.Fatalf("непрочитанным должно остаться n2: %v, %+v", err, unread)
+	}
+	if marked, err := strg.MarkNotificationsRead(ctx, "u1", nil, time.Now()); err != nil || marked != 1 {
+		t.Fatalf("ожидалось одно отмеченное уведомление: %v, %d", err, marked)
+	}
+	if count, err := strg.CountUnreadNotifications(ctx, "u1"); err != nil || count != 0 {
+		t.Fatalf("непрочитанных не должно остаться: %v, %d", err, count)
+	}
+
+	// физическое удаление комментария удаляет и уведомления о нем
+	if err := strg.PurgeComment(ctx, "c2"); err != nil {
+		t.Fatalf("не удалось удалить комментарий: %v", err)
+	}
+	all, err := strg.ListNotifications(ctx, "u1", false, nil, 10)
+	if err != nil || len(all) != 2 {
+		t.Fatalf("ожидалось два уведомления после удаления: %v, %+v", err, all)
+	}
+}
@@ -0,0 +1,375 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/MAPiryazev/OzonTest/internal/auth"
+	"github.com/MAPiryazev/OzonTest/internal/config"
+	"github.com/MAPiryazev/OzonTest/internal/customerrors"
+	"github.com/MAPiryazev/OzonTest/internal/models"
+	"github.com/MAPiryazev/OzonTest/internal/repository"
+	"github.com/MAPiryazev/OzonTest/internal/repository/inmemory"
+	"github.com/MAPiryazev/OzonTest/internal/repository/mocks"
+	"github.com/MAPiryazev/OzonTest/internal/repository/postgres"
+	"github.com/MAPiryazev/OzonTest/internal/service"
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+)
+
+// записи транзакции видны ее следующим чтениям и проверкам, а после ошибки откатываются целиком.
+// postgres проверяется, только если заданы переменные POSTGRES_*, а схема накачена командой migrate up
+func TestStorage_WithTxReadsOwnWrites(t *testing.T) {
+	backends := map[string]func(t *testing.T) repository.Storage{
+		"memory": func(t *testing.T) repository.Storage { return inmemory.NewMemoryStorage() },
+		"sqlite": func(t *testing.T) repository.Storage { return newSQLiteStorage(t) },
+		"postgres": func(t *testing.T) repository.Storage {
+			cfg := &config.DBConfig{
+				DBHost:         os.Getenv("POSTGRES_HOST"),
+				DBPort:         os.Getenv("POSTGRES_PORT"),
+				DBUser:         os.Getenv("POSTGRES_USER"),
+				DBPassword:     os.Getenv("POSTGRES_PASSWORD"),
+				DBName:         os.Getenv("POSTGRES_DB"),
+				DBSSLMode:      os.Getenv("POSTGRES_SSLMODE"),
+				DBMaxOpenConns: 2,
+				DBMaxIdleConns: 2,
+			}
+			if cfg.DBHost == "" {
+				t.Skip("POSTGRES_HOST не задан")
+			}
+			strg, err := postgres.NewPostgresStorage(cfg)
+			if err != nil {
+				t.Fatalf("не удалось подключиться к postgres: %v", err)
+			}
+			t.Cleanup(func() { strg.Close() })
+			return strg
+		},
+	}
+
+	for name, open := range backends {
+		t.Run(name, func(t *testing.T) {
+			strg := open(t)
+			ctx := context.Background()
+			// все записи откатываются, поэтому в общей базе postgres ничего не остается
+			userID, postID, commentID := uuid.NewString(), uuid.NewString(), uuid.NewString()
+			username := "tx_" + userID[:8]
+
+			errAbort := errors.New("отмена")
+			err := strg.WithTx(ctx, func(tx repository.Storage) error {
+				if err := tx.CreateUser(ctx, &models.User{ID: userID, Username: username}); err != nil {
+					return err
+				}
+				if _, err := tx.GetUserByID(ctx, userID); err != nil {
+					t.Fatalf("пользователь должен быть виден внутри транзакции: %v", err)
+				}
+				if err := tx.CreatePost(ctx, &models.Post{ID: postID, Title: "брюква", Content: "c", AuthorID: userID, CommentsEnabled: true}); err != nil {
+					return err
+				}
+				if _, err := tx.GetPostByID(ctx, postID); err != nil {
+					t.Fatalf("пост должен быть виден внутри транзакции: %v", err)
+				}
+				if posts, err := tx.ListPostsAfter(ctx, nil, 1); err != nil || len(posts) != 1 || posts[0].ID != postID {
+					t.Fatalf("новый пост должен быть первым в ленте: %v, %+v", err, posts)
+				}
+				if hits, err := tx.Search(ctx, "брюква", models.SearchPosts, 0, 100); err != nil || !slices.ContainsFunc(hits, func(h *models.SearchHit) bool { return h.Post.ID == postID }) {
+					t.Fatalf("поиск должен находить новый пост: %v, %+v", err, hits)
+				}
+
+				comment := &models.Comment{ID: commentID, PostID: postID, AuthorID: userID, Text: "привет"}
+				if err := tx.CreateComment(ctx, comment); err != nil {
+					return err
+				}
+				if err := tx.CreateComment(ctx, comment); !errors.Is(err, customerrors.ErrAlreadyExists) {
+					t.Fatalf("повтор комментария в той же транзакции должен дать ErrAlreadyExists, получено %v", err)
+				}
+				if comments, err := tx.ListCommentsByAuthor(ctx, userID, 0, 10); err != nil || len(comments) != 1 {
+					t.Fatalf("комментарий должен быть в выдаче по автору: %v, %+v", err, comments)
+				}
+
+				for range 2 {
+					if err := tx.SetReaction(ctx, &models.Reaction{Target: models.ReactionTargetPost, TargetID: postID, UserID: userID, Kind: models.ReactionLike}); err != nil {
+						return err
+					}
+				}
+				if counts, err := tx.GetReactionCounts(ctx, models.ReactionTargetPost, []string{postID}); err != nil || len(counts[postID]) != 1 || counts[postID][0].Count != 1 {
+					t.Fatalf("повтор реакции не должен менять счетчик: %v, %+v", err, counts)
+				}
+
+				if added, err := tx.ReplaceMentions(ctx, models.ReactionTargetComment, commentID, []string{userID}); err != nil || len(added) != 1 {
+					t.Fatalf("первое упоминание должно быть новым: %v, %v", err, added)
+				}
+				if added, err := tx.ReplaceMentions(ctx, models.ReactionTargetComment, commentID, []string{userID}); err != nil || len(added) != 0 {
+					t.Fatalf("повтор упоминания должен видеть предыдущее: %v, %v", err, added)
+				}
+				return errAbort
+			})
+			if !errors.Is(err, errAbort) {
+				t.Fatalf("ожидалась ошибка функции, получено %v", err)
+			}
+
+			if _, err := strg.GetUserByID(ctx, userID); !errors.Is(err, customerrors.ErrNotFound) {
+				t.Fatalf("пользователь должен откатиться: %v", err)
+			}
+			if _, err := strg.GetPostByID(ctx, postID); !errors.Is(err, customerrors.ErrNotFound) {
+				t.Fatalf("пост должен откатиться: %v", err)
+			}
+			if _, err := strg.GetCommentByID(ctx, commentID); !errors.Is(err, customerrors.ErrNotFound) {
+				t.Fatalf("комментарий должен откатиться: %v", err)
+			}
+			if comments, err := strg.ListCommentsByAuthor(ctx, userID, 0, 10); err != nil || len(comments) != 0 {
+				t.Fatalf("индекс по автору должен откатиться: %v, %+v", err, comments)
+			}
+			if hits, err := strg.Search(ctx, "брюква", models.SearchPosts, 0, 100); err != nil || slices.ContainsFunc(hits, func(h *models.SearchHit) bool { return h.Post.ID == postID }) {
+				t.Fatalf("поисковый индекс должен откатиться: %v, %+v", err, hits)
+			}
+			if counts, err := strg.GetReactionCounts(ctx, models.ReactionTargetPost, []string{postID}); err != nil || len(counts) != 0 {
+				t.Fatalf("реакции должны откатиться: %v, %+v", err, counts)
+			}
+		})
+	}
+}
+
+func TestSQLiteStorage_WithTx(t *testing.T) {
+	strg := newSQLiteStorage(t)
+	ctx := context.Background()
+	if err := strg.CreateUser(ctx, &models.User{ID: "u1", Username: "vasya"}); err != nil {
+		t.Fatalf("не удалось создать пользователя: %v", err)
+	}
+
+	// ошибка функции откатывает все ее изменения вместе с событиями outbox
+	errAbort := errors.New("отмена")
+	err := strg.WithTx(ctx, func(tx repository.Storage) error {
+		if err := tx.CreatePost(ctx, &models.Post{ID: "p1", Title: "t", Content: "c", AuthorID: "u1"}); err != nil {
+			return err
+		}
+		if _, err := tx.GetPostByID(ctx, "p1"); err != nil {
+			t.Fatalf("пост должен быть виден внутри транзакции: %v", err)
+		}
+		return errAbort
+	})
+	if !errors.Is(err, errAbort) {
+		t.Fatalf("ожидалась ошибка функции, получено %v", err)
+	}
+	if _, err := strg.GetPostByID(ctx, "p1"); !errors.Is(err, customerrors.ErrNotFound) {
+		t.Fatalf("пост должен откатиться: %v", err)
+	}
+	if events, _ := strg.ListOutboxEvents(ctx, 0, 10); len(events) != 1 {
+		t.Fatalf("событие поста должно откатиться вместе с ним: %+v", events)
+	}
+
+	// ошибка отдельного метода откатывает только его изменения, остальное фиксируется
+	err = strg.WithTx(ctx, func(tx repository.Storage) error {
+		if err := tx.CreatePost(ctx, &models.Post{ID: "p2", Title: "t", Content: "c", AuthorID: "u1", CommentsEnabled: true}); err != nil {
+			return err
+		}
+		if err := tx.UpdatePost(ctx, &models.Post{ID: "nope", Title: "t", Content: "c"}, nil); !errors.Is(err, customerrors.ErrNotFound) {
+			t.Fatalf("ожидалась ErrNotFound, получено %v", err)
+		}
+		// вложенная транзакция выполняется в той же
+		return tx.WithTx(ctx, func(inner repository.Storage) error {
+			return inner.CreateComment(ctx, &models.Comment{ID: "c1", PostID: "p2", AuthorID: "u1", Text: "привет"})
+		})
+	})
+	if err != nil {
+		t.Fatalf("транзакция должна зафиксироваться: %v", err)
+	}
+	if _, err := strg.GetCommentByID(ctx, "c1"); err != nil {
+		t.Fatalf("комментарий должен сохраниться: %v", err)
+	}
+	if events, _ := strg.ListOutboxEvents(ctx, 0, 10); len(events) != 3 {
+		t.Fatalf("ожидались события пользователя, поста и комментария: %+v", events)
+	}
+}
+
+func TestMemoryStorage_WithTxIsolation(t *testing.T) {
+	strg := inmemory.NewMemoryStorage()
+	ctx := context.Background()
+
+	done := make(chan error, 1)
+	err := strg.WithTx(ctx, func(tx repository.Storage) error {
+		if err := tx.CreateUser(ctx, &models.User{ID: "u1", Username: "vasya"}); err != nil {
+			return err
+		}
+		// запись снаружи ждет конца транзакции
+		go func() {
+			done <- strg.CreatePost(ctx, &models.Post{ID: "p1", Title: "t", Content: "c", AuthorID: "u1"})
+		}()
+		time.Sleep(50 * time.Millisecond)
+		if _, err := tx.GetPostByID(ctx, "p1"); !errors.Is(err, customerrors.ErrNotFound) {
+			t.Fatalf("запись снаружи не должна попасть внутрь транзакции: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("не удалось выполнить транзакцию: %v", err)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("не удалось создать пост после транзакции: %v", err)
+	}
+	if _, err := strg.GetPostByID(ctx, "p1"); err != nil {
+		t.Fatalf("пост должен появиться после транзакции: %v", err)
+	}
+}
+
+func TestMemoryStorage_WithTxRollback(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &config.MemoryConfig{
+		WALPath:      filepath.Join(dir, "memory.wal"),
+		SnapshotPath: filepath.Join(dir, "memory.snapshot"),
+		FsyncPolicy:  config.FsyncAlways,
+	}
+	ctx := context.Background()
+
+	strg, err := inmemory.OpenMemoryStorage(cfg)
+	if err != nil {
+		t.Fatalf("не удалось открыть хранилище: %v", err)
+	}
+	if err := strg.CreateUser(ctx, &models.User{ID: "u1", Username: "vasya"}); err != nil {
+		t.Fatalf("не удалось создать пользователя: %v", err)
+	}
+
+	createPosts := func(tx repository.Storage) error {
+		for _, id := range []string{"p1", "p2"} {
+			if err := tx.CreatePost(ctx, &models.Post{ID: id, Title: "t", Content: "c", AuthorID: "u1"}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	// ошибка функции отбрасывает все ее записи вместе с событиями outbox
+	errAbort := errors.New("отмена")
+	err = strg.WithTx(ctx, func(tx repository.Storage) error {
+		if err := createPosts(tx); err != nil {
+			return err
+		}
+		return errAbort
+	})
+	if !errors.Is(err, errAbort) {
+		t.Fatalf("ожидалась ошибка функции, получено %v", err)
+	}
+	if _, err := strg.GetPostByID(ctx, "p1"); !errors.Is(err, customerrors.ErrNotFound) {
+		t.Fatalf("пост должен откатиться: %v", err)
+	}
+	if events, _ := strg.ListOutboxEvents(ctx, 0, 10); len(events) != 1 {
+		t.Fatalf("события постов должны откатиться вместе с ними: %+v", events)
+	}
+
+	// записи транзакции попадают в журнал одной записью и получают разные seq событий
+	if err := strg.WithTx(ctx, createPosts); err != nil {
+		t.Fatalf("транзакция должна зафиксироваться: %v", err)
+	}
+	if err := strg.Close(); err != nil {
+		t.Fatalf("не удалось закрыть хранилище: %v", err)
+	}
+
+	restored, err := inmemory.OpenMemoryStorage(cfg)
+	if err != nil {
+		t.Fatalf("не удалось восстановить хранилище: %v", err)
+	}
+	defer restored.Close()
+	for _, id := range []string{"p1", "p2"} {
+		if _, err := restored.GetPostByID(ctx, id); err != nil {
+			t.Fatalf("пост %s должен восстановиться: %v", id, err)
+		}
+	}
+	events, err := restored.ListOutboxEvents(ctx, 0, 10)
+	if err != nil || len(events) != 3 || events[1].Seq != 2 || events[2].Seq != 3 {
+		t.Fatalf("ожидались события с seq 1, 2, 3: %v, %+v", err, events)
+	}
+}
+
+// откат физического удаления возвращает пост со всеми комментариями, индексами, реакциями и уведомлениями
+func TestMemoryStorage_WithTxRollbackPurge(t *testing.T) {
+	strg := inmemory.NewMemoryStorage()
+	ctx := context.Background()
+
+	if err := strg.CreateUser(ctx, &models.User{ID: "u1", Username: "vasya"}); err != nil {
+		t.Fatalf("не удалось создать пользователя: %v", err)
+	}
+	if err := strg.CreatePost(ctx, &models.Post{ID: "p1", Title: "брюква", Content: "c", AuthorID: "u1", CommentsEnabled: true}); err != nil {
+		t.Fatalf("не удалось создать пост: %v", err)
+	}
+	parentID := "c1"
+	for _, c := range []*models.Comment{
+		{ID: "c1", PostID: "p1", AuthorID: "u1", Text: "корень"},
+		{ID: "c2", PostID: "p1", ParentID: &parentID, AuthorID: "u1", Text: "ответ"},
+	} {
+		if err := strg.CreateComment(ctx, c); err != nil {
+			t.Fatalf("не удалось создать комментарий: %v", err)
+		}
+	}
+	if err := strg.SetReaction(ctx, &models.Reaction{Target: models.ReactionTargetComment, TargetID: "c2", UserID: "u1", Kind: models.ReactionLike}); err != nil {
+		t.Fatalf("не удалось поставить реакцию: %v", err)
+	}
+	if err := strg.CreateNotifications(ctx, []*models.Notification{{ID: "n1", UserID: "u1", Kind: models.NotificationCommentReply, ActorID: "u1", PostID: "p1", CommentID: &parentID, CreatedAt: time.Now()}}); err != nil {
+		t.Fatalf("не удалось создать уведомление: %v", err)
+	}
+
+	errAbort := errors.New("отмена")
+	err := strg.WithTx(ctx, func(tx repository.Storage) error {
+		if err := tx.SoftDeleteComment(ctx, "c2", time.Now()); err != nil {
+			return err
+		}
+		if err := tx.PurgePost(ctx, "p1"); err != nil {
+			return err
+		}
+		if _, err := tx.GetCommentByID(ctx, "c2"); !errors.Is(err, customerrors.ErrNotFound) {
+			t.Fatalf("комментарий должен удалиться внутри транзакции: %v", err)
+		}
+		return errAbort
+	})
+	if !errors.Is(err, errAbort) {
+		t.Fatalf("ожидалась ошибка функции, получено %v", err)
+	}
+
+	thread, err := strg.GetCommentThread(ctx, "p1", nil, 10, 10)
+	if err != nil || len(thread) != 2 || thread[1].Comment.ID != "c2" || thread[1].Comment.DeletedAt != nil {
+		t.Fatalf("дерево комментариев должно восстановиться: %v, %+v", err, thread)
+	}
+	if comments, err := strg.ListCommentsByAuthor(ctx, "u1", 0, 10); err != nil || len(comments) != 2 {
+		t.Fatalf("индекс по автору должен восстановиться: %v, %+v", err, comments)
+	}
+	if posts, err := strg.ListPostsAfter(ctx, nil, 10); err != nil || len(posts) != 1 {
+		t.Fatalf("лента должна восстановиться: %v, %+v", err, posts)
+	}
+	if hits, err := strg.Search(ctx, "ответ", models.SearchComments, 0, 10); err != nil || len(hits) != 1 {
+		t.Fatalf("поисковый индекс должен восстановиться: %v, %+v", err, hits)
+	}
+	if counts, err := strg.GetReactionCounts(ctx, models.ReactionTargetComment, []string{"c2"}); err != nil || len(counts["c2"]) != 1 {
+		t.Fatalf("реакции должны восстановиться: %v, %+v", err, counts)
+	}
+	if unread, err := strg.CountUnreadNotifications(ctx, "u1"); err != nil || unread != 1 {
+		t.Fatalf("уведомления должны восстановиться: %v, %d", err, unread)
+	}
+}
+
+func TestService_CreateCommentChecksInTx(t *testing.T) {
+	controller := gomock.NewController(t)
+	defer controller.Finish()
+
+	mockForRepository := mocks.NewMockStorage(controller)
+	mockForTx := mocks.NewMockStorage(controller)
+	svc := service.NewService(mockForRepository, &config.AppConfig{}, nil)
+	defer svc.Close()
+	ctx := auth.WithIdentity(context.Background(), &auth.Identity{UserID: "user1"})
+	parentID := "c0"
+
+	// проверки и вставка идут через хранилище транзакции, а не напрямую
+	mockForRepository.EXPECT().WithTx(ctx, gomock.Any()).DoAndReturn(
+		func(_ context.Context, fn func(tx repository.Storage) error) error {
+			return fn(mockForTx)
+		})
+	mockForTx.EXPECT().GetCommentByID(ctx, parentID).Return(&models.Comment{ID: parentID, PostID: "p1", AuthorID: "user1"}, nil)
+	mockForTx.EXPECT().GetPostByID(ctx, "p1").Return(&models.Post{ID: "p1", AuthorID: "user1", CommentsEnabled: false}, nil)
+
+	err := svc.CreateComment(ctx, &models.Comment{PostID: "p1", ParentID: &parentID, Text: "привет"})
+	if !errors.Is(err, customerrors.ErrCommForbidden) {
+		t.Fatalf("ожидалась ErrCommForbidden, получено %v", err)
+	}
+}
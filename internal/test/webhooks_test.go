@@ -0,0 +1,346 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/MAPiryazev/OzonTest/internal/auth"
+	"github.com/MAPiryazev/OzonTest/internal/config"
+	"github.com/MAPiryazev/OzonTest/internal/customerrors"
+	"github.com/MAPiryazev/OzonTest/internal/models"
+	"github.com/MAPiryazev/OzonTest/internal/outbox"
+	"github.com/MAPiryazev/OzonTest/internal/repository"
+	"github.com/MAPiryazev/OzonTest/internal/repository/inmemory"
+	"github.com/MAPiryazev/OzonTest/internal/repository/mocks"
+	"github.com/MAPiryazev/OzonTest/internal/service"
+	"github.com/MAPiryazev/OzonTest/internal/webhooks"
+	"github.com/golang/mock/gomock"
+)
+
+// хранилище с одним вебхуком на url и одной ожидающей доставкой
+func newWebhookStorage(t *testing.T, url string) repository.Storage {
+	t.Helper()
+	strg := inmemory.NewMemoryStorage()
+	ctx := context.Background()
+	now := time.Now().UTC()
+
+	if err := strg.CreateUser(ctx, &models.User{ID: "admin", Username: "admin", Role: models.RoleAdmin}); err != nil {
+		t.Fatalf("не удалось создать пользователя: %v", err)
+	}
+	webhook := &models.Webhook{ID: "w1", URL: url, Secret: "0123456789abcdef", Events: []models.WebhookEvent{models.WebhookPostCreated}, Active: true, CreatedBy: "admin", CreatedAt: now}
+	if err := strg.CreateWebhook(ctx, webhook); err != nil {
+		t.Fatalf("не удалось создать вебхук: %v", err)
+	}
+	payload, err := webhooks.Payload("d1", models.WebhookPostCreated, now, &models.Post{ID: "p1", Title: "t"})
+	if err != nil {
+		t.Fatalf("не удалось собрать тело: %v", err)
+	}
+	delivery := &models.WebhookDelivery{ID: "d1", WebhookID: "w1", Event: models.WebhookPostCreated, Payload: payload, Status: models.WebhookDeliveryPending, NextAttemptAt: now, CreatedAt: now}
+	if err := strg.CreateWebhookDeliveries(ctx, []*models.WebhookDelivery{delivery}); err != nil {
+		t.Fatalf("не удалось создать доставку: %v", err)
+	}
+	return strg
+}
+
+func TestWebhooks_Backoff(t *testing.T) {
+	base, limit := 10*time.Second, time.Minute
+	want := []time.Duration{10 * time.Second, 20 * time.Second, 40 * time.Second, time.Minute, time.Minute}
+	for i, expected := range want {
+		if got := webhooks.Backoff(base, limit, i+1); got != expected {
+			t.Fatalf("попытка %d: ожидалось %v, получено %v", i+1, expected, got)
+		}
+	}
+}
+
+func TestWebhookWorker_DeliversSigned(t *testing.T) {
+	received := make(chan *http.Request, 1)
+	var body []byte
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		received <- r
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	strg := newWebhookStorage(t, receiver.URL)
+	worker := webhooks.NewWorker(strg, &config.WebhookConfig{BatchSize: 10, MaxAttempts: 3, BackoffBaseSec: 1, BackoffMaxSec: 10, TimeoutSec: 5})
+	ctx := context.Background()
+
+	processed, err := worker.RunOnce(ctx)
+	if err != nil || processed != 1 {
+		t.Fatalf("ожидалась одна доставка: %d, %v", processed, err)
+	}
+
+	r := <-received
+	if r.Header.Get(webhooks.HeaderEvent) != string(models.WebhookPostCreated) || r.Header.Get(webhooks.HeaderDelivery) != "d1" {
+		t.Fatalf("неожиданные заголовки %v", r.Header)
+	}
+	// получатель проверяет подпись так же, как ее считает сервис
+	timestamp, err := strconv.ParseInt(r.Header.Get(webhooks.HeaderTimestamp), 10, 64)
+	if err != nil {
+		t.Fatalf("некорректная метка времени: %v", err)
+	}
+	if r.Header.Get(webhooks.HeaderSignature) != webhooks.Sign("0123456789abcdef", timestamp, body) {
+		t.Fatalf("подпись не совпала")
+	}
+	var envelope struct {
+		ID    string          `json:"id"`
+		Event string          `json:"event"`
+		Data  json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(body, &envelope); err != nil || envelope.ID != "d1" || !strings.Contains(string(envelope.Data), `"id":"p1"`) {
+		t.Fatalf("неожиданное тело %s: %v", body, err)
+	}
+
+	delivery, err := strg.GetWebhookDeliveryByID(ctx, "d1")
+	if err != nil || delivery.Status != models.WebhookDeliveryDelivered || delivery.Attempts != 1 || delivery.DeliveredAt == nil {
+		t.Fatalf("доставка должна быть отмечена доставленной: %v, %+v", err, delivery)
+	}
+	if processed, _ := worker.RunOnce(ctx); processed != 0 {
+		t.Fatalf("доставленное событие не должно отправляться повторно")
+	}
+}
+
+func TestWebhookWorker_RetriesThenDead(t *testing.T) {
+	var calls atomic.Int32
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		http.Error(w, "недоступно", http.StatusServiceUnavailable)
+	}))
+	defer receiver.Close()
+
+	strg := newWebhookStorage(t, receiver.URL)
+	// нулевая задержка, чтобы повтор был доступен сразу
+	worker := webhooks.NewWorker(strg, &config.WebhookConfig{BatchSize: 10, MaxAttempts: 3, BackoffBaseSec: 0, BackoffMaxSec: 0, TimeoutSec: 5})
+	ctx := context.Background()
+
+	for i := 1; i <= 3; i++ {
+		if processed, err := worker.RunOnce(ctx); err != nil || processed != 1 {
+			t.Fatalf("попытка %d: ожидалась одна доставка: %d, %v", i, processed, err)
+		}
+		delivery, _ := strg.GetWebhookDeliveryByID(ctx, "d1")
+		if delivery.Attempts != i || !strings.Contains(delivery.LastError, "503") {
+			t.Fatalf("попытка %d: неожиданное состояние %+v", i, delivery)
+		}
+		expected := models.WebhookDeliveryPending
+		if i == 3 {
+			expected = models.WebhookDeliveryDead
+		}
+		if delivery.Status != expected {
+			t.Fatalf("попытка %d: ожидался статус %s, получен %s", i, expected, delivery.Status)
+		}
+	}
+
+	// dead больше не забирается
+	if processed, _ := worker.RunOnce(ctx); processed != 0 || calls.Load() != 3 {
+		t.Fatalf("после исчерпания попыток запросов быть не должно, было %d", calls.Load())
+	}
+}
+
+func TestWebhookWorker_Backoff(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer receiver.Close()
+
+	strg := newWebhookStorage(t, receiver.URL)
+	worker := webhooks.NewWorker(strg, &config.WebhookConfig{BatchSize: 10, MaxAttempts: 5, BackoffBaseSec: 60, BackoffMaxSec: 600, TimeoutSec: 5})
+	ctx := context.Background()
+
+	before := time.Now().UTC()
+	if _, err := worker.RunOnce(ctx); err != nil {
+		t.Fatalf("ошибка разбора очереди: %v", err)
+	}
+	delivery, _ := strg.GetWebhookDeliveryByID(ctx, "d1")
+	if delivery.Status != models.WebhookDeliveryPending || delivery.NextAttemptAt.Before(before.Add(time.Minute)) {
+		t.Fatalf("повтор должен быть отложен на минуту: %+v", delivery)
+	}
+	if processed, _ := worker.RunOnce(ctx); processed != 0 {
+		t.Fatalf("отложенная доставка не должна забираться раньше времени")
+	}
+}
+
+func TestSQLiteStorage_WebhookDeliveries(t *testing.T) {
+	strg := newSQLiteStorage(t)
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Millisecond)
+
+	if err := strg.CreateUser(ctx, &models.User{ID: "admin", Username: "admin"}); err != nil {
+		t.Fatalf("не удалось создать пользователя: %v", err)
+	}
+	for _, id := range []string{"w1", "w2"} {
+		webhook := &models.Webhook{ID: id, URL: "http://example.com/" + id, Secret: "s", Events: []models.WebhookEvent{models.WebhookPostCreated, models.WebhookCommentCreated}, Active: true, CreatedBy: "admin", CreatedAt: now}
+		if err := strg.CreateWebhook(ctx, webhook); err != nil {
+			t.Fatalf("не удалось создать вебхук: %v", err)
+		}
+	}
+	webhooks, err := strg.ListWebhooks(ctx)
+	if err != nil || len(webhooks) != 2 || len(webhooks[0].Events) != 2 || !webhooks[0].Active {
+		t.Fatalf("неожиданные вебхуки: %v, %+v", err, webhooks)
+	}
+
+	var deliveries []*models.WebhookDelivery
+	for i, webhookID := range []string{"w1", "w1", "w2"} {
+		deliveries = append(deliveries, &models.WebhookDelivery{
+			ID: "d" + strconv.Itoa(i), WebhookID: webhookID, Event: models.WebhookPostCreated, Payload: "{}",
+			Status: models.WebhookDeliveryPending, NextAttemptAt: now.Add(time.Duration(i) * time.Second), CreatedAt: now.Add(time.Duration(i) * time.Second),
+		})
+	}
+	if err := strg.CreateWebhookDeliveries(ctx, deliveries); err != nil {
+		t.Fatalf("не удалось создать доставки: %v", err)
+	}
+
+	// доставки отключенного вебхука ждут его включения
+	if err := strg.SetWebhookActive(ctx, "w2", false); err != nil {
+		t.Fatalf("не удалось отключить вебхук: %v", err)
+	}
+	lease := now.Add(time.Minute)
+	claimed, err := strg.ClaimWebhookDeliveries(ctx, now.Add(10*time.Second), lease, 10)
+	if err != nil || len(claimed) != 2 {
+		t.Fatalf("ожидались две доставки w1: %v, %+v", err, claimed)
+	}
+	for _, d := range claimed {
+		if d.WebhookID != "w1" || !d.NextAttemptAt.Equal(lease) {
+			t.Fatalf("неожиданная доставка %+v", d)
+		}
+	}
+	// до конца аренды повторно не забираются
+	if again, _ := strg.ClaimWebhookDeliveries(ctx, now.Add(10*time.Second), lease, 10); len(again) != 0 {
+		t.Fatalf("арендованные доставки не должны забираться повторно: %+v", again)
+	}
+
+	delivered := claimed[0]
+	delivered.Status = models.WebhookDeliveryDelivered
+	delivered.Attempts = 1
+	delivered.DeliveredAt = &now
+	if err := strg.UpdateWebhookDelivery(ctx, delivered); err != nil {
+		t.Fatalf("не удалось обновить доставку: %v", err)
+	}
+	list, err := strg.ListWebhookDeliveries(ctx, "w1", models.WebhookDeliveryDelivered, 0, 10)
+	if err != nil || len(list) != 1 || list[0].ID != delivered.ID || list[0].DeliveredAt == nil {
+		t.Fatalf("ожидалась одна доставленная: %v, %+v", err, list)
+	}
+	list, err = strg.ListWebhookDeliveries(ctx, "w1", "", 0, 10)
+	if err != nil || len(list) != 2 || list[0].ID != "d1" {
+		t.Fatalf("ожидались все доставки от новых к старым: %v, %+v", err, list)
+	}
+
+	// удаление вебхука удаляет его доставки
+	if err := strg.DeleteWebhook(ctx, "w1"); err != nil {
+		t.Fatalf("не удалось удалить вебхук: %v", err)
+	}
+	if _, err := strg.GetWebhookDeliveryByID(ctx, "d0"); !errors.Is(err, customerrors.ErrNotFound) {
+		t.Fatalf("доставки должны удаляться вместе с вебхуком: %v", err)
+	}
+}
+
+func TestWebhookWorker_EnqueueFromOutbox(t *testing.T) {
+	strg := inmemory.NewMemoryStorage()
+	ctx := context.Background()
+	now := time.Now().UTC()
+
+	if err := strg.CreateUser(ctx, &models.User{ID: "admin", Username: "admin", Role: models.RoleAdmin}); err != nil {
+		t.Fatalf("не удалось создать пользователя: %v", err)
+	}
+	for _, webhook := range []*models.Webhook{
+		{ID: "w1", Active: true, Events: []models.WebhookEvent{models.WebhookPostCreated}},
+		{ID: "w2", Active: false, Events: []models.WebhookEvent{models.WebhookPostCreated}},
+		{ID: "w3", Active: true, Events: []models.WebhookEvent{models.WebhookCommentCreated}},
+	} {
+		webhook.URL, webhook.Secret, webhook.CreatedBy, webhook.CreatedAt = "http://example.com", "s", "admin", now
+		if err := strg.CreateWebhook(ctx, webhook); err != nil {
+			t.Fatalf("не удалось создать вебхук: %v", err)
+		}
+	}
+	if err := strg.CreatePost(ctx, &models.Post{ID: "p1", Title: "Заголовок", Content: "текст", AuthorID: "admin"}); err != nil {
+		t.Fatalf("не удалось создать пост: %v", err)
+	}
+
+	worker := webhooks.NewWorker(strg, &config.WebhookConfig{BatchSize: 10, TimeoutSec: 1})
+	relay := outbox.NewRelay(strg, &config.OutboxConfig{BatchSize: 10})
+	relay.Register("webhooks", worker.Enqueue)
+	if _, err := relay.RunOnce(ctx); err != nil {
+		t.Fatalf("не удалось разобрать outbox: %v", err)
+	}
+
+	// только активный вебхук, подписанный на событие; событие создания пользователя вебхукам не отправляется
+	deliveries, err := strg.ListWebhookDeliveries(ctx, "w1", "", 0, 10)
+	if err != nil || len(deliveries) != 1 || deliveries[0].Status != models.WebhookDeliveryPending {
+		t.Fatalf("ожидалась одна доставка w1: %v, %+v", err, deliveries)
+	}
+	if !strings.Contains(deliveries[0].Payload, `"event":"post_created"`) || !strings.Contains(deliveries[0].Payload, `"title":"Заголовок"`) {
+		t.Fatalf("неожиданное тело %s", deliveries[0].Payload)
+	}
+	for _, id := range []string{"w2", "w3"} {
+		if other, _ := strg.ListWebhookDeliveries(ctx, id, "", 0, 10); len(other) != 0 {
+			t.Fatalf("у %s не должно быть доставок: %+v", id, other)
+		}
+	}
+
+	// повторная обработка события после сбоя не создает дублей
+	events, err := strg.ListOutboxEvents(ctx, 0, 10)
+	if err != nil {
+		t.Fatalf("не удалось получить события: %v", err)
+	}
+	event := &models.OutboxEvent{Seq: 2, Type: models.EventPostCreated, AggregateID: "p1", Payload: "{}", CreatedAt: now}
+	if len(events) != 0 || deliveries[0].ID != webhooks.DeliveryID(event.Seq, "w1") {
+		t.Fatalf("неожиданные события %+v или id доставки %s", events, deliveries[0].ID)
+	}
+	if err := worker.Enqueue(ctx, event); err != nil {
+		t.Fatalf("не удалось повторно обработать событие: %v", err)
+	}
+	if again, _ := strg.ListWebhookDeliveries(ctx, "w1", "", 0, 10); len(again) != 1 || again[0].Payload != deliveries[0].Payload {
+		t.Fatalf("повтор события не должен создавать доставки: %+v", again)
+	}
+}
+
+func TestService_WebhooksRequireAdmin(t *testing.T) {
+	controller := gomock.NewController(t)
+	defer controller.Finish()
+
+	mockForRepository := mocks.NewMockStorage(controller)
+	svc := service.NewService(mockForRepository, &config.AppConfig{MaxListLimit: 100}, nil)
+	defer svc.Close()
+
+	user := auth.WithIdentity(context.Background(), &auth.Identity{UserID: "user1", Role: models.RoleModerator})
+	if _, err := svc.CreateWebhook(user, "http://example.com", []models.WebhookEvent{models.WebhookPostCreated}, ""); !errors.Is(err, customerrors.ErrForbidden) {
+		t.Fatalf("ожидалась ErrForbidden, получено %v", err)
+	}
+	if _, err := svc.ListWebhooks(context.Background()); !errors.Is(err, customerrors.ErrUnauthorized) {
+		t.Fatalf("ожидалась ErrUnauthorized, получено %v", err)
+	}
+
+	admin := auth.WithIdentity(context.Background(), &auth.Identity{UserID: "admin", Role: models.RoleAdmin})
+	mockForRepository.EXPECT().GetUserByID(admin, "admin").Return(&models.User{ID: "admin", Role: models.RoleAdmin}, nil).AnyTimes()
+	for _, url := range []string{"ftp://example.com", "/relative", ""} {
+		if _, err := svc.CreateWebhook(admin, url, []models.WebhookEvent{models.WebhookPostCreated}, ""); !errors.Is(err, customerrors.ErrValidation) {
+			t.Fatalf("%q: ожидалась ErrValidation, получено %v", url, err)
+		}
+	}
+	if _, err := svc.CreateWebhook(admin, "https://example.com", []models.WebhookEvent{"post_deleted"}, ""); !errors.Is(err, customerrors.ErrValidation) {
+		t.Fatalf("неизвестное событие должно отклоняться: %v", err)
+	}
+
+	mockForRepository.EXPECT().CreateWebhook(admin, gomock.Any()).Return(nil)
+	webhook, err := svc.CreateWebhook(admin, " https://example.com/hook ", []models.WebhookEvent{models.WebhookPostCreated, models.WebhookPostCreated}, "")
+	if err != nil {
+		t.Fatalf("не удалось создать вебхук: %v", err)
+	}
+	if webhook.URL != "https://example.com/hook" || len(webhook.Events) != 1 || len(webhook.Secret) != 64 || webhook.CreatedBy != "admin" {
+		t.Fatalf("неожиданный вебхук %+v", webhook)
+	}
+
+	mockForRepository.EXPECT().GetWebhookDeliveryByID(admin, "d1").Return(&models.WebhookDelivery{ID: "d1", Status: models.WebhookDeliveryPending}, nil)
+	if _, err := svc.RetryWebhookDelivery(admin, "d1"); !errors.Is(err, customerrors.ErrValidation) {
+		t.Fatalf("повторять можно только dead: %v", err)
+	}
+}
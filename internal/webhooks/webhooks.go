@@ -0,0 +1,259 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/MAPiryazev/OzonTest/internal/config"
+	"github.com/MAPiryazev/OzonTest/internal/models"
+	"github.com/MAPiryazev/OzonTest/internal/repository"
+)
+
+// заголовки запроса доставки
+const (
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderDelivery  = "X-Webhook-Delivery"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+// сколько тела ответа сохраняется в ошибке доставки
+const maxErrorBody = 512
+
+// тело доставки: Data - пост или комментарий в том виде, в котором он сохранен после события
+type envelope struct {
+	ID        string              `json:"id"`
+	Event     models.WebhookEvent `json:"event"`
+	CreatedAt time.Time           `json:"createdAt"`
+	Data      any                 `json:"data"`
+}
+
+// собирает json тело доставки, id совпадает с id доставки и служит ключом идемпотентности у получателя
+func Payload(deliveryID string, event models.WebhookEvent, at time.Time, data any) (string, error) {
+	body, err := json.Marshal(envelope{ID: deliveryID, Event: event, CreatedAt: at, Data: data})
+	if err != nil {
+		return "", fmt.Errorf("не удалось сериализовать событие %s: %w", event, err)
+	}
+	return string(body), nil
+}
+
+// подпись "sha256=<hex>" от HMAC-SHA256 строки "<timestamp>.<тело>",
+// метка времени входит в подпись, чтобы перехваченный запрос нельзя было повторить позже
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// задержка перед повтором после attempts неудачных попыток: base * 2^(attempts-1), не больше limit
+func Backoff(base, limit time.Duration, attempts int) time.Duration {
+	delay := base
+	for i := 1; i < attempts && delay < limit; i++ {
+		delay *= 2
+	}
+	return min(delay, limit)
+}
+
+// фоновый воркер: забирает ожидающие доставки из хранилища и отправляет их,
+// при нескольких экземплярах сервиса доставку забирает только один из них
+type Worker struct {
+	storage repository.Storage
+	cfg     *config.WebhookConfig
+	client  *http.Client
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+	once sync.Once
+}
+
+func NewWorker(storage repository.Storage, cfg *config.WebhookConfig) *Worker {
+	return &Worker{
+		storage: storage,
+		cfg:     cfg,
+		client:  &http.Client{Timeout: time.Duration(cfg.TimeoutSec) * time.Second},
+		stop:    make(chan struct{}),
+	}
+}
+
+// запускает опрос очереди в отдельной горутине
+func (w *Worker) Start() {
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		ticker := time.NewTicker(time.Duration(w.cfg.PollIntervalMs) * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				// пачку разбираем до конца, пока очередь не опустеет, чтобы не ждать тика после каждой пачки
+				for {
+					processed, err := w.RunOnce(context.Background())
+					if err != nil {
+						log.Printf("ошибка при разборе очереди вебхуков: %v", err)
+					}
+					if err != nil || processed < w.cfg.BatchSize || w.stopped() {
+						break
+					}
+				}
+			case <-w.stop:
+				return
+			}
+		}
+	}()
+}
+
+// останавливает опрос и дожидается запросов, которые уже отправляются;
+// они ограничены таймаутом клиента, поэтому Stop не зависает на медленном получателе
+func (w *Worker) Stop() {
+	w.once.Do(func() { close(w.stop) })
+	w.wg.Wait()
+}
+
+func (w *Worker) stopped() bool {
+	select {
+	case <-w.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// забирает одну пачку доставок и отправляет их параллельно, возвращает размер пачки
+func (w *Worker) RunOnce(ctx context.Context) (int, error) {
+	now := time.Now().UTC()
+	// аренда с запасом на таймаут: если воркер упадет посреди запроса, доставку заберут повторно
+	lease := now.Add(2 * time.Duration(w.cfg.TimeoutSec) * time.Second)
+	deliveries, err := w.storage.ClaimWebhookDeliveries(ctx, now, lease, w.cfg.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	var wg sync.WaitGroup
+	for _, d := range deliveries {
+		wg.Add(1)
+		go func(d *models.WebhookDelivery) {
+			defer wg.Done()
+			w.deliver(ctx, d)
+		}(d)
+	}
+	wg.Wait()
+	return len(deliveries), nil
+}
+
+// потребитель outbox: ставит в очередь доставку события всем активным вебхукам, подписанным на него.
+// id доставки выводится из seq события и id вебхука, поэтому повторная обработка того же события
+// не создает дублей, а получатель видит один и тот же id
+func (w *Worker) Enqueue(ctx context.Context, event *models.OutboxEvent) error {
+	webhookEvent := models.WebhookEvent(event.Type)
+	all, err := w.storage.ListWebhooks(ctx)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	var deliveries []*models.WebhookDelivery
+	for _, webhook := range all {
+		if !webhook.Active || !slices.Contains(webhook.Events, webhookEvent) {
+			continue
+		}
+		id := DeliveryID(event.Seq, webhook.ID)
+		payload, err := Payload(id, webhookEvent, event.CreatedAt, json.RawMessage(event.Payload))
+		if err != nil {
+			return err
+		}
+		deliveries = append(deliveries, &models.WebhookDelivery{
+			ID:            id,
+			WebhookID:     webhook.ID,
+			Event:         webhookEvent,
+			Payload:       payload,
+			Status:        models.WebhookDeliveryPending,
+			NextAttemptAt: now,
+			CreatedAt:     now,
+		})
+	}
+	return w.storage.CreateWebhookDeliveries(ctx, deliveries)
+}
+
+// детерминированный id доставки события seq вебхуку webhookID
+func DeliveryID(seq int64, webhookID string) string {
+	return uuid.NewSHA1(uuid.NameSpaceOID, []byte(strconv.FormatInt(seq, 10)+"/"+webhookID)).String()
+}
+
+// одна попытка доставки, результат сохраняется в хранилище
+func (w *Worker) deliver(ctx context.Context, d *models.WebhookDelivery) {
+	webhook, err := w.storage.GetWebhookByID(ctx, d.WebhookID)
+	if err != nil {
+		// вебхук удален между выборкой и отправкой, доставки удалились вместе с ним
+		log.Printf("не удалось получить вебхук %s для доставки %s: %v", d.WebhookID, d.ID, err)
+		return
+	}
+
+	sendErr := w.send(ctx, webhook, d)
+	d.Attempts++
+	now := time.Now().UTC()
+	switch {
+	case sendErr == nil:
+		d.Status = models.WebhookDeliveryDelivered
+		d.DeliveredAt = &now
+		d.LastError = ""
+	case d.Attempts >= w.cfg.MaxAttempts:
+		d.Status = models.WebhookDeliveryDead
+		d.LastError = sendErr.Error()
+		log.Printf("доставка %s вебхука %s исчерпала попытки: %v", d.ID, webhook.ID, sendErr)
+	default:
+		base := time.Duration(w.cfg.BackoffBaseSec) * time.Second
+		limit := time.Duration(w.cfg.BackoffMaxSec) * time.Second
+		d.NextAttemptAt = now.Add(Backoff(base, limit, d.Attempts))
+		d.LastError = sendErr.Error()
+	}
+
+	if err := w.storage.UpdateWebhookDelivery(ctx, d); err != nil {
+		// аренда истечет, и доставка уйдет повторно: получатель отличает повторы по id
+		log.Printf("не удалось сохранить результат доставки %s: %v", d.ID, err)
+	}
+}
+
+// успехом считается любой ответ 2xx
+func (w *Worker) send(ctx context.Context, webhook *models.Webhook, d *models.WebhookDelivery) error {
+	body := []byte(d.Payload)
+	timestamp := time.Now().UTC().Unix()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("некорректный запрос: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, string(d.Event))
+	req.Header.Set(HeaderDelivery, d.ID)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(webhook.Secret, timestamp, body))
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+	return fmt.Errorf("получатель ответил %d: %s", resp.StatusCode, bytes.TrimSpace(respBody))
+}
@@ -0,0 +1,11 @@
+package migrations
+
+import "embed"
+
+// sql миграции, встроенные в бинарник: <версия>_<имя>.up.sql и <версия>_<имя>.down.sql
+
+//go:embed postgres/*.sql
+var Postgres embed.FS
+
+//go:embed sqlite/*.sql
+var SQLite embed.FS
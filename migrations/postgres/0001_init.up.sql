@@ -0,0 +1,37 @@
+--простая модель данных, покрывающая нужды тестового задания
+--if not exists нужен для баз, созданных раньше через docker-entrypoint-initdb.d
+create table if not exists users(
+    id uuid primary key,
+    username varchar(255) not null
+);
+
+create table if not exists posts(
+    id uuid primary key,
+    title varchar(255) not null,
+    content text not null,
+    author_id uuid not null references users(id),
+    comments_enabled boolean default true,
+    created_at timestamp not null default now()
+);
+
+create table if not exists comments (
+    id uuid primary key,
+    post_id uuid not null references posts(id),
+    parent_id uuid null references comments(id), --связь с родительским комментарием
+    author_id uuid not null references users(id),
+    text varchar(2000) not null, --2к символов ограничение
+    created_at timestamp not null default now()
+);
+
+--индексы
+create unique index if not exists idx_users_username on users(username);
+create index if not exists idx_posts_author_id on posts(author_id);
+create index if not exists idx_posts_created_at on posts(created_at);
+create index if not exists idx_comments_post_id on comments(post_id);
+create index if not exists idx_comments_parent_id on comments(parent_id);
+create index if not exists idx_comments_author_id on comments(author_id);
+create index if not exists idx_comments_created_at on comments(created_at);
+
+--индексы для keyset пагинации по (created_at, id)
+create index if not exists idx_posts_created_at_id on posts(created_at desc, id desc);
+create index if not exists idx_comments_post_parent_created_at_id on comments(post_id, parent_id, created_at, id);
@@ -0,0 +1,4 @@
+-- удаляет тестовые объекты из 0002_seed.up.sql вместе с комментариями к тестовым постам
+delete from comments where post_id in ('550e8400-e29b-41d4-a716-446655440100', '550e8400-e29b-41d4-a716-446655440101', '550e8400-e29b-41d4-a716-446655440102');
+delete from posts where id in ('550e8400-e29b-41d4-a716-446655440100', '550e8400-e29b-41d4-a716-446655440101', '550e8400-e29b-41d4-a716-446655440102');
+delete from users where id in ('550e8400-e29b-41d4-a716-446655440000', '550e8400-e29b-41d4-a716-446655440001', '550e8400-e29b-41d4-a716-446655440002', '550e8400-e29b-41d4-a716-446655440003');
@@ -1,17 +1,19 @@
--- этот скрипт заполняет БД тестовыми объектами при первом старте
+-- тестовые объекты, on conflict нужен для баз, уже заполненных через docker-entrypoint-initdb.d
 
 insert into users (id, username) 
 values(
 '550e8400-e29b-41d4-a716-446655440000', 'vasya'),
 ('550e8400-e29b-41d4-a716-446655440001', 'petya'),
 ('550e8400-e29b-41d4-a716-446655440002', 'masha'),
-('550e8400-e29b-41d4-a716-446655440003', 'kolya');
+('550e8400-e29b-41d4-a716-446655440003', 'kolya')
+on conflict do nothing;
 
 insert into posts (id, title, content, author_id, comments_enabled, created_at) 
 values(
 '550e8400-e29b-41d4-a716-446655440100', 'Первый пост', 'Это мой первый пост!', '550e8400-e29b-41d4-a716-446655440000', true, now() - interval '2 days'),
 ('550e8400-e29b-41d4-a716-446655440101', 'Второй пост', 'А тут я запретил комментарии.', '550e8400-e29b-41d4-a716-446655440001', false, now() - interval '1 day'),
-('550e8400-e29b-41d4-a716-446655440102', 'Третий пост', 'В этом посте можно комментировать что угодно.', '550e8400-e29b-41d4-a716-446655440002', true, now());
+('550e8400-e29b-41d4-a716-446655440102', 'Третий пост', 'В этом посте можно комментировать что угодно.', '550e8400-e29b-41d4-a716-446655440002', true, now())
+on conflict do nothing;
 
 insert into comments (id, post_id, parent_id, author_id, text, created_at) 
 values(
@@ -19,11 +21,13 @@ values(
 ('550e8400-e29b-41d4-a716-446655440201', '550e8400-e29b-41d4-a716-446655440100', '550e8400-e29b-41d4-a716-446655440200', '550e8400-e29b-41d4-a716-446655440000', 'Спасибо, рад что понравилось!', now() - interval '23 hours'),
 ('550e8400-e29b-41d4-a716-446655440202', '550e8400-e29b-41d4-a716-446655440100', null, '550e8400-e29b-41d4-a716-446655440002', 'А мне не очень зашло :(', now() - interval '20 hours'),
 ('550e8400-e29b-41d4-a716-446655440203', '550e8400-e29b-41d4-a716-446655440100', '550e8400-e29b-41d4-a716-446655440202', '550e8400-e29b-41d4-a716-446655440003', 'Ну ты слишком строгий критик!', now() - interval '19 hours'),
-('550e8400-e29b-41d4-a716-446655440204', '550e8400-e29b-41d4-a716-446655440100', '550e8400-e29b-41d4-a716-446655440203', '550e8400-e29b-41d4-a716-446655440002', 'Ок, возможно я перегнул.', now() - interval '18 hours');
+('550e8400-e29b-41d4-a716-446655440204', '550e8400-e29b-41d4-a716-446655440100', '550e8400-e29b-41d4-a716-446655440203', '550e8400-e29b-41d4-a716-446655440002', 'Ок, возможно я перегнул.', now() - interval '18 hours')
+on conflict do nothing;
 
 insert into comments (id, post_id, parent_id, author_id, text, created_at) 
 values(
 '550e8400-e29b-41d4-a716-446655440210', '550e8400-e29b-41d4-a716-446655440102', null, '550e8400-e29b-41d4-a716-446655440000', 'Первый коммент!', now() - interval '10 hours'),
 ('550e8400-e29b-41d4-a716-446655440211', '550e8400-e29b-41d4-a716-446655440102', null, '550e8400-e29b-41d4-a716-446655440001', 'Второй коммент!', now() - interval '9 hours'),
 ('550e8400-e29b-41d4-a716-446655440212', '550e8400-e29b-41d4-a716-446655440102', '550e8400-e29b-41d4-a716-446655440211', '550e8400-e29b-41d4-a716-446655440002', 'Ответ на второй коммент', now() - interval '8 hours'),
-('550e8400-e29b-41d4-a716-446655440213', '550e8400-e29b-41d4-a716-446655440102', '550e8400-e29b-41d4-a716-446655440212', '550e8400-e29b-41d4-a716-446655440003', 'И ещё один уровень вложенности', now() - interval '7 hours');
+('550e8400-e29b-41d4-a716-446655440213', '550e8400-e29b-41d4-a716-446655440102', '550e8400-e29b-41d4-a716-446655440212', '550e8400-e29b-41d4-a716-446655440003', 'И ещё один уровень вложенности', now() - interval '7 hours')
+on conflict do nothing;
@@ -0,0 +1,3 @@
+alter table users drop column locked_until;
+alter table users drop column failed_logins;
+alter table users drop column password_hash;
@@ -0,0 +1,2 @@
+alter table users drop constraint users_role_check;
+alter table users drop column role;
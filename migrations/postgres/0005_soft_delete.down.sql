@@ -0,0 +1,7 @@
+alter table comments drop constraint comments_parent_id_fkey;
+alter table comments add constraint comments_parent_id_fkey foreign key (parent_id) references comments(id);
+alter table comments drop constraint comments_post_id_fkey;
+alter table comments add constraint comments_post_id_fkey foreign key (post_id) references posts(id);
+
+alter table comments drop column deleted_at;
+alter table posts drop column deleted_at;
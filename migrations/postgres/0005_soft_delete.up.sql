@@ -0,0 +1,9 @@
+--мягкое удаление постов и комментариев
+alter table posts add column deleted_at timestamp null;
+alter table comments add column deleted_at timestamp null;
+
+--физическое удаление поста или комментария забирает с собой всё поддерево
+alter table comments drop constraint comments_post_id_fkey;
+alter table comments add constraint comments_post_id_fkey foreign key (post_id) references posts(id) on delete cascade;
+alter table comments drop constraint comments_parent_id_fkey;
+alter table comments add constraint comments_parent_id_fkey foreign key (parent_id) references comments(id) on delete cascade;
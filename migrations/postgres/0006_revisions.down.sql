@@ -0,0 +1,5 @@
+drop table comment_revisions;
+drop table post_revisions;
+
+alter table comments drop column edited_at;
+alter table posts drop column edited_at;
@@ -0,0 +1,23 @@
+--история правок: в таблицах ревизий хранятся предыдущие версии, edited_at - время последней правки
+alter table posts add column edited_at timestamp null;
+alter table comments add column edited_at timestamp null;
+
+create table post_revisions (
+    id uuid primary key,
+    post_id uuid not null references posts(id) on delete cascade,
+    title varchar(255) not null,
+    content text not null,
+    editor_id uuid not null references users(id),
+    created_at timestamp not null default now()
+);
+
+create table comment_revisions (
+    id uuid primary key,
+    comment_id uuid not null references comments(id) on delete cascade,
+    text varchar(2000) not null,
+    editor_id uuid not null references users(id),
+    created_at timestamp not null default now()
+);
+
+create index idx_post_revisions_post_id_created_at on post_revisions(post_id, created_at);
+create index idx_comment_revisions_comment_id_created_at on comment_revisions(comment_id, created_at);
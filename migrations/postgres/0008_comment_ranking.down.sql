@@ -0,0 +1,6 @@
+drop index idx_comments_rank_controversial;
+drop index idx_comments_rank_top;
+alter table comments drop column rank_controversial;
+alter table comments drop column rank_top;
+drop function controversy(integer, integer);
+drop function wilson_lower_bound(integer, integer);
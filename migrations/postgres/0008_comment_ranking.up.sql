@@ -0,0 +1,35 @@
+--ранги комментариев для сортировок top и controversial, те же формулы в internal/ranking
+create function wilson_lower_bound(up integer, down integer) returns double precision
+language sql immutable as $$
+    select case when up + down = 0 then 0
+        else ((up::float8 / (up + down)) + 1.9208 / (up + down)
+            - 1.96 * sqrt(up::float8 * down / (up + down) + 0.9604) / (up + down))
+            / (1 + 3.8416 / (up + down))
+    end
+$$;
+
+create function controversy(up integer, down integer) returns double precision
+language sql immutable as $$
+    select case when up <= 0 or down <= 0 then 0
+        else power((up + down)::float8, least(up, down)::float8 / greatest(up, down))
+    end
+$$;
+
+--ранги хранятся в строке комментария и пересчитываются вместе со счетчиками реакций
+alter table comments add column rank_top double precision not null default 0;
+alter table comments add column rank_controversial double precision not null default 0;
+
+update comments set
+    rank_top = wilson_lower_bound(votes.up, votes.down),
+    rank_controversial = controversy(votes.up, votes.down)
+from (
+    select comment_id,
+        coalesce(sum(count) filter (where kind = 'up'), 0)::integer as up,
+        coalesce(sum(count) filter (where kind = 'down'), 0)::integer as down
+    from comment_reaction_counts
+    group by comment_id
+) as votes
+where comments.id = votes.comment_id;
+
+create index idx_comments_rank_top on comments(post_id, parent_id, rank_top desc, created_at, id);
+create index idx_comments_rank_controversial on comments(post_id, parent_id, rank_controversial desc, created_at, id);
@@ -0,0 +1,4 @@
+drop index idx_comments_search_vector;
+drop index idx_posts_search_vector;
+alter table comments drop column search_vector;
+alter table posts drop column search_vector;
@@ -0,0 +1,8 @@
+--полнотекстовый поиск: векторы со стеммингом russian вычисляются самой базой, заголовок поста весит больше текста
+alter table posts add column search_vector tsvector generated always as (
+    setweight(to_tsvector('russian', title), 'A') || setweight(to_tsvector('russian', content), 'B')
+) stored;
+alter table comments add column search_vector tsvector generated always as (to_tsvector('russian', text)) stored;
+
+create index idx_posts_search_vector on posts using gin(search_vector);
+create index idx_comments_search_vector on comments using gin(search_vector);
@@ -0,0 +1,15 @@
+--уведомления об ответах на посты и комментарии, удаляются вместе с комментарием
+create table notifications (
+    id uuid primary key,
+    user_id uuid not null references users(id),
+    kind varchar(16) not null check (kind in ('post_comment', 'comment_reply')),
+    actor_id uuid not null references users(id),
+    post_id uuid not null references posts(id) on delete cascade,
+    comment_id uuid not null references comments(id) on delete cascade,
+    created_at timestamp not null default now(),
+    read_at timestamp null
+);
+
+--лента пользователя от новых к старым и счетчик непрочитанных
+create index idx_notifications_user_created on notifications(user_id, created_at desc, id desc);
+create index idx_notifications_user_unread on notifications(user_id) where read_at is null;
@@ -0,0 +1,7 @@
+delete from notifications where kind = 'mention';
+alter table notifications drop constraint notifications_kind_check;
+alter table notifications add constraint notifications_kind_check check (kind in ('post_comment', 'comment_reply'));
+alter table notifications alter column comment_id set not null;
+
+drop table comment_mentions;
+drop table post_mentions;
@@ -0,0 +1,22 @@
+--упоминания @username, position - порядок первого появления в тексте
+create table post_mentions (
+    post_id uuid not null references posts(id) on delete cascade,
+    user_id uuid not null references users(id),
+    position integer not null,
+    primary key (post_id, user_id)
+);
+
+create table comment_mentions (
+    comment_id uuid not null references comments(id) on delete cascade,
+    user_id uuid not null references users(id),
+    position integer not null,
+    primary key (comment_id, user_id)
+);
+
+create index idx_post_mentions_user_id on post_mentions(user_id);
+create index idx_comment_mentions_user_id on comment_mentions(user_id);
+
+--упоминание в посте не относится к комментарию
+alter table notifications alter column comment_id drop not null;
+alter table notifications drop constraint notifications_kind_check;
+alter table notifications add constraint notifications_kind_check check (kind in ('post_comment', 'comment_reply', 'mention'));
@@ -0,0 +1,28 @@
+--подписки внешних сервисов на события постов и комментариев
+create table webhooks (
+    id uuid primary key,
+    url text not null,
+    secret text not null,
+    events text[] not null,
+    active boolean not null default true,
+    created_by uuid not null references users(id),
+    created_at timestamp not null default now()
+);
+
+--очередь доставок, строки остаются после доставки для истории, удаляются вместе с вебхуком
+create table webhook_deliveries (
+    id uuid primary key,
+    webhook_id uuid not null references webhooks(id) on delete cascade,
+    event varchar(32) not null,
+    payload text not null,
+    status varchar(16) not null check (status in ('pending', 'delivered', 'dead')),
+    attempts int not null default 0,
+    next_attempt_at timestamp not null,
+    last_error text not null default '',
+    created_at timestamp not null default now(),
+    delivered_at timestamp null
+);
+
+--воркер выбирает ожидающие доставки по времени следующей попытки
+create index idx_webhook_deliveries_due on webhook_deliveries(next_attempt_at) where status = 'pending';
+create index idx_webhook_deliveries_webhook on webhook_deliveries(webhook_id, created_at desc, id desc);
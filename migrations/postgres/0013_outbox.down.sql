@@ -0,0 +1,2 @@
+drop table outbox_offsets;
+drop table outbox;
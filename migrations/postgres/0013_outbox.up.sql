@@ -0,0 +1,15 @@
+--доменные события, пишутся в одной транзакции с изменением данных; seq задает порядок доставки
+create table outbox (
+    seq bigserial primary key,
+    type varchar(32) not null,
+    aggregate_id uuid not null,
+    payload text not null,
+    created_at timestamp not null default now()
+);
+
+--последнее обработанное событие для каждого потребителя
+create table outbox_offsets (
+    consumer varchar(64) primary key,
+    seq bigint not null,
+    updated_at timestamp not null default now()
+);
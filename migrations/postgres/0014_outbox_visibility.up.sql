@@ -0,0 +1,3 @@
+--граница видимости события: его можно отдавать, когда завершились все транзакции, начатые до выдачи seq,
+--тогда ни одно событие с меньшим seq уже не появится
+alter table outbox add column visible_after xid8 not null default '0';
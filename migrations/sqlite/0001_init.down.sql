@@ -0,0 +1,3 @@
+drop table if exists comments;
+drop table if exists posts;
+drop table if exists users;
@@ -0,0 +1,31 @@
+-- схема из migrations/postgres/0001_init.up.sql, адаптированная под sqlite: uuid и время хранятся текстом
+create table if not exists users(
+    id text primary key,
+    username text not null
+);
+
+create table if not exists posts(
+    id text primary key,
+    title text not null check (length(title) <= 255),
+    content text not null,
+    author_id text not null references users(id),
+    comments_enabled integer not null default 1,
+    created_at text not null
+);
+
+create table if not exists comments (
+    id text primary key,
+    post_id text not null references posts(id),
+    parent_id text null references comments(id), --связь с родительским комментарием
+    author_id text not null references users(id),
+    text text not null check (length(text) <= 2000), --2к символов ограничение
+    created_at text not null
+);
+
+--индексы
+create unique index if not exists idx_users_username on users(username);
+create index if not exists idx_posts_author_id on posts(author_id);
+create index if not exists idx_posts_created_at_id on posts(created_at desc, id desc);
+create index if not exists idx_comments_post_parent_created_at_id on comments(post_id, parent_id, created_at, id);
+create index if not exists idx_comments_parent_id on comments(parent_id);
+create index if not exists idx_comments_author_id on comments(author_id);
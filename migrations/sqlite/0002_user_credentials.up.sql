@@ -0,0 +1,4 @@
+--пароль (argon2id в формате PHC) и счетчик неудачных входов для блокировки
+alter table users add column password_hash text not null default '';
+alter table users add column failed_logins integer not null default 0;
+alter table users add column locked_until text null;
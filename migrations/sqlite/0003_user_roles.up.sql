@@ -0,0 +1,2 @@
+--роли пользователей: user, moderator, admin
+alter table users add column role text not null default 'user' check (role in ('user', 'moderator', 'admin'));
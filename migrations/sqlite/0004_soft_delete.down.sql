@@ -0,0 +1,21 @@
+alter table comments rename to comments_old;
+
+create table comments (
+    id text primary key,
+    post_id text not null references posts(id),
+    parent_id text null references comments(id), --связь с родительским комментарием
+    author_id text not null references users(id),
+    text text not null check (length(text) <= 2000), --2к символов ограничение
+    created_at text not null
+);
+
+insert into comments (id, post_id, parent_id, author_id, text, created_at)
+select id, post_id, parent_id, author_id, text, created_at from comments_old;
+
+drop table comments_old;
+
+create index idx_comments_post_parent_created_at_id on comments(post_id, parent_id, created_at, id);
+create index idx_comments_parent_id on comments(parent_id);
+create index idx_comments_author_id on comments(author_id);
+
+alter table posts drop column deleted_at;
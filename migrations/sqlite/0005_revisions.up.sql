@@ -0,0 +1,23 @@
+--история правок: в таблицах ревизий хранятся предыдущие версии, edited_at - время последней правки
+alter table posts add column edited_at text null;
+alter table comments add column edited_at text null;
+
+create table post_revisions (
+    id text primary key,
+    post_id text not null references posts(id) on delete cascade,
+    title text not null check (length(title) <= 255),
+    content text not null,
+    editor_id text not null references users(id),
+    created_at text not null
+);
+
+create table comment_revisions (
+    id text primary key,
+    comment_id text not null references comments(id) on delete cascade,
+    text text not null check (length(text) <= 2000),
+    editor_id text not null references users(id),
+    created_at text not null
+);
+
+create index idx_post_revisions_post_id_created_at on post_revisions(post_id, created_at);
+create index idx_comment_revisions_comment_id_created_at on comment_revisions(comment_id, created_at);
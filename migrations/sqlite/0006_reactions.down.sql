@@ -0,0 +1,4 @@
+drop table comment_reaction_counts;
+drop table comment_reactions;
+drop table post_reaction_counts;
+drop table post_reactions;
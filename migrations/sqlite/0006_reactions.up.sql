@@ -0,0 +1,34 @@
+--реакции: одна на пользователя и объект, счетчики обновляются вместе с реакцией, чтобы не считать строки при чтении
+create table post_reactions (
+    post_id text not null references posts(id) on delete cascade,
+    user_id text not null references users(id),
+    kind text not null check (kind in ('up', 'down', 'like', 'heart', 'laugh', 'sad')),
+    created_at text not null,
+    primary key (post_id, user_id)
+);
+
+create table post_reaction_counts (
+    post_id text not null references posts(id) on delete cascade,
+    kind text not null,
+    count integer not null default 0 check (count >= 0),
+    primary key (post_id, kind)
+);
+
+create table comment_reactions (
+    comment_id text not null references comments(id) on delete cascade,
+    user_id text not null references users(id),
+    kind text not null check (kind in ('up', 'down', 'like', 'heart', 'laugh', 'sad')),
+    created_at text not null,
+    primary key (comment_id, user_id)
+);
+
+create table comment_reaction_counts (
+    comment_id text not null references comments(id) on delete cascade,
+    kind text not null,
+    count integer not null default 0 check (count >= 0),
+    primary key (comment_id, kind)
+);
+
+--реакции пользователя для поля myReaction
+create index idx_post_reactions_user_id on post_reactions(user_id);
+create index idx_comment_reactions_user_id on comment_reactions(user_id);
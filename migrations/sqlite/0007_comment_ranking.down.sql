@@ -0,0 +1,4 @@
+drop index idx_comments_rank_controversial;
+drop index idx_comments_rank_top;
+alter table comments drop column rank_controversial;
+alter table comments drop column rank_top;
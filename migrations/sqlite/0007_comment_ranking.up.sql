@@ -0,0 +1,19 @@
+--ранги комментариев для сортировок top и controversial, функции wilson_lower_bound и controversy
+--регистрируются драйвером из internal/ranking
+alter table comments add column rank_top real not null default 0;
+alter table comments add column rank_controversial real not null default 0;
+
+update comments set
+    rank_top = wilson_lower_bound(votes.up, votes.down),
+    rank_controversial = controversy(votes.up, votes.down)
+from (
+    select comment_id,
+        coalesce(sum(case when kind = 'up' then count end), 0) as up,
+        coalesce(sum(case when kind = 'down' then count end), 0) as down
+    from comment_reaction_counts
+    group by comment_id
+) as votes
+where comments.id = votes.comment_id;
+
+create index idx_comments_rank_top on comments(post_id, parent_id, rank_top desc, created_at, id);
+create index idx_comments_rank_controversial on comments(post_id, parent_id, rank_controversial desc, created_at, id);
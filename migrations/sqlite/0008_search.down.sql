@@ -0,0 +1,10 @@
+drop trigger comments_fts_after_insert;
+drop trigger comments_fts_after_update;
+drop trigger comments_fts_before_delete;
+drop trigger comments_fts_before_update;
+drop trigger posts_fts_after_insert;
+drop trigger posts_fts_after_update;
+drop trigger posts_fts_before_delete;
+drop trigger posts_fts_before_update;
+drop table comments_fts;
+drop table posts_fts;
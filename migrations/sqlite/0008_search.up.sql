@@ -0,0 +1,34 @@
+--полнотекстовый поиск на fts4: индекс хранит только термы, тексты берутся из самих таблиц через rowid,
+--remove_diacritics=0 не дает превращать й в и, иначе не совпадут префиксы запроса
+create virtual table posts_fts using fts4(content="posts", title, content, tokenize=unicode61 "remove_diacritics=0");
+create virtual table comments_fts using fts4(content="comments", text, tokenize=unicode61 "remove_diacritics=0");
+
+--для внешнего содержимого старые термы удаляются до изменения строки, новые добавляются после
+create trigger posts_fts_before_update before update of title, content on posts begin
+    delete from posts_fts where docid = old.rowid;
+end;
+create trigger posts_fts_before_delete before delete on posts begin
+    delete from posts_fts where docid = old.rowid;
+end;
+create trigger posts_fts_after_update after update of title, content on posts begin
+    insert into posts_fts(docid, title, content) values (new.rowid, new.title, new.content);
+end;
+create trigger posts_fts_after_insert after insert on posts begin
+    insert into posts_fts(docid, title, content) values (new.rowid, new.title, new.content);
+end;
+
+create trigger comments_fts_before_update before update of text on comments begin
+    delete from comments_fts where docid = old.rowid;
+end;
+create trigger comments_fts_before_delete before delete on comments begin
+    delete from comments_fts where docid = old.rowid;
+end;
+create trigger comments_fts_after_update after update of text on comments begin
+    insert into comments_fts(docid, text) values (new.rowid, new.text);
+end;
+create trigger comments_fts_after_insert after insert on comments begin
+    insert into comments_fts(docid, text) values (new.rowid, new.text);
+end;
+
+insert into posts_fts(posts_fts) values ('rebuild');
+insert into comments_fts(comments_fts) values ('rebuild');
@@ -0,0 +1,15 @@
+--уведомления об ответах на посты и комментарии, удаляются вместе с комментарием
+create table notifications (
+    id text primary key,
+    user_id text not null references users(id),
+    kind text not null check (kind in ('post_comment', 'comment_reply')),
+    actor_id text not null references users(id),
+    post_id text not null references posts(id) on delete cascade,
+    comment_id text not null references comments(id) on delete cascade,
+    created_at text not null,
+    read_at text null
+);
+
+--лента пользователя от новых к старым и счетчик непрочитанных
+create index idx_notifications_user_created on notifications(user_id, created_at desc, id desc);
+create index idx_notifications_user_unread on notifications(user_id) where read_at is null;
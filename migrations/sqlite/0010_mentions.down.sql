@@ -0,0 +1,21 @@
+create table notifications_old (
+    id text primary key,
+    user_id text not null references users(id),
+    kind text not null check (kind in ('post_comment', 'comment_reply')),
+    actor_id text not null references users(id),
+    post_id text not null references posts(id) on delete cascade,
+    comment_id text not null references comments(id) on delete cascade,
+    created_at text not null,
+    read_at text null
+);
+
+insert into notifications_old
+select id, user_id, kind, actor_id, post_id, comment_id, created_at, read_at from notifications where kind != 'mention';
+drop table notifications;
+alter table notifications_old rename to notifications;
+
+create index idx_notifications_user_created on notifications(user_id, created_at desc, id desc);
+create index idx_notifications_user_unread on notifications(user_id) where read_at is null;
+
+drop table comment_mentions;
+drop table post_mentions;
@@ -0,0 +1,36 @@
+--упоминания @username, position - порядок первого появления в тексте
+create table post_mentions (
+    post_id text not null references posts(id) on delete cascade,
+    user_id text not null references users(id),
+    position integer not null,
+    primary key (post_id, user_id)
+);
+
+create table comment_mentions (
+    comment_id text not null references comments(id) on delete cascade,
+    user_id text not null references users(id),
+    position integer not null,
+    primary key (comment_id, user_id)
+);
+
+create index idx_post_mentions_user_id on post_mentions(user_id);
+create index idx_comment_mentions_user_id on comment_mentions(user_id);
+
+--упоминание в посте не относится к комментарию; sqlite не умеет менять ограничения, поэтому таблица пересоздается
+create table notifications_new (
+    id text primary key,
+    user_id text not null references users(id),
+    kind text not null check (kind in ('post_comment', 'comment_reply', 'mention')),
+    actor_id text not null references users(id),
+    post_id text not null references posts(id) on delete cascade,
+    comment_id text null references comments(id) on delete cascade,
+    created_at text not null,
+    read_at text null
+);
+
+insert into notifications_new select id, user_id, kind, actor_id, post_id, comment_id, created_at, read_at from notifications;
+drop table notifications;
+alter table notifications_new rename to notifications;
+
+create index idx_notifications_user_created on notifications(user_id, created_at desc, id desc);
+create index idx_notifications_user_unread on notifications(user_id) where read_at is null;
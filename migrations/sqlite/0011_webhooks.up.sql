@@ -0,0 +1,28 @@
+--подписки внешних сервисов на события постов и комментариев, events - json массив
+create table webhooks (
+    id text primary key,
+    url text not null,
+    secret text not null,
+    events text not null,
+    active integer not null default 1,
+    created_by text not null references users(id),
+    created_at text not null
+);
+
+--очередь доставок, строки остаются после доставки для истории, удаляются вместе с вебхуком
+create table webhook_deliveries (
+    id text primary key,
+    webhook_id text not null references webhooks(id) on delete cascade,
+    event text not null,
+    payload text not null,
+    status text not null check (status in ('pending', 'delivered', 'dead')),
+    attempts integer not null default 0,
+    next_attempt_at text not null,
+    last_error text not null default '',
+    created_at text not null,
+    delivered_at text null
+);
+
+--воркер выбирает ожидающие доставки по времени следующей попытки
+create index idx_webhook_deliveries_due on webhook_deliveries(next_attempt_at) where status = 'pending';
+create index idx_webhook_deliveries_webhook on webhook_deliveries(webhook_id, created_at desc, id desc);
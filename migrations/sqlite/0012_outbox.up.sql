@@ -0,0 +1,15 @@
+--доменные события, пишутся в одной транзакции с изменением данных; seq задает порядок доставки и не переиспользуется
+create table outbox (
+    seq integer primary key autoincrement,
+    type text not null,
+    aggregate_id text not null,
+    payload text not null,
+    created_at text not null
+);
+
+--последнее обработанное событие для каждого потребителя
+create table outbox_offsets (
+    consumer text primary key,
+    seq integer not null,
+    updated_at text not null
+);